@@ -0,0 +1,235 @@
+package bmp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultRetryInterval is the time waited before reconnecting to a station after a session failed
+	DefaultRetryInterval = 30 * time.Second
+
+	dialTimeout  = 10 * time.Second
+	writeTimeout = 30 * time.Second
+
+	// maxQueueLen is the maximum number of messages queued for a station. A station not keeping
+	// up is disconnected and receives the complete state again after reconnecting.
+	maxQueueLen = 1 << 20
+)
+
+var errStopped = errors.New("Client stopped")
+
+// Client exports the state of the monitored router to a single BMP station (RFC7854 3.2).
+// Every time a session has been established onConnect is called to send the current state,
+// i.e. Peer Up notifications of all peers followed by their routes. Messages sent while
+// no session is established are dropped. onDisconnect is called once a session has ended.
+type Client struct {
+	addr          string
+	information   []InformationTLV
+	onConnect     func()
+	onDisconnect  func()
+	retryInterval time.Duration
+
+	mu        sync.Mutex
+	connected bool
+	overflow  bool
+	queue     [][]byte
+	wakeCh    chan struct{}
+
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// NewClient creates a new BMP client for the station at addr (host:port). information
+// is sent to the station in the Initiation message (e.g. sysName and sysDescr).
+func NewClient(addr string, information []InformationTLV, onConnect func(), onDisconnect func()) *Client {
+	return &Client{
+		addr:          addr,
+		information:   information,
+		onConnect:     onConnect,
+		onDisconnect:  onDisconnect,
+		retryInterval: DefaultRetryInterval,
+		wakeCh:        make(chan struct{}, 1),
+		stopCh:        make(chan struct{}),
+		doneCh:        make(chan struct{}),
+	}
+}
+
+// Start connects to the station and keeps reconnecting until Stop is called
+func (c *Client) Start() {
+	go c.run()
+}
+
+// Stop sends all messages queued followed by a Termination message and closes the session
+func (c *Client) Stop() {
+	close(c.stopCh)
+	<-c.doneCh
+}
+
+// Addr returns the address of the station
+func (c *Client) Addr() string {
+	return c.addr
+}
+
+// Connected checks if a session with the station is established
+func (c *Client) Connected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.connected
+}
+
+// Send queues the serialized message msg for the station. It is dropped if no session is established.
+func (c *Client) Send(msg []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.connected || c.overflow {
+		return
+	}
+
+	if len(c.queue) >= maxQueueLen {
+		c.overflow = true
+	} else {
+		c.queue = append(c.queue, msg)
+	}
+
+	select {
+	case c.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+func (c *Client) run() {
+	defer close(c.doneCh)
+
+	for {
+		err := c.session()
+		if err == errStopped {
+			return
+		}
+
+		log.WithFields(log.Fields{
+			"station": c.addr,
+		}).Warningf("BMP session failed: %v", err)
+
+		select {
+		case <-c.stopCh:
+			return
+		case <-time.After(c.retryInterval):
+		}
+	}
+}
+
+// session runs a single session with the station until it fails or the client is stopped
+func (c *Client) session() error {
+	con, err := net.DialTimeout("tcp", c.addr, dialTimeout)
+	if err != nil {
+		return fmt.Errorf("Unable to connect: %v", err)
+	}
+	defer con.Close()
+
+	if err := write(con, SerializeInitiationMsg(&InitiationMsg{Information: c.information})); err != nil {
+		return fmt.Errorf("Unable to send Initiation message: %v", err)
+	}
+
+	// Stations do not send any messages (RFC7854 3.3) so reading only detects the session being closed
+	closedCh := make(chan struct{})
+	go func() {
+		io.Copy(ioutil.Discard, con)
+		close(closedCh)
+	}()
+
+	c.mu.Lock()
+	c.connected = true
+	c.mu.Unlock()
+	defer c.disconnect()
+
+	log.WithFields(log.Fields{
+		"station": c.addr,
+	}).Info("BMP session established")
+
+	if c.onConnect != nil {
+		c.onConnect()
+	}
+
+	for {
+		select {
+		case <-c.stopCh:
+			if err := c.flush(con); err != nil {
+				return err
+			}
+			write(con, serializeTermination(AdministrativelyClosed))
+			return errStopped
+		case <-closedCh:
+			return fmt.Errorf("Session closed by station")
+		case <-c.wakeCh:
+			if err := c.flush(con); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// flush sends all messages queued. A queue having overflown ends the session.
+func (c *Client) flush(con net.Conn) error {
+	c.mu.Lock()
+	queue := c.queue
+	overflow := c.overflow
+	c.queue = nil
+	c.mu.Unlock()
+
+	for _, msg := range queue {
+		if err := write(con, msg); err != nil {
+			return fmt.Errorf("Unable to send message: %v", err)
+		}
+	}
+
+	if overflow {
+		write(con, serializeTermination(OutOfResources))
+		return fmt.Errorf("Station does not keep up, more than %d messages queued", maxQueueLen)
+	}
+
+	return nil
+}
+
+func (c *Client) disconnect() {
+	c.mu.Lock()
+	c.connected = false
+	c.overflow = false
+	c.queue = nil
+	c.mu.Unlock()
+
+	if c.onDisconnect != nil {
+		c.onDisconnect()
+	}
+}
+
+func write(con net.Conn, msg []byte) error {
+	con.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err := con.Write(msg)
+	return err
+}
+
+// serializeTermination creates a Termination message giving reason
+func serializeTermination(reason uint16) []byte {
+	value := make([]byte, 2)
+	binary.BigEndian.PutUint16(value, reason)
+
+	return SerializeTerminationMsg(&TerminationMsg{
+		Information: []InformationTLV{
+			{
+				Type:  TerminationReasonTLV,
+				Value: value,
+			},
+		},
+	})
+}
@@ -0,0 +1,114 @@
+package bmp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stationStub accepts BMP sessions and passes on all messages received
+type stationStub struct {
+	l     net.Listener
+	msgCh chan *message
+	conCh chan net.Conn
+}
+
+func newStationStub(t *testing.T) *stationStub {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen: %v", err)
+	}
+
+	s := &stationStub{
+		l:     l,
+		msgCh: make(chan *message, 100),
+		conCh: make(chan net.Conn, 10),
+	}
+	go s.serve()
+
+	return s
+}
+
+func (s *stationStub) serve() {
+	for {
+		con, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+		s.conCh <- con
+
+		go func() {
+			for {
+				m, err := readMessage(con)
+				if err != nil {
+					return
+				}
+				s.msgCh <- m
+			}
+		}()
+	}
+}
+
+func (s *stationStub) next(t *testing.T) *message {
+	select {
+	case m := <-s.msgCh:
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatalf("No message received")
+		return nil
+	}
+}
+
+func TestClient(t *testing.T) {
+	s := newStationStub(t)
+	defer s.l.Close()
+
+	var c *Client
+	disconnected := make(chan struct{}, 10)
+	c = NewClient(s.l.Addr().String(), []InformationTLV{NewStringTLV(SysNameTLV, "rtr1")}, func() {
+		c.Send(SerializeRouteMonitoringMsg(&RouteMonitoringMsg{Update: []byte{1, 2, 3}}))
+	}, func() {
+		disconnected <- struct{}{}
+	})
+	c.retryInterval = 10 * time.Millisecond
+	c.Start()
+
+	m := s.next(t)
+	assert.Equal(t, uint8(InitiationType), m.header.Type)
+	assert.Equal(t, []byte{0, 2, 0, 4, 'r', 't', 'r', '1'}, m.body)
+
+	m = s.next(t)
+	assert.Equal(t, uint8(RouteMonitoringType), m.header.Type)
+	assert.Equal(t, []byte{1, 2, 3}, m.body[perPeerHeaderLen:])
+
+	// The complete state is sent again after reconnecting
+	con := <-s.conCh
+	con.Close()
+	select {
+	case <-disconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Session loss not detected")
+	}
+
+	assert.Equal(t, uint8(InitiationType), s.next(t).header.Type)
+	assert.Equal(t, uint8(RouteMonitoringType), s.next(t).header.Type)
+	assert.True(t, c.Connected())
+
+	c.Send(SerializeRouteMonitoringMsg(&RouteMonitoringMsg{Update: []byte{4}}))
+	c.Stop()
+	assert.Equal(t, uint8(RouteMonitoringType), s.next(t).header.Type)
+
+	m = s.next(t)
+	assert.Equal(t, uint8(TerminationType), m.header.Type)
+	assert.Equal(t, []byte{0, 1, 0, 2, 0, AdministrativelyClosed}, m.body)
+	assert.False(t, c.Connected())
+}
+
+func TestClientDropsMessagesWhileDisconnected(t *testing.T) {
+	c := NewClient("127.0.0.1:1", nil, nil, nil)
+	c.Send([]byte{1})
+
+	assert.Equal(t, 0, len(c.queue))
+}
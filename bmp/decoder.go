@@ -0,0 +1,232 @@
+package bmp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+const bgpHeaderLen = 19
+
+// tlvHeader is the header of information TLVs and statistics
+type tlvHeader struct {
+	Type   uint16
+	Length uint16
+}
+
+// RouteMirroringMsg carries verbatim copies of BGP messages received from a peer (RFC7854 4.7)
+type RouteMirroringMsg struct {
+	Peer        PeerHeader
+	Information []InformationTLV
+}
+
+// ReadMessage reads a single message from r and returns it decoded as one of *InitiationMsg, *TerminationMsg,
+// *PeerUpMsg, *PeerDownMsg, *RouteMonitoringMsg, *RouteMirroringMsg or *StatisticsReportMsg
+func ReadMessage(r io.Reader) (interface{}, error) {
+	m, err := readMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.decode()
+}
+
+func (m *message) decode() (interface{}, error) {
+	buf := bytes.NewBuffer(m.body)
+	switch m.header.Type {
+	case InitiationType:
+		tlvs, err := decodeTLVs(buf)
+		if err != nil {
+			return nil, err
+		}
+		return &InitiationMsg{Information: tlvs}, nil
+	case TerminationType:
+		tlvs, err := decodeTLVs(buf)
+		if err != nil {
+			return nil, err
+		}
+		return &TerminationMsg{Information: tlvs}, nil
+	}
+
+	peer, err := decodePeerHeader(buf)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decode per-peer header: %v", err)
+	}
+
+	switch m.header.Type {
+	case RouteMonitoringType:
+		return &RouteMonitoringMsg{
+			Peer:   peer,
+			Update: buf.Bytes(),
+		}, nil
+	case RouteMirroringType:
+		tlvs, err := decodeTLVs(buf)
+		if err != nil {
+			return nil, err
+		}
+		return &RouteMirroringMsg{
+			Peer:        peer,
+			Information: tlvs,
+		}, nil
+	case StatisticsReportType:
+		return decodeStatisticsReport(buf, peer)
+	case PeerDownType:
+		reason, err := buf.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("Unable to decode Peer Down reason: %v", err)
+		}
+		return &PeerDownMsg{
+			Peer:   peer,
+			Reason: reason,
+			Data:   buf.Bytes(),
+		}, nil
+	case PeerUpType:
+		return decodePeerUp(buf, peer)
+	}
+
+	return nil, fmt.Errorf("Unsupported message type: %d", m.header.Type)
+}
+
+func decodePeerHeader(buf *bytes.Buffer) (PeerHeader, error) {
+	h := PeerHeader{}
+	if buf.Len() < perPeerHeaderLen {
+		return h, fmt.Errorf("Message too short: %d bytes", buf.Len())
+	}
+
+	h.PeerType, _ = buf.ReadByte()
+	h.Flags, _ = buf.ReadByte()
+	binary.Read(buf, binary.BigEndian, &h.Distinguisher)
+
+	h.Address = decodeAddress(buf.Next(net.IPv6len), h.PeerType != LocRIBInstancePeer && h.Flags&IPv6PeerFlag != 0)
+
+	var sec, usec uint32
+	binary.Read(buf, binary.BigEndian, &h.AS)
+	binary.Read(buf, binary.BigEndian, &h.BGPID)
+	binary.Read(buf, binary.BigEndian, &sec)
+	binary.Read(buf, binary.BigEndian, &usec)
+	if sec != 0 || usec != 0 {
+		h.Timestamp = time.Unix(int64(sec), int64(usec)*1000)
+	}
+
+	return h, nil
+}
+
+// decodeAddress decodes a 16 byte address field. IPv4 addresses are stored in the last 4 bytes.
+func decodeAddress(b []byte, ipv6 bool) net.IP {
+	if ipv6 {
+		return net.IP(append([]byte(nil), b...))
+	}
+
+	return net.IPv4(b[12], b[13], b[14], b[15]).To4()
+}
+
+func decodePeerUp(buf *bytes.Buffer, peer PeerHeader) (*PeerUpMsg, error) {
+	if buf.Len() < net.IPv6len+4 {
+		return nil, fmt.Errorf("Peer Up message too short")
+	}
+
+	msg := &PeerUpMsg{
+		Peer: peer,
+	}
+	msg.LocalAddress = decodeAddress(buf.Next(net.IPv6len), peer.Flags&IPv6PeerFlag != 0)
+	binary.Read(buf, binary.BigEndian, &msg.LocalPort)
+	binary.Read(buf, binary.BigEndian, &msg.RemotePort)
+
+	var err error
+	msg.SentOpen, err = nextBGPMessage(buf)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decode sent OPEN message: %v", err)
+	}
+
+	msg.ReceivedOpen, err = nextBGPMessage(buf)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decode received OPEN message: %v", err)
+	}
+
+	msg.Information, err = decodeTLVs(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// nextBGPMessage returns the BGP message at the start of buf
+func nextBGPMessage(buf *bytes.Buffer) ([]byte, error) {
+	if buf.Len() < bgpHeaderLen {
+		return nil, fmt.Errorf("Message truncated")
+	}
+
+	l := int(binary.BigEndian.Uint16(buf.Bytes()[16:18]))
+	if l < bgpHeaderLen || l > buf.Len() {
+		return nil, fmt.Errorf("Invalid message length: %d", l)
+	}
+
+	return buf.Next(l), nil
+}
+
+func decodeStatisticsReport(buf *bytes.Buffer, peer PeerHeader) (*StatisticsReportMsg, error) {
+	var count uint32
+	if err := binary.Read(buf, binary.BigEndian, &count); err != nil {
+		return nil, fmt.Errorf("Unable to decode statistics count: %v", err)
+	}
+
+	msg := &StatisticsReportMsg{
+		Peer: peer,
+	}
+	for i := uint32(0); i < count; i++ {
+		var h tlvHeader
+		if err := binary.Read(buf, binary.BigEndian, &h); err != nil {
+			return nil, fmt.Errorf("Unable to decode statistic: %v", err)
+		}
+		if int(h.Length) > buf.Len() {
+			return nil, fmt.Errorf("Invalid length of statistic %d: %d", h.Type, h.Length)
+		}
+
+		value := bytes.NewBuffer(buf.Next(int(h.Length)))
+		s := Stat{
+			Type: h.Type,
+		}
+		switch h.Length {
+		case 4:
+			var v uint32
+			binary.Read(value, binary.BigEndian, &v)
+			s.Value = uint64(v)
+		case 8:
+			binary.Read(value, binary.BigEndian, &s.Value)
+		case 11:
+			binary.Read(value, binary.BigEndian, &s.AFI)
+			s.SAFI, _ = value.ReadByte()
+			binary.Read(value, binary.BigEndian, &s.Value)
+		default:
+			// Statistics of unknown format are skipped
+			continue
+		}
+		msg.Stats = append(msg.Stats, s)
+	}
+
+	return msg, nil
+}
+
+func decodeTLVs(buf *bytes.Buffer) ([]InformationTLV, error) {
+	var res []InformationTLV
+	for buf.Len() > 0 {
+		var h tlvHeader
+		if err := binary.Read(buf, binary.BigEndian, &h); err != nil {
+			return nil, fmt.Errorf("Unable to decode information TLV: %v", err)
+		}
+		if int(h.Length) > buf.Len() {
+			return nil, fmt.Errorf("Invalid length of information TLV %d: %d", h.Type, h.Length)
+		}
+
+		res = append(res, InformationTLV{
+			Type:  h.Type,
+			Value: append([]byte(nil), buf.Next(int(h.Length))...),
+		})
+	}
+
+	return res, nil
+}
@@ -0,0 +1,168 @@
+package bmp
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadMessageDecode(t *testing.T) {
+	peer := PeerHeader{
+		PeerType:  GlobalInstancePeer,
+		Flags:     PostPolicyFlag | LegacyASPathFlag,
+		Address:   net.IP{10, 0, 0, 1},
+		AS:        65001,
+		BGPID:     167772161,
+		Timestamp: time.Unix(1000, 5000),
+	}
+	open := []byte{
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0, 29, // Length
+		1,     // Type
+		4,     // Version
+		0, 10, // AS
+		0, 90, // Hold Time
+		10, 0, 0, 1, // BGP Identifier
+		0, // Optional Parameters Length
+	}
+
+	tests := []struct {
+		name     string
+		input    []byte
+		wantFail bool
+		expected interface{}
+	}{
+		{
+			name: "Initiation",
+			input: SerializeInitiationMsg(&InitiationMsg{
+				Information: []InformationTLV{NewStringTLV(SysNameTLV, "rtr1")},
+			}),
+			expected: &InitiationMsg{
+				Information: []InformationTLV{NewStringTLV(SysNameTLV, "rtr1")},
+			},
+		},
+		{
+			name: "Peer Up",
+			input: SerializePeerUpMsg(&PeerUpMsg{
+				Peer:         peer,
+				LocalAddress: net.IP{10, 0, 0, 2},
+				LocalPort:    179,
+				RemotePort:   50000,
+				SentOpen:     open,
+				ReceivedOpen: open,
+				Information:  []InformationTLV{NewStringTLV(StringTLV, "up")},
+			}),
+			expected: &PeerUpMsg{
+				Peer:         peer,
+				LocalAddress: net.IP{10, 0, 0, 2},
+				LocalPort:    179,
+				RemotePort:   50000,
+				SentOpen:     open,
+				ReceivedOpen: open,
+				Information:  []InformationTLV{NewStringTLV(StringTLV, "up")},
+			},
+		},
+		{
+			name: "Peer Up with truncated OPEN",
+			input: SerializePeerUpMsg(&PeerUpMsg{
+				Peer:     peer,
+				SentOpen: open[:20],
+			}),
+			wantFail: true,
+		},
+		{
+			name: "Peer Down",
+			input: SerializePeerDownMsg(&PeerDownMsg{
+				Peer:   peer,
+				Reason: LocalNoNotification,
+				Data:   []byte{0, 18},
+			}),
+			expected: &PeerDownMsg{
+				Peer:   peer,
+				Reason: LocalNoNotification,
+				Data:   []byte{0, 18},
+			},
+		},
+		{
+			name: "Route Monitoring",
+			input: SerializeRouteMonitoringMsg(&RouteMonitoringMsg{
+				Peer:   peer,
+				Update: []byte{1, 2, 3},
+			}),
+			expected: &RouteMonitoringMsg{
+				Peer:   peer,
+				Update: []byte{1, 2, 3},
+			},
+		},
+		{
+			name: "Statistics Report",
+			input: SerializeStatisticsReportMsg(&StatisticsReportMsg{
+				Peer: peer,
+				Stats: []Stat{
+					{Type: RejectedPrefixesStat, Value: 5},
+					{Type: AdjRIBInRoutesStat, Value: 100},
+					{Type: AdjRIBInRoutesPerAFIStat, Value: 100, AFI: 1, SAFI: 1},
+				},
+			}),
+			expected: &StatisticsReportMsg{
+				Peer: peer,
+				Stats: []Stat{
+					{Type: RejectedPrefixesStat, Value: 5},
+					{Type: AdjRIBInRoutesStat, Value: 100},
+					{Type: AdjRIBInRoutesPerAFIStat, Value: 100, AFI: 1, SAFI: 1},
+				},
+			},
+		},
+		{
+			name:  "Termination",
+			input: serializeTermination(AdministrativelyClosed),
+			expected: &TerminationMsg{
+				Information: []InformationTLV{{Type: TerminationReasonTLV, Value: []byte{0, 0}}},
+			},
+		},
+		{
+			name: "Truncated per-peer header",
+			input: []byte{
+				3,           // Version
+				0, 0, 0, 10, // Length
+				0,          // Type
+				0, 0, 0, 0, // Peer Type, Flags, Distinguisher
+			},
+			wantFail: true,
+		},
+		{
+			name: "Truncated TLV",
+			input: []byte{
+				3,           // Version
+				0, 0, 0, 12, // Length
+				4,          // Type
+				0, 2, 0, 4, // TLV type, length
+				'r', 't',
+			},
+			wantFail: true,
+		},
+		{
+			name: "Unsupported type",
+			input: []byte{
+				3,          // Version
+				0, 0, 0, 6, // Length
+				99, // Type
+			},
+			wantFail: true,
+		},
+	}
+
+	for _, test := range tests {
+		res, err := ReadMessage(bytes.NewBuffer(test.input))
+		if test.wantFail {
+			assert.Error(t, err, test.name)
+			continue
+		}
+
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.expected, res, test.name)
+	}
+}
@@ -0,0 +1,344 @@
+package bmp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// Version is the BGP Monitoring Protocol version implemented (RFC7854)
+const Version = 3
+
+// Message types (RFC7854 4.1)
+const (
+	RouteMonitoringType  = 0
+	StatisticsReportType = 1
+	PeerDownType         = 2
+	PeerUpType           = 3
+	InitiationType       = 4
+	TerminationType      = 5
+	RouteMirroringType   = 6
+)
+
+// Peer types (RFC7854 4.2, RFC9069 4.1)
+const (
+	GlobalInstancePeer = 0
+	RDInstancePeer     = 1
+	LocalInstancePeer  = 2
+	LocRIBInstancePeer = 3
+)
+
+// Peer flags (RFC7854 4.2, RFC8671 4). FilteredFlag is only used with Loc-RIB instance peers (RFC9069 4.2).
+const (
+	IPv6PeerFlag     = 0x80
+	PostPolicyFlag   = 0x40
+	LegacyASPathFlag = 0x20
+	AdjRIBOutFlag    = 0x10
+	FilteredFlag     = 0x80
+)
+
+// Information TLV types of Initiation and Peer Up messages (RFC7854 4.4, RFC9069 5.1)
+const (
+	StringTLV       = 0
+	SysDescrTLV     = 1
+	SysNameTLV      = 2
+	VRFTableNameTLV = 3
+)
+
+// Information TLV types of Termination messages (RFC7854 4.5)
+const (
+	TerminationStringTLV = 0
+	TerminationReasonTLV = 1
+)
+
+// Termination reasons (RFC7854 4.5)
+const (
+	AdministrativelyClosed = 0
+	UnspecifiedReason      = 1
+	OutOfResources         = 2
+	RedundantConnection    = 3
+	PermanentlyAdminClosed = 4
+)
+
+// Peer Down reasons (RFC7854 4.9, RFC9069 5.4)
+const (
+	LocalNotification    = 1
+	LocalNoNotification  = 2
+	RemoteNotification   = 3
+	RemoteNoNotification = 4
+	PeerDeconfigured     = 5
+	LocalSystemClosed    = 6
+)
+
+// Statistics types (RFC7854 4.8)
+const (
+	RejectedPrefixesStat        = 0
+	DuplicatePrefixesStat       = 1
+	DuplicateWithdrawsStat      = 2
+	ClusterListLoopStat         = 3
+	ASPathLoopStat              = 4
+	OriginatorIDLoopStat        = 5
+	ASConfedLoopStat            = 6
+	AdjRIBInRoutesStat          = 7
+	LocRIBRoutesStat            = 8
+	AdjRIBInRoutesPerAFIStat    = 9
+	LocRIBRoutesPerAFIStat      = 10
+	TreatAsWithdrawUpdatesStat  = 11
+	TreatAsWithdrawPrefixesStat = 12
+	DuplicateUpdatesStat        = 13
+)
+
+const (
+	commonHeaderLen  = 6
+	perPeerHeaderLen = 42
+	maxMessageLen    = 1 << 20
+)
+
+// commonHeader is the header every message starts with
+type commonHeader struct {
+	Version uint8
+	Length  uint32
+	Type    uint8
+}
+
+// message is a message read from a BMP session. body holds everything after the common header.
+type message struct {
+	header commonHeader
+	body   []byte
+}
+
+// PeerHeader is the per-peer header of all messages concerning a single peer (RFC7854 4.2).
+// Address may be an IPv4 or IPv6 address. IPv4 addresses are sent without the IPv6PeerFlag.
+type PeerHeader struct {
+	PeerType      uint8
+	Flags         uint8
+	Distinguisher uint64
+	Address       net.IP
+	AS            uint32
+	BGPID         uint32
+	Timestamp     time.Time
+}
+
+// InformationTLV is an information element of Initiation, Peer Up and Termination messages
+type InformationTLV struct {
+	Type  uint16
+	Value []byte
+}
+
+// InitiationMsg is sent by a monitored router when a session with a station has been established
+type InitiationMsg struct {
+	Information []InformationTLV
+}
+
+// TerminationMsg is sent by a monitored router before closing a session with a station
+type TerminationMsg struct {
+	Information []InformationTLV
+}
+
+// PeerUpMsg indicates a BGP session came up. SentOpen and ReceivedOpen are complete BGP OPEN messages.
+type PeerUpMsg struct {
+	Peer         PeerHeader
+	LocalAddress net.IP
+	LocalPort    uint16
+	RemotePort   uint16
+	SentOpen     []byte
+	ReceivedOpen []byte
+	Information  []InformationTLV
+}
+
+// PeerDownMsg indicates a BGP session went down. Data is the NOTIFICATION message sent or
+// received, the FSM event code (LocalNoNotification) or information TLVs (LocalSystemClosed).
+type PeerDownMsg struct {
+	Peer   PeerHeader
+	Reason uint8
+	Data   []byte
+}
+
+// RouteMonitoringMsg carries a complete BGP UPDATE message describing the routes of a peer
+type RouteMonitoringMsg struct {
+	Peer   PeerHeader
+	Update []byte
+}
+
+// StatisticsReportMsg carries statistics of a peer
+type StatisticsReportMsg struct {
+	Peer  PeerHeader
+	Stats []Stat
+}
+
+// Stat is a single statistic. Counters are 32 bit, gauges 64 bit wide.
+// AFI and SAFI are only used by statistics kept per address family.
+type Stat struct {
+	Type  uint16
+	Value uint64
+	AFI   uint16
+	SAFI  uint8
+}
+
+// perAFI checks if statistics of type t are kept per address family
+func perAFI(t uint16) bool {
+	return t == AdjRIBInRoutesPerAFIStat || t == LocRIBRoutesPerAFIStat
+}
+
+// gauge checks if statistics of type t are 64 bit gauges
+func gauge(t uint16) bool {
+	return t == AdjRIBInRoutesStat || t == LocRIBRoutesStat || perAFI(t)
+}
+
+// readMessage reads a single message from r
+func readMessage(r io.Reader) (*message, error) {
+	hdr := make([]byte, commonHeaderLen)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, err
+	}
+
+	m := &message{}
+	if err := binary.Read(bytes.NewReader(hdr), binary.BigEndian, &m.header); err != nil {
+		return nil, err
+	}
+
+	if m.header.Version != Version {
+		return nil, fmt.Errorf("Unsupported BMP version: %d", m.header.Version)
+	}
+
+	if m.header.Length < commonHeaderLen || m.header.Length > maxMessageLen {
+		return nil, fmt.Errorf("Invalid message length: %d", m.header.Length)
+	}
+
+	m.body = make([]byte, m.header.Length-commonHeaderLen)
+	if _, err := io.ReadFull(r, m.body); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// SerializeInitiationMsg serializes an Initiation message
+func SerializeInitiationMsg(msg *InitiationMsg) []byte {
+	body := bytes.NewBuffer(nil)
+	serializeTLVs(body, msg.Information)
+	return serializeMessage(InitiationType, body.Bytes())
+}
+
+// SerializeTerminationMsg serializes a Termination message
+func SerializeTerminationMsg(msg *TerminationMsg) []byte {
+	body := bytes.NewBuffer(nil)
+	serializeTLVs(body, msg.Information)
+	return serializeMessage(TerminationType, body.Bytes())
+}
+
+// SerializePeerUpMsg serializes a Peer Up Notification
+func SerializePeerUpMsg(msg *PeerUpMsg) []byte {
+	body := bytes.NewBuffer(nil)
+	serializePeerHeader(body, &msg.Peer)
+	body.Write(addressBytes(msg.LocalAddress))
+	binary.Write(body, binary.BigEndian, msg.LocalPort)
+	binary.Write(body, binary.BigEndian, msg.RemotePort)
+	body.Write(msg.SentOpen)
+	body.Write(msg.ReceivedOpen)
+	serializeTLVs(body, msg.Information)
+	return serializeMessage(PeerUpType, body.Bytes())
+}
+
+// SerializePeerDownMsg serializes a Peer Down Notification
+func SerializePeerDownMsg(msg *PeerDownMsg) []byte {
+	body := bytes.NewBuffer(nil)
+	serializePeerHeader(body, &msg.Peer)
+	body.WriteByte(msg.Reason)
+	body.Write(msg.Data)
+	return serializeMessage(PeerDownType, body.Bytes())
+}
+
+// SerializeRouteMonitoringMsg serializes a Route Monitoring message
+func SerializeRouteMonitoringMsg(msg *RouteMonitoringMsg) []byte {
+	body := bytes.NewBuffer(nil)
+	serializePeerHeader(body, &msg.Peer)
+	body.Write(msg.Update)
+	return serializeMessage(RouteMonitoringType, body.Bytes())
+}
+
+// SerializeStatisticsReportMsg serializes a Statistics Report
+func SerializeStatisticsReportMsg(msg *StatisticsReportMsg) []byte {
+	body := bytes.NewBuffer(nil)
+	serializePeerHeader(body, &msg.Peer)
+	binary.Write(body, binary.BigEndian, uint32(len(msg.Stats)))
+	for _, s := range msg.Stats {
+		switch {
+		case perAFI(s.Type):
+			binary.Write(body, binary.BigEndian, []uint16{s.Type, 11, s.AFI})
+			body.WriteByte(s.SAFI)
+			binary.Write(body, binary.BigEndian, s.Value)
+		case gauge(s.Type):
+			binary.Write(body, binary.BigEndian, []uint16{s.Type, 8})
+			binary.Write(body, binary.BigEndian, s.Value)
+		default:
+			binary.Write(body, binary.BigEndian, []uint16{s.Type, 4})
+			binary.Write(body, binary.BigEndian, uint32(s.Value))
+		}
+	}
+	return serializeMessage(StatisticsReportType, body.Bytes())
+}
+
+// NewStringTLV creates an information TLV of type t holding s
+func NewStringTLV(t uint16, s string) InformationTLV {
+	return InformationTLV{
+		Type:  t,
+		Value: []byte(s),
+	}
+}
+
+func serializeMessage(t uint8, body []byte) []byte {
+	buf := bytes.NewBuffer(make([]byte, 0, commonHeaderLen+len(body)))
+	binary.Write(buf, binary.BigEndian, commonHeader{
+		Version: Version,
+		Length:  uint32(commonHeaderLen + len(body)),
+		Type:    t,
+	})
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+func serializePeerHeader(buf *bytes.Buffer, h *PeerHeader) {
+	flags := h.Flags
+	if h.PeerType != LocRIBInstancePeer && h.Address != nil && h.Address.To4() == nil {
+		flags |= IPv6PeerFlag
+	}
+
+	var sec, usec uint32
+	if !h.Timestamp.IsZero() {
+		sec = uint32(h.Timestamp.Unix())
+		usec = uint32(h.Timestamp.Nanosecond() / 1000)
+	}
+
+	buf.WriteByte(h.PeerType)
+	buf.WriteByte(flags)
+	binary.Write(buf, binary.BigEndian, h.Distinguisher)
+	buf.Write(addressBytes(h.Address))
+	binary.Write(buf, binary.BigEndian, []uint32{h.AS, h.BGPID, sec, usec})
+}
+
+// addressBytes returns addr as 16 byte field. IPv4 addresses are stored in the last 4 bytes.
+func addressBytes(addr net.IP) []byte {
+	res := make([]byte, net.IPv6len)
+	if addr == nil {
+		return res
+	}
+
+	if v4 := addr.To4(); v4 != nil {
+		copy(res[12:], v4)
+		return res
+	}
+
+	copy(res, addr.To16())
+	return res
+}
+
+func serializeTLVs(buf *bytes.Buffer, tlvs []InformationTLV) {
+	for _, tlv := range tlvs {
+		binary.Write(buf, binary.BigEndian, []uint16{tlv.Type, uint16(len(tlv.Value))})
+		buf.Write(tlv.Value)
+	}
+}
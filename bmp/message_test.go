@@ -0,0 +1,200 @@
+package bmp
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSerializeInitiationMsg(t *testing.T) {
+	res := SerializeInitiationMsg(&InitiationMsg{
+		Information: []InformationTLV{
+			NewStringTLV(SysNameTLV, "rtr1"),
+		},
+	})
+
+	assert.Equal(t, []byte{
+		3,           // Version
+		0, 0, 0, 14, // Length
+		4,          // Type
+		0, 2, 0, 4, // TLV type, length
+		'r', 't', 'r', '1',
+	}, res)
+}
+
+func TestSerializePeerHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    PeerHeader
+		expected []byte
+	}{
+		{
+			name: "IPv4 post-policy",
+			input: PeerHeader{
+				PeerType:  GlobalInstancePeer,
+				Flags:     PostPolicyFlag | LegacyASPathFlag,
+				Address:   net.IP{10, 0, 0, 1},
+				AS:        65001,
+				BGPID:     167772161,
+				Timestamp: time.Unix(1000, 5000),
+			},
+			expected: []byte{
+				0,                      // Peer Type
+				0x60,                   // Flags
+				0, 0, 0, 0, 0, 0, 0, 0, // Distinguisher
+				0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 10, 0, 0, 1, // Address
+				0, 0, 0xfd, 0xe9, // AS
+				10, 0, 0, 1, // BGP ID
+				0, 0, 0x03, 0xe8, // Seconds
+				0, 0, 0, 5, // Microseconds
+			},
+		},
+		{
+			name: "IPv6",
+			input: PeerHeader{
+				PeerType: GlobalInstancePeer,
+				Address:  net.ParseIP("2001:db8::1"),
+				AS:       65001,
+			},
+			expected: []byte{
+				0,                      // Peer Type
+				0x80,                   // Flags
+				0, 0, 0, 0, 0, 0, 0, 0, // Distinguisher
+				0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, // Address
+				0, 0, 0xfd, 0xe9, // AS
+				0, 0, 0, 0, // BGP ID
+				0, 0, 0, 0, // Seconds
+				0, 0, 0, 0, // Microseconds
+			},
+		},
+		{
+			name: "Loc-RIB",
+			input: PeerHeader{
+				PeerType: LocRIBInstancePeer,
+				AS:       65000,
+				BGPID:    167772160,
+			},
+			expected: []byte{
+				3,                      // Peer Type
+				0,                      // Flags
+				0, 0, 0, 0, 0, 0, 0, 0, // Distinguisher
+				0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // Address
+				0, 0, 0xfd, 0xe8, // AS
+				10, 0, 0, 0, // BGP ID
+				0, 0, 0, 0, // Seconds
+				0, 0, 0, 0, // Microseconds
+			},
+		},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		serializePeerHeader(buf, &test.input)
+		assert.Equal(t, test.expected, buf.Bytes(), test.name)
+	}
+}
+
+func TestSerializePeerUpMsg(t *testing.T) {
+	res := SerializePeerUpMsg(&PeerUpMsg{
+		Peer: PeerHeader{
+			Address: net.IP{10, 0, 0, 1},
+		},
+		LocalAddress: net.IP{10, 0, 0, 2},
+		LocalPort:    179,
+		RemotePort:   50000,
+		SentOpen:     []byte{1, 2},
+		ReceivedOpen: []byte{3, 4},
+	})
+
+	assert.Equal(t, uint8(PeerUpType), res[5])
+	assert.Equal(t, []byte{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 10, 0, 0, 2, // Local Address
+		0, 179, // Local Port
+		0xc3, 0x50, // Remote Port
+		1, 2, // Sent OPEN
+		3, 4, // Received OPEN
+	}, res[commonHeaderLen+perPeerHeaderLen:])
+}
+
+func TestSerializeStatisticsReportMsg(t *testing.T) {
+	res := SerializeStatisticsReportMsg(&StatisticsReportMsg{
+		Stats: []Stat{
+			{Type: RejectedPrefixesStat, Value: 5},
+			{Type: AdjRIBInRoutesStat, Value: 100},
+			{Type: AdjRIBInRoutesPerAFIStat, Value: 100, AFI: 1, SAFI: 1},
+		},
+	})
+
+	assert.Equal(t, uint8(StatisticsReportType), res[5])
+	assert.Equal(t, []byte{
+		0, 0, 0, 3, // Stats Count
+		0, 0, 0, 4, // Type, Length
+		0, 0, 0, 5, // Counter
+		0, 7, 0, 8, // Type, Length
+		0, 0, 0, 0, 0, 0, 0, 100, // Gauge
+		0, 9, 0, 11, // Type, Length
+		0, 1, 1, // AFI, SAFI
+		0, 0, 0, 0, 0, 0, 0, 100, // Gauge
+	}, res[commonHeaderLen+perPeerHeaderLen:])
+}
+
+func TestReadMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []byte
+		wantFail bool
+		expected *message
+	}{
+		{
+			name: "Termination",
+			input: []byte{
+				3,           // Version
+				0, 0, 0, 12, // Length
+				5,          // Type
+				0, 1, 0, 2, // TLV type, length
+				0, 0,
+			},
+			expected: &message{
+				header: commonHeader{
+					Version: 3,
+					Length:  12,
+					Type:    TerminationType,
+				},
+				body: []byte{0, 1, 0, 2, 0, 0},
+			},
+		},
+		{
+			name: "Unsupported version",
+			input: []byte{
+				1,          // Version
+				0, 0, 0, 6, // Length
+				4, // Type
+			},
+			wantFail: true,
+		},
+		{
+			name: "Truncated",
+			input: []byte{
+				3,           // Version
+				0, 0, 0, 20, // Length
+				4, // Type
+				0, 0,
+			},
+			wantFail: true,
+		},
+	}
+
+	for _, test := range tests {
+		res, err := readMessage(bytes.NewBuffer(test.input))
+		if test.wantFail {
+			assert.Error(t, err, test.name)
+			continue
+		}
+
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.expected, res, test.name)
+	}
+}
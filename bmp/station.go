@@ -0,0 +1,128 @@
+package bmp
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Handler processes the messages a station receives from monitored routers
+type Handler interface {
+	// HandleMessage processes the decoded message msg received from the router with address router
+	HandleMessage(router net.IP, msg interface{})
+
+	// SessionClosed is called once the session with the router with address router has ended
+	SessionClosed(router net.IP)
+}
+
+// Station accepts sessions of monitored routers (RFC7854 3.3) and passes on all messages received to a handler
+type Station struct {
+	l       net.Listener
+	handler Handler
+
+	mu       sync.Mutex
+	sessions map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+// NewStation creates a station accepting sessions on addr (host:port)
+func NewStation(addr string, handler Handler) (*Station, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to listen on %s: %v", addr, err)
+	}
+
+	s := &Station{
+		l:        l,
+		handler:  handler,
+		sessions: make(map[net.Conn]struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s, nil
+}
+
+// Addr returns the address the station accepts sessions on
+func (s *Station) Addr() net.Addr {
+	return s.l.Addr()
+}
+
+// Close stops accepting sessions and closes all sessions established
+func (s *Station) Close() {
+	s.l.Close()
+
+	s.mu.Lock()
+	for con := range s.sessions {
+		con.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *Station) serve() {
+	defer s.wg.Done()
+
+	for {
+		con, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.sessions[con] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.session(con)
+	}
+}
+
+// session processes the messages of a monitored router until the session ends
+func (s *Station) session(con net.Conn) {
+	defer s.wg.Done()
+
+	router := con.RemoteAddr().(*net.TCPAddr).IP
+	err := s.receive(con, router)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"router": router.String(),
+		}).Warningf("BMP session failed: %v", err)
+	}
+
+	s.mu.Lock()
+	delete(s.sessions, con)
+	s.mu.Unlock()
+	con.Close()
+
+	s.handler.SessionClosed(router)
+}
+
+func (s *Station) receive(con net.Conn, router net.IP) error {
+	initiated := false
+	for {
+		msg, err := ReadMessage(con)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Unable to read message: %v", err)
+		}
+
+		// Every session starts with an Initiation message (RFC7854 4.3)
+		if _, ok := msg.(*InitiationMsg); !ok && !initiated {
+			return fmt.Errorf("Session not started with an Initiation message")
+		}
+		initiated = true
+
+		s.handler.HandleMessage(router, msg)
+		if _, ok := msg.(*TerminationMsg); ok {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package bmp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingHandler passes on all messages and session ends
+type recordingHandler struct {
+	msgCh    chan interface{}
+	closedCh chan net.IP
+}
+
+func (h *recordingHandler) HandleMessage(router net.IP, msg interface{}) {
+	h.msgCh <- msg
+}
+
+func (h *recordingHandler) SessionClosed(router net.IP) {
+	h.closedCh <- router
+}
+
+func (h *recordingHandler) next(t *testing.T) interface{} {
+	select {
+	case m := <-h.msgCh:
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatalf("No message received")
+		return nil
+	}
+}
+
+func TestStation(t *testing.T) {
+	h := &recordingHandler{
+		msgCh:    make(chan interface{}, 100),
+		closedCh: make(chan net.IP, 10),
+	}
+	s, err := NewStation("127.0.0.1:0", h)
+	if err != nil {
+		t.Fatalf("Unable to start station: %v", err)
+	}
+	defer s.Close()
+
+	var c *Client
+	c = NewClient(s.Addr().String(), []InformationTLV{NewStringTLV(SysNameTLV, "rtr1")}, func() {
+		c.Send(SerializePeerDownMsg(&PeerDownMsg{
+			Peer: PeerHeader{
+				Address: net.IP{10, 0, 0, 1},
+				AS:      65001,
+			},
+			Reason: RemoteNoNotification,
+		}))
+	}, nil)
+	c.Start()
+
+	assert.Equal(t, &InitiationMsg{
+		Information: []InformationTLV{NewStringTLV(SysNameTLV, "rtr1")},
+	}, h.next(t))
+	assert.Equal(t, &PeerDownMsg{
+		Peer: PeerHeader{
+			Address: net.IP{10, 0, 0, 1},
+			AS:      65001,
+		},
+		Reason: RemoteNoNotification,
+		Data:   []byte{},
+	}, h.next(t))
+
+	c.Stop()
+	assert.IsType(t, &TerminationMsg{}, h.next(t))
+	select {
+	case router := <-h.closedCh:
+		assert.Equal(t, "127.0.0.1", router.String())
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Session end not reported")
+	}
+}
+
+func TestStationRequiresInitiation(t *testing.T) {
+	h := &recordingHandler{
+		msgCh:    make(chan interface{}, 100),
+		closedCh: make(chan net.IP, 10),
+	}
+	s, err := NewStation("127.0.0.1:0", h)
+	if err != nil {
+		t.Fatalf("Unable to start station: %v", err)
+	}
+	defer s.Close()
+
+	con, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatalf("Unable to connect: %v", err)
+	}
+	defer con.Close()
+
+	con.Write(SerializeRouteMonitoringMsg(&RouteMonitoringMsg{Update: []byte{1}}))
+	select {
+	case <-h.closedCh:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Session not closed")
+	}
+	assert.Equal(t, 0, len(h.msgCh))
+}
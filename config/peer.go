@@ -2,6 +2,8 @@ package config
 
 import (
 	"net"
+
+	"github.com/taktv6/tbgp/filter"
 )
 
 type Peer struct {
@@ -14,4 +16,153 @@ type Peer struct {
 	PeerAS       uint32
 	Passive      bool
 	RouterID     uint32
+
+	// ImportFilter is applied to all routes received from the peer. nil accepts all routes.
+	ImportFilter *filter.Filter
+
+	// SoftReconfigInbound retains the routes received from the peer before the import
+	// filter is applied. This allows re-applying the import filter without a route refresh.
+	SoftReconfigInbound bool
+
+	// GracefulRestart enables the graceful restart capability (RFC4724) for the session
+	GracefulRestart bool
+
+	// LongLivedGracefulRestart enables the long-lived graceful restart capability (RFC9494) for the session
+	LongLivedGracefulRestart bool
+
+	// LongLivedStaleTime is the time in seconds the peer is asked to retain our routes as long-lived stale
+	LongLivedStaleTime uint32
+
+	// AddPathReceive enables receiving multiple paths per prefix from the peer (RFC7911)
+	AddPathReceive bool
+
+	// AddPathSend selects the paths per prefix advertised to the peer if ADD-PATH has been negotiated
+	AddPathSend AddPathSendMode
+
+	// AddPathSendMax limits the number of paths per prefix advertised in AddPathSendBestN mode
+	AddPathSendMax uint
+
+	// RouteReflectorClient makes the (iBGP) peer a route reflector client (RFC4456)
+	RouteReflectorClient bool
+
+	// RouteReflectorClusterID is the cluster ID used for paths reflected to and received from the peer.
+	// It defaults to the router ID.
+	RouteReflectorClusterID uint32
+
+	// AllowASIn is the number of times our AS may be in the AS_PATH of paths received from the peer
+	AllowASIn uint8
+
+	// SkipFirstASCheck accepts paths from the eBGP peer whose leftmost AS is not the peers AS (RFC4271 6.3).
+	// The check is always skipped for route servers (Role RoleRSClient) as they do not add their AS.
+	SkipFirstASCheck bool
+
+	// Role is our role in the relationship with the (eBGP) peer (RFC9234). It is announced in the
+	// BGP Role capability, controls the Only to Customer attribute of paths exchanged with the
+	// peer and selects the ASPA verification procedure applied to paths received from the peer.
+	Role Role
+
+	// StrictRole refuses sessions with peers not announcing the BGP Role capability
+	StrictRole bool
+
+	// MaxPrefixes limits the number of prefixes received from the peer per address family
+	MaxPrefixes []MaxPrefix
+
+	// Dampening enables route flap dampening (RFC2439) of the routes received from the peer if set
+	Dampening *Dampening
+
+	// Replay makes the peer a synthetic peer whose routes are read from an MRT file instead of a BGP session
+	Replay *Replay
+}
+
+// Replay configures a synthetic peer. The routes a peer recorded in an MRT file (RFC6396) are fed
+// into the import pipeline as if received from the synthetic peer.
+type Replay struct {
+	// File is the MRT file holding a TABLE_DUMP_V2 snapshot or BGP4MP messages. It may be gzip compressed.
+	File string
+
+	// Source is the address of the recorded peer whose routes are replayed. It defaults to the peer address.
+	Source net.IP
+
+	// RecordedTiming replays BGP4MP messages at the pace they were recorded instead of at full speed
+	RecordedTiming bool
 }
+
+// MaxPrefix limits the number of prefixes accepted from a peer for an address family
+type MaxPrefix struct {
+	AFI  uint16
+	SAFI uint8
+
+	// Limit is the maximum number of distinct prefixes received, before applying the import filter
+	Limit uint
+
+	// WarningThreshold is the percentage of Limit at which a warning is logged. 0 disables the warning.
+	WarningThreshold uint8
+
+	// WarningOnly only logs a warning if Limit is exceeded instead of tearing down the session
+	WarningOnly bool
+
+	// RestartInterval is the time in seconds after which a session torn down for exceeding Limit
+	// is restarted. Such sessions stay down until started manually if 0.
+	RestartInterval uint32
+}
+
+// Dampening configures route flap dampening. Zero values select the defaults given.
+type Dampening struct {
+	// HalfLife is the time in seconds after which a penalty has decayed to half of its value (default 900)
+	HalfLife uint32
+
+	// ReuseThreshold is the penalty below which a suppressed route is used again (default 750)
+	ReuseThreshold uint32
+
+	// SuppressThreshold is the penalty above which a route is suppressed (default 2000)
+	SuppressThreshold uint32
+
+	// MaxSuppressTime is the maximum time in seconds a route is suppressed (default 3600)
+	MaxSuppressTime uint32
+
+	// WithdrawPenalty is the penalty added whenever a route is withdrawn (default 1000)
+	WithdrawPenalty uint32
+
+	// AttributeChangePenalty is the penalty added whenever the attributes of a route change (default 500)
+	AttributeChangePenalty uint32
+}
+
+// Role is the role of a BGP speaker in the relationship with a peer (RFC9234 3.1)
+type Role uint8
+
+const (
+	// RoleNone means the relationship is not configured
+	RoleNone Role = iota
+
+	// RoleProvider means we are the provider of the peer
+	RoleProvider
+
+	// RoleRS means we are a route server and the peer is a route server client
+	RoleRS
+
+	// RoleRSClient means we are a route server client and the peer is a route server
+	RoleRSClient
+
+	// RoleCustomer means we are a customer of the peer
+	RoleCustomer
+
+	// RolePeer means we are a lateral peer of the peer
+	RolePeer
+)
+
+// AddPathSendMode defines which paths of a prefix are advertised to an ADD-PATH capable peer
+type AddPathSendMode uint8
+
+const (
+	// AddPathSendDisabled advertises the best path only
+	AddPathSendDisabled AddPathSendMode = iota
+
+	// AddPathSendAll advertises all paths
+	AddPathSendAll
+
+	// AddPathSendBestN advertises the best AddPathSendMax paths
+	AddPathSendBestN
+
+	// AddPathSendECMP advertises the best path and all paths equally preferred
+	AddPathSendECMP
+)
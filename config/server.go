@@ -5,6 +5,7 @@ import (
 	"net"
 	"strings"
 
+	tnet "github.com/taktv6/tbgp/net"
 	"github.com/taktv6/tflow2/convert"
 )
 
@@ -14,9 +15,117 @@ type Global struct {
 	Port             uint16
 	LocalAddressList []net.IP
 	Listen           bool
+
+	// GracefulRestartTime is the time in seconds peers are asked to retain our routes after a restart (RFC4724)
+	GracefulRestartTime uint16
+
+	// Restarting is to be set if the speaker starts up after a graceful restart. Route advertisement
+	// is deferred until all graceful restart capable peers have sent End-of-RIB or SelectionDeferralTime expired.
+	Restarting bool
+
+	// ForwardingStatePreserved is to be set if the forwarding state has been preserved across the restart
+	ForwardingStatePreserved bool
+
+	// SelectionDeferralTime is the maximum time in seconds route advertisement is deferred after a restart
+	SelectionDeferralTime uint16
+
+	// ReadinessTimeout is the time in seconds after the start after which the server is considered ready even if
+	// some peers have not finished sending their routes, e.g. because their sessions never got established
+	ReadinessTimeout uint16
+
+	// ConfederationID is the AS number the confederation is known as to peers outside of it (RFC5065).
+	// LocalAS is the member AS then. 0 disables confederations.
+	ConfederationID uint32
+
+	// ConfederationMembers are the member ASes of the confederation other than our own
+	ConfederationMembers []uint32
+
+	// RPKICache is the address (host:port) of an RPKI-to-Router cache (RFC8210). If set all routes received
+	// are validated against the VRPs of the cache and routes received are retained pre-policy so the
+	// import filters can be re-applied whenever the validation state of a route changes.
+	RPKICache string
+
+	// ASPAFile is the path of a JSON file holding ASPA records in the format exported by rpki-client.
+	// If set the records are used for AS_PATH verification instead of those received from the RPKI cache.
+	ASPAFile string
+
+	// BMPStations are the BGP Monitoring Protocol stations the sessions and routes of all peers are reported to (RFC7854)
+	BMPStations []BMPStation
+
+	// BMPListen is the address (host:port) BMP sessions of monitored routers are accepted on. Empty disables station mode.
+	BMPListen string
+
+	// MRT configures writing routing information to MRT files (RFC6396). nil disables it.
+	MRT *MRT
+
+	// Aggregates are the aggregate routes originated while at least one more specific route is in the Loc-RIB
+	Aggregates []Aggregate
+}
+
+// Aggregate configures an aggregate route (RFC4271 9.2.2.2)
+type Aggregate struct {
+	// Prefix is the prefix of the aggregate
+	Prefix *tnet.Prefix
+
+	// SummaryOnly suppresses advertising the contributing more specific routes to peers
+	SummaryOnly bool
+
+	// ASSet generates an AS_SET of the ASNs of all contributing routes. Otherwise the AS path is empty and
+	// ATOMIC_AGGREGATE is set if any contributing route had a non empty AS path.
+	// The communities of the contributing routes are merged in either case.
+	ASSet bool
+}
+
+// BMPStation configures a BGP Monitoring Protocol station
+type BMPStation struct {
+	// Address is the address (host:port) of the station
+	Address string
+
+	// PrePolicy enables monitoring the routes as received from peers. Routes received are retained pre-policy then.
+	PrePolicy bool
+
+	// PostPolicy enables monitoring the routes of peers accepted by the import filters
+	PostPolicy bool
+
+	// LocRIB enables monitoring the best routes of the Loc-RIB (RFC9069)
+	LocRIB bool
+
+	// StatisticsInterval is the interval in seconds Statistics Reports are sent at. 0 disables them.
+	StatisticsInterval uint32
+}
+
+// MRT configures MRT files. File names are formatted with the time the file is opened (see time.Format).
+type MRT struct {
+	// LocRIBDumpFile is the file TABLE_DUMP_V2 snapshots of the Loc-RIB are written to. Empty disables them.
+	LocRIBDumpFile string
+
+	// AdjRIBInDumpFile is the file TABLE_DUMP_V2 snapshots of the routes received from all peers are written to.
+	// Routes are dumped pre-policy if retained. Empty disables them.
+	AdjRIBInDumpFile string
+
+	// DumpInterval is the interval in seconds snapshots are taken at
+	DumpInterval uint32
+
+	// UpdatesFile is the file BGP4MP records of all messages received and sent are written to. Empty disables them.
+	UpdatesFile string
+
+	// RotationInterval is the interval in seconds a new updates file is opened at. 0 disables rotation by time.
+	RotationInterval uint32
+
+	// MaxFileSize is the number of bytes after which a new updates file is opened. 0 disables rotation by size.
+	MaxFileSize int64
+
+	// Gzip enables writing files gzip compressed
+	Gzip bool
 }
 
-const BGPPORT = uint16(179)
+const (
+	BGPPORT                      = uint16(179)
+	DefaultGracefulRestartTime   = uint16(120)
+	DefaultSelectionDeferralTime = uint16(360)
+	DefaultReadinessTimeout      = uint16(600)
+	DefaultMRTDumpInterval       = uint32(7200)
+)
 
 func (g *Global) SetDefaultGlobalConfigValues() error {
 	if g.LocalAddressList == nil {
@@ -37,6 +146,22 @@ func (g *Global) SetDefaultGlobalConfigValues() error {
 		g.Port = BGPPORT
 	}
 
+	if g.GracefulRestartTime == 0 {
+		g.GracefulRestartTime = DefaultGracefulRestartTime
+	}
+
+	if g.SelectionDeferralTime == 0 {
+		g.SelectionDeferralTime = DefaultSelectionDeferralTime
+	}
+
+	if g.ReadinessTimeout == 0 {
+		g.ReadinessTimeout = DefaultReadinessTimeout
+	}
+
+	if g.MRT != nil && g.MRT.DumpInterval == 0 {
+		g.MRT.DumpInterval = DefaultMRTDumpInterval
+	}
+
 	return nil
 }
 
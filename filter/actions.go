@@ -0,0 +1,71 @@
+package filter
+
+import (
+	"github.com/taktv6/tbgp/net"
+	"github.com/taktv6/tbgp/route"
+)
+
+// Action is something a term does with a route
+type Action interface {
+	Do(pfx *net.Prefix, p *route.Path) ActionResult
+}
+
+// ActionResult is the outcome of an action
+type ActionResult struct {
+	Path      *route.Path
+	Terminate bool
+	Reject    bool
+}
+
+// AcceptAction accepts a route and stops processing it
+type AcceptAction struct{}
+
+// Do implements Action
+func (a *AcceptAction) Do(pfx *net.Prefix, p *route.Path) ActionResult {
+	return ActionResult{
+		Path:      p,
+		Terminate: true,
+	}
+}
+
+// RejectAction rejects a route and stops processing it
+type RejectAction struct{}
+
+// Do implements Action
+func (a *RejectAction) Do(pfx *net.Prefix, p *route.Path) ActionResult {
+	return ActionResult{
+		Path:      p,
+		Terminate: true,
+		Reject:    true,
+	}
+}
+
+// SetLocalPrefAction sets the LOCAL_PREF of a route
+type SetLocalPrefAction struct {
+	LocalPref uint32
+}
+
+// Do implements Action
+func (a *SetLocalPrefAction) Do(pfx *net.Prefix, p *route.Path) ActionResult {
+	c := p.Copy()
+	c.LocalPref = a.LocalPref
+
+	return ActionResult{
+		Path: c,
+	}
+}
+
+// SetMEDAction sets the MED of a route
+type SetMEDAction struct {
+	MED uint32
+}
+
+// Do implements Action
+func (a *SetMEDAction) Do(pfx *net.Prefix, p *route.Path) ActionResult {
+	c := p.Copy()
+	c.MED = a.MED
+
+	return ActionResult{
+		Path: c,
+	}
+}
@@ -0,0 +1,48 @@
+package filter
+
+import (
+	"github.com/taktv6/tbgp/net"
+	"github.com/taktv6/tbgp/route"
+)
+
+// Filter is an ordered list of terms routes are processed by.
+// A route not terminated by any term is accepted.
+type Filter struct {
+	terms []*Term
+}
+
+// NewFilter creates a new filter consisting of terms
+func NewFilter(terms ...*Term) *Filter {
+	return &Filter{
+		terms: terms,
+	}
+}
+
+// NewAcceptAllFilter creates a filter accepting all routes
+func NewAcceptAllFilter() *Filter {
+	return NewFilter(NewTerm(nil, []Action{&AcceptAction{}}))
+}
+
+// NewDrainFilter creates a filter rejecting all routes
+func NewDrainFilter() *Filter {
+	return NewFilter(NewTerm(nil, []Action{&RejectAction{}}))
+}
+
+// ProcessTerms runs pfx and p through all terms of the filter. It returns the
+// resulting path and whether the route has been rejected. A nil filter accepts all routes.
+// Paths are never modified in place. Actions changing attributes work on a copy.
+func (f *Filter) ProcessTerms(pfx *net.Prefix, p *route.Path) (*route.Path, bool) {
+	if f == nil {
+		return p, false
+	}
+
+	for _, t := range f.terms {
+		res, terminate := t.Process(pfx, p)
+		if terminate {
+			return res.Path, res.Reject
+		}
+		p = res.Path
+	}
+
+	return p, false
+}
@@ -0,0 +1,164 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taktv6/tbgp/net"
+	"github.com/taktv6/tbgp/route"
+	"github.com/taktv6/tbgp/rpki"
+)
+
+func TestProcessTerms(t *testing.T) {
+	tests := []struct {
+		name           string
+		filter         *Filter
+		prefix         *net.Prefix
+		path           *route.Path
+		expectedPath   *route.Path
+		expectedReject bool
+	}{
+		{
+			name:         "Nil filter",
+			filter:       nil,
+			prefix:       net.NewPfx(167772160, 8), // 10.0.0.0/8
+			path:         &route.Path{LocalPref: 100},
+			expectedPath: &route.Path{LocalPref: 100},
+		},
+		{
+			name:         "Accept all",
+			filter:       NewAcceptAllFilter(),
+			prefix:       net.NewPfx(167772160, 8), // 10.0.0.0/8
+			path:         &route.Path{LocalPref: 100},
+			expectedPath: &route.Path{LocalPref: 100},
+		},
+		{
+			name:           "Drain",
+			filter:         NewDrainFilter(),
+			prefix:         net.NewPfx(167772160, 8), // 10.0.0.0/8
+			path:           &route.Path{LocalPref: 100},
+			expectedPath:   &route.Path{LocalPref: 100},
+			expectedReject: true,
+		},
+		{
+			name: "Modify matching route",
+			filter: NewFilter(
+				NewTerm(
+					[]*TermCondition{
+						NewTermConditionWithPrefixLists(NewPrefixList(net.NewPfx(167772160, 8))), // 10.0.0.0/8
+					},
+					[]Action{
+						&SetLocalPrefAction{LocalPref: 200},
+						&SetMEDAction{MED: 10},
+						&AcceptAction{},
+					},
+				),
+				NewTerm(nil, []Action{&RejectAction{}}),
+			),
+			prefix:       net.NewPfx(167772160, 8), // 10.0.0.0/8
+			path:         &route.Path{LocalPref: 100},
+			expectedPath: &route.Path{LocalPref: 200, MED: 10},
+		},
+		{
+			name: "Non matching route falls through",
+			filter: NewFilter(
+				NewTerm(
+					[]*TermCondition{
+						NewTermConditionWithPrefixLists(NewPrefixList(net.NewPfx(167772160, 8))), // 10.0.0.0/8
+					},
+					[]Action{
+						&AcceptAction{},
+					},
+				),
+				NewTerm(nil, []Action{&RejectAction{}}),
+			),
+			prefix:         net.NewPfx(3232235520, 16), // 192.168.0.0/16
+			path:           &route.Path{LocalPref: 100},
+			expectedPath:   &route.Path{LocalPref: 100},
+			expectedReject: true,
+		},
+		{
+			name: "Reject invalid route",
+			filter: NewFilter(
+				NewTerm(
+					[]*TermCondition{
+						NewTermConditionWithValidationStates(rpki.Invalid),
+					},
+					[]Action{
+						&RejectAction{},
+					},
+				),
+			),
+			prefix:         net.NewPfx(167772160, 8), // 10.0.0.0/8
+			path:           &route.Path{ValidationState: rpki.Invalid},
+			expectedPath:   &route.Path{ValidationState: rpki.Invalid},
+			expectedReject: true,
+		},
+		{
+			name: "Accept valid route",
+			filter: NewFilter(
+				NewTerm(
+					[]*TermCondition{
+						NewTermConditionWithValidationStates(rpki.Invalid),
+					},
+					[]Action{
+						&RejectAction{},
+					},
+				),
+			),
+			prefix:       net.NewPfx(167772160, 8), // 10.0.0.0/8
+			path:         &route.Path{ValidationState: rpki.Valid},
+			expectedPath: &route.Path{ValidationState: rpki.Valid},
+		},
+		{
+			name: "Lower local pref of ASPA unknown route",
+			filter: NewFilter(
+				NewTerm(
+					[]*TermCondition{
+						NewTermConditionWithASPAStates(rpki.ASPAUnknown),
+					},
+					[]Action{
+						&SetLocalPrefAction{LocalPref: 50},
+					},
+				),
+			),
+			prefix:       net.NewPfx(167772160, 8), // 10.0.0.0/8
+			path:         &route.Path{LocalPref: 100, ValidationState: rpki.Valid, ASPAState: rpki.ASPAUnknown},
+			expectedPath: &route.Path{LocalPref: 50, ValidationState: rpki.Valid, ASPAState: rpki.ASPAUnknown},
+		},
+		{
+			name: "Accept ASPA valid route",
+			filter: NewFilter(
+				NewTerm(
+					[]*TermCondition{
+						NewTermConditionWithASPAStates(rpki.ASPAInvalid),
+					},
+					[]Action{
+						&RejectAction{},
+					},
+				),
+			),
+			prefix:       net.NewPfx(167772160, 8), // 10.0.0.0/8
+			path:         &route.Path{ASPAState: rpki.ASPAValid},
+			expectedPath: &route.Path{ASPAState: rpki.ASPAValid},
+		},
+		{
+			name: "Non terminating term",
+			filter: NewFilter(
+				NewTerm(nil, []Action{&SetLocalPrefAction{LocalPref: 50}}),
+			),
+			prefix:       net.NewPfx(3232235520, 16), // 192.168.0.0/16
+			path:         &route.Path{LocalPref: 100},
+			expectedPath: &route.Path{LocalPref: 50},
+		},
+	}
+
+	for _, test := range tests {
+		orig := test.path.Copy()
+		p, reject := test.filter.ProcessTerms(test.prefix, test.path)
+
+		assert.Equal(t, test.expectedReject, reject, test.name)
+		assert.Equal(t, test.expectedPath, p, test.name)
+		assert.Equal(t, orig, test.path, "Path modified in place in test %q", test.name)
+	}
+}
@@ -0,0 +1,82 @@
+package filter
+
+import (
+	"github.com/taktv6/tbgp/net"
+)
+
+// PrefixList matches a set of prefixes exactly
+type PrefixList struct {
+	prefixes []*net.Prefix
+}
+
+// NewPrefixList creates a new prefix list
+func NewPrefixList(pfxs ...*net.Prefix) *PrefixList {
+	return &PrefixList{
+		prefixes: pfxs,
+	}
+}
+
+// Matches checks if pfx is in the prefix list
+func (l *PrefixList) Matches(pfx *net.Prefix) bool {
+	for _, x := range l.prefixes {
+		if x.Equal(pfx) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RouteFilter matches prefixes relative to a prefix using a matcher
+type RouteFilter struct {
+	pfx     *net.Prefix
+	matcher PrefixMatcher
+}
+
+// PrefixMatcher decides if pfx matches a route filter for prefix ref
+type PrefixMatcher func(ref, pfx *net.Prefix) bool
+
+// NewRouteFilter creates a new route filter
+func NewRouteFilter(pfx *net.Prefix, matcher PrefixMatcher) *RouteFilter {
+	return &RouteFilter{
+		pfx:     pfx,
+		matcher: matcher,
+	}
+}
+
+// Matches checks if pfx matches the route filter
+func (f *RouteFilter) Matches(pfx *net.Prefix) bool {
+	return f.matcher(f.pfx, pfx)
+}
+
+// Exact matches the reference prefix only
+func Exact() PrefixMatcher {
+	return func(ref, pfx *net.Prefix) bool {
+		return ref.Equal(pfx)
+	}
+}
+
+// OrLonger matches the reference prefix and all its more specifics
+func OrLonger() PrefixMatcher {
+	return func(ref, pfx *net.Prefix) bool {
+		return ref.Equal(pfx) || ref.Contains(pfx)
+	}
+}
+
+// Longer matches all more specifics of the reference prefix
+func Longer() PrefixMatcher {
+	return func(ref, pfx *net.Prefix) bool {
+		return ref.Contains(pfx)
+	}
+}
+
+// InRange matches the reference prefix and its more specifics with a length between min and max
+func InRange(min, max uint8) PrefixMatcher {
+	return func(ref, pfx *net.Prefix) bool {
+		if pfx.Pfxlen() < min || pfx.Pfxlen() > max {
+			return false
+		}
+
+		return ref.Equal(pfx) || ref.Contains(pfx)
+	}
+}
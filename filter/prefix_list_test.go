@@ -0,0 +1,96 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taktv6/tbgp/net"
+)
+
+func TestPrefixListMatches(t *testing.T) {
+	l := NewPrefixList(
+		net.NewPfx(167772160, 8),   // 10.0.0.0/8
+		net.NewPfx(3232235520, 16), // 192.168.0.0/16
+	)
+
+	assert.True(t, l.Matches(net.NewPfx(3232235520, 16)))  // 192.168.0.0/16
+	assert.False(t, l.Matches(net.NewPfx(3232235520, 24))) // 192.168.0.0/24
+	assert.False(t, l.Matches(net.NewPfx(2886729728, 12))) // 172.16.0.0/12
+}
+
+func TestRouteFilterMatches(t *testing.T) {
+	ref := net.NewPfx(167772160, 8) // 10.0.0.0/8
+
+	tests := []struct {
+		name     string
+		matcher  PrefixMatcher
+		pfx      *net.Prefix
+		expected bool
+	}{
+		{
+			name:     "Exact match",
+			matcher:  Exact(),
+			pfx:      net.NewPfx(167772160, 8), // 10.0.0.0/8
+			expected: true,
+		},
+		{
+			name:     "Exact more specific",
+			matcher:  Exact(),
+			pfx:      net.NewPfx(167772160, 16), // 10.0.0.0/16
+			expected: false,
+		},
+		{
+			name:     "OrLonger exact",
+			matcher:  OrLonger(),
+			pfx:      net.NewPfx(167772160, 8), // 10.0.0.0/8
+			expected: true,
+		},
+		{
+			name:     "OrLonger more specific",
+			matcher:  OrLonger(),
+			pfx:      net.NewPfx(169082880, 24), // 10.20.30.0/24
+			expected: true,
+		},
+		{
+			name:     "OrLonger other prefix",
+			matcher:  OrLonger(),
+			pfx:      net.NewPfx(201326592, 16), // 12.0.0.0/16
+			expected: false,
+		},
+		{
+			name:     "Longer exact",
+			matcher:  Longer(),
+			pfx:      net.NewPfx(167772160, 8), // 10.0.0.0/8
+			expected: false,
+		},
+		{
+			name:     "Longer more specific",
+			matcher:  Longer(),
+			pfx:      net.NewPfx(167772160, 9), // 10.0.0.0/9
+			expected: true,
+		},
+		{
+			name:     "InRange within range",
+			matcher:  InRange(16, 24),
+			pfx:      net.NewPfx(169082880, 24), // 10.20.30.0/24
+			expected: true,
+		},
+		{
+			name:     "InRange too long",
+			matcher:  InRange(16, 24),
+			pfx:      net.NewPfx(169082880, 25), // 10.20.30.0/25
+			expected: false,
+		},
+		{
+			name:     "InRange too short",
+			matcher:  InRange(16, 24),
+			pfx:      net.NewPfx(167772160, 12), // 10.0.0.0/12
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		f := NewRouteFilter(ref, test.matcher)
+		assert.Equal(t, test.expected, f.Matches(test.pfx), test.name)
+	}
+}
@@ -0,0 +1,156 @@
+package filter
+
+import (
+	"github.com/taktv6/tbgp/net"
+	"github.com/taktv6/tbgp/route"
+	"github.com/taktv6/tbgp/rpki"
+)
+
+// Term applies its actions to every route matching at least one of its conditions.
+// A term without conditions matches all routes.
+type Term struct {
+	from []*TermCondition
+	then []Action
+}
+
+// NewTerm creates a new term
+func NewTerm(from []*TermCondition, then []Action) *Term {
+	return &Term{
+		from: from,
+		then: then,
+	}
+}
+
+// Process applies the actions of t if pfx and p match. It returns the result of the
+// actions and whether one of them terminated the processing of the route.
+func (t *Term) Process(pfx *net.Prefix, p *route.Path) (ActionResult, bool) {
+	res := ActionResult{
+		Path: p,
+	}
+
+	if !t.matches(pfx, p) {
+		return res, false
+	}
+
+	for _, a := range t.then {
+		res = a.Do(pfx, res.Path)
+		if res.Terminate {
+			return res, true
+		}
+	}
+
+	return res, false
+}
+
+func (t *Term) matches(pfx *net.Prefix, p *route.Path) bool {
+	if len(t.from) == 0 {
+		return true
+	}
+
+	for _, c := range t.from {
+		if c.Matches(pfx, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// TermCondition matches routes matching all of its criteria. For every
+// kind of criterion it is sufficient if one of the configured ones matches.
+type TermCondition struct {
+	prefixLists      []*PrefixList
+	routeFilters     []*RouteFilter
+	validationStates []rpki.ValidationState
+	aspaStates       []rpki.ASPAState
+}
+
+// NewTermConditionWithPrefixLists creates a condition matching prefixes in any of the prefix lists
+func NewTermConditionWithPrefixLists(lists ...*PrefixList) *TermCondition {
+	return &TermCondition{
+		prefixLists: lists,
+	}
+}
+
+// NewTermConditionWithRouteFilters creates a condition matching prefixes matched by any of the route filters
+func NewTermConditionWithRouteFilters(filters ...*RouteFilter) *TermCondition {
+	return &TermCondition{
+		routeFilters: filters,
+	}
+}
+
+// NewTermConditionWithValidationStates creates a condition matching routes in any of the origin validation states
+func NewTermConditionWithValidationStates(states ...rpki.ValidationState) *TermCondition {
+	return &TermCondition{
+		validationStates: states,
+	}
+}
+
+// NewTermConditionWithASPAStates creates a condition matching routes in any of the ASPA verification states
+func NewTermConditionWithASPAStates(states ...rpki.ASPAState) *TermCondition {
+	return &TermCondition{
+		aspaStates: states,
+	}
+}
+
+// Matches checks if pfx and p match the condition
+func (c *TermCondition) Matches(pfx *net.Prefix, p *route.Path) bool {
+	return c.matchesPrefixLists(pfx) && c.matchesRouteFilters(pfx) && c.matchesValidationStates(p) &&
+		c.matchesASPAStates(p)
+}
+
+func (c *TermCondition) matchesPrefixLists(pfx *net.Prefix) bool {
+	if len(c.prefixLists) == 0 {
+		return true
+	}
+
+	for _, l := range c.prefixLists {
+		if l.Matches(pfx) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *TermCondition) matchesRouteFilters(pfx *net.Prefix) bool {
+	if len(c.routeFilters) == 0 {
+		return true
+	}
+
+	for _, f := range c.routeFilters {
+		if f.Matches(pfx) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *TermCondition) matchesValidationStates(p *route.Path) bool {
+	if len(c.validationStates) == 0 {
+		return true
+	}
+
+	for _, s := range c.validationStates {
+		if p.ValidationState == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *TermCondition) matchesASPAStates(p *route.Path) bool {
+	if len(c.aspaStates) == 0 {
+		return true
+	}
+
+	for _, s := range c.aspaStates {
+		if p.ASPAState == s {
+			return true
+		}
+	}
+
+	return false
+}
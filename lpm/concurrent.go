@@ -0,0 +1,90 @@
+package lpm
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/taktv6/tbgp/net"
+)
+
+// ConcurrentLPM is an LPM that can be read by any number of goroutines while it is written to.
+// Writers never modify nodes readers can see. They copy the nodes on the path to the
+// modified one instead and publish the new root atomically, so readers never lock and
+// always see the table either before or after a write.
+type ConcurrentLPM struct {
+	// mu serializes writers
+	mu   sync.Mutex
+	gen  uint64
+	root atomic.Value
+}
+
+// NewConcurrent creates a new empty ConcurrentLPM
+func NewConcurrent() *ConcurrentLPM {
+	lpm := &ConcurrentLPM{}
+	lpm.root.Store((*node)(nil))
+	return lpm
+}
+
+func (lpm *ConcurrentLPM) load() *node {
+	return lpm.root.Load().(*node)
+}
+
+// Snapshot returns the current state of lpm. It is not affected by later writes.
+func (lpm *ConcurrentLPM) Snapshot() *Snapshot {
+	return &Snapshot{
+		root: lpm.load(),
+	}
+}
+
+// LPM performs a longest prefix match for pfx on lpm
+func (lpm *ConcurrentLPM) LPM(pfx *net.Prefix) []*net.Prefix {
+	return lpm.Snapshot().LPM(pfx)
+}
+
+// Get get's prefix pfx from the LPM
+func (lpm *ConcurrentLPM) Get(pfx *net.Prefix, moreSpecifics bool) []*net.Prefix {
+	return lpm.Snapshot().Get(pfx, moreSpecifics)
+}
+
+// Value returns the value associated with prefix pfx and if pfx is in the LPM
+func (lpm *ConcurrentLPM) Value(pfx *net.Prefix) (interface{}, bool) {
+	return lpm.Snapshot().Value(pfx)
+}
+
+// Dump returns all prefixes in lpm
+func (lpm *ConcurrentLPM) Dump() []*net.Prefix {
+	return lpm.Snapshot().Dump()
+}
+
+// Insert inserts a route into the LPM
+func (lpm *ConcurrentLPM) Insert(pfx *net.Prefix) {
+	lpm.InsertValue(pfx, nil)
+}
+
+// InsertValue inserts a route into the LPM and associates value with it
+func (lpm *ConcurrentLPM) InsertValue(pfx *net.Prefix, value interface{}) {
+	lpm.mu.Lock()
+	defer lpm.mu.Unlock()
+
+	root := lpm.load()
+	if n := root.get(pfx); n != nil && sameValue(n.value, value) {
+		return
+	}
+
+	lpm.gen++
+	lpm.root.Store(insert(root, pfx, value, lpm.gen))
+}
+
+// Remove removes prefix pfx from the LPM
+func (lpm *ConcurrentLPM) Remove(pfx *net.Prefix) {
+	lpm.mu.Lock()
+	defer lpm.mu.Unlock()
+
+	root := lpm.load()
+	if root.get(pfx) == nil {
+		return
+	}
+
+	lpm.gen++
+	lpm.root.Store(root.remove(pfx, lpm.gen))
+}
@@ -0,0 +1,355 @@
+package lpm
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/taktv6/tbgp/net"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrentInsertRemove(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefixes []*net.Prefix
+		remove   []*net.Prefix
+		expected []*net.Prefix
+	}{
+		{
+			name: "Insert before existing root",
+			prefixes: []*net.Prefix{
+				net.NewPfx(176160768, 9),  // 10.128.0.0/9
+				net.NewPfx(167772160, 8),  // 10.0.0.0/8
+				net.NewPfx(176160768, 16), // 10.128.0.0/16
+			},
+			expected: []*net.Prefix{
+				net.NewPfx(167772160, 8),  // 10.0.0.0/8
+				net.NewPfx(176160768, 9),  // 10.128.0.0/9
+				net.NewPfx(176160768, 16), // 10.128.0.0/16
+			},
+		},
+		{
+			name: "Remove covering prefix",
+			prefixes: []*net.Prefix{
+				net.NewPfx(167772160, 8), // 10.0.0.0/8
+				net.NewPfx(167772160, 9), // 10.0.0.0/9
+				net.NewPfx(176160768, 9), // 10.128.0.0/9
+			},
+			remove: []*net.Prefix{
+				net.NewPfx(167772160, 8), // 10.0.0.0/8
+			},
+			expected: []*net.Prefix{
+				net.NewPfx(167772160, 9), // 10.0.0.0/9
+				net.NewPfx(176160768, 9), // 10.128.0.0/9
+			},
+		},
+		{
+			name: "Remove non existing prefix",
+			prefixes: []*net.Prefix{
+				net.NewPfx(167772160, 8),  // 10.0.0.0/8
+				net.NewPfx(191134464, 24), // 11.100.123.0/24
+			},
+			remove: []*net.Prefix{
+				net.NewPfx(167772160, 7), // 10.0.0.0/7
+			},
+			expected: []*net.Prefix{
+				net.NewPfx(167772160, 8),  // 10.0.0.0/8
+				net.NewPfx(191134464, 24), // 11.100.123.0/24
+			},
+		},
+		{
+			name: "Remove from empty LPM",
+			remove: []*net.Prefix{
+				net.NewPfx(167772160, 7), // 10.0.0.0/7
+			},
+			expected: []*net.Prefix{},
+		},
+		{
+			name: "Remove all",
+			prefixes: []*net.Prefix{
+				net.NewPfx(167772160, 8),  // 10.0.0.0/8
+				net.NewPfx(191134464, 24), // 11.100.123.0/24
+				net.NewPfx(167772160, 12), // 10.0.0.0/12
+			},
+			remove: []*net.Prefix{
+				net.NewPfx(167772160, 12), // 10.0.0.0/12
+				net.NewPfx(191134464, 24), // 11.100.123.0/24
+				net.NewPfx(167772160, 8),  // 10.0.0.0/8
+			},
+			expected: []*net.Prefix{},
+		},
+		{
+			name: "Reinsert removed prefix",
+			prefixes: []*net.Prefix{
+				net.NewPfx(167772160, 8),  // 10.0.0.0/8
+				net.NewPfx(191134464, 24), // 11.100.123.0/24
+				net.NewPfx(167772160, 12), // 10.0.0.0/12
+				net.NewPfx(167772160, 10), // 10.0.0.0/10
+				net.NewPfx(191134592, 25), // 11.100.123.128/25
+			},
+			remove: []*net.Prefix{
+				net.NewPfx(191134464, 24), // 11.100.123.0/24
+				net.NewPfx(167772160, 10), // 10.0.0.0/10
+			},
+			expected: []*net.Prefix{
+				net.NewPfx(167772160, 8),  // 10.0.0.0/8
+				net.NewPfx(167772160, 12), // 10.0.0.0/12
+				net.NewPfx(191134592, 25), // 11.100.123.128/25
+			},
+		},
+	}
+
+	for _, test := range tests {
+		lpm := NewConcurrent()
+		for _, pfx := range test.prefixes {
+			lpm.Insert(pfx)
+		}
+
+		for _, pfx := range test.remove {
+			lpm.Remove(pfx)
+		}
+
+		assert.Equal(t, test.expected, lpm.Dump(), test.name)
+	}
+}
+
+func TestConcurrentPathCopying(t *testing.T) {
+	lpm := NewConcurrent()
+	lpm.Insert(net.NewPfx(167772160, 8))  // 10.0.0.0/8
+	lpm.Insert(net.NewPfx(167772160, 12)) // 10.0.0.0/12
+	lpm.Insert(net.NewPfx(176160768, 9))  // 10.128.0.0/9
+
+	old := lpm.load()
+	lpm.Insert(net.NewPfx(191134464, 24)) // 11.100.123.0/24
+	lpm.Insert(net.NewPfx(167772160, 10)) // 10.0.0.0/10
+	lpm.Remove(net.NewPfx(167772160, 12)) // 10.0.0.0/12
+
+	assert.Equal(t, []*net.Prefix{
+		net.NewPfx(167772160, 8),  // 10.0.0.0/8
+		net.NewPfx(167772160, 12), // 10.0.0.0/12
+		net.NewPfx(176160768, 9),  // 10.128.0.0/9
+	}, old.dump(nil))
+
+	assert.Equal(t, []*net.Prefix{
+		net.NewPfx(167772160, 8),  // 10.0.0.0/8
+		net.NewPfx(167772160, 10), // 10.0.0.0/10
+		net.NewPfx(176160768, 9),  // 10.128.0.0/9
+		net.NewPfx(191134464, 24), // 11.100.123.0/24
+	}, lpm.Dump())
+}
+
+func TestConcurrentRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	lpm := NewConcurrent()
+	present := make(map[net.Prefix]struct{})
+
+	for i := 0; i < 5000; i++ {
+		pfx := randomPrefix(r)
+		if _, ok := present[*pfx]; ok && r.Intn(2) == 0 {
+			lpm.Remove(pfx)
+			delete(present, *pfx)
+		} else {
+			lpm.Insert(pfx)
+			present[*pfx] = struct{}{}
+		}
+	}
+
+	expected := make([]*net.Prefix, 0, len(present))
+	for pfx := range present {
+		p := pfx
+		expected = append(expected, &p)
+	}
+	sortPrefixes(expected)
+	assert.Equal(t, expected, lpm.Dump())
+
+	for i := 0; i < 1000; i++ {
+		needle := net.NewPfx(r.Uint32(), 32)
+
+		var covering []*net.Prefix
+		for _, pfx := range expected {
+			if pfx.Contains(needle) || *pfx == *needle {
+				covering = append(covering, pfx)
+			}
+		}
+
+		assert.Equal(t, covering, lpm.LPM(needle), needle.String())
+	}
+
+	for _, pfx := range expected {
+		assert.Equal(t, []*net.Prefix{pfx}, lpm.Get(pfx, false), pfx.String())
+	}
+}
+
+func TestConcurrentReaders(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	lpm := NewConcurrent()
+	lpm.Insert(net.NewPfx(0, 0))
+
+	prefixes := make([]*net.Prefix, 1000)
+	for i := range prefixes {
+		prefixes[i] = randomPrefix(r)
+	}
+
+	stop := make(chan struct{})
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				needle := net.NewPfx(r.Uint32(), 32)
+				res := lpm.LPM(needle)
+				if len(res) == 0 || *res[0] != *net.NewPfx(0, 0) {
+					t.Errorf("Default route missing for %s: %v", needle, res)
+					return
+				}
+
+				for _, pfx := range res[1:] {
+					if !pfx.Contains(needle) && *pfx != *needle {
+						t.Errorf("%s does not cover %s", pfx, needle)
+						return
+					}
+				}
+			}
+		}(int64(i))
+	}
+
+	for i := 0; i < 10; i++ {
+		for _, pfx := range prefixes {
+			lpm.Insert(pfx)
+		}
+		for _, pfx := range prefixes {
+			lpm.Remove(pfx)
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	assert.Equal(t, []*net.Prefix{net.NewPfx(0, 0)}, lpm.Dump())
+}
+
+func randomPrefix(r *rand.Rand) *net.Prefix {
+	pfxlen := uint8(8 + r.Intn(25))
+	return net.NewPfx(r.Uint32()&(^uint32(0)<<(32-pfxlen)), pfxlen)
+}
+
+func sortPrefixes(prefixes []*net.Prefix) {
+	sort.Slice(prefixes, func(i, j int) bool {
+		if prefixes[i].Addr() != prefixes[j].Addr() {
+			return prefixes[i].Addr() < prefixes[j].Addr()
+		}
+		return prefixes[i].Pfxlen() < prefixes[j].Pfxlen()
+	})
+}
+
+func benchmarkPrefixes(n int) []*net.Prefix {
+	r := rand.New(rand.NewSource(1))
+	prefixes := make([]*net.Prefix, n)
+	for i := range prefixes {
+		prefixes[i] = randomPrefix(r)
+	}
+	return prefixes
+}
+
+func BenchmarkInsert(b *testing.B) {
+	prefixes := benchmarkPrefixes(100000)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		lpm := New()
+		for _, pfx := range prefixes {
+			lpm.Insert(pfx)
+		}
+	}
+}
+
+func BenchmarkConcurrentInsert(b *testing.B) {
+	prefixes := benchmarkPrefixes(100000)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		lpm := NewConcurrent()
+		for _, pfx := range prefixes {
+			lpm.Insert(pfx)
+		}
+	}
+}
+
+// BenchmarkLPMParallel reads from an LPM guarded by a RWMutex while it is written to
+func BenchmarkLPMParallel(b *testing.B) {
+	prefixes := benchmarkPrefixes(100000)
+	lpm := New()
+	for _, pfx := range prefixes {
+		lpm.Insert(pfx)
+	}
+
+	mu := sync.RWMutex{}
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			pfx := prefixes[i%len(prefixes)]
+			mu.Lock()
+			lpm.Remove(pfx)
+			lpm.Insert(pfx)
+			mu.Unlock()
+		}
+	}()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			mu.RLock()
+			lpm.LPM(prefixes[i%len(prefixes)])
+			mu.RUnlock()
+		}
+	})
+}
+
+// BenchmarkConcurrentLPMParallel reads from a ConcurrentLPM while it is written to
+func BenchmarkConcurrentLPMParallel(b *testing.B) {
+	prefixes := benchmarkPrefixes(100000)
+	lpm := NewConcurrent()
+	for _, pfx := range prefixes {
+		lpm.Insert(pfx)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			pfx := prefixes[i%len(prefixes)]
+			lpm.Remove(pfx)
+			lpm.Insert(pfx)
+		}
+	}()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			lpm.LPM(prefixes[i%len(prefixes)])
+		}
+	})
+}
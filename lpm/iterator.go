@@ -0,0 +1,295 @@
+package lpm
+
+import (
+	"github.com/taktv6/tbgp/net"
+)
+
+// WalkFunc is called for every prefix visited by a walk. The walk stops if it returns false.
+type WalkFunc func(pfx *net.Prefix, value interface{}) bool
+
+// Iterator iterates over prefixes ordered by address and prefix length
+type Iterator struct {
+	stack   []*node
+	descend bool
+	current *node
+}
+
+// Next moves the iterator to the next prefix. It returns false if there is none left.
+func (it *Iterator) Next() bool {
+	for len(it.stack) > 0 {
+		n := it.stack[len(it.stack)-1]
+		it.stack = it.stack[:len(it.stack)-1]
+
+		if it.descend {
+			it.push(n.h)
+			it.push(n.l)
+		}
+
+		if !n.dummy {
+			it.current = n
+			return true
+		}
+	}
+
+	it.current = nil
+	return false
+}
+
+// Prefix returns the current prefix
+func (it *Iterator) Prefix() *net.Prefix {
+	return it.current.pfx
+}
+
+// Value returns the value associated with the current prefix
+func (it *Iterator) Value() interface{} {
+	return it.current.value
+}
+
+func (it *Iterator) push(n *node) {
+	if n != nil {
+		it.stack = append(it.stack, n)
+	}
+}
+
+// seek pushes the subtrees below n with prefixes ordered after start
+func (it *Iterator) seek(n *node, start *net.Prefix) {
+	for n != nil {
+		if after(n.pfx, start) {
+			it.push(n)
+			return
+		}
+
+		if *n.pfx == *start {
+			it.push(n.h)
+			it.push(n.l)
+			return
+		}
+
+		// All prefixes below n are ordered before start
+		if !n.pfx.Contains(start) {
+			return
+		}
+
+		if !getBitUint32(start.Addr(), n.pfx.Pfxlen()+1) {
+			it.push(n.h)
+			n = n.l
+			continue
+		}
+		n = n.h
+	}
+}
+
+func newIterator(root *node) *Iterator {
+	it := &Iterator{
+		stack:   make([]*node, 0, 32),
+		descend: true,
+	}
+	it.push(root)
+	return it
+}
+
+func newMoreSpecificsIterator(root *node, pfx *net.Prefix) *Iterator {
+	it := &Iterator{
+		stack:   make([]*node, 0, 32),
+		descend: true,
+	}
+	it.push(root.moreSpecifics(pfx))
+	return it
+}
+
+func newLessSpecificsIterator(root *node, pfx *net.Prefix) *Iterator {
+	it := &Iterator{
+		stack: make([]*node, 0, 33),
+	}
+	for n := root.cover(pfx); n != nil; n = n.next(pfx) {
+		it.stack = append(it.stack, n)
+	}
+
+	// The shortest prefix has to be on top of the stack
+	for i, j := 0, len(it.stack)-1; i < j; i, j = i+1, j-1 {
+		it.stack[i], it.stack[j] = it.stack[j], it.stack[i]
+	}
+	return it
+}
+
+func newIteratorAfter(root *node, pfx *net.Prefix) *Iterator {
+	it := &Iterator{
+		stack:   make([]*node, 0, 32),
+		descend: true,
+	}
+	it.seek(root, pfx)
+	return it
+}
+
+// after checks if a is ordered after b by address and prefix length
+func after(a *net.Prefix, b *net.Prefix) bool {
+	return a.Compare(b) > 0
+}
+
+// moreSpecifics returns the topmost node below n with a prefix equal to or within pfx
+func (n *node) moreSpecifics(pfx *net.Prefix) *node {
+	for n != nil {
+		if *pfx == *n.pfx || pfx.Contains(n.pfx) {
+			return n
+		}
+
+		if !n.pfx.Contains(pfx) {
+			return nil
+		}
+
+		if !getBitUint32(pfx.Addr(), n.pfx.Pfxlen()+1) {
+			n = n.l
+			continue
+		}
+		n = n.h
+	}
+
+	return nil
+}
+
+// cover returns n if its prefix is equal to or covers pfx. n may be nil.
+func (n *node) cover(pfx *net.Prefix) *node {
+	if n == nil || (*n.pfx != *pfx && !n.pfx.Contains(pfx)) {
+		return nil
+	}
+
+	return n
+}
+
+// next returns the child of n on the path towards pfx if it covers pfx
+func (n *node) next(pfx *net.Prefix) *node {
+	if *n.pfx == *pfx {
+		return nil
+	}
+
+	if !getBitUint32(pfx.Addr(), n.pfx.Pfxlen()+1) {
+		return n.l.cover(pfx)
+	}
+	return n.h.cover(pfx)
+}
+
+func (n *node) walk(fn WalkFunc) bool {
+	if n == nil {
+		return true
+	}
+
+	if !n.dummy && !fn(n.pfx, n.value) {
+		return false
+	}
+
+	return n.l.walk(fn) && n.h.walk(fn)
+}
+
+func (n *node) walkAfter(start *net.Prefix, fn WalkFunc) bool {
+	if n == nil {
+		return true
+	}
+
+	if after(n.pfx, start) {
+		return n.walk(fn)
+	}
+
+	if *n.pfx == *start {
+		return n.l.walk(fn) && n.h.walk(fn)
+	}
+
+	// All prefixes below n are ordered before start
+	if !n.pfx.Contains(start) {
+		return true
+	}
+
+	if !getBitUint32(start.Addr(), n.pfx.Pfxlen()+1) {
+		return n.l.walkAfter(start, fn) && n.h.walk(fn)
+	}
+	return n.h.walkAfter(start, fn)
+}
+
+func walkLessSpecifics(root *node, pfx *net.Prefix, fn WalkFunc) {
+	for n := root.cover(pfx); n != nil; n = n.next(pfx) {
+		if !n.dummy && !fn(n.pfx, n.value) {
+			return
+		}
+	}
+}
+
+// Walk calls fn for all prefixes in lpm ordered by address and prefix length
+func (lpm *LPM) Walk(fn WalkFunc) {
+	lpm.root.walk(fn)
+}
+
+// WalkMoreSpecifics calls fn for pfx and all its more specifics in lpm ordered by address and prefix length
+func (lpm *LPM) WalkMoreSpecifics(pfx *net.Prefix, fn WalkFunc) {
+	lpm.root.moreSpecifics(pfx).walk(fn)
+}
+
+// WalkLessSpecifics calls fn for pfx and all prefixes in lpm covering it, starting with the shortest
+func (lpm *LPM) WalkLessSpecifics(pfx *net.Prefix, fn WalkFunc) {
+	walkLessSpecifics(lpm.root, pfx, fn)
+}
+
+// WalkAfter calls fn for all prefixes in lpm ordered after start by address and prefix length.
+// Passing the last prefix visited continues a walk that has been stopped.
+func (lpm *LPM) WalkAfter(start *net.Prefix, fn WalkFunc) {
+	lpm.root.walkAfter(start, fn)
+}
+
+// Iterate returns an iterator over all prefixes in lpm. lpm must not be written to while iterating.
+func (lpm *LPM) Iterate() *Iterator {
+	return newIterator(lpm.root)
+}
+
+// IterateMoreSpecifics returns an iterator over pfx and all its more specifics in lpm
+func (lpm *LPM) IterateMoreSpecifics(pfx *net.Prefix) *Iterator {
+	return newMoreSpecificsIterator(lpm.root, pfx)
+}
+
+// IterateLessSpecifics returns an iterator over pfx and all prefixes in lpm covering it, starting with the shortest
+func (lpm *LPM) IterateLessSpecifics(pfx *net.Prefix) *Iterator {
+	return newLessSpecificsIterator(lpm.root, pfx)
+}
+
+// IterateAfter returns an iterator over all prefixes in lpm ordered after start
+func (lpm *LPM) IterateAfter(start *net.Prefix) *Iterator {
+	return newIteratorAfter(lpm.root, start)
+}
+
+// Walk calls fn for all prefixes in s ordered by address and prefix length
+func (s *Snapshot) Walk(fn WalkFunc) {
+	s.root.walk(fn)
+}
+
+// WalkMoreSpecifics calls fn for pfx and all its more specifics in s ordered by address and prefix length
+func (s *Snapshot) WalkMoreSpecifics(pfx *net.Prefix, fn WalkFunc) {
+	s.root.moreSpecifics(pfx).walk(fn)
+}
+
+// WalkLessSpecifics calls fn for pfx and all prefixes in s covering it, starting with the shortest
+func (s *Snapshot) WalkLessSpecifics(pfx *net.Prefix, fn WalkFunc) {
+	walkLessSpecifics(s.root, pfx, fn)
+}
+
+// WalkAfter calls fn for all prefixes in s ordered after start by address and prefix length.
+// Passing the last prefix visited continues a walk that has been stopped.
+func (s *Snapshot) WalkAfter(start *net.Prefix, fn WalkFunc) {
+	s.root.walkAfter(start, fn)
+}
+
+// Iterate returns an iterator over all prefixes in s
+func (s *Snapshot) Iterate() *Iterator {
+	return newIterator(s.root)
+}
+
+// IterateMoreSpecifics returns an iterator over pfx and all its more specifics in s
+func (s *Snapshot) IterateMoreSpecifics(pfx *net.Prefix) *Iterator {
+	return newMoreSpecificsIterator(s.root, pfx)
+}
+
+// IterateLessSpecifics returns an iterator over pfx and all prefixes in s covering it, starting with the shortest
+func (s *Snapshot) IterateLessSpecifics(pfx *net.Prefix) *Iterator {
+	return newLessSpecificsIterator(s.root, pfx)
+}
+
+// IterateAfter returns an iterator over all prefixes in s ordered after start
+func (s *Snapshot) IterateAfter(start *net.Prefix) *Iterator {
+	return newIteratorAfter(s.root, start)
+}
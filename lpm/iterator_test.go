@@ -0,0 +1,295 @@
+package lpm
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/taktv6/tbgp/net"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func iteratorTestLPM() *LPM {
+	lpm := New()
+	lpm.InsertValue(net.NewPfx(167772160, 8), 1)  // 10.0.0.0/8
+	lpm.InsertValue(net.NewPfx(191134464, 24), 2) // 11.100.123.0/24
+	lpm.InsertValue(net.NewPfx(167772160, 12), 3) // 10.0.0.0/12
+	lpm.InsertValue(net.NewPfx(167772160, 10), 4) // 10.0.0.0/10
+	lpm.InsertValue(net.NewPfx(191134592, 25), 5) // 11.100.123.128/25
+	lpm.InsertValue(net.NewPfx(176160768, 9), 6)  // 10.128.0.0/9
+	return lpm
+}
+
+func collect(walk func(fn WalkFunc)) []*net.Prefix {
+	res := make([]*net.Prefix, 0)
+	walk(func(pfx *net.Prefix, value interface{}) bool {
+		res = append(res, pfx)
+		return true
+	})
+	return res
+}
+
+func collectIterator(it *Iterator) []*net.Prefix {
+	res := make([]*net.Prefix, 0)
+	for it.Next() {
+		res = append(res, it.Prefix())
+	}
+	return res
+}
+
+func TestWalk(t *testing.T) {
+	tests := []struct {
+		name     string
+		walk     func(l *LPM, fn WalkFunc)
+		iterator func(l *LPM) *Iterator
+		expected []*net.Prefix
+	}{
+		{
+			name: "All",
+			walk: func(l *LPM, fn WalkFunc) {
+				l.Walk(fn)
+			},
+			iterator: func(l *LPM) *Iterator {
+				return l.Iterate()
+			},
+			expected: []*net.Prefix{
+				net.NewPfx(167772160, 8),  // 10.0.0.0/8
+				net.NewPfx(167772160, 10), // 10.0.0.0/10
+				net.NewPfx(167772160, 12), // 10.0.0.0/12
+				net.NewPfx(176160768, 9),  // 10.128.0.0/9
+				net.NewPfx(191134464, 24), // 11.100.123.0/24
+				net.NewPfx(191134592, 25), // 11.100.123.128/25
+			},
+		},
+		{
+			name: "More specifics of existing prefix",
+			walk: func(l *LPM, fn WalkFunc) {
+				l.WalkMoreSpecifics(net.NewPfx(167772160, 10), fn)
+			},
+			iterator: func(l *LPM) *Iterator {
+				return l.IterateMoreSpecifics(net.NewPfx(167772160, 10))
+			},
+			expected: []*net.Prefix{
+				net.NewPfx(167772160, 10), // 10.0.0.0/10
+				net.NewPfx(167772160, 12), // 10.0.0.0/12
+			},
+		},
+		{
+			name: "More specifics of missing prefix",
+			walk: func(l *LPM, fn WalkFunc) {
+				l.WalkMoreSpecifics(net.NewPfx(167772160, 7), fn)
+			},
+			iterator: func(l *LPM) *Iterator {
+				return l.IterateMoreSpecifics(net.NewPfx(167772160, 7))
+			},
+			expected: []*net.Prefix{
+				net.NewPfx(167772160, 8),  // 10.0.0.0/8
+				net.NewPfx(167772160, 10), // 10.0.0.0/10
+				net.NewPfx(167772160, 12), // 10.0.0.0/12
+				net.NewPfx(176160768, 9),  // 10.128.0.0/9
+				net.NewPfx(191134464, 24), // 11.100.123.0/24
+				net.NewPfx(191134592, 25), // 11.100.123.128/25
+			},
+		},
+		{
+			name: "More specifics outside of the LPM",
+			walk: func(l *LPM, fn WalkFunc) {
+				l.WalkMoreSpecifics(net.NewPfx(3232235520, 16), fn) // 192.168.0.0/16
+			},
+			iterator: func(l *LPM) *Iterator {
+				return l.IterateMoreSpecifics(net.NewPfx(3232235520, 16))
+			},
+			expected: []*net.Prefix{},
+		},
+		{
+			name: "Less specifics",
+			walk: func(l *LPM, fn WalkFunc) {
+				l.WalkLessSpecifics(net.NewPfx(167772161, 32), fn) // 10.0.0.1/32
+			},
+			iterator: func(l *LPM) *Iterator {
+				return l.IterateLessSpecifics(net.NewPfx(167772161, 32))
+			},
+			expected: []*net.Prefix{
+				net.NewPfx(167772160, 8),  // 10.0.0.0/8
+				net.NewPfx(167772160, 10), // 10.0.0.0/10
+				net.NewPfx(167772160, 12), // 10.0.0.0/12
+			},
+		},
+		{
+			name: "Less specifics of existing prefix",
+			walk: func(l *LPM, fn WalkFunc) {
+				l.WalkLessSpecifics(net.NewPfx(191134592, 25), fn)
+			},
+			iterator: func(l *LPM) *Iterator {
+				return l.IterateLessSpecifics(net.NewPfx(191134592, 25))
+			},
+			expected: []*net.Prefix{
+				net.NewPfx(191134464, 24), // 11.100.123.0/24
+				net.NewPfx(191134592, 25), // 11.100.123.128/25
+			},
+		},
+		{
+			name: "Less specifics outside of the LPM",
+			walk: func(l *LPM, fn WalkFunc) {
+				l.WalkLessSpecifics(net.NewPfx(3232235520, 16), fn) // 192.168.0.0/16
+			},
+			iterator: func(l *LPM) *Iterator {
+				return l.IterateLessSpecifics(net.NewPfx(3232235520, 16))
+			},
+			expected: []*net.Prefix{},
+		},
+		{
+			name: "After existing prefix",
+			walk: func(l *LPM, fn WalkFunc) {
+				l.WalkAfter(net.NewPfx(167772160, 12), fn)
+			},
+			iterator: func(l *LPM) *Iterator {
+				return l.IterateAfter(net.NewPfx(167772160, 12))
+			},
+			expected: []*net.Prefix{
+				net.NewPfx(176160768, 9),  // 10.128.0.0/9
+				net.NewPfx(191134464, 24), // 11.100.123.0/24
+				net.NewPfx(191134592, 25), // 11.100.123.128/25
+			},
+		},
+		{
+			name: "After missing prefix",
+			walk: func(l *LPM, fn WalkFunc) {
+				l.WalkAfter(net.NewPfx(167772160, 11), fn) // 10.0.0.0/11
+			},
+			iterator: func(l *LPM) *Iterator {
+				return l.IterateAfter(net.NewPfx(167772160, 11))
+			},
+			expected: []*net.Prefix{
+				net.NewPfx(167772160, 12), // 10.0.0.0/12
+				net.NewPfx(176160768, 9),  // 10.128.0.0/9
+				net.NewPfx(191134464, 24), // 11.100.123.0/24
+				net.NewPfx(191134592, 25), // 11.100.123.128/25
+			},
+		},
+		{
+			name: "After last prefix",
+			walk: func(l *LPM, fn WalkFunc) {
+				l.WalkAfter(net.NewPfx(191134592, 25), fn)
+			},
+			iterator: func(l *LPM) *Iterator {
+				return l.IterateAfter(net.NewPfx(191134592, 25))
+			},
+			expected: []*net.Prefix{},
+		},
+	}
+
+	for _, test := range tests {
+		l := iteratorTestLPM()
+		assert.Equal(t, test.expected, collect(func(fn WalkFunc) {
+			test.walk(l, fn)
+		}), test.name)
+		assert.Equal(t, test.expected, collectIterator(test.iterator(l)), test.name)
+	}
+}
+
+func TestWalkStop(t *testing.T) {
+	l := iteratorTestLPM()
+
+	res := make([]*net.Prefix, 0)
+	values := make([]interface{}, 0)
+	l.Walk(func(pfx *net.Prefix, value interface{}) bool {
+		res = append(res, pfx)
+		values = append(values, value)
+		return len(res) < 2
+	})
+
+	assert.Equal(t, []*net.Prefix{
+		net.NewPfx(167772160, 8),  // 10.0.0.0/8
+		net.NewPfx(167772160, 10), // 10.0.0.0/10
+	}, res)
+	assert.Equal(t, []interface{}{1, 4}, values)
+
+	it := l.IterateLessSpecifics(net.NewPfx(167772160, 12))
+	assert.True(t, it.Next())
+	assert.Equal(t, net.NewPfx(167772160, 8), it.Prefix())
+	assert.Equal(t, 1, it.Value())
+}
+
+func TestWalkPagination(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	l := New()
+	for i := 0; i < 1000; i++ {
+		l.Insert(randomPrefix(r))
+	}
+
+	pageSize := 7
+	res := make([]*net.Prefix, 0)
+	walkPage := func(pfx *net.Prefix, value interface{}) bool {
+		res = append(res, pfx)
+		return len(res)%pageSize != 0
+	}
+
+	l.Walk(walkPage)
+	for {
+		n := len(res)
+		l.WalkAfter(res[n-1], walkPage)
+		if len(res) == n {
+			break
+		}
+	}
+
+	expected := l.Dump()
+	assert.Equal(t, expected, res)
+
+	for i := 0; i < 100; i++ {
+		start := randomPrefix(r)
+
+		after := make([]*net.Prefix, 0)
+		for _, pfx := range expected {
+			if pfx.Addr() > start.Addr() || (pfx.Addr() == start.Addr() && pfx.Pfxlen() > start.Pfxlen()) {
+				after = append(after, pfx)
+			}
+		}
+
+		assert.Equal(t, after, collectIterator(l.Snapshot().IterateAfter(start)), start.String())
+	}
+}
+
+func BenchmarkWalk(b *testing.B) {
+	l := New()
+	for _, pfx := range benchmarkPrefixes(100000) {
+		l.Insert(pfx)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		l.Walk(func(pfx *net.Prefix, value interface{}) bool {
+			return true
+		})
+	}
+}
+
+func BenchmarkIterate(b *testing.B) {
+	l := New()
+	for _, pfx := range benchmarkPrefixes(100000) {
+		l.Insert(pfx)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		it := l.Iterate()
+		for it.Next() {
+		}
+	}
+}
+
+func BenchmarkDump(b *testing.B) {
+	l := New()
+	for _, pfx := range benchmarkPrefixes(100000) {
+		l.Insert(pfx)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		l.Dump()
+	}
+}
@@ -0,0 +1,92 @@
+package lpm
+
+import (
+	"github.com/taktv6/tbgp/net"
+)
+
+// lookup returns the node with the longest prefix of at most maxPfxLen bits below n containing addr
+func (n *node) lookup(addr uint32, maxPfxLen uint8) *node {
+	var res *node
+	for n != nil && n.pfx.Pfxlen() <= maxPfxLen && n.pfx.ContainsAddr(addr) {
+		if !n.dummy {
+			res = n
+		}
+
+		if n.pfx.Pfxlen() == 32 {
+			break
+		}
+
+		if !getBitUint32(addr, n.pfx.Pfxlen()+1) {
+			n = n.l
+			continue
+		}
+		n = n.h
+	}
+
+	return res
+}
+
+// covering returns the node with the longest prefix below n covering pfx, not counting pfx itself
+func (n *node) covering(pfx *net.Prefix) *node {
+	if pfx.Pfxlen() == 0 {
+		return nil
+	}
+
+	return n.lookup(pfx.Addr(), pfx.Pfxlen()-1)
+}
+
+func (n *node) result() (*net.Prefix, interface{}) {
+	if n == nil {
+		return nil, nil
+	}
+
+	return n.pfx, n.value
+}
+
+// Lookup returns the longest prefix in lpm containing addr and its value. The prefix is nil if there is none.
+func (lpm *LPM) Lookup(addr uint32) (*net.Prefix, interface{}) {
+	return lpm.root.lookup(addr, 32).result()
+}
+
+// LookupMaxLen returns the longest prefix in lpm of at most maxPfxLen bits containing addr and its value.
+// The prefix is nil if there is none.
+func (lpm *LPM) LookupMaxLen(addr uint32, maxPfxLen uint8) (*net.Prefix, interface{}) {
+	return lpm.root.lookup(addr, maxPfxLen).result()
+}
+
+// Covering returns the longest prefix in lpm less specific than pfx and its value. The prefix is nil if there is none.
+func (lpm *LPM) Covering(pfx *net.Prefix) (*net.Prefix, interface{}) {
+	return lpm.root.covering(pfx).result()
+}
+
+// Lookup returns the longest prefix in s containing addr and its value. The prefix is nil if there is none.
+func (s *Snapshot) Lookup(addr uint32) (*net.Prefix, interface{}) {
+	return s.root.lookup(addr, 32).result()
+}
+
+// LookupMaxLen returns the longest prefix in s of at most maxPfxLen bits containing addr and its value.
+// The prefix is nil if there is none.
+func (s *Snapshot) LookupMaxLen(addr uint32, maxPfxLen uint8) (*net.Prefix, interface{}) {
+	return s.root.lookup(addr, maxPfxLen).result()
+}
+
+// Covering returns the longest prefix in s less specific than pfx and its value. The prefix is nil if there is none.
+func (s *Snapshot) Covering(pfx *net.Prefix) (*net.Prefix, interface{}) {
+	return s.root.covering(pfx).result()
+}
+
+// Lookup returns the longest prefix in lpm containing addr and its value. The prefix is nil if there is none.
+func (lpm *ConcurrentLPM) Lookup(addr uint32) (*net.Prefix, interface{}) {
+	return lpm.load().lookup(addr, 32).result()
+}
+
+// LookupMaxLen returns the longest prefix in lpm of at most maxPfxLen bits containing addr and its value.
+// The prefix is nil if there is none.
+func (lpm *ConcurrentLPM) LookupMaxLen(addr uint32, maxPfxLen uint8) (*net.Prefix, interface{}) {
+	return lpm.load().lookup(addr, maxPfxLen).result()
+}
+
+// Covering returns the longest prefix in lpm less specific than pfx and its value. The prefix is nil if there is none.
+func (lpm *ConcurrentLPM) Covering(pfx *net.Prefix) (*net.Prefix, interface{}) {
+	return lpm.load().covering(pfx).result()
+}
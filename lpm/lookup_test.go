@@ -0,0 +1,229 @@
+package lpm
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/taktv6/tbgp/net"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name      string
+		addr      uint32
+		maxPfxLen uint8
+		expected  *net.Prefix
+		value     interface{}
+	}{
+		{
+			name:      "Most specific",
+			addr:      167772161, // 10.0.0.1
+			maxPfxLen: 32,
+			expected:  net.NewPfx(167772160, 12), // 10.0.0.0/12
+			value:     3,
+		},
+		{
+			name:      "Maximum prefix length",
+			addr:      167772161, // 10.0.0.1
+			maxPfxLen: 11,
+			expected:  net.NewPfx(167772160, 10), // 10.0.0.0/10
+			value:     4,
+		},
+		{
+			name:      "Maximum prefix length equal to prefix length",
+			addr:      167772161, // 10.0.0.1
+			maxPfxLen: 12,
+			expected:  net.NewPfx(167772160, 12), // 10.0.0.0/12
+			value:     3,
+		},
+		{
+			name:      "Below dummy node",
+			addr:      191134593, // 11.100.123.129
+			maxPfxLen: 32,
+			expected:  net.NewPfx(191134592, 25), // 11.100.123.128/25
+			value:     5,
+		},
+		{
+			name:      "No match within maximum prefix length",
+			addr:      191134593, // 11.100.123.129
+			maxPfxLen: 23,
+		},
+		{
+			name:      "No match",
+			addr:      3232235521, // 192.168.0.1
+			maxPfxLen: 32,
+		},
+	}
+
+	for _, test := range tests {
+		l := iteratorTestLPM()
+		pfx, value := l.LookupMaxLen(test.addr, test.maxPfxLen)
+		assert.Equal(t, test.expected, pfx, test.name)
+		assert.Equal(t, test.value, value, test.name)
+
+		if test.maxPfxLen == 32 {
+			pfx, value = l.Lookup(test.addr)
+			assert.Equal(t, test.expected, pfx, test.name)
+			assert.Equal(t, test.value, value, test.name)
+		}
+	}
+}
+
+func TestCovering(t *testing.T) {
+	tests := []struct {
+		name     string
+		pfx      *net.Prefix
+		expected *net.Prefix
+	}{
+		{
+			name:     "Existing prefix",
+			pfx:      net.NewPfx(167772160, 12), // 10.0.0.0/12
+			expected: net.NewPfx(167772160, 10), // 10.0.0.0/10
+		},
+		{
+			name:     "Missing prefix",
+			pfx:      net.NewPfx(167772160, 11), // 10.0.0.0/11
+			expected: net.NewPfx(167772160, 10), // 10.0.0.0/10
+		},
+		{
+			name: "Least specific prefix",
+			pfx:  net.NewPfx(167772160, 8), // 10.0.0.0/8
+		},
+		{
+			name: "Default route",
+			pfx:  net.NewPfx(0, 0),
+		},
+	}
+
+	for _, test := range tests {
+		l := iteratorTestLPM()
+		pfx, _ := l.Covering(test.pfx)
+		assert.Equal(t, test.expected, pfx, test.name)
+	}
+}
+
+func TestLookupRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	l := NewConcurrent()
+	for i := 0; i < 5000; i++ {
+		l.Insert(randomPrefix(r))
+	}
+	prefixes := l.Dump()
+
+	for i := 0; i < 1000; i++ {
+		addr := r.Uint32()
+		maxPfxLen := uint8(r.Intn(33))
+
+		var expected, expectedMaxLen *net.Prefix
+		for _, pfx := range prefixes {
+			if !pfx.ContainsAddr(addr) {
+				continue
+			}
+
+			if expected == nil || pfx.Pfxlen() > expected.Pfxlen() {
+				expected = pfx
+			}
+			if pfx.Pfxlen() <= maxPfxLen && (expectedMaxLen == nil || pfx.Pfxlen() > expectedMaxLen.Pfxlen()) {
+				expectedMaxLen = pfx
+			}
+		}
+
+		pfx, _ := l.Lookup(addr)
+		assert.Equal(t, expected, pfx)
+
+		pfx, _ = l.LookupMaxLen(addr, maxPfxLen)
+		assert.Equal(t, expectedMaxLen, pfx)
+	}
+
+	for _, pfx := range prefixes {
+		var expected *net.Prefix
+		for _, c := range prefixes {
+			if c.Contains(pfx) && (expected == nil || c.Pfxlen() > expected.Pfxlen()) {
+				expected = c
+			}
+		}
+
+		res, _ := l.Covering(pfx)
+		assert.Equal(t, expected, res, pfx.String())
+	}
+}
+
+func TestLookupAllocations(t *testing.T) {
+	l := iteratorTestLPM()
+	pfx := net.NewPfx(191134592, 25)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		l.Lookup(191134593)
+		l.LookupMaxLen(191134593, 24)
+		l.Covering(pfx)
+	})
+	assert.Equal(t, float64(0), allocs)
+}
+
+var (
+	fullTableOnce sync.Once
+	fullTable     *LPM
+)
+
+// fullTableLPM returns an LPM with about as many prefixes as the IPv4 DFZ. Most of them are /24s.
+func fullTableLPM() *LPM {
+	fullTableOnce.Do(func() {
+		r := rand.New(rand.NewSource(1))
+		fullTable = New()
+		for i := 0; i < 900000; i++ {
+			pfxlen := uint8(24)
+			if r.Intn(10) < 4 {
+				pfxlen = uint8(8 + r.Intn(16))
+			}
+
+			fullTable.Insert(net.NewPfx(r.Uint32()&(^uint32(0)<<(32-pfxlen)), pfxlen))
+		}
+	})
+
+	return fullTable
+}
+
+func BenchmarkLookupFullTable(b *testing.B) {
+	l := fullTableLPM()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		l.Lookup(uint32(i) * 2654435761)
+	}
+}
+
+func BenchmarkLookupMaxLenFullTable(b *testing.B) {
+	l := fullTableLPM()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		l.LookupMaxLen(uint32(i)*2654435761, 20)
+	}
+}
+
+func BenchmarkCoveringFullTable(b *testing.B) {
+	l := fullTableLPM()
+	pfx := net.NewPfx(191134464, 24) // 11.100.123.0/24
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		l.Covering(pfx)
+	}
+}
+
+// BenchmarkLPMFullTable is the host route lookup using LPM for comparison
+func BenchmarkLPMFullTable(b *testing.B) {
+	l := fullTableLPM()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		l.LPM(net.NewPfx(uint32(i)*2654435761, 32))
+	}
+}
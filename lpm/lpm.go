@@ -7,14 +7,22 @@ import (
 type LPM struct {
 	root  *node
 	nodes uint64
+
+	// gen is the generation of nodes created by writes. It is increased whenever a snapshot is taken.
+	gen uint64
 }
 
 type node struct {
 	skip  uint8
 	dummy bool
 	pfx   *net.Prefix
+	value interface{}
 	l     *node
 	h     *node
+
+	// gen is the generation of the write that created the node. Nodes of older
+	// generations may be shared with snapshots and are copied instead of modified.
+	gen uint64
 }
 
 // New creates a new empty LPM
@@ -31,6 +39,12 @@ func newNode(pfx *net.Prefix, skip uint8, dummy bool) *node {
 	return n
 }
 
+func newOwnedNode(pfx *net.Prefix, skip uint8, dummy bool, gen uint64) *node {
+	n := newNode(pfx, skip, dummy)
+	n.gen = gen
+	return n
+}
+
 // LPM performs a longest prefix match for pfx on lpm
 func (lpm *LPM) LPM(pfx *net.Prefix) (res []*net.Prefix) {
 	if lpm.root == nil {
@@ -41,8 +55,13 @@ func (lpm *LPM) LPM(pfx *net.Prefix) (res []*net.Prefix) {
 	return res
 }
 
+// Remove removes prefix pfx from the LPM
 func (lpm *LPM) Remove(pfx *net.Prefix) {
-	lpm.root.remove(pfx)
+	if lpm.root.get(pfx) == nil {
+		return
+	}
+
+	lpm.root = lpm.root.remove(pfx, lpm.gen)
 }
 
 // Get get's prefix pfx from the LPM
@@ -65,36 +84,102 @@ func (lpm *LPM) Get(pfx *net.Prefix, moreSpecifics bool) (res []*net.Prefix) {
 	}
 }
 
+// Value returns the value associated with prefix pfx and if pfx is in the LPM
+func (lpm *LPM) Value(pfx *net.Prefix) (interface{}, bool) {
+	node := lpm.root.get(pfx)
+	if node == nil {
+		return nil, false
+	}
+
+	return node.value, true
+}
+
 // Insert inserts a route into the LPM
 func (lpm *LPM) Insert(pfx *net.Prefix) {
-	if lpm.root == nil {
-		lpm.root = newNode(pfx, pfx.Pfxlen(), false)
+	lpm.InsertValue(pfx, nil)
+}
+
+// InsertValue inserts a route into the LPM and associates value with it
+func (lpm *LPM) InsertValue(pfx *net.Prefix, value interface{}) {
+	if n := lpm.root.get(pfx); n != nil && sameValue(n.value, value) {
 		return
 	}
 
-	lpm.root = lpm.root.insert(pfx)
+	lpm.root = insert(lpm.root, pfx, value, lpm.gen)
+}
+
+// sameValue checks if a and b are equal. Values that cannot be compared, e.g. slices, are never equal.
+func sameValue(a interface{}, b interface{}) (same bool) {
+	defer func() {
+		if recover() != nil {
+			same = false
+		}
+	}()
+
+	return a == b
+}
+
+// insert inserts pfx into the trie below root using nodes of generation gen and returns the new root
+func insert(root *node, pfx *net.Prefix, value interface{}, gen uint64) *node {
+	new := newOwnedNode(pfx, pfx.Pfxlen(), false, gen)
+	new.value = value
+	if root == nil {
+		return new
+	}
+
+	return root.insert(new, gen)
+}
+
+// own returns n if it has been created by a write of generation gen and a copy of n otherwise
+func (n *node) own(gen uint64) *node {
+	if n.gen == gen {
+		return n
+	}
+
+	c := *n
+	c.gen = gen
+	return &c
 }
 
-func (n *node) remove(pfx *net.Prefix) {
+func (n *node) remove(pfx *net.Prefix, gen uint64) *node {
 	if n == nil {
-		return
+		return nil
 	}
 
+	n = n.own(gen)
 	if *n.pfx == *pfx {
-		if n.dummy {
-			return
-		}
 		n.dummy = true
-		return
+		n.value = nil
+		return n.compact(gen)
 	}
 
 	b := getBitUint32(pfx.Addr(), n.pfx.Pfxlen()+1)
 	if !b {
-		n.l.remove(pfx)
-		return
+		n.l = n.l.remove(pfx, gen)
+	} else {
+		n.h = n.h.remove(pfx, gen)
+	}
+
+	return n.compact(gen)
+}
+
+// compact returns the subtree replacing n if n is a dummy node with less than two children
+func (n *node) compact(gen uint64) *node {
+	if !n.dummy || (n.l != nil && n.h != nil) {
+		return n
+	}
+
+	child := n.l
+	if child == nil {
+		child = n.h
 	}
-	n.h.remove(pfx)
-	return
+	if child == nil {
+		return nil
+	}
+
+	child = child.own(gen)
+	child.skip += n.skip + 1
+	return child
 }
 
 func (n *node) lpm(needle *net.Prefix, res *[]*net.Prefix) {
@@ -161,96 +246,87 @@ func (n *node) get(pfx *net.Prefix) *node {
 	return n.h.get(pfx)
 }
 
-func (n *node) insert(pfx *net.Prefix) *node {
-	if *n.pfx == *pfx {
+func (n *node) insert(new *node, gen uint64) *node {
+	if *n.pfx == *new.pfx {
+		n = n.own(gen)
 		n.dummy = false
+		n.value = new.value
 		return n
 	}
 
 	// is pfx NOT a subnet of this node?
-	if !n.pfx.Contains(pfx) {
-		if pfx.Contains(n.pfx) {
-			return n.insertBefore(pfx, n.pfx.Pfxlen()-n.skip-1)
+	if !n.pfx.Contains(new.pfx) {
+		if new.pfx.Contains(n.pfx) {
+			return n.insertBefore(new, gen)
 		}
 
-		return n.newSuperNode(pfx)
+		return n.newSuperNode(new, gen)
 	}
 
 	// pfx is a subnet of this node
-	b := getBitUint32(pfx.Addr(), n.pfx.Pfxlen()+1)
+	n = n.own(gen)
+	b := getBitUint32(new.pfx.Addr(), n.pfx.Pfxlen()+1)
 	if !b {
-		return n.insertLow(pfx, n.pfx.Pfxlen())
+		return n.insertLow(new, gen)
 	}
-	return n.insertHigh(pfx, n.pfx.Pfxlen())
+	return n.insertHigh(new, gen)
 }
 
-func (n *node) insertLow(pfx *net.Prefix, parentPfxLen uint8) *node {
+func (n *node) insertLow(new *node, gen uint64) *node {
 	if n.l == nil {
-		n.l = newNode(pfx, pfx.Pfxlen()-parentPfxLen-1, false)
+		n.adopt(new, gen)
 		return n
 	}
-	n.l = n.l.insert(pfx)
+	n.l = n.l.insert(new, gen)
 	return n
 }
 
-func (n *node) insertHigh(pfx *net.Prefix, parentPfxLen uint8) *node {
+func (n *node) insertHigh(new *node, gen uint64) *node {
 	if n.h == nil {
-		n.h = newNode(pfx, pfx.Pfxlen()-parentPfxLen-1, false)
+		n.adopt(new, gen)
 		return n
 	}
-	n.h = n.h.insert(pfx)
+	n.h = n.h.insert(new, gen)
 	return n
 }
 
-func (n *node) newSuperNode(pfx *net.Prefix) *node {
-	superNet := pfx.GetSupernet(n.pfx)
+func (n *node) newSuperNode(new *node, gen uint64) *node {
+	superNet := new.pfx.GetSupernet(n.pfx)
 
 	pfxLenDiff := n.pfx.Pfxlen() - superNet.Pfxlen()
 	skip := n.skip - pfxLenDiff
 
-	pseudoNode := newNode(superNet, skip, true)
-	pseudoNode.insertChildren(n, pfx)
+	pseudoNode := newOwnedNode(superNet, skip, true, gen)
+	pseudoNode.insertChildren(n, new, gen)
 	return pseudoNode
 }
 
-func (n *node) insertChildren(old *node, new *net.Prefix) {
-	// Place the old node
-	b := getBitUint32(old.pfx.Addr(), n.pfx.Pfxlen()+1)
-	if !b {
-		n.l = old
-		n.l.skip = old.pfx.Pfxlen() - n.pfx.Pfxlen() - 1
-	} else {
-		n.h = old
-		n.h.skip = old.pfx.Pfxlen() - n.pfx.Pfxlen() - 1
-	}
-
-	// Place the new Prefix
-	newNode := newNode(new, new.Pfxlen()-n.pfx.Pfxlen()-1, false)
-	b = getBitUint32(new.Addr(), n.pfx.Pfxlen()+1)
-	if !b {
-		n.l = newNode
-	} else {
-		n.h = newNode
-	}
+func (n *node) insertChildren(old *node, new *node, gen uint64) {
+	n.adopt(old, gen)
+	n.adopt(new, gen)
 }
 
-func (n *node) insertBefore(pfx *net.Prefix, parentPfxLen uint8) *node {
-	tmp := n
+func (n *node) insertBefore(new *node, gen uint64) *node {
+	pfxLenDiff := n.pfx.Pfxlen() - new.pfx.Pfxlen()
+	new.skip = n.skip - pfxLenDiff
+	new.adopt(n, gen)
+	return new
+}
 
-	pfxLenDiff := n.pfx.Pfxlen() - pfx.Pfxlen()
-	skip := n.skip - pfxLenDiff
-	new := newNode(pfx, skip, false)
+// adopt places child below n, which has to be of generation gen
+func (n *node) adopt(child *node, gen uint64) {
+	skip := child.pfx.Pfxlen() - n.pfx.Pfxlen() - 1
+	if child.skip != skip {
+		child = child.own(gen)
+		child.skip = skip
+	}
 
-	b := getBitUint32(pfx.Addr(), parentPfxLen) // TODO: Is this correct?
+	b := getBitUint32(child.pfx.Addr(), n.pfx.Pfxlen()+1)
 	if !b {
-		new.l = tmp
-		new.l.skip = tmp.pfx.Pfxlen() - pfx.Pfxlen() - 1
-	} else {
-		new.h = tmp
-		new.h.skip = tmp.pfx.Pfxlen() - pfx.Pfxlen() - 1
+		n.l = child
+		return
 	}
-
-	return new
+	n.h = child
 }
 
 func (lpm *LPM) Dump() []*net.Prefix {
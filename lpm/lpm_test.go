@@ -441,7 +441,7 @@ func TestNewSuperNode(t *testing.T) {
 
 	for _, test := range tests {
 		n := newNode(test.a, test.a.Pfxlen(), false)
-		n = n.newSuperNode(test.b)
+		n = n.newSuperNode(newNode(test.b, 0, false), 0)
 		assert.Equal(t, test.expected, n)
 	}
 }
@@ -562,7 +562,7 @@ func TestInsertChildren(t *testing.T) {
 	for _, test := range tests {
 		n := newNode(test.base, test.base.Pfxlen(), true)
 		old := newNode(test.old, test.old.Pfxlen(), false)
-		n.insertChildren(old, test.new)
+		n.insertChildren(old, newNode(test.new, 0, false), 0)
 		assert.Equal(t, test.expected, n)
 	}
 }
@@ -604,7 +604,7 @@ func TestInsertBefore(t *testing.T) {
 
 	for _, test := range tests {
 		n := newNode(test.a, test.a.Pfxlen(), false)
-		n = n.insertBefore(test.b, test.b.Pfxlen())
+		n = n.insertBefore(newNode(test.b, 0, false), 0)
 		assert.Equal(t, test.expected, n)
 	}
 }
@@ -0,0 +1,141 @@
+package lpm
+
+import (
+	"github.com/taktv6/tbgp/net"
+)
+
+// Snapshot is a read-only copy of an LPM at a point in time. It shares all nodes with the
+// LPM that have not been written to since and can be read while the LPM is being written to.
+type Snapshot struct {
+	root *node
+}
+
+// Diff lists the prefixes that differ between two snapshots
+type Diff struct {
+	Added   []*net.Prefix
+	Removed []*net.Prefix
+
+	// Changed are prefixes in both snapshots with different values. Values that cannot be compared never count as equal.
+	Changed []*net.Prefix
+}
+
+// Snapshot returns the current state of lpm. Nodes are copied instead of modified by later writes.
+// It has to be called by the goroutine writing to lpm.
+func (lpm *LPM) Snapshot() *Snapshot {
+	lpm.gen++
+	return &Snapshot{
+		root: lpm.root,
+	}
+}
+
+// LPM performs a longest prefix match for pfx on s
+func (s *Snapshot) LPM(pfx *net.Prefix) (res []*net.Prefix) {
+	if s.root == nil {
+		return nil
+	}
+
+	s.root.lpm(pfx, &res)
+	return res
+}
+
+// Get get's prefix pfx from the snapshot
+func (s *Snapshot) Get(pfx *net.Prefix, moreSpecifics bool) (res []*net.Prefix) {
+	if s.root == nil {
+		return nil
+	}
+
+	node := s.root.get(pfx)
+	if moreSpecifics {
+		return node.dumpPfxs(res)
+	}
+
+	if node == nil {
+		return nil
+	}
+
+	return []*net.Prefix{
+		node.pfx,
+	}
+}
+
+// Value returns the value associated with prefix pfx and if pfx is in the snapshot
+func (s *Snapshot) Value(pfx *net.Prefix) (interface{}, bool) {
+	node := s.root.get(pfx)
+	if node == nil {
+		return nil, false
+	}
+
+	return node.value, true
+}
+
+// Dump returns all prefixes in s
+func (s *Snapshot) Dump() []*net.Prefix {
+	res := make([]*net.Prefix, 0)
+	return s.root.dump(res)
+}
+
+// Diff returns the changes from s to newer ordered by address and prefix length. Subtrees both
+// snapshots share are skipped, so its cost depends on the number of changes rather than the table size.
+func (s *Snapshot) Diff(newer *Snapshot) *Diff {
+	d := &Diff{}
+	d.diff(s.root, newer.root)
+	return d
+}
+
+func (d *Diff) diff(a *node, b *node) {
+	if a == b {
+		return
+	}
+
+	if a == nil {
+		d.Added = b.dump(d.Added)
+		return
+	}
+
+	if b == nil {
+		d.Removed = a.dump(d.Removed)
+		return
+	}
+
+	switch {
+	case *a.pfx == *b.pfx:
+		switch {
+		case a.dummy && !b.dummy:
+			d.Added = append(d.Added, b.pfx)
+		case !a.dummy && b.dummy:
+			d.Removed = append(d.Removed, a.pfx)
+		case !a.dummy && !b.dummy && !sameValue(a.value, b.value):
+			d.Changed = append(d.Changed, b.pfx)
+		}
+
+		d.diff(a.l, b.l)
+		d.diff(a.h, b.h)
+	case a.pfx.Contains(b.pfx):
+		if !a.dummy {
+			d.Removed = append(d.Removed, a.pfx)
+		}
+
+		if !getBitUint32(b.pfx.Addr(), a.pfx.Pfxlen()+1) {
+			d.diff(a.l, b)
+			d.Removed = a.h.dump(d.Removed)
+			return
+		}
+		d.Removed = a.l.dump(d.Removed)
+		d.diff(a.h, b)
+	case b.pfx.Contains(a.pfx):
+		if !b.dummy {
+			d.Added = append(d.Added, b.pfx)
+		}
+
+		if !getBitUint32(a.pfx.Addr(), b.pfx.Pfxlen()+1) {
+			d.diff(a, b.l)
+			d.Added = b.h.dump(d.Added)
+			return
+		}
+		d.Added = b.l.dump(d.Added)
+		d.diff(a, b.h)
+	default:
+		d.Removed = a.dump(d.Removed)
+		d.Added = b.dump(d.Added)
+	}
+}
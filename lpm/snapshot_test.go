@@ -0,0 +1,264 @@
+package lpm
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/taktv6/tbgp/net"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot(t *testing.T) {
+	lpm := New()
+	lpm.InsertValue(net.NewPfx(167772160, 8), 1)  // 10.0.0.0/8
+	lpm.InsertValue(net.NewPfx(167772160, 12), 2) // 10.0.0.0/12
+	lpm.InsertValue(net.NewPfx(176160768, 9), 3)  // 10.128.0.0/9
+
+	s := lpm.Snapshot()
+	lpm.InsertValue(net.NewPfx(167772160, 8), 4)  // 10.0.0.0/8
+	lpm.InsertValue(net.NewPfx(167772160, 10), 5) // 10.0.0.0/10
+	lpm.Remove(net.NewPfx(176160768, 9))          // 10.128.0.0/9
+
+	assert.Equal(t, []*net.Prefix{
+		net.NewPfx(167772160, 8),  // 10.0.0.0/8
+		net.NewPfx(167772160, 12), // 10.0.0.0/12
+		net.NewPfx(176160768, 9),  // 10.128.0.0/9
+	}, s.Dump())
+
+	v, ok := s.Value(net.NewPfx(167772160, 8))
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	assert.Equal(t, []*net.Prefix{
+		net.NewPfx(167772160, 8),  // 10.0.0.0/8
+		net.NewPfx(167772160, 12), // 10.0.0.0/12
+	}, s.LPM(net.NewPfx(167772160, 32)))
+
+	assert.Equal(t, []*net.Prefix{
+		net.NewPfx(167772160, 8),  // 10.0.0.0/8
+		net.NewPfx(167772160, 10), // 10.0.0.0/10
+		net.NewPfx(167772160, 12), // 10.0.0.0/12
+	}, lpm.Dump())
+
+	v, ok = lpm.Value(net.NewPfx(167772160, 8))
+	assert.True(t, ok)
+	assert.Equal(t, 4, v)
+
+	_, ok = lpm.Value(net.NewPfx(176160768, 9))
+	assert.False(t, ok)
+}
+
+func TestSnapshotDiff(t *testing.T) {
+	type entry struct {
+		pfx   *net.Prefix
+		value int
+	}
+
+	tests := []struct {
+		name     string
+		before   []entry
+		insert   []entry
+		remove   []*net.Prefix
+		expected *Diff
+	}{
+		{
+			name: "No changes",
+			before: []entry{
+				{net.NewPfx(167772160, 8), 1}, // 10.0.0.0/8
+			},
+			insert: []entry{
+				{net.NewPfx(167772160, 8), 1}, // 10.0.0.0/8
+			},
+			expected: &Diff{},
+		},
+		{
+			name: "Added to empty LPM",
+			insert: []entry{
+				{net.NewPfx(167772160, 8), 1},  // 10.0.0.0/8
+				{net.NewPfx(191134464, 24), 2}, // 11.100.123.0/24
+			},
+			expected: &Diff{
+				Added: []*net.Prefix{
+					net.NewPfx(167772160, 8),  // 10.0.0.0/8
+					net.NewPfx(191134464, 24), // 11.100.123.0/24
+				},
+			},
+		},
+		{
+			name: "Added, removed and changed",
+			before: []entry{
+				{net.NewPfx(167772160, 8), 1},  // 10.0.0.0/8
+				{net.NewPfx(167772160, 12), 2}, // 10.0.0.0/12
+				{net.NewPfx(176160768, 9), 3},  // 10.128.0.0/9
+				{net.NewPfx(191134464, 24), 4}, // 11.100.123.0/24
+			},
+			insert: []entry{
+				{net.NewPfx(167772160, 10), 5}, // 10.0.0.0/10
+				{net.NewPfx(176160768, 9), 6},  // 10.128.0.0/9
+				{net.NewPfx(191134592, 25), 7}, // 11.100.123.128/25
+			},
+			remove: []*net.Prefix{
+				net.NewPfx(167772160, 8),  // 10.0.0.0/8
+				net.NewPfx(191134464, 24), // 11.100.123.0/24
+			},
+			expected: &Diff{
+				Added: []*net.Prefix{
+					net.NewPfx(167772160, 10), // 10.0.0.0/10
+					net.NewPfx(191134592, 25), // 11.100.123.128/25
+				},
+				Removed: []*net.Prefix{
+					net.NewPfx(167772160, 8),  // 10.0.0.0/8
+					net.NewPfx(191134464, 24), // 11.100.123.0/24
+				},
+				Changed: []*net.Prefix{
+					net.NewPfx(176160768, 9), // 10.128.0.0/9
+				},
+			},
+		},
+		{
+			name: "Everything removed",
+			before: []entry{
+				{net.NewPfx(167772160, 8), 1},  // 10.0.0.0/8
+				{net.NewPfx(167772160, 12), 2}, // 10.0.0.0/12
+			},
+			remove: []*net.Prefix{
+				net.NewPfx(167772160, 12), // 10.0.0.0/12
+				net.NewPfx(167772160, 8),  // 10.0.0.0/8
+			},
+			expected: &Diff{
+				Removed: []*net.Prefix{
+					net.NewPfx(167772160, 8),  // 10.0.0.0/8
+					net.NewPfx(167772160, 12), // 10.0.0.0/12
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		lpm := New()
+		for _, e := range test.before {
+			lpm.InsertValue(e.pfx, e.value)
+		}
+
+		before := lpm.Snapshot()
+		for _, e := range test.insert {
+			lpm.InsertValue(e.pfx, e.value)
+		}
+		for _, pfx := range test.remove {
+			lpm.Remove(pfx)
+		}
+
+		assert.Equal(t, test.expected, before.Diff(lpm.Snapshot()), test.name)
+	}
+}
+
+func TestSnapshotDiffRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	lpm := New()
+	values := make(map[net.Prefix]int)
+
+	prefixes := make([]*net.Prefix, 2000)
+	for i := range prefixes {
+		prefixes[i] = randomPrefix(r)
+	}
+
+	for i := 0; i < 10; i++ {
+		old := make(map[net.Prefix]int)
+		for pfx, v := range values {
+			old[pfx] = v
+		}
+		before := lpm.Snapshot()
+
+		for j := 0; j < 500; j++ {
+			pfx := prefixes[r.Intn(len(prefixes))]
+			if r.Intn(3) == 0 {
+				lpm.Remove(pfx)
+				delete(values, *pfx)
+				continue
+			}
+
+			v := r.Intn(2)
+			lpm.InsertValue(pfx, v)
+			values[*pfx] = v
+		}
+
+		expected := &Diff{}
+		for pfx, v := range values {
+			p := pfx
+			ov, ok := old[pfx]
+			if !ok {
+				expected.Added = append(expected.Added, &p)
+			} else if ov != v {
+				expected.Changed = append(expected.Changed, &p)
+			}
+		}
+		for pfx := range old {
+			p := pfx
+			if _, ok := values[pfx]; !ok {
+				expected.Removed = append(expected.Removed, &p)
+			}
+		}
+		sortPrefixes(expected.Added)
+		sortPrefixes(expected.Removed)
+		sortPrefixes(expected.Changed)
+
+		assert.Equal(t, expected, before.Diff(lpm.Snapshot()))
+	}
+}
+
+func TestSnapshotConcurrentRead(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	lpm := New()
+
+	prefixes := make([]*net.Prefix, 1000)
+	for i := range prefixes {
+		prefixes[i] = randomPrefix(r)
+		lpm.Insert(prefixes[i])
+	}
+
+	s := lpm.Snapshot()
+	expected := s.Dump()
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			assert.Equal(t, expected, s.Dump())
+		}
+	}()
+
+	for i := 0; i < 10; i++ {
+		for _, pfx := range prefixes {
+			lpm.Remove(pfx)
+		}
+		for _, pfx := range prefixes {
+			lpm.Insert(pfx)
+		}
+	}
+	wg.Wait()
+}
+
+func TestIncomparableValues(t *testing.T) {
+	pfx := net.NewPfx(167772160, 8) // 10.0.0.0/8
+
+	lpm := New()
+	concurrent := NewConcurrent()
+	assert.NotPanics(t, func() {
+		for _, v := range [][]int{{1}, {1}, {2}} {
+			lpm.InsertValue(pfx, v)
+			concurrent.InsertValue(pfx, v)
+		}
+	})
+
+	v, _ := lpm.Value(pfx)
+	assert.Equal(t, []int{2}, v)
+	v, _ = concurrent.Value(pfx)
+	assert.Equal(t, []int{2}, v)
+
+	s := lpm.Snapshot()
+	lpm.InsertValue(pfx, []int{3})
+	assert.Equal(t, []*net.Prefix{pfx}, s.Diff(lpm.Snapshot()).Changed)
+}
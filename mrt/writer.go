@@ -0,0 +1,165 @@
+package mrt
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Writer writes MRT records (RFC6396) to files. The name of every file is created by formatting
+// a layout with the time the file is opened (see time.Format), e.g. "/var/mrt/updates.20060102.1504.mrt".
+// The directory is taken as is.
+// With a rotation interval files are named by the start of the interval they cover.
+// A new file is opened once the rotation interval has passed or the maximum size has been reached.
+// Files opened within the same interval for size reasons get a sequence number appended to their path.
+type Writer struct {
+	layout   string
+	interval time.Duration
+	maxSize  int64
+	compress bool
+	now      func() time.Time
+
+	mu      sync.Mutex
+	closed  bool
+	f       *os.File
+	w       io.Writer
+	gz      *gzip.Writer
+	path    string
+	base    string
+	seq     int
+	size    int64
+	expires time.Time
+}
+
+// NewWriter creates a writer for files with names formatted from layout. interval is the time after
+// which a new file is opened and maxSize the maximum number of bytes (uncompressed) written to a file.
+// Both are disabled if 0. If compress is set files are written gzip compressed.
+func NewWriter(layout string, interval time.Duration, maxSize int64, compress bool) *Writer {
+	return &Writer{
+		layout:   layout,
+		interval: interval,
+		maxSize:  maxSize,
+		compress: compress,
+		now:      time.Now,
+	}
+}
+
+// Write appends the serialized record rec to the current file, rotating it first if required
+func (w *Writer) Write(rec []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return fmt.Errorf("Writer closed")
+	}
+
+	if w.rotationDue(int64(len(rec))) {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := w.w.Write(rec); err != nil {
+		return fmt.Errorf("Unable to write MRT record to %s: %v", w.path, err)
+	}
+	w.size += int64(len(rec))
+
+	return nil
+}
+
+// Path returns the path of the current file. It is empty if no record has been written yet.
+func (w *Writer) Path() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.path
+}
+
+// Close closes the current file. Records written afterwards are rejected.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.closed = true
+	return w.close()
+}
+
+func (w *Writer) rotationDue(n int64) bool {
+	if w.f == nil {
+		return true
+	}
+
+	if w.interval > 0 && !w.now().Before(w.expires) {
+		return true
+	}
+
+	return w.maxSize > 0 && w.size > 0 && w.size+n > w.maxSize
+}
+
+func (w *Writer) rotate() error {
+	if err := w.close(); err != nil {
+		return err
+	}
+
+	// Files are rotated at multiples of the interval and named by the start of it so names line up over time
+	start := w.now()
+	if w.interval > 0 {
+		start = start.Truncate(w.interval)
+		w.expires = start.Add(w.interval)
+	}
+
+	dir, name := filepath.Split(w.layout)
+	path := dir + start.Format(name)
+	if path == w.base {
+		w.seq++
+	} else {
+		w.base = path
+		w.seq = 0
+	}
+	if w.seq > 0 {
+		path = fmt.Sprintf("%s.%d", path, w.seq)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("Unable to open MRT file: %v", err)
+	}
+
+	w.f = f
+	w.w = f
+	if w.compress {
+		w.gz = gzip.NewWriter(f)
+		w.w = w.gz
+	}
+	w.path = path
+	w.size = 0
+
+	return nil
+}
+
+func (w *Writer) close() error {
+	if w.f == nil {
+		return nil
+	}
+
+	var err error
+	if w.gz != nil {
+		err = w.gz.Close()
+		w.gz = nil
+	}
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	w.f = nil
+	w.w = nil
+
+	if err != nil {
+		return fmt.Errorf("Unable to close MRT file %s: %v", w.path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package mrt
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriterRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mrt")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	w := NewWriter(filepath.Join(dir, "updates.20060102.1504"), 5*time.Minute, 4, false)
+	w.now = func() time.Time {
+		return now
+	}
+
+	assert.NoError(t, w.Write([]byte{1, 2}))
+	assert.NoError(t, w.Write([]byte{3, 4}))
+
+	// Maximum size reached
+	assert.NoError(t, w.Write([]byte{5}))
+	assert.Equal(t, filepath.Join(dir, "updates.20200101.1200.1"), w.Path())
+
+	// Interval passed
+	now = now.Add(6 * time.Minute)
+	assert.NoError(t, w.Write([]byte{6}))
+	assert.Equal(t, filepath.Join(dir, "updates.20200101.1205"), w.Path())
+	assert.NoError(t, w.Close())
+
+	expected := map[string][]byte{
+		"updates.20200101.1200":   {1, 2, 3, 4},
+		"updates.20200101.1200.1": {5},
+		"updates.20200101.1205":   {6},
+	}
+	for name, content := range expected {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		assert.NoError(t, err, name)
+		assert.Equal(t, content, b, name)
+	}
+}
+
+func TestWriterCompression(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mrt")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	w := NewWriter(filepath.Join(dir, "rib.mrt.gz"), 0, 0, true)
+	assert.NoError(t, w.Write([]byte{1, 2, 3}))
+	assert.NoError(t, w.Write([]byte{4}))
+	assert.NoError(t, w.Close())
+	assert.Error(t, w.Write([]byte{5}))
+
+	f, err := os.Open(filepath.Join(dir, "rib.mrt.gz"))
+	if err != nil {
+		t.Fatalf("Unable to open file: %v", err)
+	}
+	defer f.Close()
+
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("Unable to read file: %v", err)
+	}
+
+	b, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{1, 2, 3, 4}, b)
+}
@@ -0,0 +1,149 @@
+package net
+
+import (
+	"math"
+	"sort"
+)
+
+// addrRange is an inclusive range of addresses. Its bounds are 64 bit wide so the address
+// following the last one can be represented.
+type addrRange struct {
+	first uint64
+	last  uint64
+}
+
+// Aggregate returns the minimal set of prefixes covering the same addresses as prefixes ordered
+// by address. Covered more specifics are dropped and siblings are merged into their supernet.
+func Aggregate(prefixes []*Prefix) []*Prefix {
+	sorted := make([]*Prefix, 0, len(prefixes))
+	for _, pfx := range prefixes {
+		sorted = append(sorted, pfx.Masked())
+	}
+	sortPrefixes(sorted)
+
+	res := make([]*Prefix, 0, len(sorted))
+	for _, pfx := range sorted {
+		if len(res) > 0 {
+			top := res[len(res)-1]
+			if top.Equal(pfx) || top.Contains(pfx) {
+				continue
+			}
+		}
+
+		res = append(res, pfx)
+		for len(res) > 1 {
+			a, b := res[len(res)-2], res[len(res)-1]
+			if !siblings(a, b) {
+				break
+			}
+
+			res = append(res[:len(res)-2], a.GetSupernet(b))
+		}
+	}
+
+	return res
+}
+
+// Subtract returns the minimal set of prefixes covering the addresses in prefixes but not in exclude ordered by address
+func Subtract(prefixes []*Prefix, exclude []*Prefix) []*Prefix {
+	ex := toRanges(exclude)
+
+	res := make([]*Prefix, 0)
+	i := 0
+	for _, r := range toRanges(prefixes) {
+		for i < len(ex) && ex[i].last < r.first {
+			i++
+		}
+
+		first := r.first
+		for j := i; j < len(ex) && ex[j].first <= r.last; j++ {
+			if ex[j].first > first {
+				res = appendRange(res, first, ex[j].first-1)
+			}
+			first = ex[j].last + 1
+		}
+
+		if first <= r.last {
+			res = appendRange(res, first, r.last)
+		}
+	}
+
+	return res
+}
+
+// RangeToPrefixes returns the minimal set of prefixes covering all addresses from first to last ordered by address
+func RangeToPrefixes(first uint32, last uint32) []*Prefix {
+	if first > last {
+		return []*Prefix{}
+	}
+
+	return appendRange(make([]*Prefix, 0), uint64(first), uint64(last))
+}
+
+func appendRange(res []*Prefix, first uint64, last uint64) []*Prefix {
+	for first <= last {
+		// Grow the prefix as long as it stays aligned and within the range
+		pfxlen := uint8(32)
+		for pfxlen > 0 {
+			size := uint64(1) << (32 - pfxlen + 1)
+			if first%size != 0 || first+size-1 > last {
+				break
+			}
+			pfxlen--
+		}
+
+		res = append(res, NewPfx(uint32(first), pfxlen))
+		first += uint64(1) << (32 - pfxlen)
+	}
+
+	return res
+}
+
+// toRanges returns the address ranges covered by prefixes. Overlapping and adjacent ranges are merged.
+func toRanges(prefixes []*Prefix) []addrRange {
+	ranges := make([]addrRange, 0, len(prefixes))
+	for _, pfx := range prefixes {
+		first := uint64(pfx.addr & netmask(pfx.pfxlen))
+		ranges = append(ranges, addrRange{
+			first: first,
+			last:  first + uint64(1)<<(32-pfx.pfxlen) - 1,
+		})
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].first < ranges[j].first
+	})
+
+	res := make([]addrRange, 0, len(ranges))
+	for _, r := range ranges {
+		if len(res) > 0 && r.first <= res[len(res)-1].last+1 {
+			if r.last > res[len(res)-1].last {
+				res[len(res)-1].last = r.last
+			}
+			continue
+		}
+
+		res = append(res, r)
+	}
+
+	return res
+}
+
+// siblings checks if a and b are the two halves of the same supernet
+func siblings(a *Prefix, b *Prefix) bool {
+	if a.pfxlen != b.pfxlen || a.pfxlen == 0 || a.Equal(b) {
+		return false
+	}
+
+	return a.GetSupernet(b).pfxlen == a.pfxlen-1
+}
+
+func sortPrefixes(prefixes []*Prefix) {
+	sort.Slice(prefixes, func(i, j int) bool {
+		return prefixes[i].Compare(prefixes[j]) < 0
+	})
+}
+
+func netmask(pfxlen uint8) uint32 {
+	return uint32(math.MaxUint32) << (32 - pfxlen)
+}
@@ -0,0 +1,284 @@
+package net
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregate(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []*Prefix
+		expected []*Prefix
+	}{
+		{
+			name:     "Empty",
+			input:    []*Prefix{},
+			expected: []*Prefix{},
+		},
+		{
+			name: "Siblings",
+			input: []*Prefix{
+				NewPfx(176160768, 9), // 10.128.0.0/9
+				NewPfx(167772160, 9), // 10.0.0.0/9
+			},
+			expected: []*Prefix{
+				NewPfx(167772160, 8), // 10.0.0.0/8
+			},
+		},
+		{
+			name: "Siblings merged repeatedly",
+			input: []*Prefix{
+				NewPfx(167772160, 10), // 10.0.0.0/10
+				NewPfx(171966464, 10), // 10.64.0.0/10
+				NewPfx(176160768, 9),  // 10.128.0.0/9
+				NewPfx(184549376, 8),  // 11.0.0.0/8
+			},
+			expected: []*Prefix{
+				NewPfx(167772160, 7), // 10.0.0.0/7
+			},
+		},
+		{
+			name: "Covered more specifics and duplicates",
+			input: []*Prefix{
+				NewPfx(167772160, 16), // 10.0.0.0/16
+				NewPfx(167772160, 8),  // 10.0.0.0/8
+				NewPfx(174391040, 24), // 10.100.0.0/24
+				NewPfx(167772160, 8),  // 10.0.0.0/8
+			},
+			expected: []*Prefix{
+				NewPfx(167772160, 8), // 10.0.0.0/8
+			},
+		},
+		{
+			name: "Adjacent prefixes with different supernets",
+			input: []*Prefix{
+				NewPfx(201326592, 8), // 12.0.0.0/8
+				NewPfx(184549376, 8), // 11.0.0.0/8
+			},
+			expected: []*Prefix{
+				NewPfx(184549376, 8), // 11.0.0.0/8
+				NewPfx(201326592, 8), // 12.0.0.0/8
+			},
+		},
+		{
+			name: "Host bits",
+			input: []*Prefix{
+				NewPfx(167772161, 24), // 10.0.0.1/24
+				NewPfx(167772416, 24), // 10.0.1.0/24
+			},
+			expected: []*Prefix{
+				NewPfx(167772160, 23), // 10.0.0.0/23
+			},
+		},
+		{
+			name: "Halves of the address space",
+			input: []*Prefix{
+				NewPfx(0, 1),          // 0.0.0.0/1
+				NewPfx(2147483648, 1), // 128.0.0.0/1
+			},
+			expected: []*Prefix{
+				NewPfx(0, 0),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, Aggregate(test.input), test.name)
+	}
+}
+
+func TestSubtract(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []*Prefix
+		exclude  []*Prefix
+		expected []*Prefix
+	}{
+		{
+			name: "Nothing excluded",
+			input: []*Prefix{
+				NewPfx(167772160, 9), // 10.0.0.0/9
+				NewPfx(176160768, 9), // 10.128.0.0/9
+			},
+			expected: []*Prefix{
+				NewPfx(167772160, 8), // 10.0.0.0/8
+			},
+		},
+		{
+			name: "More specific excluded",
+			input: []*Prefix{
+				NewPfx(167772160, 8), // 10.0.0.0/8
+			},
+			exclude: []*Prefix{
+				NewPfx(167772160, 10), // 10.0.0.0/10
+			},
+			expected: []*Prefix{
+				NewPfx(171966464, 10), // 10.64.0.0/10
+				NewPfx(176160768, 9),  // 10.128.0.0/9
+			},
+		},
+		{
+			name: "Less specific excluded",
+			input: []*Prefix{
+				NewPfx(167772160, 16), // 10.0.0.0/16
+				NewPfx(184549376, 16), // 11.0.0.0/16
+			},
+			exclude: []*Prefix{
+				NewPfx(167772160, 8), // 10.0.0.0/8
+			},
+			expected: []*Prefix{
+				NewPfx(184549376, 16), // 11.0.0.0/16
+			},
+		},
+		{
+			name: "Multiple exclusions",
+			input: []*Prefix{
+				NewPfx(167772160, 22), // 10.0.0.0/22
+			},
+			exclude: []*Prefix{
+				NewPfx(167772416, 24), // 10.0.1.0/24
+				NewPfx(167772672, 25), // 10.0.2.0/25
+			},
+			expected: []*Prefix{
+				NewPfx(167772160, 24), // 10.0.0.0/24
+				NewPfx(167772800, 25), // 10.0.2.128/25
+				NewPfx(167772928, 24), // 10.0.3.0/24
+			},
+		},
+		{
+			name: "Everything excluded",
+			input: []*Prefix{
+				NewPfx(167772160, 8), // 10.0.0.0/8
+			},
+			exclude: []*Prefix{
+				NewPfx(0, 0),
+			},
+			expected: []*Prefix{},
+		},
+		{
+			name: "Exclusion from the end of the address space",
+			input: []*Prefix{
+				NewPfx(0, 0),
+			},
+			exclude: []*Prefix{
+				NewPfx(2147483648, 1), // 128.0.0.0/1
+			},
+			expected: []*Prefix{
+				NewPfx(0, 1),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, Subtract(test.input, test.exclude), test.name)
+	}
+}
+
+func TestRangeToPrefixes(t *testing.T) {
+	tests := []struct {
+		name     string
+		first    uint32
+		last     uint32
+		expected []*Prefix
+	}{
+		{
+			name:  "Single address",
+			first: 167772161, // 10.0.0.1
+			last:  167772161, // 10.0.0.1
+			expected: []*Prefix{
+				NewPfx(167772161, 32), // 10.0.0.1/32
+			},
+		},
+		{
+			name:  "Aligned range",
+			first: 167772160, // 10.0.0.0
+			last:  184549375, // 10.255.255.255
+			expected: []*Prefix{
+				NewPfx(167772160, 8), // 10.0.0.0/8
+			},
+		},
+		{
+			name:  "Unaligned range",
+			first: 167772161, // 10.0.0.1
+			last:  167772424, // 10.0.1.8
+			expected: []*Prefix{
+				NewPfx(167772161, 32), // 10.0.0.1/32
+				NewPfx(167772162, 31), // 10.0.0.2/31
+				NewPfx(167772164, 30), // 10.0.0.4/30
+				NewPfx(167772168, 29), // 10.0.0.8/29
+				NewPfx(167772176, 28), // 10.0.0.16/28
+				NewPfx(167772192, 27), // 10.0.0.32/27
+				NewPfx(167772224, 26), // 10.0.0.64/26
+				NewPfx(167772288, 25), // 10.0.0.128/25
+				NewPfx(167772416, 29), // 10.0.1.0/29
+				NewPfx(167772424, 32), // 10.0.1.8/32
+			},
+		},
+		{
+			name:  "Whole address space",
+			first: 0,
+			last:  math.MaxUint32,
+			expected: []*Prefix{
+				NewPfx(0, 0),
+			},
+		},
+		{
+			name:     "Empty range",
+			first:    167772161, // 10.0.0.1
+			last:     167772160, // 10.0.0.0
+			expected: []*Prefix{},
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, RangeToPrefixes(test.first, test.last), test.name)
+	}
+}
+
+func TestAggregateRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 100; i++ {
+		// Keep all prefixes within 10.0.0.0/16 so they are likely to overlap
+		prefixes := make([]*Prefix, 50)
+		for j := range prefixes {
+			pfxlen := uint8(18 + r.Intn(15))
+			prefixes[j] = NewPfx((167772160|r.Uint32()&0xffff)&netmask(pfxlen), pfxlen)
+		}
+
+		res := Aggregate(prefixes)
+
+		// Aggregation is minimal if it equals the prefixes needed for the covered ranges
+		expected := make([]*Prefix, 0)
+		for _, rng := range toRanges(prefixes) {
+			expected = appendRange(expected, rng.first, rng.last)
+		}
+		assert.Equal(t, expected, res)
+
+		for j := 0; j < 100; j++ {
+			addr := 167772160 | r.Uint32()&0xffff
+			assert.Equal(t, covered(prefixes, addr), covered(res, addr))
+		}
+
+		exclude := prefixes[:10]
+		rest := Subtract(prefixes, exclude)
+		for j := 0; j < 100; j++ {
+			addr := 167772160 | r.Uint32()&0xffff
+			assert.Equal(t, covered(prefixes, addr) && !covered(exclude, addr), covered(rest, addr))
+		}
+	}
+}
+
+func covered(prefixes []*Prefix, addr uint32) bool {
+	for _, pfx := range prefixes {
+		if pfx.ContainsAddr(addr) {
+			return true
+		}
+	}
+
+	return false
+}
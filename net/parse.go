@@ -0,0 +1,113 @@
+package net
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/taktv6/tflow2/convert"
+)
+
+var (
+	// ErrInvalidPfxlen is returned for prefix lengths beyond 32 bits
+	ErrInvalidPfxlen = errors.New("Invalid prefix length")
+
+	// ErrHostBitsSet is returned for prefixes with bits set beyond the prefix length
+	ErrHostBitsSet = errors.New("Host bits set")
+
+	// ErrNotIPv4 is returned for addresses and prefixes of other address families
+	ErrNotIPv4 = errors.New("Not an IPv4 prefix")
+)
+
+// ParsePrefix parses a prefix in CIDR notation (e.g. 10.0.0.0/8). Prefixes with host bits set are rejected.
+func ParsePrefix(s string) (*Prefix, error) {
+	i := strings.IndexByte(s, '/')
+	if i < 0 {
+		return nil, fmt.Errorf("Invalid prefix %q: Missing prefix length", s)
+	}
+
+	addr, err := netip.ParseAddr(s[:i])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid prefix %q: %v", s, err)
+	}
+	if !addr.Is4() {
+		return nil, fmt.Errorf("Invalid prefix %q: %w", s, ErrNotIPv4)
+	}
+
+	pfxlen, err := strconv.ParseUint(s[i+1:], 10, 8)
+	if err != nil || pfxlen > 32 {
+		return nil, fmt.Errorf("Invalid prefix %q: %w", s, ErrInvalidPfxlen)
+	}
+
+	a := addr.As4()
+	pfx := NewPfx(convert.Uint32b(a[:]), uint8(pfxlen))
+	if err := pfx.Validate(); err != nil {
+		return nil, fmt.Errorf("Invalid prefix %q: %w", s, err)
+	}
+
+	return pfx, nil
+}
+
+// NewPfxFromIPNet creates a Prefix from an IPv4 net.IPNet. Bits beyond the prefix length are cleared.
+func NewPfxFromIPNet(n *net.IPNet) (*Prefix, error) {
+	addr := n.IP.To4()
+	if addr == nil {
+		return nil, ErrNotIPv4
+	}
+
+	ones, bits := n.Mask.Size()
+	if bits != 32 {
+		return nil, ErrNotIPv4
+	}
+
+	return NewPfx(convert.Uint32b(addr), uint8(ones)).Masked(), nil
+}
+
+// NewPfxFromNetipPrefix creates a Prefix from an IPv4 netip.Prefix. Bits beyond the prefix length are cleared.
+func NewPfxFromNetipPrefix(p netip.Prefix) (*Prefix, error) {
+	if !p.IsValid() {
+		return nil, ErrInvalidPfxlen
+	}
+
+	if !p.Addr().Is4() {
+		return nil, ErrNotIPv4
+	}
+
+	a := p.Masked().Addr().As4()
+	return NewPfx(convert.Uint32b(a[:]), uint8(p.Bits())), nil
+}
+
+// IPNet returns pfx as net.IPNet
+func (pfx *Prefix) IPNet() *net.IPNet {
+	return &net.IPNet{
+		IP:   net.IP(convert.Uint32Byte(pfx.addr)),
+		Mask: net.CIDRMask(int(pfx.pfxlen), 32),
+	}
+}
+
+// NetipPrefix returns pfx as netip.Prefix
+func (pfx *Prefix) NetipPrefix() netip.Prefix {
+	var a [4]byte
+	copy(a[:], convert.Uint32Byte(pfx.addr))
+	return netip.PrefixFrom(netip.AddrFrom4(a), int(pfx.pfxlen))
+}
+
+// MarshalText implements encoding.TextMarshaler using CIDR notation. It has a value receiver so prefixes
+// are marshaled as text when held by value as well.
+func (pfx Prefix) MarshalText() ([]byte, error) {
+	return []byte(pfx.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. The text has to be accepted by ParsePrefix.
+func (pfx *Prefix) UnmarshalText(text []byte) error {
+	p, err := ParsePrefix(string(text))
+	if err != nil {
+		return err
+	}
+
+	*pfx = *p
+	return nil
+}
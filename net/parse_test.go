@@ -0,0 +1,214 @@
+package net
+
+import (
+	"encoding/json"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePrefix(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected *Prefix
+		err      error
+		wantFail bool
+	}{
+		{
+			name:     "Valid prefix",
+			input:    "10.0.0.0/8",
+			expected: NewPfx(167772160, 8),
+		},
+		{
+			name:     "Host route",
+			input:    "10.0.0.1/32",
+			expected: NewPfx(167772161, 32),
+		},
+		{
+			name:     "Default route",
+			input:    "0.0.0.0/0",
+			expected: NewPfx(0, 0),
+		},
+		{
+			name:     "Host bits set",
+			input:    "10.0.0.1/8",
+			err:      ErrHostBitsSet,
+			wantFail: true,
+		},
+		{
+			name:     "Prefix length too long",
+			input:    "10.0.0.0/33",
+			err:      ErrInvalidPfxlen,
+			wantFail: true,
+		},
+		{
+			name:     "Negative prefix length",
+			input:    "10.0.0.0/-1",
+			err:      ErrInvalidPfxlen,
+			wantFail: true,
+		},
+		{
+			name:     "IPv6 prefix",
+			input:    "2001:db8::/32",
+			err:      ErrNotIPv4,
+			wantFail: true,
+		},
+		{
+			name:     "Missing prefix length",
+			input:    "10.0.0.0",
+			wantFail: true,
+		},
+		{
+			name:     "Invalid address",
+			input:    "10.0.0.256/24",
+			wantFail: true,
+		},
+	}
+
+	for _, test := range tests {
+		pfx, err := ParsePrefix(test.input)
+		if test.wantFail {
+			assert.Error(t, err, test.name)
+			if test.err != nil {
+				assert.ErrorIs(t, err, test.err, test.name)
+			}
+			continue
+		}
+
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.expected, pfx, test.name)
+		assert.Equal(t, test.input, pfx.String(), test.name)
+	}
+}
+
+func TestMaskedValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		pfx      *Prefix
+		masked   *Prefix
+		expected error
+	}{
+		{
+			name:   "Canonical",
+			pfx:    NewPfx(167772160, 8), // 10.0.0.0/8
+			masked: NewPfx(167772160, 8),
+		},
+		{
+			name:     "Host bits set",
+			pfx:      NewPfx(167772161, 8), // 10.0.0.1/8
+			masked:   NewPfx(167772160, 8),
+			expected: ErrHostBitsSet,
+		},
+		{
+			name:     "Default route with host bits set",
+			pfx:      NewPfx(167772161, 0),
+			masked:   NewPfx(0, 0),
+			expected: ErrHostBitsSet,
+		},
+		{
+			name:   "Host route",
+			pfx:    NewPfx(167772161, 32), // 10.0.0.1/32
+			masked: NewPfx(167772161, 32),
+		},
+		{
+			name:     "Invalid prefix length",
+			pfx:      NewPfx(167772160, 33),
+			expected: ErrInvalidPfxlen,
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, test.pfx.Validate(), test.name)
+		if test.masked != nil {
+			assert.Equal(t, test.masked, test.pfx.Masked(), test.name)
+			assert.NoError(t, test.pfx.Masked().Validate(), test.name)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	a := NewPfx(167772160, 8)  // 10.0.0.0/8
+	b := NewPfx(167772160, 16) // 10.0.0.0/16
+	c := NewPfx(184549376, 8)  // 11.0.0.0/8
+
+	assert.Equal(t, 0, a.Compare(NewPfx(167772160, 8)))
+	assert.Equal(t, -1, a.Compare(b))
+	assert.Equal(t, 1, b.Compare(a))
+	assert.Equal(t, -1, b.Compare(c))
+	assert.Equal(t, 1, c.Compare(a))
+}
+
+func TestStdlibConversion(t *testing.T) {
+	pfx := NewPfx(167772160, 12) // 10.0.0.0/12
+
+	_, ipNet, _ := net.ParseCIDR("10.0.0.0/12")
+	assert.Equal(t, ipNet.String(), pfx.IPNet().String())
+	res, err := NewPfxFromIPNet(ipNet)
+	assert.NoError(t, err)
+	assert.Equal(t, pfx, res)
+
+	p := netip.MustParsePrefix("10.0.0.0/12")
+	assert.Equal(t, p, pfx.NetipPrefix())
+	res, err = NewPfxFromNetipPrefix(p)
+	assert.NoError(t, err)
+	assert.Equal(t, pfx, res)
+
+	// IPv4-mapped IPv6 addresses are accepted with an IPv4 mask only
+	res, err = NewPfxFromIPNet(&net.IPNet{IP: net.ParseIP("10.0.0.0"), Mask: net.CIDRMask(12, 32)})
+	assert.NoError(t, err)
+	assert.Equal(t, pfx, res)
+
+	// Host bits are cleared
+	res, err = NewPfxFromIPNet(&net.IPNet{IP: net.IP{10, 1, 2, 3}, Mask: net.CIDRMask(12, 32)})
+	assert.NoError(t, err)
+	assert.Equal(t, pfx, res)
+
+	res, err = NewPfxFromNetipPrefix(netip.MustParsePrefix("10.1.2.3/12"))
+	assert.NoError(t, err)
+	assert.Equal(t, pfx, res)
+
+	_, ipNet, _ = net.ParseCIDR("2001:db8::/32")
+	_, err = NewPfxFromIPNet(ipNet)
+	assert.Equal(t, ErrNotIPv4, err)
+
+	_, err = NewPfxFromNetipPrefix(netip.MustParsePrefix("2001:db8::/32"))
+	assert.Equal(t, ErrNotIPv4, err)
+
+	_, err = NewPfxFromNetipPrefix(netip.Prefix{})
+	assert.Equal(t, ErrInvalidPfxlen, err)
+}
+
+func TestMarshalJSON(t *testing.T) {
+	type config struct {
+		Prefix   *Prefix
+		Prefixes []*Prefix
+		Value    Prefix
+		Values   []Prefix
+	}
+
+	c := config{
+		Prefix: NewPfx(167772160, 8), // 10.0.0.0/8
+		Prefixes: []*Prefix{
+			NewPfx(3232235520, 16), // 192.168.0.0/16
+			NewPfx(0, 0),
+		},
+		Value: *NewPfx(2886729728, 12), // 172.16.0.0/12
+		Values: []Prefix{
+			*NewPfx(167772160, 8),
+		},
+	}
+
+	b, err := json.Marshal(c)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"Prefix":"10.0.0.0/8","Prefixes":["192.168.0.0/16","0.0.0.0/0"],"Value":"172.16.0.0/12","Values":["10.0.0.0/8"]}`, string(b))
+
+	var res config
+	assert.NoError(t, json.Unmarshal(b, &res))
+	assert.Equal(t, c, res)
+
+	err = json.Unmarshal([]byte(`{"Prefix":"10.0.0.1/8"}`), &res)
+	assert.ErrorIs(t, err, ErrHostBitsSet)
+}
@@ -2,6 +2,7 @@ package net
 
 import (
 	"fmt"
+	"math"
 	"net"
 
 	"github.com/taktv6/tflow2/convert"
@@ -42,10 +43,51 @@ func (pfx *Prefix) Contains(x *Prefix) bool {
 		return false
 	}
 
-	mask := (uint32(1) << (32 - pfx.pfxlen))
+	mask := uint32(math.MaxUint32) << (32 - pfx.pfxlen)
 	return (pfx.addr & mask) == (x.addr & mask)
 }
 
+// ContainsAddr checks if addr is within pfx
+func (pfx *Prefix) ContainsAddr(addr uint32) bool {
+	mask := uint32(math.MaxUint32) << (32 - pfx.pfxlen)
+	return (pfx.addr & mask) == (addr & mask)
+}
+
+// Masked returns pfx with all bits beyond the prefix length cleared
+func (pfx *Prefix) Masked() *Prefix {
+	return NewPfx(pfx.addr&netmask(pfx.pfxlen), pfx.pfxlen)
+}
+
+// Validate checks if pfx has a valid prefix length and no host bits set
+func (pfx *Prefix) Validate() error {
+	if pfx.pfxlen > 32 {
+		return ErrInvalidPfxlen
+	}
+
+	if pfx.addr&^netmask(pfx.pfxlen) != 0 {
+		return ErrHostBitsSet
+	}
+
+	return nil
+}
+
+// Compare orders prefixes by address and prefix length. It returns -1 if pfx is ordered before x,
+// 1 if it is ordered after x and 0 if both are equal.
+func (pfx *Prefix) Compare(x *Prefix) int {
+	switch {
+	case pfx.addr < x.addr:
+		return -1
+	case pfx.addr > x.addr:
+		return 1
+	case pfx.pfxlen < x.pfxlen:
+		return -1
+	case pfx.pfxlen > x.pfxlen:
+		return 1
+	}
+
+	return 0
+}
+
 // Equal checks if pfx and x are equal
 func (pfx *Prefix) Equal(x *Prefix) bool {
 	return *pfx == *x
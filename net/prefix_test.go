@@ -179,6 +179,18 @@ func TestContains(t *testing.T) {
 			},
 			expected: false,
 		},
+		{
+			name: "Test 7",
+			a: &Prefix{
+				addr:   167772160, // 10.0.0.0/8
+				pfxlen: 8,
+			},
+			b: &Prefix{
+				addr:   201326592, // 12.0.0.0/16
+				pfxlen: 16,
+			},
+			expected: false,
+		},
 	}
 
 	for _, test := range tests {
@@ -189,6 +201,53 @@ func TestContains(t *testing.T) {
 	}
 }
 
+func TestContainsAddr(t *testing.T) {
+	tests := []struct {
+		name     string
+		pfx      *Prefix
+		addr     uint32
+		expected bool
+	}{
+		{
+			name:     "Default route",
+			pfx:      NewPfx(0, 0),
+			addr:     167772161, // 10.0.0.1
+			expected: true,
+		},
+		{
+			name:     "Within prefix",
+			pfx:      NewPfx(167772160, 8), // 10.0.0.0/8
+			addr:     184549375,            // 10.255.255.255
+			expected: true,
+		},
+		{
+			name:     "Outside of prefix",
+			pfx:      NewPfx(167772160, 8), // 10.0.0.0/8
+			addr:     184549376,            // 11.0.0.0
+			expected: false,
+		},
+		{
+			name:     "Host route",
+			pfx:      NewPfx(167772161, 32), // 10.0.0.1/32
+			addr:     167772161,             // 10.0.0.1
+			expected: true,
+		},
+		{
+			name:     "Other host",
+			pfx:      NewPfx(167772161, 32), // 10.0.0.1/32
+			addr:     167772162,             // 10.0.0.2
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		res := test.pfx.ContainsAddr(test.addr)
+		if res != test.expected {
+			t.Errorf("Unexpected result for %q: Got %v Expected %v", test.name, res, test.expected)
+		}
+	}
+}
+
 func TestMin(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -0,0 +1,288 @@
+package packet
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxSegmentLength is the maximum number of ASNs in a single AS path segment
+const maxSegmentLength = 255
+
+func validASPathSegmentType(t uint8) bool {
+	switch t {
+	case ASSet, ASSequence, ASConfedSequence, ASConfedSet:
+		return true
+	}
+
+	return false
+}
+
+// isConfedSegmentType checks if t is a segment type only valid within a confederation (RFC5065)
+func isConfedSegmentType(t uint8) bool {
+	return t == ASConfedSequence || t == ASConfedSet
+}
+
+// Length returns the length of an AS path as considered by the decision process.
+// An AS_SET counts as one AS no matter how many ASNs it contains (RFC4271 9.1.2.2).
+// Confederation segments are not counted (RFC5065 5.3).
+func (a ASPath) Length() uint16 {
+	l := uint16(0)
+	for _, segment := range a {
+		if isConfedSegmentType(segment.Type) {
+			continue
+		}
+
+		if segment.Type == ASSet {
+			l++
+			continue
+		}
+		l += uint16(len(segment.ASNs))
+	}
+
+	return l
+}
+
+// Copy creates a deep copy of an AS path
+func (a ASPath) Copy() ASPath {
+	if a == nil {
+		return nil
+	}
+
+	res := make(ASPath, len(a))
+	for i, segment := range a {
+		res[i] = ASPathSegment{
+			Type:  segment.Type,
+			Count: segment.Count,
+			ASNs:  append([]uint32(nil), segment.ASNs...),
+		}
+	}
+
+	return res
+}
+
+// String returns a string representation of an AS path
+func (a ASPath) String() string {
+	parts := make([]string, 0, len(a))
+	for _, segment := range a {
+		asns := make([]string, len(segment.ASNs))
+		for i, asn := range segment.ASNs {
+			asns[i] = fmt.Sprintf("%d", asn)
+		}
+
+		switch segment.Type {
+		case ASSet:
+			parts = append(parts, fmt.Sprintf("{%s}", strings.Join(asns, " ")))
+		case ASConfedSequence:
+			parts = append(parts, fmt.Sprintf("(%s)", strings.Join(asns, " ")))
+		case ASConfedSet:
+			parts = append(parts, fmt.Sprintf("[%s]", strings.Join(asns, " ")))
+		default:
+			parts = append(parts, strings.Join(asns, " "))
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// Prepend returns a copy of the AS path with asn prepended in a segment of type segmentType.
+// asn is added to the first segment if it is of that type and not full yet.
+func (a ASPath) Prepend(segmentType uint8, asn uint32) ASPath {
+	res := a.Copy()
+	if len(res) > 0 && res[0].Type == segmentType && len(res[0].ASNs) < maxSegmentLength {
+		res[0].ASNs = append([]uint32{asn}, res[0].ASNs...)
+		res[0].Count = uint8(len(res[0].ASNs))
+		return res
+	}
+
+	return append(ASPath{
+		{
+			Type:  segmentType,
+			Count: 1,
+			ASNs:  []uint32{asn},
+		},
+	}, res...)
+}
+
+// HasConfedSegments checks if the AS path contains AS_CONFED_SEQUENCE or AS_CONFED_SET segments
+func (a ASPath) HasConfedSegments() bool {
+	for _, segment := range a {
+		if isConfedSegmentType(segment.Type) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ConfedContains checks if asn is part of any confederation segment of the AS path
+func (a ASPath) ConfedContains(asn uint32) bool {
+	for _, segment := range a {
+		if !isConfedSegmentType(segment.Type) {
+			continue
+		}
+
+		for _, x := range segment.ASNs {
+			if x == asn {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// StripConfedSegments returns a copy of the AS path without any confederation
+// segments as required when leaving the confederation (RFC5065 4.1)
+func (a ASPath) StripConfedSegments() ASPath {
+	res := make(ASPath, 0, len(a))
+	for _, segment := range a.Copy() {
+		if isConfedSegmentType(segment.Type) {
+			continue
+		}
+		res = append(res, segment)
+	}
+
+	return res
+}
+
+// FirstAS returns the leftmost AS of the AS path not being part of a confederation segment.
+// It returns 0 if the path does not start with an AS_SEQUENCE.
+func (a ASPath) FirstAS() uint32 {
+	for _, segment := range a {
+		if isConfedSegmentType(segment.Type) {
+			continue
+		}
+
+		if segment.Type != ASSequence || len(segment.ASNs) == 0 {
+			return 0
+		}
+
+		return segment.ASNs[0]
+	}
+
+	return 0
+}
+
+// Occurrences returns how often asn is in the AS path. Confederation segments are not considered.
+func (a ASPath) Occurrences(asn uint32) int {
+	n := 0
+	for _, segment := range a {
+		if isConfedSegmentType(segment.Type) {
+			continue
+		}
+
+		for _, x := range segment.ASNs {
+			if x == asn {
+				n++
+			}
+		}
+	}
+
+	return n
+}
+
+// OriginAS returns the AS that originated a route with this AS path (RFC6811 2). It is the rightmost AS of the
+// final AS_SEQUENCE or 0 (NONE) if the path ends with an AS_SET. ok is false if the path contains no ASNs outside
+// of confederation segments, i.e. the route originated in the local AS.
+func (a ASPath) OriginAS() (asn uint32, ok bool) {
+	for i := len(a) - 1; i >= 0; i-- {
+		if isConfedSegmentType(a[i].Type) || len(a[i].ASNs) == 0 {
+			continue
+		}
+
+		if a[i].Type != ASSequence {
+			return 0, true
+		}
+
+		return a[i].ASNs[len(a[i].ASNs)-1], true
+	}
+
+	return 0, false
+}
+
+// AggregateASPaths returns the AS path of a route aggregating routes with the AS paths paths (RFC4271 9.2.2.2).
+// It starts with the AS_SEQUENCE all paths have in common followed by an AS_SET of all other ASNs.
+// Confederation segments are not considered.
+func AggregateASPaths(paths []ASPath) ASPath {
+	var common []uint32
+	for i, p := range paths {
+		seq := leadingSequence(p)
+		if i == 0 {
+			common = seq
+			continue
+		}
+
+		n := 0
+		for n < len(common) && n < len(seq) && common[n] == seq[n] {
+			n++
+		}
+		common = common[:n]
+	}
+
+	seen := make(map[uint32]struct{}, len(common))
+	for _, asn := range common {
+		seen[asn] = struct{}{}
+	}
+
+	set := make([]uint32, 0)
+	for _, p := range paths {
+		for _, segment := range p {
+			if isConfedSegmentType(segment.Type) {
+				continue
+			}
+
+			for _, asn := range segment.ASNs {
+				if _, ok := seen[asn]; ok {
+					continue
+				}
+				seen[asn] = struct{}{}
+				set = append(set, asn)
+			}
+		}
+	}
+	sort.Slice(set, func(i, j int) bool {
+		return set[i] < set[j]
+	})
+
+	res := ASPath{}
+	res = appendSegments(res, ASSequence, common)
+	res = appendSegments(res, ASSet, set)
+	return res
+}
+
+// leadingSequence returns the ASNs of the AS_SEQUENCE segments an AS path starts with
+func leadingSequence(a ASPath) []uint32 {
+	res := make([]uint32, 0)
+	for _, segment := range a {
+		if isConfedSegmentType(segment.Type) {
+			continue
+		}
+
+		if segment.Type != ASSequence {
+			break
+		}
+		res = append(res, segment.ASNs...)
+	}
+
+	return res
+}
+
+// appendSegments appends asns to a in segments of type segmentType holding up to maxSegmentLength ASNs each
+func appendSegments(a ASPath, segmentType uint8, asns []uint32) ASPath {
+	for len(asns) > 0 {
+		n := len(asns)
+		if n > maxSegmentLength {
+			n = maxSegmentLength
+		}
+
+		a = append(a, ASPathSegment{
+			Type:  segmentType,
+			Count: uint8(n),
+			ASNs:  append([]uint32(nil), asns[:n]...),
+		})
+		asns = asns[n:]
+	}
+
+	return a
+}
@@ -0,0 +1,419 @@
+package packet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestASPathLength(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    ASPath
+		expected uint16
+	}{
+		{
+			name:     "Empty path",
+			input:    ASPath{},
+			expected: 0,
+		},
+		{
+			name: "Sequence and set",
+			input: ASPath{
+				{
+					Type: ASSequence,
+					ASNs: []uint32{15169, 3320},
+				},
+				{
+					Type: ASSet,
+					ASNs: []uint32{100, 200, 300},
+				},
+			},
+			expected: 3,
+		},
+		{
+			name: "Confederation segments are not counted",
+			input: ASPath{
+				{
+					Type: ASConfedSequence,
+					ASNs: []uint32{65000, 65001},
+				},
+				{
+					Type: ASConfedSet,
+					ASNs: []uint32{65002},
+				},
+				{
+					Type: ASSequence,
+					ASNs: []uint32{3320},
+				},
+			},
+			expected: 1,
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, test.input.Length(), test.name)
+	}
+}
+
+func TestASPathString(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    ASPath
+		expected string
+	}{
+		{
+			name:     "Empty path",
+			input:    ASPath{},
+			expected: "",
+		},
+		{
+			name: "Sequence and set",
+			input: ASPath{
+				{
+					Type: ASSequence,
+					ASNs: []uint32{15169, 3320},
+				},
+				{
+					Type: ASSet,
+					ASNs: []uint32{100, 200},
+				},
+			},
+			expected: "15169 3320 {100 200}",
+		},
+		{
+			name: "Confederation segments",
+			input: ASPath{
+				{
+					Type: ASConfedSequence,
+					ASNs: []uint32{65000, 65001},
+				},
+				{
+					Type: ASConfedSet,
+					ASNs: []uint32{65002, 65003},
+				},
+				{
+					Type: ASSequence,
+					ASNs: []uint32{3320},
+				},
+			},
+			expected: "(65000 65001) [65002 65003] 3320",
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, test.input.String(), test.name)
+	}
+}
+
+func TestASPathCopy(t *testing.T) {
+	a := ASPath{
+		{
+			Type: ASSequence,
+			ASNs: []uint32{15169, 3320},
+		},
+	}
+
+	b := a.Copy()
+	assert.Equal(t, a, b)
+
+	b[0].ASNs[0] = 1
+	assert.Equal(t, uint32(15169), a[0].ASNs[0])
+}
+
+func TestASPathPrepend(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       ASPath
+		segmentType uint8
+		asn         uint32
+		expected    ASPath
+	}{
+		{
+			name:        "Empty path",
+			input:       ASPath{},
+			segmentType: ASSequence,
+			asn:         100,
+			expected: ASPath{
+				{
+					Type:  ASSequence,
+					Count: 1,
+					ASNs:  []uint32{100},
+				},
+			},
+		},
+		{
+			name: "Same segment type",
+			input: ASPath{
+				{
+					Type:  ASSequence,
+					Count: 1,
+					ASNs:  []uint32{200},
+				},
+			},
+			segmentType: ASSequence,
+			asn:         100,
+			expected: ASPath{
+				{
+					Type:  ASSequence,
+					Count: 2,
+					ASNs:  []uint32{100, 200},
+				},
+			},
+		},
+		{
+			name: "Different segment type",
+			input: ASPath{
+				{
+					Type:  ASSequence,
+					Count: 1,
+					ASNs:  []uint32{200},
+				},
+			},
+			segmentType: ASConfedSequence,
+			asn:         65000,
+			expected: ASPath{
+				{
+					Type:  ASConfedSequence,
+					Count: 1,
+					ASNs:  []uint32{65000},
+				},
+				{
+					Type:  ASSequence,
+					Count: 1,
+					ASNs:  []uint32{200},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		res := test.input.Prepend(test.segmentType, test.asn)
+		assert.Equal(t, test.expected, res, test.name)
+	}
+}
+
+func TestASPathStripConfedSegments(t *testing.T) {
+	a := ASPath{
+		{
+			Type: ASConfedSequence,
+			ASNs: []uint32{65000},
+		},
+		{
+			Type: ASSequence,
+			ASNs: []uint32{3320},
+		},
+	}
+
+	assert.True(t, a.HasConfedSegments())
+	assert.True(t, a.ConfedContains(65000))
+	assert.False(t, a.ConfedContains(3320))
+
+	res := a.StripConfedSegments()
+	assert.False(t, res.HasConfedSegments())
+	assert.Equal(t, ASPath{
+		{
+			Type: ASSequence,
+			ASNs: []uint32{3320},
+		},
+	}, res)
+	assert.Len(t, a, 2)
+}
+
+func TestASPathFirstAS(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    ASPath
+		expected uint32
+	}{
+		{
+			name:     "Empty path",
+			input:    ASPath{},
+			expected: 0,
+		},
+		{
+			name: "Confederation segment first",
+			input: ASPath{
+				{
+					Type: ASConfedSequence,
+					ASNs: []uint32{65000},
+				},
+				{
+					Type: ASSequence,
+					ASNs: []uint32{3320, 15169},
+				},
+			},
+			expected: 3320,
+		},
+		{
+			name: "Set first",
+			input: ASPath{
+				{
+					Type: ASSet,
+					ASNs: []uint32{3320, 15169},
+				},
+			},
+			expected: 0,
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, test.input.FirstAS(), test.name)
+	}
+}
+
+func TestASPathOccurrences(t *testing.T) {
+	a := ASPath{
+		{
+			Type: ASConfedSequence,
+			ASNs: []uint32{100},
+		},
+		{
+			Type: ASSequence,
+			ASNs: []uint32{100, 200, 100},
+		},
+		{
+			Type: ASSet,
+			ASNs: []uint32{100, 300},
+		},
+	}
+
+	assert.Equal(t, 3, a.Occurrences(100))
+	assert.Equal(t, 1, a.Occurrences(300))
+	assert.Equal(t, 0, a.Occurrences(400))
+}
+
+func TestASPathOriginAS(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      ASPath
+		expected   uint32
+		expectedOK bool
+	}{
+		{
+			name:       "Empty path",
+			input:      ASPath{},
+			expected:   0,
+			expectedOK: false,
+		},
+		{
+			name: "Sequence",
+			input: ASPath{
+				{
+					Type: ASSequence,
+					ASNs: []uint32{3320, 15169},
+				},
+			},
+			expected:   15169,
+			expectedOK: true,
+		},
+		{
+			name: "Set last",
+			input: ASPath{
+				{
+					Type: ASSequence,
+					ASNs: []uint32{3320},
+				},
+				{
+					Type: ASSet,
+					ASNs: []uint32{100, 200},
+				},
+			},
+			expected:   0,
+			expectedOK: true,
+		},
+		{
+			name: "Confederation segments only",
+			input: ASPath{
+				{
+					Type: ASConfedSequence,
+					ASNs: []uint32{65000},
+				},
+			},
+			expected:   0,
+			expectedOK: false,
+		},
+	}
+
+	for _, test := range tests {
+		asn, ok := test.input.OriginAS()
+		assert.Equal(t, test.expected, asn, test.name)
+		assert.Equal(t, test.expectedOK, ok, test.name)
+	}
+}
+
+func TestAggregateASPaths(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []ASPath
+		expected ASPath
+	}{
+		{
+			name: "Identical paths",
+			input: []ASPath{
+				{
+					{Type: ASSequence, Count: 2, ASNs: []uint32{100, 200}},
+				},
+				{
+					{Type: ASSequence, Count: 2, ASNs: []uint32{100, 200}},
+				},
+			},
+			expected: ASPath{
+				{Type: ASSequence, Count: 2, ASNs: []uint32{100, 200}},
+			},
+		},
+		{
+			name: "Common leading sequence",
+			input: []ASPath{
+				{
+					{Type: ASSequence, Count: 3, ASNs: []uint32{100, 200, 400}},
+				},
+				{
+					{Type: ASSequence, Count: 3, ASNs: []uint32{100, 300, 200}},
+				},
+			},
+			expected: ASPath{
+				{Type: ASSequence, Count: 1, ASNs: []uint32{100}},
+				{Type: ASSet, Count: 3, ASNs: []uint32{200, 300, 400}},
+			},
+		},
+		{
+			name: "Nothing in common",
+			input: []ASPath{
+				{
+					{Type: ASSequence, Count: 1, ASNs: []uint32{200}},
+					{Type: ASSet, Count: 2, ASNs: []uint32{500, 100}},
+				},
+				{},
+			},
+			expected: ASPath{
+				{Type: ASSet, Count: 3, ASNs: []uint32{100, 200, 500}},
+			},
+		},
+		{
+			name: "Confederation segments",
+			input: []ASPath{
+				{
+					{Type: ASConfedSequence, Count: 1, ASNs: []uint32{65001}},
+					{Type: ASSequence, Count: 2, ASNs: []uint32{100, 200}},
+				},
+				{
+					{Type: ASSequence, Count: 2, ASNs: []uint32{100, 300}},
+				},
+			},
+			expected: ASPath{
+				{Type: ASSequence, Count: 1, ASNs: []uint32{100}},
+				{Type: ASSet, Count: 2, ASNs: []uint32{200, 300}},
+			},
+		},
+		{
+			name:     "No paths",
+			input:    []ASPath{},
+			expected: ASPath{},
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, AggregateASPaths(test.input), test.name)
+	}
+}
@@ -9,10 +9,13 @@ const (
 	MinLen    = 19
 	MaxLen    = 4096
 
+	RouteRefreshLen = 23
+
 	OpenMsg         = 1
 	UpdateMsg       = 2
 	NotificationMsg = 3
 	KeepaliveMsg    = 4
+	RouteRefreshMsg = 5
 
 	MessageHeaderError      = 1
 	OpenMessageError        = 2
@@ -20,6 +23,7 @@ const (
 	HoldTimeExpired         = 4
 	FiniteStateMachineError = 5
 	Cease                   = 6
+	RouteRefreshMsgError    = 7
 
 	// Msg Header Errors
 	ConnectionNotSync = 1
@@ -33,6 +37,7 @@ const (
 	UnsupportedOptionalParameter = 4
 	DeprecatedOpenMsgError5      = 5
 	UnacceptableHoldTime         = 6
+	RoleMismatch                 = 11 // RFC9234
 
 	// Update Msg Errors
 	MalformedAttributeList    = 1
@@ -47,23 +52,79 @@ const (
 	InvalidNetworkField       = 10
 	MalformedASPath           = 11
 
+	// Route Refresh Msg Errors (RFC7313)
+	InvalidMessageLength = 1
+
 	// Attribute Type Codes
-	OriginAttr     = 1
-	ASPathAttr     = 2
-	NextHopAttr    = 3
-	MEDAttr        = 4
-	LocalPrefAttr  = 5
-	AtomicAggrAttr = 6
-	AggregatorAttr = 7
+	OriginAttr                   = 1
+	ASPathAttr                   = 2
+	NextHopAttr                  = 3
+	MEDAttr                      = 4
+	LocalPrefAttr                = 5
+	AtomicAggrAttr               = 6
+	AggregatorAttr               = 7
+	CommunitiesAttr              = 8
+	OriginatorIDAttr             = 9
+	ClusterListAttr              = 10
+	MultiProtocolUnreachNLRIAttr = 15
+	OnlyToCustomerAttr           = 35 // RFC9234
 
 	// ORIGIN values
 	IGP        = 0
 	EGP        = 1
 	INCOMPLETE = 2
 
+	// Optional Parameter Types
+	CapabilitiesParam = 2
+
+	// Capability Codes
+	MultiProtocolCapability            = 1
+	RouteRefreshCapability             = 2
+	RoleCapability                     = 9
+	GracefulRestartCapability          = 64
+	FourOctetASCapability              = 65
+	AddPathCapability                  = 69
+	EnhancedRouteRefreshCapability     = 70
+	LongLivedGracefulRestartCapability = 71
+
+	// Graceful Restart Capability Flags (RFC4724)
+	RestartStateFlag    = 0x8000
+	RestartTimeMask     = 0x0fff
+	ForwardingStateFlag = 0x80
+
+	// Address Family Identifiers
+	IPv4AFI = 1
+	IPv6AFI = 2
+
+	// Subsequent Address Family Identifiers
+	UnicastSAFI = 1
+
+	// ROUTE-REFRESH Message Subtypes (RFC7313)
+	NormalRouteRefresh      = 0
+	BeginningOfRouteRefresh = 1
+	EndOfRouteRefresh       = 2
+
+	// ADD-PATH Send/Receive values (RFC7911)
+	AddPathReceive     = 1
+	AddPathSend        = 2
+	AddPathSendReceive = 3
+
+	// Well-known Communities
+	LLGRStaleCommunity = 0xFFFF0006 // RFC9494
+	NoLLGRCommunity    = 0xFFFF0007 // RFC9494
+
+	// BGP Role values (RFC9234)
+	RoleProvider = 0
+	RoleRS       = 1
+	RoleRSClient = 2
+	RoleCustomer = 3
+	RolePeer     = 4
+
 	// ASPath Segment Types
-	ASSet      = 1
-	ASSequence = 2
+	ASSet            = 1
+	ASSequence       = 2
+	ASConfedSequence = 3 // RFC5065
+	ASConfedSet      = 4 // RFC5065
 
 	// NOTIFICATION Cease error SubCodes (RFC4486)
 	MaxPrefReached                = 1
@@ -71,9 +132,10 @@ const (
 	PeerDeconfigured              = 3
 	AdminReset                    = 4
 	ConnectionRejected            = 5
-	OtherConfigChange             = 8
+	OtherConfigChange             = 6
 	ConnectionCollisionResolution = 7
 	OutOfResoutces                = 8
+	HardReset                     = 9 // RFC8538
 )
 
 type BGPError struct {
@@ -102,6 +164,63 @@ type BGPOpen struct {
 	HoldTime      uint16
 	BGPIdentifier uint32
 	OptParmLen    uint8
+	OptParams     []OptParam
+}
+
+type OptParam struct {
+	Type   uint8
+	Length uint8
+	Value  interface{}
+}
+
+type Capabilities []Capability
+
+type Capability struct {
+	Code   uint8
+	Length uint8
+	Value  interface{}
+}
+
+type MultiProtocolCapabilityValue struct {
+	AFI  uint16
+	SAFI uint8
+}
+
+type GracefulRestartCapabilityValue struct {
+	Restarting  bool
+	RestartTime uint16
+	AFIs        []GracefulRestartAFI
+}
+
+type GracefulRestartAFI struct {
+	AFI                 uint16
+	SAFI                uint8
+	ForwardingPreserved bool
+}
+
+type AddPathCapabilityValue struct {
+	AFIs []AddPathAFI
+}
+
+type AddPathAFI struct {
+	AFI         uint16
+	SAFI        uint8
+	SendReceive uint8
+}
+
+type RoleCapabilityValue struct {
+	Role uint8
+}
+
+type LongLivedGracefulRestartCapabilityValue struct {
+	AFIs []LongLivedGracefulRestartAFI
+}
+
+type LongLivedGracefulRestartAFI struct {
+	AFI                 uint16
+	SAFI                uint8
+	ForwardingPreserved bool
+	StaleTime           uint32 // 24 bits
 }
 
 type BGPNotification struct {
@@ -109,6 +228,12 @@ type BGPNotification struct {
 	ErrorSubcode uint8
 }
 
+type BGPRouteRefresh struct {
+	AFI     uint16
+	SubType uint8
+	SAFI    uint8
+}
+
 type BGPUpdate struct {
 	WithdrawnRoutesLen uint16
 	WithdrawnRoutes    *NLRI
@@ -128,10 +253,17 @@ type PathAttribute struct {
 	Next           *PathAttribute
 }
 
+type MultiProtocolUnreachNLRI struct {
+	AFI             uint16
+	SAFI            uint8
+	WithdrawnRoutes []byte
+}
+
 type NLRI struct {
-	IP     interface{}
-	Pfxlen uint8
-	Next   *NLRI
+	PathIdentifier uint32
+	IP             interface{}
+	Pfxlen         uint8
+	Next           *NLRI
 }
 
 type ASPath []ASPathSegment
@@ -0,0 +1,380 @@
+package packet
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/taktv6/tflow2/convert"
+)
+
+// Capabilities returns all capabilities announced in an OPEN message
+func (msg *BGPOpen) Capabilities() Capabilities {
+	caps := make(Capabilities, 0)
+	for _, o := range msg.OptParams {
+		if c, ok := o.Value.(Capabilities); ok {
+			caps = append(caps, c...)
+		}
+	}
+
+	return caps
+}
+
+// Has checks if a capability with code is in caps
+func (caps Capabilities) Has(code uint8) bool {
+	_, ok := caps.Get(code)
+	return ok
+}
+
+// Get returns the first capability with code in caps
+func (caps Capabilities) Get(code uint8) (Capability, bool) {
+	for _, c := range caps {
+		if c.Code == code {
+			return c, true
+		}
+	}
+
+	return Capability{}, false
+}
+
+// Preserved checks if the forwarding state for afi/safi has been preserved across a restart
+func (gr GracefulRestartCapabilityValue) Preserved(afi uint16, safi uint8) bool {
+	for _, a := range gr.AFIs {
+		if a.AFI == afi && a.SAFI == safi {
+			return a.ForwardingPreserved
+		}
+	}
+
+	return false
+}
+
+// Supports checks if graceful restart has been announced for afi/safi
+func (gr GracefulRestartCapabilityValue) Supports(afi uint16, safi uint8) bool {
+	for _, a := range gr.AFIs {
+		if a.AFI == afi && a.SAFI == safi {
+			return true
+		}
+	}
+
+	return false
+}
+
+func decodeOptParams(buf *bytes.Buffer, optParmLen uint8) ([]OptParam, error) {
+	optParams := make([]OptParam, 0)
+
+	p := uint16(0)
+	for p < uint16(optParmLen) {
+		o := OptParam{}
+		err := decode(buf, []interface{}{&o.Type, &o.Length})
+		if err != nil {
+			return nil, err
+		}
+
+		p += 2 + uint16(o.Length)
+		if p > uint16(optParmLen) {
+			return nil, fmt.Errorf("Optional parameter %d exceeds optional parameters length %d", o.Type, optParmLen)
+		}
+
+		switch o.Type {
+		case CapabilitiesParam:
+			caps, err := decodeCapabilities(buf, o.Length)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to decode capabilities: %v", err)
+			}
+			o.Value = caps
+		default:
+			return nil, BGPError{
+				ErrorCode:    OpenMessageError,
+				ErrorSubCode: UnsupportedOptionalParameter,
+				ErrorStr:     fmt.Sprintf("Unsupported optional parameter: %d", o.Type),
+			}
+		}
+
+		optParams = append(optParams, o)
+	}
+
+	return optParams, nil
+}
+
+func decodeCapabilities(buf *bytes.Buffer, length uint8) (Capabilities, error) {
+	caps := make(Capabilities, 0)
+
+	// Computed in uint16 as the lengths of the capabilities may add up to more than 255
+	p := uint16(0)
+	for p < uint16(length) {
+		c, err := decodeCapability(buf)
+		if err != nil {
+			return nil, err
+		}
+
+		p += 2 + uint16(c.Length)
+		if p > uint16(length) {
+			return nil, fmt.Errorf("Capability %d exceeds capabilities length %d", c.Code, length)
+		}
+
+		caps = append(caps, c)
+	}
+
+	return caps, nil
+}
+
+func decodeCapability(buf *bytes.Buffer) (Capability, error) {
+	c := Capability{}
+	err := decode(buf, []interface{}{&c.Code, &c.Length})
+	if err != nil {
+		return c, err
+	}
+
+	switch c.Code {
+	case MultiProtocolCapability:
+		if c.Length < 4 {
+			return c, fmt.Errorf("Invalid multi protocol capability length: %d", c.Length)
+		}
+
+		mp := MultiProtocolCapabilityValue{}
+		reserved := uint8(0)
+		err := decode(buf, []interface{}{&mp.AFI, &reserved, &mp.SAFI})
+		if err != nil {
+			return c, fmt.Errorf("Unable to decode multi protocol capability: %v", err)
+		}
+		c.Value = mp
+
+		err = dumpNBytes(buf, uint16(c.Length)-4)
+		if err != nil {
+			return c, err
+		}
+	case GracefulRestartCapability:
+		gr, err := decodeGracefulRestartCapability(buf, c.Length)
+		if err != nil {
+			return c, fmt.Errorf("Unable to decode graceful restart capability: %v", err)
+		}
+		c.Value = gr
+	case AddPathCapability:
+		ap, err := decodeAddPathCapability(buf, c.Length)
+		if err != nil {
+			return c, fmt.Errorf("Unable to decode ADD-PATH capability: %v", err)
+		}
+		c.Value = ap
+	case RoleCapability:
+		if c.Length != 1 {
+			return c, fmt.Errorf("Invalid BGP role capability length: %d", c.Length)
+		}
+
+		r := RoleCapabilityValue{}
+		err := decode(buf, []interface{}{&r.Role})
+		if err != nil {
+			return c, fmt.Errorf("Unable to decode BGP role capability: %v", err)
+		}
+		c.Value = r
+	case LongLivedGracefulRestartCapability:
+		llgr, err := decodeLongLivedGracefulRestartCapability(buf, c.Length)
+		if err != nil {
+			return c, fmt.Errorf("Unable to decode long-lived graceful restart capability: %v", err)
+		}
+		c.Value = llgr
+	case RouteRefreshCapability, EnhancedRouteRefreshCapability:
+		// These capabilities do not carry any value
+		err := dumpNBytes(buf, uint16(c.Length))
+		if err != nil {
+			return c, err
+		}
+	default:
+		// Capabilities we do not know are kept as is
+		raw := make([]byte, c.Length)
+		err := decode(buf, []interface{}{raw})
+		if err != nil {
+			return c, err
+		}
+		c.Value = raw
+	}
+
+	return c, nil
+}
+
+func decodeGracefulRestartCapability(buf *bytes.Buffer, length uint8) (GracefulRestartCapabilityValue, error) {
+	gr := GracefulRestartCapabilityValue{
+		AFIs: make([]GracefulRestartAFI, 0),
+	}
+
+	if length < 2 {
+		return gr, fmt.Errorf("Invalid length: %d", length)
+	}
+
+	flagsAndTime := uint16(0)
+	err := decode(buf, []interface{}{&flagsAndTime})
+	if err != nil {
+		return gr, err
+	}
+	gr.Restarting = flagsAndTime&RestartStateFlag != 0
+	gr.RestartTime = flagsAndTime & RestartTimeMask
+
+	p := uint8(2)
+	for p+4 <= length {
+		a := GracefulRestartAFI{}
+		flags := uint8(0)
+		err := decode(buf, []interface{}{&a.AFI, &a.SAFI, &flags})
+		if err != nil {
+			return gr, err
+		}
+		a.ForwardingPreserved = flags&ForwardingStateFlag != 0
+		p += 4
+
+		gr.AFIs = append(gr.AFIs, a)
+	}
+
+	return gr, dumpNBytes(buf, uint16(length-p))
+}
+
+func decodeAddPathCapability(buf *bytes.Buffer, length uint8) (AddPathCapabilityValue, error) {
+	ap := AddPathCapabilityValue{
+		AFIs: make([]AddPathAFI, 0),
+	}
+
+	if length%4 != 0 {
+		return ap, fmt.Errorf("Invalid length: %d", length)
+	}
+
+	for p := uint8(0); p < length; p += 4 {
+		a := AddPathAFI{}
+		err := decode(buf, []interface{}{&a.AFI, &a.SAFI, &a.SendReceive})
+		if err != nil {
+			return ap, err
+		}
+
+		ap.AFIs = append(ap.AFIs, a)
+	}
+
+	return ap, nil
+}
+
+// SendReceive returns the Send/Receive value announced for afi/safi. It is 0 if afi/safi is not announced.
+func (ap AddPathCapabilityValue) SendReceive(afi uint16, safi uint8) uint8 {
+	for _, a := range ap.AFIs {
+		if a.AFI == afi && a.SAFI == safi {
+			return a.SendReceive
+		}
+	}
+
+	return 0
+}
+
+func decodeLongLivedGracefulRestartCapability(buf *bytes.Buffer, length uint8) (LongLivedGracefulRestartCapabilityValue, error) {
+	llgr := LongLivedGracefulRestartCapabilityValue{
+		AFIs: make([]LongLivedGracefulRestartAFI, 0),
+	}
+
+	if length%7 != 0 {
+		return llgr, fmt.Errorf("Invalid length: %d", length)
+	}
+
+	for p := uint8(0); p < length; p += 7 {
+		a := LongLivedGracefulRestartAFI{}
+		flags := uint8(0)
+		staleTime := [3]byte{}
+		err := decode(buf, []interface{}{&a.AFI, &a.SAFI, &flags, &staleTime})
+		if err != nil {
+			return llgr, err
+		}
+		a.ForwardingPreserved = flags&ForwardingStateFlag != 0
+		a.StaleTime = uint32(staleTime[0])<<16 | uint32(staleTime[1])<<8 | uint32(staleTime[2])
+
+		llgr.AFIs = append(llgr.AFIs, a)
+	}
+
+	return llgr, nil
+}
+
+// Get returns the long-lived graceful restart parameters announced for afi/safi
+func (llgr LongLivedGracefulRestartCapabilityValue) Get(afi uint16, safi uint8) (LongLivedGracefulRestartAFI, bool) {
+	for _, a := range llgr.AFIs {
+		if a.AFI == afi && a.SAFI == safi {
+			return a, true
+		}
+	}
+
+	return LongLivedGracefulRestartAFI{}, false
+}
+
+func serializeOptParams(optParams []OptParam) []byte {
+	buf := bytes.NewBuffer(nil)
+	for _, o := range optParams {
+		caps, ok := o.Value.(Capabilities)
+		if !ok {
+			continue
+		}
+
+		value := caps.serialize()
+		buf.WriteByte(o.Type)
+		buf.WriteByte(uint8(len(value)))
+		buf.Write(value)
+	}
+
+	return buf.Bytes()
+}
+
+func (caps Capabilities) serialize() []byte {
+	buf := bytes.NewBuffer(nil)
+	for _, c := range caps {
+		value := c.serializeValue()
+		buf.WriteByte(c.Code)
+		buf.WriteByte(uint8(len(value)))
+		buf.Write(value)
+	}
+
+	return buf.Bytes()
+}
+
+func (c Capability) serializeValue() []byte {
+	switch v := c.Value.(type) {
+	case MultiProtocolCapabilityValue:
+		buf := bytes.NewBuffer(make([]byte, 0, 4))
+		buf.Write(convert.Uint16Byte(v.AFI))
+		buf.WriteByte(0) // Reserved
+		buf.WriteByte(v.SAFI)
+		return buf.Bytes()
+	case GracefulRestartCapabilityValue:
+		buf := bytes.NewBuffer(make([]byte, 0, 2+4*len(v.AFIs)))
+		flagsAndTime := v.RestartTime & RestartTimeMask
+		if v.Restarting {
+			flagsAndTime |= RestartStateFlag
+		}
+		buf.Write(convert.Uint16Byte(flagsAndTime))
+
+		for _, a := range v.AFIs {
+			buf.Write(convert.Uint16Byte(a.AFI))
+			buf.WriteByte(a.SAFI)
+			flags := uint8(0)
+			if a.ForwardingPreserved {
+				flags |= ForwardingStateFlag
+			}
+			buf.WriteByte(flags)
+		}
+		return buf.Bytes()
+	case AddPathCapabilityValue:
+		buf := bytes.NewBuffer(make([]byte, 0, 4*len(v.AFIs)))
+		for _, a := range v.AFIs {
+			buf.Write(convert.Uint16Byte(a.AFI))
+			buf.WriteByte(a.SAFI)
+			buf.WriteByte(a.SendReceive)
+		}
+		return buf.Bytes()
+	case RoleCapabilityValue:
+		return []byte{v.Role}
+	case LongLivedGracefulRestartCapabilityValue:
+		buf := bytes.NewBuffer(make([]byte, 0, 7*len(v.AFIs)))
+		for _, a := range v.AFIs {
+			buf.Write(convert.Uint16Byte(a.AFI))
+			buf.WriteByte(a.SAFI)
+			flags := uint8(0)
+			if a.ForwardingPreserved {
+				flags |= ForwardingStateFlag
+			}
+			buf.WriteByte(flags)
+			buf.Write(convert.Uint32Byte(a.StaleTime)[1:])
+		}
+		return buf.Bytes()
+	case []byte:
+		return v
+	}
+
+	return nil
+}
@@ -11,12 +11,17 @@ import (
 
 // Decode decodes a BGP message
 func Decode(buf *bytes.Buffer) (*BGPMessage, error) {
+	return DecodeWithOptions(buf, &DecodeOptions{})
+}
+
+// DecodeWithOptions decodes a BGP message of a session with the properties described by opt
+func DecodeWithOptions(buf *bytes.Buffer, opt *DecodeOptions) (*BGPMessage, error) {
 	hdr, err := decodeHeader(buf)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to decode header: %v", err)
 	}
 
-	body, err := decodeMsgBody(buf, hdr.Type, hdr.Length-MinLen)
+	body, err := decodeMsgBody(buf, hdr.Type, hdr.Length-MinLen, opt)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to decode message: %v", err)
 	}
@@ -27,21 +32,23 @@ func Decode(buf *bytes.Buffer) (*BGPMessage, error) {
 	}, nil
 }
 
-func decodeMsgBody(buf *bytes.Buffer, msgType uint8, l uint16) (interface{}, error) {
+func decodeMsgBody(buf *bytes.Buffer, msgType uint8, l uint16, opt *DecodeOptions) (interface{}, error) {
 	switch msgType {
 	case OpenMsg:
 		return decodeOpenMsg(buf)
 	case UpdateMsg:
-		return decodeUpdateMsg(buf, l)
+		return decodeUpdateMsg(buf, l, opt)
 	case KeepaliveMsg:
 		return nil, nil // Nothing to decode in Keepalive message
 	case NotificationMsg:
 		return decodeNotificationMsg(buf)
+	case RouteRefreshMsg:
+		return decodeRouteRefreshMsg(buf, l)
 	}
 	return nil, fmt.Errorf("Unknown message type: %d", msgType)
 }
 
-func decodeUpdateMsg(buf *bytes.Buffer, l uint16) (*BGPUpdate, error) {
+func decodeUpdateMsg(buf *bytes.Buffer, l uint16, opt *DecodeOptions) (*BGPUpdate, error) {
 	msg := &BGPUpdate{}
 
 	err := decode(buf, []interface{}{&msg.WithdrawnRoutesLen})
@@ -49,7 +56,7 @@ func decodeUpdateMsg(buf *bytes.Buffer, l uint16) (*BGPUpdate, error) {
 		return msg, err
 	}
 
-	msg.WithdrawnRoutes, err = decodeNLRIs(buf, uint16(msg.WithdrawnRoutesLen))
+	msg.WithdrawnRoutes, err = decodeNLRIs(buf, uint16(msg.WithdrawnRoutesLen), opt.AddPath)
 	if err != nil {
 		return msg, err
 	}
@@ -59,14 +66,14 @@ func decodeUpdateMsg(buf *bytes.Buffer, l uint16) (*BGPUpdate, error) {
 		return msg, err
 	}
 
-	msg.PathAttributes, err = decodePathAttrs(buf, msg.TotalPathAttrLen)
+	msg.PathAttributes, err = decodePathAttrs(buf, msg.TotalPathAttrLen, opt.ASN4)
 	if err != nil {
 		return msg, err
 	}
 
 	nlriLen := uint16(l) - 4 - uint16(msg.TotalPathAttrLen) - uint16(msg.WithdrawnRoutesLen)
 	if nlriLen > 0 {
-		msg.NLRI, err = decodeNLRIs(buf, nlriLen)
+		msg.NLRI, err = decodeNLRIs(buf, nlriLen, opt.AddPath)
 		if err != nil {
 			return msg, err
 		}
@@ -88,7 +95,7 @@ func decodeNotificationMsg(buf *bytes.Buffer) (*BGPNotification, error) {
 		return msg, err
 	}
 
-	if msg.ErrorCode > Cease {
+	if msg.ErrorCode > RouteRefreshMsgError {
 		return msg, fmt.Errorf("Invalid error code: %d", msg.ErrorSubcode)
 	}
 
@@ -98,7 +105,8 @@ func decodeNotificationMsg(buf *bytes.Buffer) (*BGPNotification, error) {
 			return invalidErrCode(msg)
 		}
 	case OpenMessageError:
-		if msg.ErrorSubcode > UnacceptableHoldTime || msg.ErrorSubcode == 0 || msg.ErrorSubcode == DeprecatedOpenMsgError5 {
+		if (msg.ErrorSubcode > UnacceptableHoldTime && msg.ErrorSubcode != RoleMismatch) || msg.ErrorSubcode == 0 ||
+			msg.ErrorSubcode == DeprecatedOpenMsgError5 {
 			return invalidErrCode(msg)
 		}
 	case UpdateMessageError:
@@ -114,7 +122,9 @@ func decodeNotificationMsg(buf *bytes.Buffer) (*BGPNotification, error) {
 			return invalidErrCode(msg)
 		}
 	case Cease:
-		if msg.ErrorSubcode != 0 {
+		// Subcodes are added over time (e.g. Hard Reset, RFC8538) and unknown ones must not fail the session
+	case RouteRefreshMsgError:
+		if msg.ErrorSubcode != InvalidMessageLength {
 			return invalidErrCode(msg)
 		}
 	default:
@@ -124,6 +134,48 @@ func decodeNotificationMsg(buf *bytes.Buffer) (*BGPNotification, error) {
 	return msg, nil
 }
 
+func decodeRouteRefreshMsg(buf *bytes.Buffer, l uint16) (*BGPRouteRefresh, error) {
+	msg := &BGPRouteRefresh{}
+
+	if l < RouteRefreshLen-MinLen {
+		return msg, BGPError{
+			ErrorCode:    MessageHeaderError,
+			ErrorSubCode: BadMessageLength,
+			ErrorStr:     fmt.Sprintf("Invalid ROUTE-REFRESH message length: %d", l),
+		}
+	}
+
+	fields := []interface{}{
+		&msg.AFI,
+		&msg.SubType,
+		&msg.SAFI,
+	}
+
+	err := decode(buf, fields)
+	if err != nil {
+		return msg, err
+	}
+
+	// BoRR and EoRR messages must not carry anything but AFI/SAFI (RFC7313)
+	if l != RouteRefreshLen-MinLen {
+		if msg.SubType == BeginningOfRouteRefresh || msg.SubType == EndOfRouteRefresh {
+			return msg, BGPError{
+				ErrorCode:    RouteRefreshMsgError,
+				ErrorSubCode: InvalidMessageLength,
+				ErrorStr:     fmt.Sprintf("Invalid ROUTE-REFRESH message length: %d", l),
+			}
+		}
+
+		// Skip ORF entries (RFC5291) as we do not support them
+		err = dumpNBytes(buf, l-(RouteRefreshLen-MinLen))
+		if err != nil {
+			return msg, err
+		}
+	}
+
+	return msg, nil
+}
+
 func invalidErrCode(n *BGPNotification) (*BGPNotification, error) {
 	return n, fmt.Errorf("Invalid error sub code: %d/%d", n.ErrorCode, n.ErrorSubcode)
 }
@@ -149,9 +201,16 @@ func _decodeOpenMsg(buf *bytes.Buffer) (interface{}, error) {
 		return msg, err
 	}
 
+	if msg.OptParmLen > 0 {
+		msg.OptParams, err = decodeOptParams(buf, msg.OptParmLen)
+		if err != nil {
+			return msg, err
+		}
+	}
+
 	err = validateOpen(msg)
 	if err != nil {
-		return nil, err
+		return msg, err
 	}
 
 	return msg, nil
@@ -250,7 +309,7 @@ func decodeHeader(buf *bytes.Buffer) (*BGPHeader, error) {
 		}
 	}
 
-	if hdr.Type > KeepaliveMsg || hdr.Type == 0 {
+	if hdr.Type > RouteRefreshMsg || hdr.Type == 0 {
 		return hdr, BGPError{
 			ErrorCode:    MessageHeaderError,
 			ErrorSubCode: BadMessageType,
@@ -69,7 +69,7 @@ func BenchmarkDecodeUpdateMsg(b *testing.B) {
 
 	for i := 0; i < b.N; i++ {
 		buf := bytes.NewBuffer(input)
-		_, err := decodeUpdateMsg(buf, uint16(len(input)))
+		_, err := decodeUpdateMsg(buf, uint16(len(input)), &DecodeOptions{})
 		if err != nil {
 			fmt.Printf("decodeUpdateMsg failed: %v\n", err)
 		}
@@ -215,12 +215,36 @@ func TestDecode(t *testing.T) {
 				},
 			},
 		},
+		{
+			// Proper ROUTE-REFRESH packet
+			testNum: 8,
+			input: []byte{
+				255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, // Marker
+				0, 23, // Length
+				5,    // Type = Route Refresh
+				0, 1, // AFI
+				0, // Subtype
+				1, // SAFI
+			},
+			wantFail: false,
+			expected: &BGPMessage{
+				Header: &BGPHeader{
+					Length: 23,
+					Type:   5,
+				},
+				Body: &BGPRouteRefresh{
+					AFI:     IPv4AFI,
+					SubType: NormalRouteRefresh,
+					SAFI:    UnicastSAFI,
+				},
+			},
+		},
 		{
 			testNum: 7,
 			input: []byte{
 				255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, // Marker
 				0, 28, // Length
-				5,                               // Type = Invalid
+				6,                               // Type = Invalid
 				0, 5, 8, 10, 16, 192, 168, 0, 0, // Some more stuff
 			},
 			wantFail: true,
@@ -296,6 +320,15 @@ func TestDecodeNotificationMsg(t *testing.T) {
 			input:    []byte{2, 5},
 			wantFail: true,
 		},
+		{
+			name:     "Role Mismatch",
+			input:    []byte{2, 11},
+			wantFail: false,
+			expected: &BGPNotification{
+				ErrorCode:    2,
+				ErrorSubcode: 11,
+			},
+		},
 		{
 			name:     "Invalid ErrSubCode (Update)",
 			input:    []byte{3, 0},
@@ -363,8 +396,44 @@ func TestDecodeNotificationMsg(t *testing.T) {
 			},
 		},
 		{
-			name:     "Cease (invalid subcode)",
+			name:     "Cease (maximum number of prefixes reached)",
 			input:    []byte{6, 1},
+			wantFail: false,
+			expected: &BGPNotification{
+				ErrorCode:    6,
+				ErrorSubcode: 1,
+			},
+		},
+		{
+			name:     "Cease (hard reset)",
+			input:    []byte{6, 9},
+			wantFail: false,
+			expected: &BGPNotification{
+				ErrorCode:    6,
+				ErrorSubcode: 9,
+			},
+		},
+		{
+			name:     "Cease (unknown subcode)",
+			input:    []byte{6, 200},
+			wantFail: false,
+			expected: &BGPNotification{
+				ErrorCode:    6,
+				ErrorSubcode: 200,
+			},
+		},
+		{
+			name:     "Route Refresh Message Error",
+			input:    []byte{7, 1},
+			wantFail: false,
+			expected: &BGPNotification{
+				ErrorCode:    7,
+				ErrorSubcode: 1,
+			},
+		},
+		{
+			name:     "Route Refresh Message Error (invalid subcode)",
+			input:    []byte{7, 2},
 			wantFail: true,
 		},
 	}
@@ -1249,7 +1318,7 @@ func TestDecodeUpdateMsg(t *testing.T) {
 		if l == 0 {
 			l = uint16(len(test.input))
 		}
-		msg, err := decodeUpdateMsg(buf, l)
+		msg, err := decodeUpdateMsg(buf, l, &DecodeOptions{})
 
 		if err != nil && !test.wantFail {
 			t.Errorf("Unexpected error in test %d: %v", test.testNum, err)
@@ -1280,13 +1349,13 @@ func TestDecodeMsgBody(t *testing.T) {
 	}{
 		{
 			name:     "Unknown msgType",
-			msgType:  5,
+			msgType:  6,
 			wantFail: true,
 		},
 	}
 
 	for _, test := range tests {
-		res, err := decodeMsgBody(test.buffer, test.msgType, test.length)
+		res, err := decodeMsgBody(test.buffer, test.msgType, test.length, &DecodeOptions{})
 		if test.wantFail && err == nil {
 			t.Errorf("Expected error dit not happen in test %q", test.name)
 		}
@@ -1314,6 +1383,277 @@ func TestDecodeOpenMsg(t *testing.T) {
 				OptParmLen:    0,
 			},
 		},
+		{
+			// Valid message with capabilities
+			testNum: 3,
+			input: []byte{
+				4, 1, 1, 0, 15, 10, 20, 30, 40,
+				12,    // Opt Parm Len
+				2, 10, // Capabilities
+				1, 4, 0, 1, 0, 1, // Multi Protocol IPv4 Unicast
+				2, 0, // Route Refresh
+				70, 0, // Enhanced Route Refresh
+			},
+			wantFail: false,
+			expected: &BGPOpen{
+				Version:       4,
+				AS:            257,
+				HoldTime:      15,
+				BGPIdentifier: 169090600,
+				OptParmLen:    12,
+				OptParams: []OptParam{
+					{
+						Type:   CapabilitiesParam,
+						Length: 10,
+						Value: Capabilities{
+							{
+								Code:   MultiProtocolCapability,
+								Length: 4,
+								Value: MultiProtocolCapabilityValue{
+									AFI:  IPv4AFI,
+									SAFI: UnicastSAFI,
+								},
+							},
+							{
+								Code: RouteRefreshCapability,
+							},
+							{
+								Code: EnhancedRouteRefreshCapability,
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			// Unsupported optional parameter
+			testNum:  4,
+			input:    []byte{4, 1, 1, 0, 15, 10, 20, 30, 40, 3, 1, 1, 0},
+			wantFail: true,
+		},
+		{
+			// Incomplete capabilities
+			testNum:  5,
+			input:    []byte{4, 1, 1, 0, 15, 10, 20, 30, 40, 6, 2, 4, 1, 4, 0},
+			wantFail: true,
+		},
+		{
+			// Valid message with graceful restart capability
+			testNum: 6,
+			input: []byte{
+				4, 1, 1, 0, 15, 10, 20, 30, 40,
+				10,   // Opt Parm Len
+				2, 8, // Capabilities
+				64, 6, // Graceful Restart
+				0x80, 0x78, // Restarting, Restart Time 120s
+				0, 1, 1, 0x80, // IPv4 Unicast, Forwarding State preserved
+			},
+			wantFail: false,
+			expected: &BGPOpen{
+				Version:       4,
+				AS:            257,
+				HoldTime:      15,
+				BGPIdentifier: 169090600,
+				OptParmLen:    10,
+				OptParams: []OptParam{
+					{
+						Type:   CapabilitiesParam,
+						Length: 8,
+						Value: Capabilities{
+							{
+								Code:   GracefulRestartCapability,
+								Length: 6,
+								Value: GracefulRestartCapabilityValue{
+									Restarting:  true,
+									RestartTime: 120,
+									AFIs: []GracefulRestartAFI{
+										{
+											AFI:                 IPv4AFI,
+											SAFI:                UnicastSAFI,
+											ForwardingPreserved: true,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			// Graceful restart capability too short
+			testNum:  7,
+			input:    []byte{4, 1, 1, 0, 15, 10, 20, 30, 40, 5, 2, 3, 64, 1, 0},
+			wantFail: true,
+		},
+		{
+			// Valid message with long-lived graceful restart capability
+			testNum: 8,
+			input: []byte{
+				4, 1, 1, 0, 15, 10, 20, 30, 40,
+				11,   // Opt Parm Len
+				2, 9, // Capabilities
+				71, 7, // Long-lived Graceful Restart
+				0, 1, 1, 0x80, // IPv4 Unicast, Forwarding State preserved
+				0x01, 0x51, 0x80, // Long-lived Stale Time 86400s
+			},
+			wantFail: false,
+			expected: &BGPOpen{
+				Version:       4,
+				AS:            257,
+				HoldTime:      15,
+				BGPIdentifier: 169090600,
+				OptParmLen:    11,
+				OptParams: []OptParam{
+					{
+						Type:   CapabilitiesParam,
+						Length: 9,
+						Value: Capabilities{
+							{
+								Code:   LongLivedGracefulRestartCapability,
+								Length: 7,
+								Value: LongLivedGracefulRestartCapabilityValue{
+									AFIs: []LongLivedGracefulRestartAFI{
+										{
+											AFI:                 IPv4AFI,
+											SAFI:                UnicastSAFI,
+											ForwardingPreserved: true,
+											StaleTime:           86400,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			// Long-lived graceful restart capability with invalid length
+			testNum:  9,
+			input:    []byte{4, 1, 1, 0, 15, 10, 20, 30, 40, 7, 2, 5, 71, 3, 0, 1, 1},
+			wantFail: true,
+		},
+		{
+			// Valid message with ADD-PATH capability
+			testNum: 10,
+			input: []byte{
+				4, 1, 1, 0, 15, 10, 20, 30, 40,
+				8,    // Opt Parm Len
+				2, 6, // Capabilities
+				69, 4, // ADD-PATH
+				0, 1, 1, 3, // IPv4 Unicast, Send/Receive
+			},
+			wantFail: false,
+			expected: &BGPOpen{
+				Version:       4,
+				AS:            257,
+				HoldTime:      15,
+				BGPIdentifier: 169090600,
+				OptParmLen:    8,
+				OptParams: []OptParam{
+					{
+						Type:   CapabilitiesParam,
+						Length: 6,
+						Value: Capabilities{
+							{
+								Code:   AddPathCapability,
+								Length: 4,
+								Value: AddPathCapabilityValue{
+									AFIs: []AddPathAFI{
+										{
+											AFI:         IPv4AFI,
+											SAFI:        UnicastSAFI,
+											SendReceive: AddPathSendReceive,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			// ADD-PATH capability with invalid length
+			testNum:  11,
+			input:    []byte{4, 1, 1, 0, 15, 10, 20, 30, 40, 7, 2, 5, 69, 3, 0, 1, 1},
+			wantFail: true,
+		},
+		{
+			// Valid message with BGP role capability
+			testNum: 12,
+			input: []byte{
+				4, 1, 1, 0, 15, 10, 20, 30, 40,
+				5,    // Opt Parm Len
+				2, 3, // Capabilities
+				9, 1, // BGP Role
+				3, // Customer
+			},
+			wantFail: false,
+			expected: &BGPOpen{
+				Version:       4,
+				AS:            257,
+				HoldTime:      15,
+				BGPIdentifier: 169090600,
+				OptParmLen:    5,
+				OptParams: []OptParam{
+					{
+						Type:   CapabilitiesParam,
+						Length: 3,
+						Value: Capabilities{
+							{
+								Code:   RoleCapability,
+								Length: 1,
+								Value: RoleCapabilityValue{
+									Role: RoleCustomer,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			// BGP role capability with invalid length
+			testNum:  13,
+			input:    []byte{4, 1, 1, 0, 15, 10, 20, 30, 40, 6, 2, 4, 9, 2, 3, 0},
+			wantFail: true,
+		},
+		{
+			// Capability exceeding the optional parameter
+			testNum: 14,
+			input: []byte{
+				4, 1, 1, 0, 15, 10, 20, 30, 40,
+				4,    // Opt Parm Len
+				2, 2, // Capabilities
+				2, 2, 0, 0, // Route Refresh with length 2
+			},
+			wantFail: true,
+		},
+		{
+			// Capability length overflowing the length of the capabilities read so far
+			testNum: 15,
+			input: append(append([]byte{
+				4, 1, 1, 0, 15, 10, 20, 30, 40,
+				6,    // Opt Parm Len
+				2, 4, // Capabilities
+				2, 0, // Route Refresh
+				128, 254, // Unknown capability with length 254
+			}, make([]byte, 254)...), 2, 0),
+			wantFail: true,
+		},
+		{
+			// Optional parameter exceeding the optional parameters
+			testNum: 16,
+			input: []byte{
+				4, 1, 1, 0, 15, 10, 20, 30, 40,
+				2,    // Opt Parm Len
+				2, 2, // Capabilities
+				2, 0, // Route Refresh
+			},
+			wantFail: true,
+		},
 		{
 			// Invalid Version
 			testNum:  2,
@@ -1325,6 +1665,75 @@ func TestDecodeOpenMsg(t *testing.T) {
 	genericTest(_decodeOpenMsg, tests, t)
 }
 
+func TestDecodeRouteRefreshMsg(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []byte
+		wantFail bool
+		expected *BGPRouteRefresh
+	}{
+		{
+			name:     "Normal route refresh",
+			input:    []byte{0, 1, 0, 1},
+			wantFail: false,
+			expected: &BGPRouteRefresh{
+				AFI:     IPv4AFI,
+				SubType: NormalRouteRefresh,
+				SAFI:    UnicastSAFI,
+			},
+		},
+		{
+			name:     "Beginning of route refresh",
+			input:    []byte{0, 1, 1, 1},
+			wantFail: false,
+			expected: &BGPRouteRefresh{
+				AFI:     IPv4AFI,
+				SubType: BeginningOfRouteRefresh,
+				SAFI:    UnicastSAFI,
+			},
+		},
+		{
+			name:     "Route refresh with ORF entries",
+			input:    []byte{0, 1, 0, 1, 1, 2, 3},
+			wantFail: false,
+			expected: &BGPRouteRefresh{
+				AFI:     IPv4AFI,
+				SubType: NormalRouteRefresh,
+				SAFI:    UnicastSAFI,
+			},
+		},
+		{
+			name:     "End of route refresh with invalid length",
+			input:    []byte{0, 1, 2, 1, 0},
+			wantFail: true,
+		},
+		{
+			name:     "Incomplete message",
+			input:    []byte{0, 1, 0},
+			wantFail: true,
+		},
+	}
+
+	for _, test := range tests {
+		res, err := decodeRouteRefreshMsg(bytes.NewBuffer(test.input), uint16(len(test.input)))
+
+		if test.wantFail {
+			if err != nil {
+				continue
+			}
+			t.Errorf("Expected error did not happen for test %q", test.name)
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected failure for test %q: %v", test.name, err)
+			continue
+		}
+
+		assert.Equal(t, test.expected, res)
+	}
+}
+
 func TestDecodeHeader(t *testing.T) {
 	tests := []test{
 		{
@@ -1358,9 +1767,9 @@ func TestDecodeHeader(t *testing.T) {
 			},
 		},
 		{
-			// Invalid message type 5
+			// Invalid message type 6
 			testNum:  4,
-			input:    []byte{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 0, 19, 5},
+			input:    []byte{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 0, 19, 6},
 			wantFail: true,
 			expected: &BGPHeader{
 				Length: 19,
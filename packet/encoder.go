@@ -2,6 +2,7 @@ package packet
 
 import (
 	"bytes"
+	"fmt"
 
 	"github.com/taktv6/tflow2/convert"
 )
@@ -25,7 +26,8 @@ func SerializeNotificationMsg(msg *BGPNotification) []byte {
 }
 
 func SerializeOpenMsg(msg *BGPOpen) []byte {
-	openLen := uint16(29)
+	optParams := serializeOptParams(msg.OptParams)
+	openLen := uint16(29 + len(optParams))
 	buf := bytes.NewBuffer(make([]byte, 0, openLen))
 	serializeHeader(buf, openLen, OpenMsg)
 
@@ -33,11 +35,64 @@ func SerializeOpenMsg(msg *BGPOpen) []byte {
 	buf.Write(convert.Uint16Byte(msg.AS))
 	buf.Write(convert.Uint16Byte(msg.HoldTime))
 	buf.Write(convert.Uint32Byte(msg.BGPIdentifier))
-	buf.WriteByte(uint8(0))
+	buf.WriteByte(uint8(len(optParams)))
+	buf.Write(optParams)
 
 	return buf.Bytes()
 }
 
+func SerializeRouteRefreshMsg(msg *BGPRouteRefresh) []byte {
+	buf := bytes.NewBuffer(make([]byte, 0, RouteRefreshLen))
+	serializeHeader(buf, RouteRefreshLen, RouteRefreshMsg)
+
+	buf.Write(convert.Uint16Byte(msg.AFI))
+	buf.WriteByte(msg.SubType)
+	buf.WriteByte(msg.SAFI)
+
+	return buf.Bytes()
+}
+
+func SerializeUpdateMsg(msg *BGPUpdate) ([]byte, error) {
+	return SerializeUpdateMsgWithOptions(msg, &EncodeOptions{})
+}
+
+// SerializeUpdateMsgWithOptions serializes an UPDATE message for a session with the properties described by opt
+func SerializeUpdateMsgWithOptions(msg *BGPUpdate, opt *EncodeOptions) ([]byte, error) {
+	withdrawBuf := bytes.NewBuffer(nil)
+	for r := msg.WithdrawnRoutes; r != nil; r = r.Next {
+		r.serialize(withdrawBuf, opt.AddPath)
+	}
+
+	pathAttributesBuf := bytes.NewBuffer(nil)
+	for pa := msg.PathAttributes; pa != nil; pa = pa.Next {
+		err := pa.serialize(pathAttributesBuf, opt.ASN4)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to serialize path attribute: %v", err)
+		}
+	}
+
+	nlriBuf := bytes.NewBuffer(nil)
+	for r := msg.NLRI; r != nil; r = r.Next {
+		r.serialize(nlriBuf, opt.AddPath)
+	}
+
+	updateLen := HeaderLen + 2 + withdrawBuf.Len() + 2 + pathAttributesBuf.Len() + nlriBuf.Len()
+	if updateLen > MaxLen {
+		return nil, fmt.Errorf("UPDATE message too long: %d bytes", updateLen)
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, updateLen))
+	serializeHeader(buf, uint16(updateLen), UpdateMsg)
+
+	buf.Write(convert.Uint16Byte(uint16(withdrawBuf.Len())))
+	buf.Write(withdrawBuf.Bytes())
+	buf.Write(convert.Uint16Byte(uint16(pathAttributesBuf.Len())))
+	buf.Write(pathAttributesBuf.Bytes())
+	buf.Write(nlriBuf.Bytes())
+
+	return buf.Bytes(), nil
+}
+
 func serializeHeader(buf *bytes.Buffer, length uint16, typ uint8) {
 	buf.Write([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
 	buf.Write(convert.Uint16Byte(length))
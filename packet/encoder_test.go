@@ -86,6 +86,138 @@ func TestSerializeOpenMsg(t *testing.T) {
 				0x00, // Opt. Param Length
 			},
 		},
+		{
+			name: "With capabilities",
+			input: &BGPOpen{
+				Version:       4,
+				AS:            15169,
+				HoldTime:      120,
+				BGPIdentifier: convert.Uint32([]byte{100, 111, 120, 130}),
+				OptParams: []OptParam{
+					{
+						Type: CapabilitiesParam,
+						Value: Capabilities{
+							{
+								Code: MultiProtocolCapability,
+								Value: MultiProtocolCapabilityValue{
+									AFI:  IPv4AFI,
+									SAFI: UnicastSAFI,
+								},
+							},
+							{
+								Code: RouteRefreshCapability,
+							},
+						},
+					},
+				},
+			},
+			expected: []byte{
+				0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+				0x00, 0x27, // Length
+				0x01,       // Type
+				0x04,       // Version
+				0x3b, 0x41, // ASN
+				0x00, 0x78, // Holdtime
+				130, 120, 111, 100, // BGP Identifier
+				0x0a,       // Opt. Param Length
+				0x02, 0x08, // Capabilities
+				0x01, 0x04, 0x00, 0x01, 0x00, 0x01, // Multi Protocol IPv4 Unicast
+				0x02, 0x00, // Route Refresh
+			},
+		},
+		{
+			name: "With BGP role capability",
+			input: &BGPOpen{
+				Version:       4,
+				AS:            15169,
+				HoldTime:      120,
+				BGPIdentifier: convert.Uint32([]byte{100, 111, 120, 130}),
+				OptParams: []OptParam{
+					{
+						Type: CapabilitiesParam,
+						Value: Capabilities{
+							{
+								Code: RoleCapability,
+								Value: RoleCapabilityValue{
+									Role: RolePeer,
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: []byte{
+				0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+				0x00, 0x22, // Length
+				0x01,       // Type
+				0x04,       // Version
+				0x3b, 0x41, // ASN
+				0x00, 0x78, // Holdtime
+				130, 120, 111, 100, // BGP Identifier
+				0x05,       // Opt. Param Length
+				0x02, 0x03, // Capabilities
+				0x09, 0x01, 0x04, // BGP Role Peer
+			},
+		},
+		{
+			name: "With graceful restart capabilities",
+			input: &BGPOpen{
+				Version:       4,
+				AS:            15169,
+				HoldTime:      120,
+				BGPIdentifier: convert.Uint32([]byte{100, 111, 120, 130}),
+				OptParams: []OptParam{
+					{
+						Type: CapabilitiesParam,
+						Value: Capabilities{
+							{
+								Code: GracefulRestartCapability,
+								Value: GracefulRestartCapabilityValue{
+									Restarting:  true,
+									RestartTime: 300,
+									AFIs: []GracefulRestartAFI{
+										{
+											AFI:                 IPv4AFI,
+											SAFI:                UnicastSAFI,
+											ForwardingPreserved: true,
+										},
+									},
+								},
+							},
+							{
+								Code: LongLivedGracefulRestartCapability,
+								Value: LongLivedGracefulRestartCapabilityValue{
+									AFIs: []LongLivedGracefulRestartAFI{
+										{
+											AFI:       IPv4AFI,
+											SAFI:      UnicastSAFI,
+											StaleTime: 86400,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: []byte{
+				0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+				0x00, 0x30, // Length
+				0x01,       // Type
+				0x04,       // Version
+				0x3b, 0x41, // ASN
+				0x00, 0x78, // Holdtime
+				130, 120, 111, 100, // BGP Identifier
+				0x13,       // Opt. Param Length
+				0x02, 0x11, // Capabilities
+				0x40, 0x06, // Graceful Restart
+				0x81, 0x2c, // Restarting, Restart Time 300s
+				0x00, 0x01, 0x01, 0x80, // IPv4 Unicast, Forwarding State preserved
+				0x47, 0x07, // Long-lived Graceful Restart
+				0x00, 0x01, 0x01, 0x00, // IPv4 Unicast
+				0x01, 0x51, 0x80, // Long-lived Stale Time 86400s
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -94,6 +226,222 @@ func TestSerializeOpenMsg(t *testing.T) {
 	}
 }
 
+func TestSerializeRouteRefreshMsg(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    *BGPRouteRefresh
+		expected []byte
+	}{
+		{
+			name: "Beginning of route refresh",
+			input: &BGPRouteRefresh{
+				AFI:     IPv4AFI,
+				SubType: BeginningOfRouteRefresh,
+				SAFI:    UnicastSAFI,
+			},
+			expected: []byte{
+				0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+				0x00, 0x17, // Length
+				0x05,       // Type
+				0x00, 0x01, // AFI
+				0x01, // Subtype
+				0x01, // SAFI
+			},
+		},
+	}
+
+	for _, test := range tests {
+		res := SerializeRouteRefreshMsg(test.input)
+		assert.Equal(t, test.expected, res)
+	}
+}
+
+func TestSerializeUpdateMsg(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    *BGPUpdate
+		addPath  bool
+		asn4     bool
+		wantFail bool
+		expected []byte
+	}{
+		{
+			name: "Withdraw only",
+			input: &BGPUpdate{
+				WithdrawnRoutes: &NLRI{
+					IP:     [4]byte{10, 0, 0, 0},
+					Pfxlen: 8,
+					Next: &NLRI{
+						IP:     [4]byte{192, 168, 0, 0},
+						Pfxlen: 16,
+					},
+				},
+			},
+			expected: []byte{
+				0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+				0x00, 0x1c, // Length
+				0x02,       // Type
+				0x00, 0x05, // Withdrawn Routes Length
+				8, 10, // 10.0.0.0/8
+				16, 192, 168, // 192.168.0.0/16
+				0x00, 0x00, // Total Path Attribute Length
+			},
+		},
+		{
+			name: "Advertisement",
+			input: &BGPUpdate{
+				PathAttributes: &PathAttribute{
+					TypeCode:   OriginAttr,
+					Transitive: true,
+					Value:      uint8(IGP),
+					Next: &PathAttribute{
+						TypeCode:   ASPathAttr,
+						Transitive: true,
+						Value: ASPath{
+							{
+								Type: ASSequence,
+								ASNs: []uint32{15169, 3320},
+							},
+						},
+						Next: &PathAttribute{
+							TypeCode:   NextHopAttr,
+							Transitive: true,
+							Value:      [4]byte{10, 11, 12, 13},
+							Next: &PathAttribute{
+								TypeCode: MEDAttr,
+								Optional: true,
+								Value:    uint32(256),
+								Next: &PathAttribute{
+									TypeCode:   CommunitiesAttr,
+									Optional:   true,
+									Transitive: true,
+									Value:      []uint32{LLGRStaleCommunity},
+								},
+							},
+						},
+					},
+				},
+				NLRI: &NLRI{
+					IP:     [4]byte{100, 110, 128, 0},
+					Pfxlen: 17,
+				},
+			},
+			expected: []byte{
+				0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+				0x00, 0x3d, // Length
+				0x02,       // Type
+				0x00, 0x00, // Withdrawn Routes Length
+				0x00, 0x22, // Total Path Attribute Length
+				64, 1, 1, 0, // ORIGIN IGP
+				64, 2, 6, 2, 2, 59, 65, 12, 248, // AS_PATH
+				64, 3, 4, 10, 11, 12, 13, // NEXT_HOP
+				128, 4, 4, 0, 0, 1, 0, // MED
+				192, 8, 4, 0xff, 0xff, 0, 6, // COMMUNITIES
+				17, 100, 110, 128, // 100.110.128.0/17
+			},
+		},
+		{
+			name: "Advertisement and withdraw with path identifiers",
+			input: &BGPUpdate{
+				WithdrawnRoutes: &NLRI{
+					PathIdentifier: 2,
+					IP:             [4]byte{10, 0, 0, 0},
+					Pfxlen:         8,
+				},
+				PathAttributes: &PathAttribute{
+					TypeCode:   OriginAttr,
+					Transitive: true,
+					Value:      uint8(IGP),
+				},
+				NLRI: &NLRI{
+					PathIdentifier: 1,
+					IP:             [4]byte{10, 0, 0, 0},
+					Pfxlen:         8,
+				},
+			},
+			addPath: true,
+			expected: []byte{
+				0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+				0x00, 0x27, // Length
+				0x02,       // Type
+				0x00, 0x06, // Withdrawn Routes Length
+				0, 0, 0, 2, 8, 10, // 10.0.0.0/8, path identifier 2
+				0x00, 0x04, // Total Path Attribute Length
+				64, 1, 1, 0, // ORIGIN IGP
+				0, 0, 0, 1, 8, 10, // 10.0.0.0/8, path identifier 1
+			},
+		},
+		{
+			name: "AS_PATH with 4 byte ASNs",
+			input: &BGPUpdate{
+				PathAttributes: &PathAttribute{
+					TypeCode:   ASPathAttr,
+					Transitive: true,
+					Value: ASPath{
+						{
+							Type: ASSequence,
+							ASNs: []uint32{65000, 4200000000},
+						},
+					},
+				},
+				NLRI: &NLRI{
+					IP:     [4]byte{10, 0, 0, 0},
+					Pfxlen: 8,
+				},
+			},
+			asn4: true,
+			expected: []byte{
+				0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+				0x00, 0x26, // Length
+				0x02,       // Type
+				0x00, 0x00, // Withdrawn Routes Length
+				0x00, 0x0d, // Total Path Attribute Length
+				64, 2, 10, 2, 2, 0, 0, 253, 232, 250, 86, 234, 0, // AS_PATH
+				8, 10, // 10.0.0.0/8
+			},
+		},
+		{
+			name: "Unknown attribute",
+			input: &BGPUpdate{
+				PathAttributes: &PathAttribute{
+					TypeCode: 200,
+				},
+			},
+			wantFail: true,
+		},
+	}
+
+	for _, test := range tests {
+		res, err := SerializeUpdateMsgWithOptions(test.input, &EncodeOptions{
+			AddPath: test.addPath,
+			ASN4:    test.asn4,
+		})
+		if test.wantFail {
+			if err == nil {
+				t.Errorf("Expected error did not happen for test %q", test.name)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected failure for test %q: %v", test.name, err)
+			continue
+		}
+
+		assert.Equal(t, test.expected, res)
+
+		msg, err := DecodeWithOptions(bytes.NewBuffer(res), &DecodeOptions{
+			AddPath: test.addPath,
+			ASN4:    test.asn4,
+		})
+		if err != nil {
+			t.Errorf("Unable to decode serialized message for test %q: %v", test.name, err)
+			continue
+		}
+		assert.Equal(t, uint8(UpdateMsg), msg.Header.Type)
+	}
+}
+
 func TestSerializeHeader(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -0,0 +1,50 @@
+package packet
+
+// EndOfRIB checks if msg is an End-of-RIB marker (RFC4724) and returns the address family it refers to.
+// For IPv4 unicast it is an UPDATE without any withdrawn routes, path attributes and NLRI.
+// For all other address families it is an UPDATE carrying only an empty MP_UNREACH_NLRI attribute.
+func (msg *BGPUpdate) EndOfRIB() (afi uint16, safi uint8, ok bool) {
+	if msg.WithdrawnRoutes != nil || msg.NLRI != nil {
+		return 0, 0, false
+	}
+
+	if msg.PathAttributes == nil {
+		return IPv4AFI, UnicastSAFI, true
+	}
+
+	pa := msg.PathAttributes
+	if pa.Next != nil || pa.TypeCode != MultiProtocolUnreachNLRIAttr {
+		return 0, 0, false
+	}
+
+	mp := pa.Value.(MultiProtocolUnreachNLRI)
+	if len(mp.WithdrawnRoutes) != 0 {
+		return 0, 0, false
+	}
+
+	return mp.AFI, mp.SAFI, true
+}
+
+// IsEndOfRIB checks if msg is an End-of-RIB marker for any address family
+func (msg *BGPUpdate) IsEndOfRIB() bool {
+	_, _, ok := msg.EndOfRIB()
+	return ok
+}
+
+// SerializeEndOfRIBMsg returns an End-of-RIB marker for afi/safi
+func SerializeEndOfRIBMsg(afi uint16, safi uint8) []byte {
+	msg := &BGPUpdate{}
+	if afi != IPv4AFI || safi != UnicastSAFI {
+		msg.PathAttributes = &PathAttribute{
+			TypeCode: MultiProtocolUnreachNLRIAttr,
+			Optional: true,
+			Value: MultiProtocolUnreachNLRI{
+				AFI:  afi,
+				SAFI: safi,
+			},
+		}
+	}
+
+	buf, _ := SerializeUpdateMsg(msg)
+	return buf
+}
@@ -0,0 +1,146 @@
+package packet
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEndOfRIB(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    *BGPUpdate
+		expected bool
+		afi      uint16
+		safi     uint8
+	}{
+		{
+			name:     "Empty UPDATE",
+			input:    &BGPUpdate{},
+			expected: true,
+			afi:      IPv4AFI,
+			safi:     UnicastSAFI,
+		},
+		{
+			name: "Empty MP_UNREACH_NLRI",
+			input: &BGPUpdate{
+				PathAttributes: &PathAttribute{
+					TypeCode: MultiProtocolUnreachNLRIAttr,
+					Value: MultiProtocolUnreachNLRI{
+						AFI:             IPv6AFI,
+						SAFI:            UnicastSAFI,
+						WithdrawnRoutes: []byte{},
+					},
+				},
+			},
+			expected: true,
+			afi:      IPv6AFI,
+			safi:     UnicastSAFI,
+		},
+		{
+			name: "MP_UNREACH_NLRI with withdraws",
+			input: &BGPUpdate{
+				PathAttributes: &PathAttribute{
+					TypeCode: MultiProtocolUnreachNLRIAttr,
+					Value: MultiProtocolUnreachNLRI{
+						AFI:             IPv6AFI,
+						SAFI:            UnicastSAFI,
+						WithdrawnRoutes: []byte{32, 0x20, 0x01, 0x0d, 0xb8},
+					},
+				},
+			},
+			expected: false,
+		},
+		{
+			name: "Withdraw",
+			input: &BGPUpdate{
+				WithdrawnRoutesLen: 2,
+				WithdrawnRoutes: &NLRI{
+					IP:     [4]byte{10, 0, 0, 0},
+					Pfxlen: 8,
+				},
+			},
+			expected: false,
+		},
+		{
+			name: "Advertisement",
+			input: &BGPUpdate{
+				PathAttributes: &PathAttribute{
+					TypeCode: OriginAttr,
+					Value:    uint8(IGP),
+				},
+				NLRI: &NLRI{
+					IP:     [4]byte{10, 0, 0, 0},
+					Pfxlen: 8,
+				},
+			},
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		afi, safi, ok := test.input.EndOfRIB()
+		assert.Equal(t, test.expected, ok, test.name)
+		assert.Equal(t, test.expected, test.input.IsEndOfRIB(), test.name)
+		if !ok {
+			continue
+		}
+
+		assert.Equal(t, test.afi, afi, test.name)
+		assert.Equal(t, test.safi, safi, test.name)
+	}
+}
+
+func TestSerializeEndOfRIBMsg(t *testing.T) {
+	tests := []struct {
+		name     string
+		afi      uint16
+		safi     uint8
+		expected []byte
+	}{
+		{
+			name: "IPv4 unicast",
+			afi:  IPv4AFI,
+			safi: UnicastSAFI,
+			expected: []byte{
+				0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+				0x00, 0x17, // Length
+				0x02,       // Type
+				0x00, 0x00, // Withdrawn Routes Length
+				0x00, 0x00, // Total Path Attribute Length
+			},
+		},
+		{
+			name: "IPv6 unicast",
+			afi:  IPv6AFI,
+			safi: UnicastSAFI,
+			expected: []byte{
+				0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+				0x00, 0x1d, // Length
+				0x02,       // Type
+				0x00, 0x00, // Withdrawn Routes Length
+				0x00, 0x06, // Total Path Attribute Length
+				0x80, 0x0f, 0x03, // MP_UNREACH_NLRI
+				0x00, 0x02, // AFI
+				0x01, // SAFI
+			},
+		},
+	}
+
+	for _, test := range tests {
+		res := SerializeEndOfRIBMsg(test.afi, test.safi)
+		assert.Equal(t, test.expected, res, test.name)
+
+		msg, err := Decode(bytes.NewBuffer(res))
+		if err != nil {
+			t.Errorf("Unable to decode End-of-RIB marker for test %q: %v", test.name, err)
+			continue
+		}
+
+		afi, safi, ok := msg.Body.(*BGPUpdate).EndOfRIB()
+		assert.True(t, ok, test.name)
+		assert.Equal(t, test.afi, afi, test.name)
+		assert.Equal(t, test.safi, safi, test.name)
+	}
+}
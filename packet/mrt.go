@@ -0,0 +1,403 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"time"
+
+	"github.com/taktv6/tflow2/convert"
+)
+
+// MRT record types (RFC6396 4)
+const (
+	MRTTableDumpV2Type = 13
+	MRTBGP4MPType      = 16
+)
+
+// TABLE_DUMP_V2 subtypes (RFC6396 4.3, RFC8050 3)
+const (
+	MRTPeerIndexTableSubtype        = 1
+	MRTRIBIPv4UnicastSubtype        = 2
+	MRTRIBIPv4UnicastAddPathSubtype = 8
+)
+
+// BGP4MP subtypes (RFC6396 4.4, RFC8050 3)
+const (
+	MRTBGP4MPMessageAS4Subtype             = 4
+	MRTBGP4MPMessageAS4LocalSubtype        = 7
+	MRTBGP4MPMessageAS4AddPathSubtype      = 9
+	MRTBGP4MPMessageAS4LocalAddPathSubtype = 11
+)
+
+const (
+	mrtHeaderLen = 12
+	mrtMaxLen    = 1 << 24
+
+	// Peer type flags of PEER_INDEX_TABLE entries
+	mrtPeerIPv6 = 0x01
+	mrtPeerAS4  = 0x02
+)
+
+// MRTRecord is a record of an MRT file (RFC6396). Body is one of *MRTPeerIndexTable, *MRTRIB or *MRTBGP4MPMessage.
+// Records of other types are read with Body holding the undecoded message.
+type MRTRecord struct {
+	Timestamp time.Time
+	Type      uint16
+	Subtype   uint16
+	Body      interface{}
+}
+
+// MRTPeerIndexTable lists the peers the entries of the following RIB records refer to by index (RFC6396 4.3.1)
+type MRTPeerIndexTable struct {
+	CollectorID uint32
+	ViewName    string
+	Peers       []MRTPeer
+}
+
+// MRTPeer is an entry of a PEER_INDEX_TABLE
+type MRTPeer struct {
+	BGPID   uint32
+	Address net.IP
+	AS      uint32
+}
+
+// MRTRIB holds all paths towards an IPv4 unicast prefix (RFC6396 4.3.2). AddPath is set if entries carry
+// path identifiers (RFC8050).
+type MRTRIB struct {
+	Sequence uint32
+	Prefix   [4]byte
+	Pfxlen   uint8
+	AddPath  bool
+	Entries  []MRTRIBEntry
+}
+
+// MRTRIBEntry is a path towards the prefix of a RIB record. AS_PATHs carry 4 byte ASNs (RFC6396 4.3.4).
+type MRTRIBEntry struct {
+	PeerIndex      uint16
+	Originated     time.Time
+	PathIdentifier uint32
+	PathAttributes *PathAttribute
+}
+
+// MRTBGP4MPMessage is a BGP message of a session (RFC6396 4.4.3). Local is set for messages we sent
+// and AddPath if NLRI of the message carry path identifiers (RFC8050).
+type MRTBGP4MPMessage struct {
+	PeerAS         uint32
+	LocalAS        uint32
+	InterfaceIndex uint16
+	PeerAddress    net.IP
+	LocalAddress   net.IP
+	Local          bool
+	AddPath        bool
+	Message        []byte
+}
+
+// SerializeMRTRecord serializes an MRT record holding body at time ts. Type and subtype are derived from body.
+func SerializeMRTRecord(ts time.Time, body interface{}) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+
+	var typ, subtype uint16
+	switch b := body.(type) {
+	case *MRTPeerIndexTable:
+		typ, subtype = MRTTableDumpV2Type, MRTPeerIndexTableSubtype
+		b.serialize(buf)
+	case *MRTRIB:
+		typ, subtype = MRTTableDumpV2Type, MRTRIBIPv4UnicastSubtype
+		if b.AddPath {
+			subtype = MRTRIBIPv4UnicastAddPathSubtype
+		}
+		if err := b.serialize(buf); err != nil {
+			return nil, err
+		}
+	case *MRTBGP4MPMessage:
+		typ, subtype = MRTBGP4MPType, b.subtype()
+		b.serialize(buf)
+	default:
+		return nil, fmt.Errorf("Unsupported MRT record: %T", body)
+	}
+
+	if buf.Len() > mrtMaxLen {
+		return nil, fmt.Errorf("MRT record too long: %d bytes", buf.Len())
+	}
+
+	res := bytes.NewBuffer(make([]byte, 0, mrtHeaderLen+buf.Len()))
+	res.Write(convert.Uint32Byte(uint32(ts.Unix())))
+	res.Write(convert.Uint16Byte(typ))
+	res.Write(convert.Uint16Byte(subtype))
+	res.Write(convert.Uint32Byte(uint32(buf.Len())))
+	res.Write(buf.Bytes())
+
+	return res.Bytes(), nil
+}
+
+func (t *MRTPeerIndexTable) serialize(buf *bytes.Buffer) {
+	buf.Write(convert.Uint32Byte(t.CollectorID))
+	buf.Write(convert.Uint16Byte(uint16(len(t.ViewName))))
+	buf.WriteString(t.ViewName)
+	buf.Write(convert.Uint16Byte(uint16(len(t.Peers))))
+
+	// All peers are written with 4 byte ASNs
+	for _, p := range t.Peers {
+		peerType := uint8(mrtPeerAS4)
+		addr := p.Address.To4()
+		if addr == nil {
+			peerType |= mrtPeerIPv6
+			addr = p.Address.To16()
+		}
+
+		buf.WriteByte(peerType)
+		buf.Write(convert.Uint32Byte(p.BGPID))
+		buf.Write(addr)
+		buf.Write(convert.Uint32Byte(p.AS))
+	}
+}
+
+func (r *MRTRIB) serialize(buf *bytes.Buffer) error {
+	buf.Write(convert.Uint32Byte(r.Sequence))
+	buf.WriteByte(r.Pfxlen)
+	buf.Write(r.Prefix[:int(math.Ceil(float64(r.Pfxlen)/float64(OctetLen)))])
+	buf.Write(convert.Uint16Byte(uint16(len(r.Entries))))
+
+	for _, e := range r.Entries {
+		attrs := bytes.NewBuffer(nil)
+		for pa := e.PathAttributes; pa != nil; pa = pa.Next {
+			if err := pa.serialize(attrs, true); err != nil {
+				return fmt.Errorf("Unable to serialize path attribute: %v", err)
+			}
+		}
+
+		buf.Write(convert.Uint16Byte(e.PeerIndex))
+		buf.Write(convert.Uint32Byte(uint32(e.Originated.Unix())))
+		if r.AddPath {
+			buf.Write(convert.Uint32Byte(e.PathIdentifier))
+		}
+		buf.Write(convert.Uint16Byte(uint16(attrs.Len())))
+		buf.Write(attrs.Bytes())
+	}
+
+	return nil
+}
+
+func (m *MRTBGP4MPMessage) subtype() uint16 {
+	switch {
+	case m.Local && m.AddPath:
+		return MRTBGP4MPMessageAS4LocalAddPathSubtype
+	case m.Local:
+		return MRTBGP4MPMessageAS4LocalSubtype
+	case m.AddPath:
+		return MRTBGP4MPMessageAS4AddPathSubtype
+	}
+
+	return MRTBGP4MPMessageAS4Subtype
+}
+
+func (m *MRTBGP4MPMessage) serialize(buf *bytes.Buffer) {
+	afi := uint16(IPv4AFI)
+	peer, local := m.PeerAddress.To4(), m.LocalAddress.To4()
+	if peer == nil || local == nil {
+		afi = IPv6AFI
+		peer, local = m.PeerAddress.To16(), m.LocalAddress.To16()
+	}
+
+	buf.Write(convert.Uint32Byte(m.PeerAS))
+	buf.Write(convert.Uint32Byte(m.LocalAS))
+	buf.Write(convert.Uint16Byte(m.InterfaceIndex))
+	buf.Write(convert.Uint16Byte(afi))
+	buf.Write(peer)
+	buf.Write(local)
+	buf.Write(m.Message)
+}
+
+// ReadMRTRecord reads a single record of an MRT file from r. io.EOF is returned at the end of r.
+func ReadMRTRecord(r io.Reader) (*MRTRecord, error) {
+	hdr := make([]byte, mrtHeaderLen)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, err
+	}
+
+	rec := &MRTRecord{
+		Timestamp: time.Unix(int64(binary.BigEndian.Uint32(hdr[0:4])), 0),
+		Type:      binary.BigEndian.Uint16(hdr[4:6]),
+		Subtype:   binary.BigEndian.Uint16(hdr[6:8]),
+	}
+
+	l := binary.BigEndian.Uint32(hdr[8:12])
+	if l > mrtMaxLen {
+		return nil, fmt.Errorf("Invalid MRT record length: %d", l)
+	}
+
+	body := make([]byte, l)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("Unable to read MRT record: %v", err)
+	}
+
+	var err error
+	buf := bytes.NewBuffer(body)
+	switch {
+	case rec.Type == MRTTableDumpV2Type && rec.Subtype == MRTPeerIndexTableSubtype:
+		rec.Body, err = decodeMRTPeerIndexTable(buf)
+	case rec.Type == MRTTableDumpV2Type && (rec.Subtype == MRTRIBIPv4UnicastSubtype || rec.Subtype == MRTRIBIPv4UnicastAddPathSubtype):
+		rec.Body, err = decodeMRTRIB(buf, rec.Subtype == MRTRIBIPv4UnicastAddPathSubtype)
+	case rec.Type == MRTBGP4MPType && bgp4mpMessageSubtype(rec.Subtype):
+		rec.Body, err = decodeMRTBGP4MPMessage(buf, rec.Subtype)
+	default:
+		rec.Body = body
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decode MRT record of type %d/%d: %v", rec.Type, rec.Subtype, err)
+	}
+
+	return rec, nil
+}
+
+func bgp4mpMessageSubtype(subtype uint16) bool {
+	switch subtype {
+	case MRTBGP4MPMessageAS4Subtype, MRTBGP4MPMessageAS4LocalSubtype, MRTBGP4MPMessageAS4AddPathSubtype, MRTBGP4MPMessageAS4LocalAddPathSubtype:
+		return true
+	}
+
+	return false
+}
+
+func decodeMRTPeerIndexTable(buf *bytes.Buffer) (*MRTPeerIndexTable, error) {
+	t := &MRTPeerIndexTable{}
+
+	var nameLen uint16
+	if err := decode(buf, []interface{}{&t.CollectorID, &nameLen}); err != nil {
+		return nil, err
+	}
+	if int(nameLen) > buf.Len() {
+		return nil, fmt.Errorf("Invalid view name length: %d", nameLen)
+	}
+	t.ViewName = string(buf.Next(int(nameLen)))
+
+	var count uint16
+	if err := decode(buf, []interface{}{&count}); err != nil {
+		return nil, err
+	}
+
+	for i := uint16(0); i < count; i++ {
+		var peerType uint8
+		p := MRTPeer{}
+		if err := decode(buf, []interface{}{&peerType, &p.BGPID}); err != nil {
+			return nil, err
+		}
+
+		addrLen := net.IPv4len
+		if peerType&mrtPeerIPv6 != 0 {
+			addrLen = net.IPv6len
+		}
+		if buf.Len() < addrLen {
+			return nil, fmt.Errorf("Peer address truncated")
+		}
+		p.Address = net.IP(append([]byte(nil), buf.Next(addrLen)...))
+
+		if peerType&mrtPeerAS4 != 0 {
+			if err := decode(buf, []interface{}{&p.AS}); err != nil {
+				return nil, err
+			}
+		} else {
+			var as uint16
+			if err := decode(buf, []interface{}{&as}); err != nil {
+				return nil, err
+			}
+			p.AS = uint32(as)
+		}
+
+		t.Peers = append(t.Peers, p)
+	}
+
+	return t, nil
+}
+
+func decodeMRTRIB(buf *bytes.Buffer, addPath bool) (*MRTRIB, error) {
+	r := &MRTRIB{
+		AddPath: addPath,
+	}
+
+	if err := decode(buf, []interface{}{&r.Sequence, &r.Pfxlen}); err != nil {
+		return nil, err
+	}
+	if r.Pfxlen > 32 {
+		return nil, fmt.Errorf("Invalid prefix length: %d", r.Pfxlen)
+	}
+
+	toCopy := int(math.Ceil(float64(r.Pfxlen) / float64(OctetLen)))
+	if buf.Len() < toCopy {
+		return nil, fmt.Errorf("Prefix truncated")
+	}
+	copy(r.Prefix[:], buf.Next(toCopy))
+
+	var count uint16
+	if err := decode(buf, []interface{}{&count}); err != nil {
+		return nil, err
+	}
+
+	for i := uint16(0); i < count; i++ {
+		e := MRTRIBEntry{}
+
+		var originated uint32
+		if err := decode(buf, []interface{}{&e.PeerIndex, &originated}); err != nil {
+			return nil, err
+		}
+		e.Originated = time.Unix(int64(originated), 0)
+
+		if addPath {
+			if err := decode(buf, []interface{}{&e.PathIdentifier}); err != nil {
+				return nil, err
+			}
+		}
+
+		var attrLen uint16
+		if err := decode(buf, []interface{}{&attrLen}); err != nil {
+			return nil, err
+		}
+		if int(attrLen) > buf.Len() {
+			return nil, fmt.Errorf("Invalid attribute length: %d", attrLen)
+		}
+
+		var err error
+		e.PathAttributes, err = decodePathAttrs(bytes.NewBuffer(buf.Next(int(attrLen))), attrLen, true)
+		if err != nil {
+			return nil, err
+		}
+
+		r.Entries = append(r.Entries, e)
+	}
+
+	return r, nil
+}
+
+func decodeMRTBGP4MPMessage(buf *bytes.Buffer, subtype uint16) (*MRTBGP4MPMessage, error) {
+	m := &MRTBGP4MPMessage{
+		Local:   subtype == MRTBGP4MPMessageAS4LocalSubtype || subtype == MRTBGP4MPMessageAS4LocalAddPathSubtype,
+		AddPath: subtype == MRTBGP4MPMessageAS4AddPathSubtype || subtype == MRTBGP4MPMessageAS4LocalAddPathSubtype,
+	}
+
+	var afi uint16
+	if err := decode(buf, []interface{}{&m.PeerAS, &m.LocalAS, &m.InterfaceIndex, &afi}); err != nil {
+		return nil, err
+	}
+
+	addrLen := net.IPv4len
+	switch afi {
+	case IPv4AFI:
+	case IPv6AFI:
+		addrLen = net.IPv6len
+	default:
+		return nil, fmt.Errorf("Unsupported address family: %d", afi)
+	}
+	if buf.Len() < 2*addrLen {
+		return nil, fmt.Errorf("Addresses truncated")
+	}
+
+	m.PeerAddress = net.IP(append([]byte(nil), buf.Next(addrLen)...))
+	m.LocalAddress = net.IP(append([]byte(nil), buf.Next(addrLen)...))
+	m.Message = append([]byte(nil), buf.Bytes()...)
+
+	return m, nil
+}
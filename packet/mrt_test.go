@@ -0,0 +1,307 @@
+package packet
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSerializeMRTRecord(t *testing.T) {
+	ts := time.Unix(1500000000, 0)
+
+	tests := []struct {
+		name     string
+		input    interface{}
+		wantFail bool
+		expected []byte
+	}{
+		{
+			name: "Peer index table",
+			input: &MRTPeerIndexTable{
+				CollectorID: 167772161,
+				ViewName:    "rib",
+				Peers: []MRTPeer{
+					{
+						BGPID:   167772162,
+						Address: net.IP{10, 0, 0, 2},
+						AS:      65002,
+					},
+				},
+			},
+			expected: []byte{
+				0x59, 0x68, 0x2f, 0x00, // Timestamp
+				0, 13, // Type
+				0, 1, // Subtype
+				0, 0, 0, 24, // Length
+				10, 0, 0, 1, // Collector BGP ID
+				0, 3, // View Name Length
+				'r', 'i', 'b',
+				0, 1, // Peer Count
+				2,           // Peer Type
+				10, 0, 0, 2, // Peer BGP ID
+				10, 0, 0, 2, // Peer IP Address
+				0, 0, 0xfd, 0xea, // Peer AS
+			},
+		},
+		{
+			name: "RIB with 4 byte AS_PATH",
+			input: &MRTRIB{
+				Sequence: 7,
+				Prefix:   [4]byte{10, 1, 0, 0},
+				Pfxlen:   16,
+				Entries: []MRTRIBEntry{
+					{
+						PeerIndex:  1,
+						Originated: ts,
+						PathAttributes: &PathAttribute{
+							TypeCode:   ASPathAttr,
+							Transitive: true,
+							Value: ASPath{
+								{
+									Type: ASSequence,
+									ASNs: []uint32{65002},
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: []byte{
+				0x59, 0x68, 0x2f, 0x00, // Timestamp
+				0, 13, // Type
+				0, 2, // Subtype
+				0, 0, 0, 26, // Length
+				0, 0, 0, 7, // Sequence
+				16,    // Prefix Length
+				10, 1, // Prefix
+				0, 1, // Entry Count
+				0, 1, // Peer Index
+				0x59, 0x68, 0x2f, 0x00, // Originated Time
+				0, 9, // Attribute Length
+				64, 2, 6, // AS_PATH
+				2, 1, 0, 0, 0xfd, 0xea,
+			},
+		},
+		{
+			name: "Sent message with ADD-PATH",
+			input: &MRTBGP4MPMessage{
+				PeerAS:       65002,
+				LocalAS:      65001,
+				PeerAddress:  net.IP{10, 0, 0, 2},
+				LocalAddress: net.IP{10, 0, 0, 1},
+				Local:        true,
+				AddPath:      true,
+				Message:      SerializeKeepaliveMsg(),
+			},
+			expected: []byte{
+				0x59, 0x68, 0x2f, 0x00, // Timestamp
+				0, 16, // Type
+				0, 11, // Subtype
+				0, 0, 0, 39, // Length
+				0, 0, 0xfd, 0xea, // Peer AS
+				0, 0, 0xfd, 0xe9, // Local AS
+				0, 0, // Interface Index
+				0, 1, // Address Family
+				10, 0, 0, 2, // Peer IP Address
+				10, 0, 0, 1, // Local IP Address
+				0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+				0, 19, 4,
+			},
+		},
+		{
+			name:     "Unsupported record",
+			input:    &BGPOpen{},
+			wantFail: true,
+		},
+	}
+
+	for _, test := range tests {
+		res, err := SerializeMRTRecord(ts, test.input)
+		if test.wantFail {
+			assert.Error(t, err, test.name)
+			continue
+		}
+
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.expected, res, test.name)
+	}
+}
+
+func TestReadMRTRecord(t *testing.T) {
+	ts := time.Unix(1500000000, 0)
+	table := &MRTPeerIndexTable{
+		CollectorID: 167772161,
+		Peers: []MRTPeer{
+			{
+				BGPID:   167772161,
+				Address: net.IP{0, 0, 0, 0},
+				AS:      65001,
+			},
+			{
+				BGPID:   167772162,
+				Address: net.IP{10, 0, 0, 2},
+				AS:      65002,
+			},
+		},
+	}
+	rib := &MRTRIB{
+		Sequence: 1,
+		Prefix:   [4]byte{10, 1, 1, 0},
+		Pfxlen:   24,
+		AddPath:  true,
+		Entries: []MRTRIBEntry{
+			{
+				PeerIndex:      1,
+				Originated:     ts,
+				PathIdentifier: 100,
+				PathAttributes: &PathAttribute{
+					Length:     1,
+					TypeCode:   OriginAttr,
+					Transitive: true,
+					Value:      uint8(IGP),
+					Next: &PathAttribute{
+						Length:     6,
+						TypeCode:   ASPathAttr,
+						Transitive: true,
+						Value: ASPath{
+							{
+								Type:  ASSequence,
+								Count: 1,
+								ASNs:  []uint32{65002},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	msg := &MRTBGP4MPMessage{
+		PeerAS:       65002,
+		LocalAS:      65001,
+		PeerAddress:  net.IP{10, 0, 0, 2},
+		LocalAddress: net.IP{10, 0, 0, 1},
+		Message:      SerializeKeepaliveMsg(),
+	}
+
+	buf := bytes.NewBuffer(nil)
+	for _, body := range []interface{}{table, rib, msg} {
+		rec, err := SerializeMRTRecord(ts, body)
+		if err != nil {
+			t.Fatalf("Unable to serialize record: %v", err)
+		}
+		buf.Write(rec)
+	}
+
+	// Records of unknown types are passed on undecoded
+	buf.Write([]byte{
+		0x59, 0x68, 0x2f, 0x00, // Timestamp
+		0, 17, // Type
+		0, 4, // Subtype
+		0, 0, 0, 2, // Length
+		1, 2,
+	})
+
+	expected := []*MRTRecord{
+		{
+			Timestamp: ts,
+			Type:      MRTTableDumpV2Type,
+			Subtype:   MRTPeerIndexTableSubtype,
+			Body:      table,
+		},
+		{
+			Timestamp: ts,
+			Type:      MRTTableDumpV2Type,
+			Subtype:   MRTRIBIPv4UnicastAddPathSubtype,
+			Body:      rib,
+		},
+		{
+			Timestamp: ts,
+			Type:      MRTBGP4MPType,
+			Subtype:   MRTBGP4MPMessageAS4Subtype,
+			Body:      msg,
+		},
+		{
+			Timestamp: ts,
+			Type:      17,
+			Subtype:   4,
+			Body:      []byte{1, 2},
+		},
+	}
+
+	for _, e := range expected {
+		rec, err := ReadMRTRecord(buf)
+		if err != nil {
+			t.Fatalf("Unable to read record: %v", err)
+		}
+		assert.Equal(t, e, rec)
+	}
+
+	_, err := ReadMRTRecord(buf)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReadMRTRecordFailures(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{
+			name: "Truncated record",
+			input: []byte{
+				0, 0, 0, 0, // Timestamp
+				0, 13, // Type
+				0, 1, // Subtype
+				0, 0, 0, 10, // Length
+				0, 0, 0, 1,
+			},
+		},
+		{
+			name: "Truncated peer index table",
+			input: []byte{
+				0, 0, 0, 0, // Timestamp
+				0, 13, // Type
+				0, 1, // Subtype
+				0, 0, 0, 12, // Length
+				0, 0, 0, 1, // Collector BGP ID
+				0, 0, // View Name Length
+				0, 1, // Peer Count
+				2,          // Peer Type
+				0, 0, 0, 1, // Peer BGP ID
+			},
+		},
+		{
+			name: "Invalid prefix length",
+			input: []byte{
+				0, 0, 0, 0, // Timestamp
+				0, 13, // Type
+				0, 2, // Subtype
+				0, 0, 0, 7, // Length
+				0, 0, 0, 1, // Sequence
+				33, // Prefix Length
+				0, 0,
+			},
+		},
+		{
+			name: "Unsupported address family",
+			input: []byte{
+				0, 0, 0, 0, // Timestamp
+				0, 16, // Type
+				0, 4, // Subtype
+				0, 0, 0, 12, // Length
+				0, 0, 0, 1, // Peer AS
+				0, 0, 0, 2, // Local AS
+				0, 0, // Interface Index
+				0, 3, // Address Family
+			},
+		},
+	}
+
+	for _, test := range tests {
+		_, err := ReadMRTRecord(bytes.NewBuffer(test.input))
+		assert.Error(t, err, test.name)
+	}
+}
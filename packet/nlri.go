@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"math"
 	"net"
+
+	"github.com/taktv6/tflow2/convert"
 )
 
-func decodeNLRIs(buf *bytes.Buffer, length uint16) (*NLRI, error) {
+func decodeNLRIs(buf *bytes.Buffer, length uint16, addPath bool) (*NLRI, error) {
 	var ret *NLRI
 	var eol *NLRI
 	var nlri *NLRI
@@ -16,7 +18,7 @@ func decodeNLRIs(buf *bytes.Buffer, length uint16) (*NLRI, error) {
 	p := uint16(0)
 
 	for p < length {
-		nlri, consumed, err = decodeNLRI(buf)
+		nlri, consumed, err = decodeNLRI(buf, addPath)
 		if err != nil {
 			return nil, fmt.Errorf("Unable to decode NLRI: %v", err)
 		}
@@ -35,10 +37,19 @@ func decodeNLRIs(buf *bytes.Buffer, length uint16) (*NLRI, error) {
 	return ret, nil
 }
 
-func decodeNLRI(buf *bytes.Buffer) (*NLRI, uint8, error) {
+func decodeNLRI(buf *bytes.Buffer, addPath bool) (*NLRI, uint8, error) {
 	var addr [4]byte
 	nlri := &NLRI{}
 
+	consumed := uint8(0)
+	if addPath {
+		err := decode(buf, []interface{}{&nlri.PathIdentifier})
+		if err != nil {
+			return nil, 0, err
+		}
+		consumed += 4
+	}
+
 	err := decode(buf, []interface{}{&nlri.Pfxlen})
 	if err != nil {
 		return nil, 0, err
@@ -56,5 +67,16 @@ func decodeNLRI(buf *bytes.Buffer) (*NLRI, uint8, error) {
 		}
 	}
 	nlri.IP = addr
-	return nlri, toCopy + 1, nil
+	return nlri, consumed + toCopy + 1, nil
+}
+
+func (n *NLRI) serialize(buf *bytes.Buffer, addPath bool) {
+	addr := n.IP.([4]byte)
+	toCopy := uint8(math.Ceil(float64(n.Pfxlen) / float64(OctetLen)))
+
+	if addPath {
+		buf.Write(convert.Uint32Byte(n.PathIdentifier))
+	}
+	buf.WriteByte(n.Pfxlen)
+	buf.Write(addr[:toCopy])
 }
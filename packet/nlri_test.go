@@ -11,6 +11,7 @@ func TestDecodeNLRIs(t *testing.T) {
 	tests := []struct {
 		name     string
 		input    []byte
+		addPath  bool
 		wantFail bool
 		expected *NLRI
 	}{
@@ -44,11 +45,30 @@ func TestDecodeNLRIs(t *testing.T) {
 			},
 			wantFail: true,
 		},
+		{
+			name: "Valid NRLI with path identifiers",
+			input: []byte{
+				0, 0, 0, 1, 24, 192, 168, 0,
+				0, 0, 0, 2, 24, 192, 168, 0,
+			},
+			addPath:  true,
+			wantFail: false,
+			expected: &NLRI{
+				PathIdentifier: 1,
+				IP:             [4]byte{192, 168, 0, 0},
+				Pfxlen:         24,
+				Next: &NLRI{
+					PathIdentifier: 2,
+					IP:             [4]byte{192, 168, 0, 0},
+					Pfxlen:         24,
+				},
+			},
+		},
 	}
 
 	for _, test := range tests {
 		buf := bytes.NewBuffer(test.input)
-		res, err := decodeNLRIs(buf, uint16(len(test.input)))
+		res, err := decodeNLRIs(buf, uint16(len(test.input)), test.addPath)
 
 		if test.wantFail && err == nil {
 			t.Errorf("Expected error did not happen for test %q", test.name)
@@ -66,6 +86,7 @@ func TestDecodeNLRI(t *testing.T) {
 	tests := []struct {
 		name     string
 		input    []byte
+		addPath  bool
 		wantFail bool
 		expected *NLRI
 	}{
@@ -110,11 +131,32 @@ func TestDecodeNLRI(t *testing.T) {
 			input:    []byte{},
 			wantFail: true,
 		},
+		{
+			name: "Valid NRLI with path identifier",
+			input: []byte{
+				0, 0, 1, 0, 25, 192, 168, 0, 128,
+			},
+			addPath:  true,
+			wantFail: false,
+			expected: &NLRI{
+				PathIdentifier: 256,
+				IP:             [4]byte{192, 168, 0, 128},
+				Pfxlen:         25,
+			},
+		},
+		{
+			name: "Incomplete path identifier",
+			input: []byte{
+				0, 0, 1,
+			},
+			addPath:  true,
+			wantFail: true,
+		},
 	}
 
 	for _, test := range tests {
 		buf := bytes.NewBuffer(test.input)
-		res, _, err := decodeNLRI(buf)
+		res, _, err := decodeNLRI(buf, test.addPath)
 
 		if test.wantFail && err == nil {
 			t.Errorf("Expected error did not happen for test %q", test.name)
@@ -0,0 +1,13 @@
+package packet
+
+// DecodeOptions define session specific properties required to decode BGP messages
+type DecodeOptions struct {
+	AddPath bool // NLRI carry path identifiers (RFC7911)
+	ASN4    bool // AS_PATHs carry 4 byte ASNs (RFC6793)
+}
+
+// EncodeOptions define session specific properties required to encode BGP messages
+type EncodeOptions struct {
+	AddPath bool // NLRI carry path identifiers (RFC7911)
+	ASN4    bool // AS_PATHs carry 4 byte ASNs (RFC6793)
+}
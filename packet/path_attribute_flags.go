@@ -44,3 +44,20 @@ func isExtendedLength(x uint8) bool {
 	}
 	return false
 }
+
+func (pa *PathAttribute) serializeFlags(extendedLength bool) uint8 {
+	flags := uint8(0)
+	if pa.Optional {
+		flags |= 128
+	}
+	if pa.Transitive {
+		flags |= 64
+	}
+	if pa.Partial {
+		flags |= 32
+	}
+	if extendedLength {
+		flags |= 16
+	}
+	return flags
+}
@@ -3,9 +3,12 @@ package packet
 import (
 	"bytes"
 	"fmt"
+
+	"github.com/taktv6/tflow2/convert"
 )
 
-func decodePathAttrs(buf *bytes.Buffer, tpal uint16) (*PathAttribute, error) {
+// decodePathAttrs decodes tpal bytes of path attributes. asn4 is set if AS_PATHs carry 4 byte ASNs (RFC6793).
+func decodePathAttrs(buf *bytes.Buffer, tpal uint16, asn4 bool) (*PathAttribute, error) {
 	var ret *PathAttribute
 	var eol *PathAttribute
 	var pa *PathAttribute
@@ -14,7 +17,7 @@ func decodePathAttrs(buf *bytes.Buffer, tpal uint16) (*PathAttribute, error) {
 
 	p := uint16(0)
 	for p < tpal {
-		pa, consumed, err = decodePathAttr(buf)
+		pa, consumed, err = decodePathAttr(buf, asn4)
 		if err != nil {
 			return nil, fmt.Errorf("Unable to decode path attr: %v", err)
 		}
@@ -32,7 +35,7 @@ func decodePathAttrs(buf *bytes.Buffer, tpal uint16) (*PathAttribute, error) {
 	return ret, nil
 }
 
-func decodePathAttr(buf *bytes.Buffer) (pa *PathAttribute, consumed uint16, err error) {
+func decodePathAttr(buf *bytes.Buffer, asn4 bool) (pa *PathAttribute, consumed uint16, err error) {
 	pa = &PathAttribute{}
 
 	err = decodePathAttrFlags(buf, pa)
@@ -59,7 +62,7 @@ func decodePathAttr(buf *bytes.Buffer) (pa *PathAttribute, consumed uint16, err
 			return nil, consumed, fmt.Errorf("Failed to decode Origin: %v", err)
 		}
 	case ASPathAttr:
-		if err := pa.decodeASPath(buf); err != nil {
+		if err := pa.decodeASPath(buf, asn4); err != nil {
 			return nil, consumed, fmt.Errorf("Failed to decode AS Path: %v", err)
 		}
 	case NextHopAttr:
@@ -78,6 +81,26 @@ func decodePathAttr(buf *bytes.Buffer) (pa *PathAttribute, consumed uint16, err
 		if err := pa.decodeAggregator(buf); err != nil {
 			return nil, consumed, fmt.Errorf("Failed to decode Aggregator: %v", err)
 		}
+	case CommunitiesAttr:
+		if err := pa.decodeCommunities(buf); err != nil {
+			return nil, consumed, fmt.Errorf("Failed to decode Communities: %v", err)
+		}
+	case OriginatorIDAttr:
+		if err := pa.decodeOriginatorID(buf); err != nil {
+			return nil, consumed, fmt.Errorf("Failed to decode ORIGINATOR_ID: %v", err)
+		}
+	case ClusterListAttr:
+		if err := pa.decodeClusterList(buf); err != nil {
+			return nil, consumed, fmt.Errorf("Failed to decode CLUSTER_LIST: %v", err)
+		}
+	case OnlyToCustomerAttr:
+		if err := pa.decodeOnlyToCustomer(buf); err != nil {
+			return nil, consumed, fmt.Errorf("Failed to decode OTC: %v", err)
+		}
+	case MultiProtocolUnreachNLRIAttr:
+		if err := pa.decodeMultiProtocolUnreachNLRI(buf); err != nil {
+			return nil, consumed, fmt.Errorf("Failed to decode MP_UNREACH_NLRI: %v", err)
+		}
 	case AtomicAggrAttr:
 		// Nothing to do for 0 octet long attribute
 	default:
@@ -102,7 +125,7 @@ func (pa *PathAttribute) decodeOrigin(buf *bytes.Buffer) error {
 	return dumpNBytes(buf, pa.Length-p)
 }
 
-func (pa *PathAttribute) decodeASPath(buf *bytes.Buffer) error {
+func (pa *PathAttribute) decodeASPath(buf *bytes.Buffer, asn4 bool) error {
 	pa.Value = make(ASPath, 0)
 
 	p := uint16(0)
@@ -117,7 +140,7 @@ func (pa *PathAttribute) decodeASPath(buf *bytes.Buffer) error {
 		}
 		p += 2
 
-		if segment.Type != ASSet && segment.Type != ASSequence {
+		if !validASPathSegmentType(segment.Type) {
 			return fmt.Errorf("Invalid AS Path segment type: %d", segment.Type)
 		}
 
@@ -126,6 +149,17 @@ func (pa *PathAttribute) decodeASPath(buf *bytes.Buffer) error {
 		}
 
 		for i := uint8(0); i < segment.Count; i++ {
+			if asn4 {
+				asn := uint32(0)
+				if err := decode(buf, []interface{}{&asn}); err != nil {
+					return err
+				}
+				p += 4
+
+				segment.ASNs = append(segment.ASNs, asn)
+				continue
+			}
+
 			asn := uint16(0)
 
 			err := decode(buf, []interface{}{&asn})
@@ -203,6 +237,93 @@ func (pa *PathAttribute) decodeAggregator(buf *bytes.Buffer) error {
 	return dumpNBytes(buf, pa.Length-p)
 }
 
+func (pa *PathAttribute) decodeCommunities(buf *bytes.Buffer) error {
+	comms, err := pa.decodeUint32List(buf)
+	if err != nil {
+		return fmt.Errorf("Unable to read communities: %v", err)
+	}
+
+	pa.Value = comms
+	return nil
+}
+
+// decodeOriginatorID decodes an ORIGINATOR_ID attribute (RFC4456)
+func (pa *PathAttribute) decodeOriginatorID(buf *bytes.Buffer) error {
+	if pa.Length != 4 {
+		return fmt.Errorf("Invalid length: %d", pa.Length)
+	}
+
+	id, err := pa.decodeUint32(buf)
+	if err != nil {
+		return fmt.Errorf("Unable to decode originator ID: %v", err)
+	}
+
+	pa.Value = id
+	return nil
+}
+
+// decodeOnlyToCustomer decodes an Only to Customer attribute (RFC9234)
+func (pa *PathAttribute) decodeOnlyToCustomer(buf *bytes.Buffer) error {
+	if pa.Length != 4 {
+		return fmt.Errorf("Invalid length: %d", pa.Length)
+	}
+
+	asn, err := pa.decodeUint32(buf)
+	if err != nil {
+		return fmt.Errorf("Unable to decode ASN: %v", err)
+	}
+
+	pa.Value = asn
+	return nil
+}
+
+// decodeClusterList decodes a CLUSTER_LIST attribute (RFC4456)
+func (pa *PathAttribute) decodeClusterList(buf *bytes.Buffer) error {
+	ids, err := pa.decodeUint32List(buf)
+	if err != nil {
+		return fmt.Errorf("Unable to read cluster list: %v", err)
+	}
+
+	pa.Value = ids
+	return nil
+}
+
+// decodeUint32List decodes an attribute value consisting of a list of 4 octet values
+func (pa *PathAttribute) decodeUint32List(buf *bytes.Buffer) ([]uint32, error) {
+	if pa.Length%4 != 0 {
+		return nil, fmt.Errorf("Invalid length %d", pa.Length)
+	}
+
+	list := make([]uint32, pa.Length/4)
+	for i := range list {
+		err := decode(buf, []interface{}{&list[i]})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return list, nil
+}
+
+// decodeMultiProtocolUnreachNLRI decodes an MP_UNREACH_NLRI attribute (RFC4760). Withdrawn
+// routes are kept encoded as only IPv4 unicast (without MP extensions) is supported.
+func (pa *PathAttribute) decodeMultiProtocolUnreachNLRI(buf *bytes.Buffer) error {
+	if pa.Length < 3 {
+		return fmt.Errorf("Invalid length: %d", pa.Length)
+	}
+
+	mp := MultiProtocolUnreachNLRI{
+		WithdrawnRoutes: make([]byte, pa.Length-3),
+	}
+	err := decode(buf, []interface{}{&mp.AFI, &mp.SAFI, mp.WithdrawnRoutes})
+	if err != nil {
+		return err
+	}
+
+	pa.Value = mp
+	return nil
+}
+
 func (pa *PathAttribute) setLength(buf *bytes.Buffer) (int, error) {
 	bytesRead := 0
 	if pa.ExtendedLength {
@@ -254,3 +375,63 @@ func dumpNBytes(buf *bytes.Buffer, n uint16) error {
 	}
 	return nil
 }
+
+// serialize appends pa to buf. asn4 is set if AS_PATHs carry 4 byte ASNs (RFC6793).
+func (pa *PathAttribute) serialize(buf *bytes.Buffer, asn4 bool) error {
+	value := bytes.NewBuffer(nil)
+
+	switch pa.TypeCode {
+	case OriginAttr:
+		value.WriteByte(pa.Value.(uint8))
+	case ASPathAttr:
+		pa.serializeASPath(value, asn4)
+	case NextHopAttr:
+		addr := pa.Value.([4]byte)
+		value.Write(addr[:])
+	case MEDAttr, LocalPrefAttr, OriginatorIDAttr, OnlyToCustomerAttr:
+		value.Write(convert.Uint32Byte(pa.Value.(uint32)))
+	case AtomicAggrAttr:
+		// Nothing to do for 0 octet long attribute
+	case AggregatorAttr:
+		aggr := pa.Value.(Aggretator)
+		value.Write(convert.Uint16Byte(aggr.ASN))
+		value.Write(aggr.Addr[:])
+	case CommunitiesAttr, ClusterListAttr:
+		for _, c := range pa.Value.([]uint32) {
+			value.Write(convert.Uint32Byte(c))
+		}
+	case MultiProtocolUnreachNLRIAttr:
+		mp := pa.Value.(MultiProtocolUnreachNLRI)
+		value.Write(convert.Uint16Byte(mp.AFI))
+		value.WriteByte(mp.SAFI)
+		value.Write(mp.WithdrawnRoutes)
+	default:
+		return fmt.Errorf("Unable to serialize attribute type code: %d", pa.TypeCode)
+	}
+
+	extendedLength := value.Len() > 255
+	buf.WriteByte(pa.serializeFlags(extendedLength))
+	buf.WriteByte(pa.TypeCode)
+	if extendedLength {
+		buf.Write(convert.Uint16Byte(uint16(value.Len())))
+	} else {
+		buf.WriteByte(uint8(value.Len()))
+	}
+	buf.Write(value.Bytes())
+
+	return nil
+}
+
+func (pa *PathAttribute) serializeASPath(buf *bytes.Buffer, asn4 bool) {
+	for _, segment := range pa.Value.(ASPath) {
+		buf.WriteByte(segment.Type)
+		buf.WriteByte(uint8(len(segment.ASNs)))
+		for _, asn := range segment.ASNs {
+			if asn4 {
+				buf.Write(convert.Uint32Byte(asn))
+				continue
+			}
+			buf.Write(convert.Uint16Byte(uint16(asn)))
+		}
+	}
+}
@@ -50,7 +50,7 @@ func TestDecodePathAttrs(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		res, err := decodePathAttrs(bytes.NewBuffer(test.input), uint16(len(test.input)))
+		res, err := decodePathAttrs(bytes.NewBuffer(test.input), uint16(len(test.input)), false)
 
 		if test.wantFail && err == nil {
 			t.Errorf("Expected error did not happen for test %q", test.name)
@@ -161,6 +161,58 @@ func TestDecodePathAttr(t *testing.T) {
 			},
 			wantFail: true,
 		},
+		{
+			name: "Valid ORIGINATOR_ID",
+			input: []byte{
+				128,         // Attr. Flags
+				9,           // Attr. Type Code
+				4,           // Attr. Length
+				10, 0, 0, 1, // 10.0.0.1
+			},
+			wantFail: false,
+			expected: &PathAttribute{
+				Length:   4,
+				Optional: true,
+				TypeCode: OriginatorIDAttr,
+				Value:    uint32(167772161),
+			},
+		},
+		{
+			name: "Valid OTC",
+			input: []byte{
+				192,          // Attr. Flags
+				35,           // Attr. Type Code
+				4,            // Attr. Length
+				0, 0, 0, 100, // AS100
+			},
+			wantFail: false,
+			expected: &PathAttribute{
+				Length:     4,
+				Optional:   true,
+				Transitive: true,
+				TypeCode:   OnlyToCustomerAttr,
+				Value:      uint32(100),
+			},
+		},
+		{
+			name: "OTC with invalid length",
+			input: []byte{
+				192,    // Attr. Flags
+				35,     // Attr. Type Code
+				2,      // Attr. Length
+				0, 100, // Truncated ASN
+			},
+			wantFail: true,
+		},
+		{
+			name: "Missing value CLUSTER_LIST",
+			input: []byte{
+				128, // Attr. Flags
+				10,  // Attr. Type Code
+				4,   // Attr. Length
+			},
+			wantFail: true,
+		},
 		{
 			name: "Not supported attribute",
 			input: []byte{
@@ -173,7 +225,7 @@ func TestDecodePathAttr(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		res, _, err := decodePathAttr(bytes.NewBuffer(test.input))
+		res, _, err := decodePathAttr(bytes.NewBuffer(test.input), false)
 
 		if test.wantFail && err == nil {
 			t.Errorf("Expected error did not happen for test %q", test.name)
@@ -264,6 +316,7 @@ func TestDecodeASPath(t *testing.T) {
 		input          []byte
 		wantFail       bool
 		explicitLength uint16
+		asn4           bool
 		expected       *PathAttribute
 	}{
 		{
@@ -308,6 +361,67 @@ func TestDecodeASPath(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Confederation sequence",
+			input: []byte{
+				3, // AS_CONFED_SEQUENCE
+				2, // Path Length
+				0xfd, 0xe8, 0xfd, 0xe9,
+				2, // AS_SEQUENCE
+				1, // Path Length
+				0, 100,
+			},
+			wantFail: false,
+			expected: &PathAttribute{
+				Length: 10,
+				Value: ASPath{
+					ASPathSegment{
+						Type:  3,
+						Count: 2,
+						ASNs: []uint32{
+							65000, 65001,
+						},
+					},
+					ASPathSegment{
+						Type:  2,
+						Count: 1,
+						ASNs: []uint32{
+							100,
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "4 byte ASNs",
+			input: []byte{
+				2, // AS_SEQUENCE
+				2, // Path Length
+				0, 3, 0x0d, 0x40, 0, 0, 0, 100,
+			},
+			asn4: true,
+			expected: &PathAttribute{
+				Length: 10,
+				Value: ASPath{
+					ASPathSegment{
+						Type:  2,
+						Count: 2,
+						ASNs: []uint32{
+							200000, 100,
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "Invalid segment type",
+			input: []byte{
+				5, // Unknown
+				1, // Path Length
+				0, 100,
+			},
+			wantFail: true,
+		},
 		{
 			name:           "Empty input",
 			input:          []byte{},
@@ -333,7 +447,7 @@ func TestDecodeASPath(t *testing.T) {
 		pa := &PathAttribute{
 			Length: l,
 		}
-		err := pa.decodeASPath(bytes.NewBuffer(test.input))
+		err := pa.decodeASPath(bytes.NewBuffer(test.input), test.asn4)
 
 		if test.wantFail && err == nil {
 			t.Errorf("Expected error did not happen for test %q", test.name)
@@ -591,6 +705,169 @@ func TestDecodeAggregator(t *testing.T) {
 	}
 }
 
+func TestDecodeCommunities(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          []byte
+		wantFail       bool
+		explicitLength uint16
+		expected       *PathAttribute
+	}{
+		{
+			name: "Two communities",
+			input: []byte{
+				0, 200, 0, 100, // 200:100
+				0xff, 0xff, 0, 6, // LLGR_STALE
+			},
+			wantFail: false,
+			expected: &PathAttribute{
+				Length: 8,
+				Value:  []uint32{13107300, LLGRStaleCommunity},
+			},
+		},
+		{
+			name:     "Invalid length",
+			input:    []byte{0, 200, 0},
+			wantFail: true,
+		},
+		{
+			name:           "Incomplete",
+			input:          []byte{0, 200, 0, 100},
+			explicitLength: 8,
+			wantFail:       true,
+		},
+	}
+
+	for _, test := range tests {
+		l := uint16(len(test.input))
+		if test.explicitLength != 0 {
+			l = test.explicitLength
+		}
+		pa := &PathAttribute{
+			Length: l,
+		}
+		err := pa.decodeCommunities(bytes.NewBuffer(test.input))
+
+		if test.wantFail && err == nil {
+			t.Errorf("Expected error did not happen for test %q", test.name)
+		}
+
+		if !test.wantFail && err != nil {
+			t.Errorf("Unexpected failure for test %q: %v", test.name, err)
+		}
+
+		if err != nil {
+			continue
+		}
+
+		assert.Equal(t, test.expected, pa)
+	}
+}
+
+func TestDecodeOriginatorID(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          []byte
+		wantFail       bool
+		explicitLength uint16
+		expected       *PathAttribute
+	}{
+		{
+			name:     "Valid originator ID",
+			input:    []byte{10, 0, 0, 1},
+			wantFail: false,
+			expected: &PathAttribute{
+				Length: 4,
+				Value:  uint32(167772161),
+			},
+		},
+		{
+			name:     "Invalid length",
+			input:    []byte{10, 0, 0, 1, 0},
+			wantFail: true,
+		},
+		{
+			name:           "Incomplete",
+			input:          []byte{10, 0},
+			explicitLength: 4,
+			wantFail:       true,
+		},
+	}
+
+	for _, test := range tests {
+		l := uint16(len(test.input))
+		if test.explicitLength != 0 {
+			l = test.explicitLength
+		}
+		pa := &PathAttribute{
+			Length: l,
+		}
+		err := pa.decodeOriginatorID(bytes.NewBuffer(test.input))
+
+		if test.wantFail && err == nil {
+			t.Errorf("Expected error did not happen for test %q", test.name)
+		}
+
+		if !test.wantFail && err != nil {
+			t.Errorf("Unexpected failure for test %q: %v", test.name, err)
+		}
+
+		if err != nil {
+			continue
+		}
+
+		assert.Equal(t, test.expected, pa)
+	}
+}
+
+func TestDecodeClusterList(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []byte
+		wantFail bool
+		expected *PathAttribute
+	}{
+		{
+			name: "Two cluster IDs",
+			input: []byte{
+				10, 0, 0, 1, // 10.0.0.1
+				10, 0, 0, 2, // 10.0.0.2
+			},
+			wantFail: false,
+			expected: &PathAttribute{
+				Length: 8,
+				Value:  []uint32{167772161, 167772162},
+			},
+		},
+		{
+			name:     "Invalid length",
+			input:    []byte{10, 0, 0},
+			wantFail: true,
+		},
+	}
+
+	for _, test := range tests {
+		pa := &PathAttribute{
+			Length: uint16(len(test.input)),
+		}
+		err := pa.decodeClusterList(bytes.NewBuffer(test.input))
+
+		if test.wantFail && err == nil {
+			t.Errorf("Expected error did not happen for test %q", test.name)
+		}
+
+		if !test.wantFail && err != nil {
+			t.Errorf("Unexpected failure for test %q: %v", test.name, err)
+		}
+
+		if err != nil {
+			continue
+		}
+
+		assert.Equal(t, test.expected, pa)
+	}
+}
+
 func TestSetLength(t *testing.T) {
 	tests := []struct {
 		name             string
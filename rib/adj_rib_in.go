@@ -0,0 +1,586 @@
+package rib
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/taktv6/tbgp/filter"
+	"github.com/taktv6/tbgp/net"
+	"github.com/taktv6/tbgp/packet"
+	"github.com/taktv6/tbgp/route"
+	"github.com/taktv6/tbgp/rpki"
+)
+
+// AdjRIBIn holds the routes received from a single neighbor. Only routes accepted by
+// the import filter are passed on to clients. If pre-policy routes are retained
+// (soft reconfiguration inbound) the routes as received are kept as well, which allows
+// re-applying the import filter without the neighbor sending its routes again.
+type AdjRIBIn struct {
+	clientManager
+	mu               sync.RWMutex
+	importFilter     *filter.Filter
+	keepPrePolicy    bool
+	prePolicy        map[pathKey]*route.Path
+	prePolicyClients clientManager
+	routes           map[pathKey]*route.Path
+	stale            map[pathKey]struct{}
+	validator        OriginValidator
+	localASN         uint32
+	verifier         PathVerifier
+	neighborAS       uint32
+	downstream       bool
+	dampening        *dampening
+}
+
+// OriginValidator determines the route origin validation state of routes (RFC6811)
+type OriginValidator interface {
+	Validate(pfx *net.Prefix, originAS uint32) rpki.ValidationState
+}
+
+// PathVerifier determines the ASPA state of routes based on their AS_PATH (draft-ietf-sidrops-aspa-verification)
+type PathVerifier interface {
+	Verify(p packet.ASPath, neighborAS uint32, downstream bool) rpki.ASPAState
+}
+
+// pathKey identifies a path received from a neighbor by prefix and path identifier (RFC7911)
+type pathKey struct {
+	pfx    net.Prefix
+	pathID uint32
+}
+
+func newPathKey(pfx *net.Prefix, p *route.Path) pathKey {
+	return pathKey{
+		pfx:    *pfx,
+		pathID: p.PathIdentifier,
+	}
+}
+
+func (k pathKey) prefix() *net.Prefix {
+	return net.NewPfx(k.pfx.Addr(), k.pfx.Pfxlen())
+}
+
+// NewAdjRIBIn creates a new empty Adj-RIB-In
+func NewAdjRIBIn(importFilter *filter.Filter, keepPrePolicy bool) *AdjRIBIn {
+	return &AdjRIBIn{
+		clientManager:    newClientManager(),
+		importFilter:     importFilter,
+		keepPrePolicy:    keepPrePolicy,
+		prePolicy:        make(map[pathKey]*route.Path),
+		prePolicyClients: newClientManager(),
+		routes:           make(map[pathKey]*route.Path),
+		stale:            make(map[pathKey]struct{}),
+	}
+}
+
+// Register adds a client and sends it all routes currently accepted
+func (a *AdjRIBIn) Register(client RouteTableClient) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.add(client, ClientOptions{})
+	for k, p := range a.routes {
+		client.AddPath(k.prefix(), p)
+	}
+}
+
+// Unregister removes a client
+func (a *AdjRIBIn) Unregister(client RouteTableClient) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.remove(client)
+}
+
+// RegisterPrePolicy adds a client receiving the routes as received and sends it all of them.
+// Pre-policy routes have to be retained.
+func (a *AdjRIBIn) RegisterPrePolicy(client RouteTableClient) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.keepPrePolicy {
+		return fmt.Errorf("Pre-policy routes are not retained")
+	}
+
+	a.prePolicyClients.add(client, ClientOptions{})
+	for k, p := range a.prePolicy {
+		client.AddPath(k.prefix(), p)
+	}
+
+	return nil
+}
+
+// UnregisterPrePolicy removes a client added by RegisterPrePolicy
+func (a *AdjRIBIn) UnregisterPrePolicy(client RouteTableClient) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.prePolicyClients.remove(client)
+}
+
+// AddPath adds or replaces the path for pfx with the path identifier of p and passes
+// it on to all clients if accepted by the import filter
+func (a *AdjRIBIn) AddPath(pfx *net.Prefix, p *route.Path) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	k := newPathKey(pfx, p)
+	changed := false
+	if old, ok := a.prePolicy[k]; ok && !old.Equal(p) && !old.LongLivedStale() {
+		changed = true
+	}
+
+	if a.keepPrePolicy {
+		a.prePolicy[k] = p
+		a.prePolicyClients.addPath(pfx, p)
+	}
+	delete(a.stale, k)
+	a.importPath(k, p)
+
+	if a.dampening != nil {
+		if changed {
+			a.flap(k, a.dampening.params.AttributeChangePenalty)
+		} else {
+			a.dampening.expire(k)
+		}
+	}
+
+	return nil
+}
+
+// importPath validates p, runs it through the import filter and updates the accepted routes accordingly
+func (a *AdjRIBIn) importPath(k pathKey, p *route.Path) {
+	p, reject := a.importFilter.ProcessTerms(k.prefix(), a.validate(k.prefix(), p))
+	if reject {
+		a.removeAccepted(k)
+		return
+	}
+
+	if old, ok := a.routes[k]; ok && old.Equal(p) {
+		return
+	}
+
+	a.routes[k] = p
+	a.advertise(k, p)
+}
+
+// advertise passes p on to all clients unless it is suppressed by route flap dampening
+func (a *AdjRIBIn) advertise(k pathKey, p *route.Path) {
+	if a.dampening.suppressed(k) {
+		return
+	}
+
+	a.addPath(k.prefix(), p)
+}
+
+// RemovePath removes the path for pfx with the path identifier of p and withdraws it from all clients
+func (a *AdjRIBIn) RemovePath(pfx *net.Prefix, p *route.Path) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	k := newPathKey(pfx, p)
+	if !a.removePathLocked(k) {
+		return false
+	}
+
+	if a.dampening != nil {
+		a.flap(k, a.dampening.params.WithdrawPenalty)
+	}
+
+	return true
+}
+
+func (a *AdjRIBIn) removePathLocked(k pathKey) bool {
+	old, received := a.prePolicy[k]
+	if received {
+		delete(a.prePolicy, k)
+		a.prePolicyClients.removePath(k.prefix(), old)
+	}
+	delete(a.stale, k)
+
+	return a.removeAccepted(k) || received
+}
+
+func (a *AdjRIBIn) removeAccepted(k pathKey) bool {
+	old, ok := a.routes[k]
+	if !ok {
+		return false
+	}
+
+	delete(a.routes, k)
+	if !a.dampening.suppressed(k) {
+		a.removePath(k.prefix(), old)
+	}
+
+	return true
+}
+
+// EnableDampening enables route flap dampening (RFC2439) of the paths received. Suppressed paths are
+// not passed on to the clients. Attribute changes are only detected if pre-policy routes are retained,
+// otherwise only withdrawals are penalized.
+func (a *AdjRIBIn) EnableDampening(params DampeningParameters) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.dampening = newDampening(params)
+}
+
+// flap penalizes the path identified by k. A path getting suppressed is withdrawn from all clients
+// until its penalty has decayed below the reuse threshold.
+func (a *AdjRIBIn) flap(k pathKey, penalty uint32) {
+	a.scheduleCollect()
+	if !a.dampening.penalize(k, penalty) {
+		return
+	}
+
+	if p, ok := a.routes[k]; ok {
+		a.removePath(k.prefix(), p)
+	}
+	a.scheduleReuse(k)
+}
+
+func (a *AdjRIBIn) scheduleReuse(k pathKey) {
+	h := a.dampening.history[k]
+	h.timer = time.AfterFunc(a.dampening.reuseIn(h, a.dampening.now()), func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+
+		a.reuse(k)
+	})
+}
+
+// scheduleCollect disposes expired flap histories after a half-life unless already scheduled.
+// Collection is repeated as long as histories remain, so paths that flapped and vanished do not pile up.
+func (a *AdjRIBIn) scheduleCollect() {
+	d := a.dampening
+	if d.gcTimer != nil {
+		return
+	}
+
+	var t *time.Timer
+	t = time.AfterFunc(d.params.HalfLife, func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+
+		// The dampening state may have been reset since
+		if a.dampening != d || d.gcTimer != t {
+			return
+		}
+
+		d.gcTimer = nil
+		d.collect()
+		if len(d.history) > 0 {
+			a.scheduleCollect()
+		}
+	})
+	d.gcTimer = t
+}
+
+// reuse passes a suppressed path on to the clients again once its penalty has decayed sufficiently
+func (a *AdjRIBIn) reuse(k pathKey) {
+	h, ok := a.dampening.history[k]
+	if !ok || !h.suppressed {
+		return
+	}
+
+	if !a.dampening.reusable(k) {
+		a.scheduleReuse(k)
+		return
+	}
+
+	if p, ok := a.routes[k]; ok {
+		a.addPath(k.prefix(), p)
+	}
+}
+
+// DampedPaths returns the flap history of all paths received recently
+func (a *AdjRIBIn) DampedPaths() ([]DampedPath, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.dampening == nil {
+		return nil, fmt.Errorf("Route flap dampening is not enabled")
+	}
+
+	return a.dampening.paths(), nil
+}
+
+// SetImportFilter replaces the import filter. Routes already held are not re-evaluated.
+func (a *AdjRIBIn) SetImportFilter(f *filter.Filter) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.importFilter = f
+}
+
+// SetOriginValidator enables route origin validation of all routes imported. Routes with an empty
+// AS_PATH are considered to be originated by localASN. Routes already held are not re-evaluated.
+func (a *AdjRIBIn) SetOriginValidator(v OriginValidator, localASN uint32) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.validator = v
+	a.localASN = localASN
+}
+
+// SetPathVerifier enables ASPA verification of the AS_PATH of all eBGP routes imported. Downstream verification
+// is used for routes received from a provider. A neighborAS of 0 skips checking the neighbor to be the leftmost AS
+// of the AS_PATH (for routes received from a route server). Routes already held are not re-evaluated.
+func (a *AdjRIBIn) SetPathVerifier(v PathVerifier, neighborAS uint32, downstream bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.verifier = v
+	a.neighborAS = neighborAS
+	a.downstream = downstream
+}
+
+// validate returns p carrying its current origin validation and ASPA states
+func (a *AdjRIBIn) validate(pfx *net.Prefix, p *route.Path) *route.Path {
+	state := p.ValidationState
+	if a.validator != nil {
+		originAS, ok := p.ASPath.OriginAS()
+		if !ok {
+			originAS = a.localASN
+		}
+		state = a.validator.Validate(pfx, originAS)
+	}
+
+	aspaState := p.ASPAState
+	if a.verifier != nil && p.EBGP {
+		aspaState = a.verifier.Verify(p.ASPath, a.neighborAS, a.downstream)
+	}
+
+	if p.ValidationState == state && p.ASPAState == aspaState {
+		return p
+	}
+
+	c := p.Copy()
+	c.ValidationState = state
+	c.ASPAState = aspaState
+	return c
+}
+
+// Revalidate re-evaluates the validation states of all routes covered by any of changed (e.g. the
+// prefixes of VRPs changed) and passes the changes on to the clients. If pre-policy routes are retained they
+// are imported again so the import filter can act on the new state. Otherwise only the state of the routes
+// accepted is updated. It returns the number of routes re-evaluated.
+func (a *AdjRIBIn) Revalidate(changed []*net.Prefix) int {
+	return a.revalidate(func(pfx *net.Prefix) bool {
+		return coveredByAny(pfx, changed)
+	})
+}
+
+// RevalidateAll re-evaluates the validation states of all routes (e.g. after ASPA records changed)
+// the same way as Revalidate does
+func (a *AdjRIBIn) RevalidateAll() int {
+	return a.revalidate(func(pfx *net.Prefix) bool {
+		return true
+	})
+}
+
+func (a *AdjRIBIn) revalidate(affected func(pfx *net.Prefix) bool) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	n := 0
+	for k, p := range a.received() {
+		if !affected(k.prefix()) {
+			continue
+		}
+		n++
+
+		if a.keepPrePolicy {
+			a.importPath(k, p)
+			continue
+		}
+
+		v := a.validate(k.prefix(), p)
+		if v != p {
+			a.routes[k] = v
+			a.advertise(k, v)
+		}
+	}
+
+	return n
+}
+
+func coveredByAny(pfx *net.Prefix, prefixes []*net.Prefix) bool {
+	for _, x := range prefixes {
+		if x.Equal(pfx) || x.Contains(pfx) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RetainsPrePolicy checks if the routes as received are retained
+func (a *AdjRIBIn) RetainsPrePolicy() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.keepPrePolicy
+}
+
+// ReapplyImportFilter runs all retained pre-policy routes through the import filter
+// again and passes the changes on to the clients
+func (a *AdjRIBIn) ReapplyImportFilter() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.keepPrePolicy {
+		return fmt.Errorf("Pre-policy routes are not retained")
+	}
+
+	for k, p := range a.prePolicy {
+		a.importPath(k, p)
+	}
+
+	return nil
+}
+
+// Flush removes all routes and withdraws them from all clients. The flap history is reset.
+func (a *AdjRIBIn) Flush() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for k := range a.routes {
+		a.removeAccepted(k)
+	}
+	for k, p := range a.prePolicy {
+		a.prePolicyClients.removePath(k.prefix(), p)
+	}
+	a.prePolicy = make(map[pathKey]*route.Path)
+	a.stale = make(map[pathKey]struct{})
+
+	// Pending reuse timers must not pass on paths of a session gone down
+	if a.dampening != nil {
+		a.dampening.stop()
+	}
+}
+
+// MarkStale marks all routes as stale. Stale routes are kept until
+// they are either received again or removed by RemoveStale.
+func (a *AdjRIBIn) MarkStale() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for k := range a.received() {
+		a.stale[k] = struct{}{}
+	}
+}
+
+// MarkLongLivedStale moves all stale routes to the long-lived stale phase (RFC9494 4.2).
+// Routes carrying the NO_LLGR community are removed, all others are tagged with LLGR_STALE.
+// It returns the number of routes removed.
+func (a *AdjRIBIn) MarkLongLivedStale() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	n := 0
+	for k := range a.stale {
+		p := a.received()[k]
+		if p == nil {
+			continue
+		}
+
+		if p.HasCommunity(packet.NoLLGRCommunity) {
+			if a.removePathLocked(k) {
+				n++
+			}
+			continue
+		}
+
+		if !a.keepPrePolicy {
+			a.routes[k] = p.MarkLongLivedStale()
+			a.advertise(k, a.routes[k])
+			continue
+		}
+
+		a.prePolicy[k] = p.MarkLongLivedStale()
+		a.prePolicyClients.addPath(k.prefix(), a.prePolicy[k])
+		a.importPath(k, a.prePolicy[k])
+	}
+
+	return n
+}
+
+// RemoveStale removes all routes still marked stale and returns how many were removed
+func (a *AdjRIBIn) RemoveStale() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	n := 0
+	for k := range a.stale {
+		if a.removePathLocked(k) {
+			n++
+		}
+	}
+
+	return n
+}
+
+// received returns the routes as received as far as they are known
+func (a *AdjRIBIn) received() map[pathKey]*route.Path {
+	if a.keepPrePolicy {
+		return a.prePolicy
+	}
+
+	return a.routes
+}
+
+// Count returns the number of paths accepted by the import filter
+func (a *AdjRIBIn) Count() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return len(a.routes)
+}
+
+// ReceivedCount returns the number of paths received. It equals Count if pre-policy routes are not retained.
+func (a *AdjRIBIn) ReceivedCount() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return len(a.received())
+}
+
+// Routes returns all routes accepted by the import filter (post-policy)
+func (a *AdjRIBIn) Routes() []*route.Route {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return dumpRoutes(a.routes)
+}
+
+// PrePolicyRoutes returns all routes as received (pre-policy)
+func (a *AdjRIBIn) PrePolicyRoutes() ([]*route.Route, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if !a.keepPrePolicy {
+		return nil, fmt.Errorf("Pre-policy routes are not retained")
+	}
+
+	return dumpRoutes(a.prePolicy), nil
+}
+
+func dumpRoutes(routes map[pathKey]*route.Path) []*route.Route {
+	byPfx := make(map[net.Prefix]*route.Route)
+	for k, p := range routes {
+		r, ok := byPfx[k.pfx]
+		if !ok {
+			r = route.NewRoute(k.prefix())
+			byPfx[k.pfx] = r
+		}
+		r.AddPath(p)
+	}
+
+	res := make([]*route.Route, 0, len(byPfx))
+	for _, r := range byPfx {
+		res = append(res, r)
+	}
+
+	return res
+}
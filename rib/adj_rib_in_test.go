@@ -0,0 +1,421 @@
+package rib
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taktv6/tbgp/filter"
+	"github.com/taktv6/tbgp/net"
+	"github.com/taktv6/tbgp/packet"
+	"github.com/taktv6/tbgp/route"
+	"github.com/taktv6/tbgp/rpki"
+)
+
+func TestAdjRIBInAddRemovePath(t *testing.T) {
+	pfx := net.NewPfx(167772160, 8) // 10.0.0.0/8
+	p := &route.Path{Source: 1, LocalPref: 100}
+
+	a := NewAdjRIBIn(nil, false)
+	m := newMockClient()
+	a.Register(m)
+
+	a.AddPath(pfx, p)
+	assert.Equal(t, p, m.added[*pfx])
+	assert.Equal(t, 1, a.Count())
+
+	assert.True(t, a.RemovePath(pfx, &route.Path{Source: 1}))
+	assert.Equal(t, p, m.removed[*pfx])
+	assert.Equal(t, 0, a.Count())
+
+	assert.False(t, a.RemovePath(pfx, &route.Path{Source: 1}))
+}
+
+func TestAdjRIBInStale(t *testing.T) {
+	a := NewAdjRIBIn(nil, false)
+	m := newMockClient()
+	a.Register(m)
+
+	pfxA := net.NewPfx(167772160, 8)   // 10.0.0.0/8
+	pfxB := net.NewPfx(3232235520, 16) // 192.168.0.0/16
+	a.AddPath(pfxA, &route.Path{Source: 1})
+	a.AddPath(pfxB, &route.Path{Source: 1})
+
+	a.MarkStale()
+	a.AddPath(pfxA, &route.Path{Source: 1, MED: 10})
+
+	assert.Equal(t, 1, a.RemoveStale())
+	assert.Equal(t, 1, a.Count())
+	assert.Contains(t, m.removed, *pfxB)
+	assert.NotContains(t, m.removed, *pfxA)
+}
+
+func TestAdjRIBInFlush(t *testing.T) {
+	a := NewAdjRIBIn(nil, false)
+	a.AddPath(net.NewPfx(167772160, 8), &route.Path{Source: 1})   // 10.0.0.0/8
+	a.AddPath(net.NewPfx(3232235520, 16), &route.Path{Source: 1}) // 192.168.0.0/16
+
+	m := newMockClient()
+	a.Register(m)
+	assert.Len(t, m.added, 2)
+
+	a.Flush()
+	assert.Len(t, m.removed, 2)
+	assert.Equal(t, 0, a.Count())
+}
+
+func TestAdjRIBInImportFilter(t *testing.T) {
+	pfxA := net.NewPfx(167772160, 8)   // 10.0.0.0/8
+	pfxB := net.NewPfx(3232235520, 16) // 192.168.0.0/16
+
+	f := filter.NewFilter(filter.NewTerm(
+		[]*filter.TermCondition{
+			filter.NewTermConditionWithPrefixLists(filter.NewPrefixList(pfxB)),
+		},
+		[]filter.Action{&filter.RejectAction{}},
+	))
+
+	a := NewAdjRIBIn(f, false)
+	m := newMockClient()
+	a.Register(m)
+
+	a.AddPath(pfxA, &route.Path{Source: 1})
+	a.AddPath(pfxB, &route.Path{Source: 1})
+
+	assert.Equal(t, 1, a.Count())
+	assert.Contains(t, m.added, *pfxA)
+	assert.NotContains(t, m.added, *pfxB)
+
+	_, err := a.PrePolicyRoutes()
+	assert.Error(t, err)
+	assert.Error(t, a.ReapplyImportFilter())
+}
+
+func TestAdjRIBInReapplyImportFilter(t *testing.T) {
+	pfxA := net.NewPfx(167772160, 8)   // 10.0.0.0/8
+	pfxB := net.NewPfx(3232235520, 16) // 192.168.0.0/16
+
+	a := NewAdjRIBIn(filter.NewDrainFilter(), true)
+	m := newMockClient()
+	a.Register(m)
+
+	a.AddPath(pfxA, &route.Path{Source: 1})
+	a.AddPath(pfxB, &route.Path{Source: 1})
+	assert.Equal(t, 0, a.Count())
+	assert.Len(t, m.added, 0)
+
+	pre, err := a.PrePolicyRoutes()
+	assert.NoError(t, err)
+	assert.Len(t, pre, 2)
+
+	a.SetImportFilter(filter.NewFilter(filter.NewTerm(
+		[]*filter.TermCondition{
+			filter.NewTermConditionWithPrefixLists(filter.NewPrefixList(pfxA)),
+		},
+		[]filter.Action{&filter.SetLocalPrefAction{LocalPref: 200}},
+	)))
+	assert.NoError(t, a.ReapplyImportFilter())
+	assert.Equal(t, 2, a.Count())
+	assert.Equal(t, uint32(200), m.added[*pfxA].LocalPref)
+	assert.Equal(t, uint32(0), m.added[*pfxB].LocalPref)
+
+	a.SetImportFilter(filter.NewDrainFilter())
+	assert.NoError(t, a.ReapplyImportFilter())
+	assert.Equal(t, 0, a.Count())
+	assert.Contains(t, m.removed, *pfxA)
+	assert.Contains(t, m.removed, *pfxB)
+
+	pre, err = a.PrePolicyRoutes()
+	assert.NoError(t, err)
+	assert.Len(t, pre, 2)
+}
+
+func TestAdjRIBInMarkLongLivedStale(t *testing.T) {
+	for _, keepPrePolicy := range []bool{false, true} {
+		a := NewAdjRIBIn(nil, keepPrePolicy)
+		m := newMockClient()
+		a.Register(m)
+
+		pfxA := net.NewPfx(167772160, 8)   // 10.0.0.0/8
+		pfxB := net.NewPfx(3232235520, 16) // 192.168.0.0/16
+		pfxC := net.NewPfx(2886729728, 12) // 172.16.0.0/12
+		a.AddPath(pfxA, &route.Path{Source: 1})
+		a.AddPath(pfxB, &route.Path{Source: 1, Communities: []uint32{packet.NoLLGRCommunity}})
+
+		a.MarkStale()
+		a.AddPath(pfxC, &route.Path{Source: 1})
+
+		assert.Equal(t, 1, a.MarkLongLivedStale())
+		assert.Equal(t, 2, a.Count())
+		assert.True(t, m.added[*pfxA].LongLivedStale())
+		assert.False(t, m.added[*pfxC].LongLivedStale())
+		assert.Contains(t, m.removed, *pfxB)
+
+		assert.Equal(t, 1, a.RemoveStale())
+		assert.Equal(t, 1, a.Count())
+		assert.Contains(t, m.removed, *pfxA)
+	}
+}
+
+func TestAdjRIBInPathIdentifiers(t *testing.T) {
+	pfx := net.NewPfx(167772160, 8) // 10.0.0.0/8
+	p1 := &route.Path{Source: 1, PathIdentifier: 1}
+	p2 := &route.Path{Source: 1, PathIdentifier: 2}
+
+	a := NewAdjRIBIn(nil, false)
+	r := &recordingClient{}
+	a.Register(r)
+
+	a.AddPath(pfx, p1)
+	a.AddPath(pfx, p2)
+	assert.Equal(t, 2, a.Count())
+	assert.Equal(t, []*route.Path{p1, p2}, r.added)
+
+	routes := a.Routes()
+	assert.Len(t, routes, 1)
+	assert.Len(t, routes[0].Paths(), 2)
+
+	assert.True(t, a.RemovePath(pfx, &route.Path{Source: 1, PathIdentifier: 2}))
+	assert.Equal(t, []*route.Path{p2}, r.removed)
+	assert.Equal(t, 1, a.Count())
+}
+
+func TestAdjRIBInRevalidate(t *testing.T) {
+	vrps := rpki.NewVRPTable()
+	pfx := net.NewPfx(167772160, 8) // 10.0.0.0/8
+	p := &route.Path{
+		Source: 1,
+		ASPath: packet.ASPath{
+			{
+				Type: packet.ASSequence,
+				ASNs: []uint32{100},
+			},
+		},
+	}
+
+	// Invalid routes are rejected
+	f := filter.NewFilter(filter.NewTerm([]*filter.TermCondition{
+		filter.NewTermConditionWithValidationStates(rpki.Invalid),
+	}, []filter.Action{&filter.RejectAction{}}))
+
+	a := NewAdjRIBIn(f, true)
+	a.SetOriginValidator(vrps, 200)
+	m := newMockClient()
+	a.Register(m)
+
+	a.AddPath(pfx, p)
+	assert.Equal(t, rpki.NotFound, m.added[*pfx].ValidationState)
+
+	changed := vrps.Update([]rpki.VRP{
+		{
+			Prefix:    *net.NewPfx(167772160, 8),
+			MaxLength: 8,
+			ASN:       300,
+		},
+	}, nil)
+	assert.Equal(t, 1, a.Revalidate(changed))
+	assert.Equal(t, 0, a.Count())
+	assert.NotNil(t, m.removed[*pfx])
+
+	changed = vrps.Update([]rpki.VRP{
+		{
+			Prefix:    *net.NewPfx(167772160, 8),
+			MaxLength: 8,
+			ASN:       100,
+		},
+	}, nil)
+	assert.Equal(t, 1, a.Revalidate(changed))
+	assert.Equal(t, rpki.Valid, m.added[*pfx].ValidationState)
+
+	// Routes not covered by the changed prefixes are not re-evaluated
+	assert.Equal(t, 0, a.Revalidate([]*net.Prefix{net.NewPfx(184549376, 8)}))
+}
+
+func TestAdjRIBInASPA(t *testing.T) {
+	aspas := rpki.NewASPATable()
+	pfx := net.NewPfx(167772160, 8) // 10.0.0.0/8
+	p := &route.Path{
+		Source: 1,
+		EBGP:   true,
+		ASPath: packet.ASPath{
+			{
+				Type: packet.ASSequence,
+				ASNs: []uint32{100, 10},
+			},
+		},
+	}
+
+	// Paths proven to be leaked are rejected
+	f := filter.NewFilter(filter.NewTerm([]*filter.TermCondition{
+		filter.NewTermConditionWithASPAStates(rpki.ASPAInvalid),
+	}, []filter.Action{&filter.RejectAction{}}))
+
+	a := NewAdjRIBIn(f, true)
+	a.SetPathVerifier(aspas, 100, false)
+	m := newMockClient()
+	a.Register(m)
+
+	a.AddPath(pfx, p)
+	assert.Equal(t, rpki.ASPAUnknown, m.added[*pfx].ASPAState)
+
+	aspas.Update([]rpki.ASPA{{CustomerASN: 10, Providers: []uint32{200}}}, nil)
+	assert.Equal(t, 1, a.RevalidateAll())
+	assert.Equal(t, 0, a.Count())
+
+	aspas.Update([]rpki.ASPA{{CustomerASN: 10, Providers: []uint32{100, 200}}}, nil)
+	assert.Equal(t, 1, a.RevalidateAll())
+	assert.Equal(t, rpki.ASPAValid, m.added[*pfx].ASPAState)
+}
+
+func TestAdjRIBInDampening(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	pfx := net.NewPfx(167772160, 8) // 10.0.0.0/8
+	p := &route.Path{Source: 1, LocalPref: 100}
+
+	a := NewAdjRIBIn(nil, true)
+	a.EnableDampening(DefaultDampeningParameters())
+	a.dampening.now = func() time.Time { return now }
+	r := &recordingClient{}
+	a.Register(r)
+
+	// Two withdrawals stay below the suppress threshold
+	for i := 0; i < 2; i++ {
+		a.AddPath(pfx, p)
+		a.RemovePath(pfx, p)
+	}
+	assert.Equal(t, 2, len(r.added))
+	assert.Equal(t, 2, len(r.removed))
+
+	// An attribute change suppresses the path
+	a.AddPath(pfx, p)
+	a.AddPath(pfx, &route.Path{Source: 1, LocalPref: 200})
+	assert.Equal(t, 4, len(r.added))
+	assert.Equal(t, 3, len(r.removed))
+	assert.Equal(t, 1, a.Count())
+
+	damped, err := a.DampedPaths()
+	assert.NoError(t, err)
+	assert.Equal(t, []DampedPath{
+		{
+			Prefix:     pfx,
+			Penalty:    2500,
+			Suppressed: true,
+			ReuseTime:  now.Add(time.Duration(math.Log2(2500.0/750) * float64(15*time.Minute))),
+		},
+	}, damped)
+
+	// Suppressed paths are not advertised
+	a.AddPath(pfx, p)
+	assert.Equal(t, 4, len(r.added))
+
+	now = now.Add(15 * time.Minute)
+	a.mu.Lock()
+	a.reuse(newPathKey(pfx, p))
+	a.mu.Unlock()
+	assert.Equal(t, 4, len(r.added))
+
+	now = now.Add(15 * time.Minute)
+	a.mu.Lock()
+	a.reuse(newPathKey(pfx, p))
+	a.mu.Unlock()
+	assert.Equal(t, 5, len(r.added))
+	assert.Equal(t, p, r.added[4])
+
+	// Histories decayed below half the reuse threshold are disposed
+	now = now.Add(30 * time.Minute)
+	damped, err = a.DampedPaths()
+	assert.NoError(t, err)
+	assert.Equal(t, []DampedPath{}, damped)
+}
+
+func TestAdjRIBInDampeningCollect(t *testing.T) {
+	pfx := net.NewPfx(167772160, 8) // 10.0.0.0/8
+	p := &route.Path{Source: 1, LocalPref: 100}
+
+	params := DefaultDampeningParameters()
+	params.HalfLife = 20 * time.Millisecond
+
+	a := NewAdjRIBIn(nil, true)
+	a.EnableDampening(params)
+
+	// The path flaps once and is never received again
+	a.AddPath(pfx, p)
+	a.RemovePath(pfx, p)
+
+	a.mu.Lock()
+	assert.Len(t, a.dampening.history, 1)
+	assert.NotNil(t, a.dampening.gcTimer)
+	a.mu.Unlock()
+
+	assert.Eventually(t, func() bool {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+
+		return len(a.dampening.history) == 0 && a.dampening.gcTimer == nil
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestAdjRIBInFlushDampening(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	pfx := net.NewPfx(167772160, 8) // 10.0.0.0/8
+	p := &route.Path{Source: 1, LocalPref: 100}
+
+	a := NewAdjRIBIn(nil, true)
+	a.EnableDampening(DefaultDampeningParameters())
+	a.dampening.now = func() time.Time { return now }
+	r := &recordingClient{}
+	a.Register(r)
+
+	for i := 0; i < 3; i++ {
+		a.AddPath(pfx, p)
+		a.RemovePath(pfx, p)
+	}
+	a.AddPath(pfx, p)
+
+	k := newPathKey(pfx, p)
+	a.mu.Lock()
+	h := a.dampening.history[k]
+	a.mu.Unlock()
+	assert.True(t, h.suppressed)
+	added := len(r.added)
+
+	a.Flush()
+
+	// The reuse timer has been stopped and the history reset
+	assert.False(t, h.timer.Stop())
+	damped, err := a.DampedPaths()
+	assert.NoError(t, err)
+	assert.Empty(t, damped)
+
+	// A reuse timer having fired concurrently does not pass on paths of the flushed session
+	now = now.Add(2 * time.Hour)
+	a.mu.Lock()
+	a.reuse(k)
+	a.mu.Unlock()
+	assert.Len(t, r.added, added)
+	assert.Equal(t, 0, a.Count())
+}
+
+func TestAdjRIBInPrePolicyClients(t *testing.T) {
+	pfx := net.NewPfx(167772160, 8) // 10.0.0.0/8
+	p := &route.Path{Source: 1, LocalPref: 100}
+
+	assert.Error(t, NewAdjRIBIn(nil, false).RegisterPrePolicy(newMockClient()))
+
+	a := NewAdjRIBIn(filter.NewDrainFilter(), true)
+	a.AddPath(pfx, p)
+
+	m := newMockClient()
+	assert.NoError(t, a.RegisterPrePolicy(m))
+	assert.Equal(t, p, m.added[*pfx])
+	assert.Equal(t, 0, a.Count())
+	assert.Equal(t, 1, a.ReceivedCount())
+
+	a.RemovePath(pfx, p)
+	assert.Equal(t, p, m.removed[*pfx])
+
+	a.UnregisterPrePolicy(m)
+	a.AddPath(pfx, &route.Path{Source: 1, LocalPref: 200})
+	assert.Equal(t, p, m.added[*pfx])
+}
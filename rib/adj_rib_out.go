@@ -0,0 +1,324 @@
+package rib
+
+import (
+	"sync"
+
+	"github.com/taktv6/tbgp/net"
+	"github.com/taktv6/tbgp/packet"
+	"github.com/taktv6/tbgp/route"
+)
+
+// Neighbor describes the neighbor an Adj-RIB-Out belongs to
+type Neighbor struct {
+	Address      uint32
+	LocalAddress uint32
+	LocalASN     uint32
+	PeerASN      uint32
+	IBGP         bool
+
+	// LongLivedGracefulRestart is set if the neighbor supports long-lived graceful restart (RFC9494)
+	LongLivedGracefulRestart bool
+
+	// AddPath is set if we may send multiple paths per prefix to the neighbor (RFC7911)
+	AddPath bool
+
+	// RouteReflectorClient is set if the neighbor is a route reflector client (RFC4456)
+	RouteReflectorClient bool
+
+	// ClusterID is prepended to the CLUSTER_LIST of paths reflected to the neighbor
+	ClusterID uint32
+
+	// Confederation is set if the neighbor is in another member AS of our confederation (RFC5065)
+	Confederation bool
+
+	// RejectOnlyToCustomer prevents paths carrying the Only to Customer attribute from being sent to
+	// the neighbor. It is set if the neighbor is a provider, lateral peer or route server (RFC9234 5).
+	RejectOnlyToCustomer bool
+
+	// MarkOnlyToCustomer adds the Only to Customer attribute with our AS to paths sent to the neighbor.
+	// It is set if the neighbor is a customer, lateral peer or route server client (RFC9234 5).
+	MarkOnlyToCustomer bool
+}
+
+// AdjRIBOut holds the routes to be advertised to a single neighbor
+type AdjRIBOut struct {
+	clientManager
+	mu       sync.RWMutex
+	neighbor *Neighbor
+	routes   map[net.Prefix][]*adjRIBOutPath
+}
+
+// adjRIBOutPath is a path advertised to the neighbor along with the Loc-RIB path it was derived from
+type adjRIBOutPath struct {
+	source *route.Path
+	path   *route.Path
+}
+
+// NewAdjRIBOut creates a new empty Adj-RIB-Out for neighbor n
+func NewAdjRIBOut(n *Neighbor) *AdjRIBOut {
+	return &AdjRIBOut{
+		clientManager: newClientManager(),
+		neighbor:      n,
+		routes:        make(map[net.Prefix][]*adjRIBOutPath),
+	}
+}
+
+// Register adds a client and sends it all routes currently held
+func (a *AdjRIBOut) Register(client RouteTableClient) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.add(client, ClientOptions{})
+	for pfx, paths := range a.routes {
+		for _, e := range paths {
+			client.AddPath(net.NewPfx(pfx.Addr(), pfx.Pfxlen()), e.path)
+		}
+	}
+}
+
+// Unregister removes a client
+func (a *AdjRIBOut) Unregister(client RouteTableClient) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.remove(client)
+}
+
+// AddPath adds p for pfx if it may be advertised to the neighbor.
+// If it may not, a previously advertised path for pfx is withdrawn.
+// Without ADD-PATH p replaces the path advertised for pfx. With ADD-PATH (RFC7911)
+// p replaces the path of the same identity and otherwise gets a new path identifier.
+func (a *AdjRIBOut) AddPath(pfx *net.Prefix, p *route.Path) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.neighbor.AddPath {
+		if !a.exportable(p) {
+			a.withdraw(pfx, nil)
+			return nil
+		}
+
+		e := &adjRIBOutPath{
+			source: p,
+			path:   a.advertisedPath(p),
+		}
+		a.routes[*pfx] = []*adjRIBOutPath{e}
+		a.addPath(pfx, e.path)
+		return nil
+	}
+
+	if !a.exportable(p) {
+		a.withdraw(pfx, p)
+		return nil
+	}
+
+	paths := a.routes[*pfx]
+	i := indexOfSource(paths, p)
+	if i < 0 {
+		e := &adjRIBOutPath{
+			source: p,
+			path:   a.advertisedPath(p),
+		}
+		e.path.PathIdentifier = freePathIdentifier(paths)
+		a.routes[*pfx] = append(paths, e)
+		a.addPath(pfx, e.path)
+		return nil
+	}
+
+	e := &adjRIBOutPath{
+		source: p,
+		path:   a.advertisedPath(p),
+	}
+	e.path.PathIdentifier = paths[i].path.PathIdentifier
+	paths[i] = e
+	a.addPath(pfx, e.path)
+
+	return nil
+}
+
+// RemovePath withdraws the path advertised for pfx that was derived from p.
+// Without ADD-PATH the path advertised for pfx is withdrawn regardless of p.
+func (a *AdjRIBOut) RemovePath(pfx *net.Prefix, p *route.Path) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.neighbor.AddPath {
+		return a.withdraw(pfx, nil)
+	}
+
+	return a.withdraw(pfx, p)
+}
+
+// withdraw removes the path derived from p for pfx. If p is nil all paths for pfx are removed.
+func (a *AdjRIBOut) withdraw(pfx *net.Prefix, p *route.Path) bool {
+	paths, ok := a.routes[*pfx]
+	if !ok {
+		return false
+	}
+
+	if p == nil {
+		delete(a.routes, *pfx)
+		for _, e := range paths {
+			a.removePath(pfx, e.path)
+		}
+		return true
+	}
+
+	i := indexOfSource(paths, p)
+	if i < 0 {
+		return false
+	}
+
+	e := paths[i]
+	paths = append(paths[:i], paths[i+1:]...)
+	if len(paths) == 0 {
+		delete(a.routes, *pfx)
+	} else {
+		a.routes[*pfx] = paths
+	}
+	a.removePath(pfx, e.path)
+
+	return true
+}
+
+// indexOfSource returns the index of the entry derived from a path with the identity of p or -1
+func indexOfSource(paths []*adjRIBOutPath, p *route.Path) int {
+	for i, e := range paths {
+		if e.source.SameIdentity(p) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// freePathIdentifier returns the lowest path identifier not used by any of paths
+func freePathIdentifier(paths []*adjRIBOutPath) uint32 {
+	used := make(map[uint32]struct{}, len(paths))
+	for _, e := range paths {
+		used[e.path.PathIdentifier] = struct{}{}
+	}
+
+	id := uint32(1)
+	for {
+		if _, ok := used[id]; !ok {
+			return id
+		}
+		id++
+	}
+}
+
+// exportable checks if p may be advertised to the neighbor
+func (a *AdjRIBOut) exportable(p *route.Path) bool {
+	// Never send a path back to where it came from
+	if p.Source == a.neighbor.Address {
+		return false
+	}
+
+	// Paths learned via iBGP must not be sent to iBGP neighbors unless reflected (RFC4456 6):
+	// Paths of clients are reflected to all iBGP neighbors, paths of non-clients to clients only.
+	if a.reflected(p) && !p.ReflectorClient && !a.neighbor.RouteReflectorClient {
+		return false
+	}
+
+	// Long-lived stale paths must not be sent to neighbors not supporting LLGR (RFC9494 4.5)
+	if !a.neighbor.LongLivedGracefulRestart && p.LongLivedStale() {
+		return false
+	}
+
+	// Paths meant for customers only must not leak to providers and peers (RFC9234 5)
+	if a.neighbor.RejectOnlyToCustomer && p.OnlyToCustomer != 0 {
+		return false
+	}
+
+	return true
+}
+
+// reflected checks if p is learned via iBGP and sent to an iBGP neighbor
+func (a *AdjRIBOut) reflected(p *route.Path) bool {
+	return a.neighbor.IBGP && !p.EBGP && !p.Confederation && p.Source != 0
+}
+
+// advertisedPath returns the path to advertise to the neighbor for p. Paths to iBGP neighbors are only
+// copied if modified or with ADD-PATH as they are assigned a path identifier of their own then.
+// Reflected paths carry the ORIGINATOR_ID and the CLUSTER_LIST with the cluster ID prepended (RFC4456 8).
+// Paths to confed-eBGP neighbors get our member AS prepended as AS_CONFED_SEQUENCE (RFC5065 4.1).
+// Paths to eBGP neighbors are stripped of all confederation segments and get our AS prepended.
+// The next hop is set to our own address, LOCAL_PREF is removed and a MED received from another AS is not
+// passed on (RFC4271 5.1). Paths to customers and peers are marked Only to Customer unless marked already
+// (RFC9234 5). Locally originated paths without next hop get our address as next hop.
+func (a *AdjRIBOut) advertisedPath(p *route.Path) *route.Path {
+	reflected := a.reflected(p)
+	if a.neighbor.IBGP && !reflected && !a.neighbor.AddPath && p.NextHop != 0 {
+		return p
+	}
+
+	c := p.Copy()
+	switch {
+	case reflected:
+		if c.OriginatorID == 0 {
+			c.OriginatorID = p.RouterID
+		}
+		c.ClusterList = append([]uint32{a.neighbor.ClusterID}, p.ClusterList...)
+	case a.neighbor.Confederation:
+		c.ASPath = p.ASPath.Prepend(packet.ASConfedSequence, a.neighbor.LocalASN)
+	case !a.neighbor.IBGP:
+		c.ASPath = p.ASPath.StripConfedSegments().Prepend(packet.ASSequence, a.neighbor.LocalASN)
+		c.NextHop = a.neighbor.LocalAddress
+		c.LocalPref = 0
+		if p.Source != 0 {
+			c.MED = 0
+		}
+		if a.neighbor.MarkOnlyToCustomer && c.OnlyToCustomer == 0 {
+			c.OnlyToCustomer = a.neighbor.LocalASN
+		}
+	}
+
+	if c.NextHop == 0 {
+		c.NextHop = a.neighbor.LocalAddress
+	}
+
+	return c
+}
+
+// Refresh sends all routes held to all clients again
+func (a *AdjRIBOut) Refresh() {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	for pfx, paths := range a.routes {
+		for _, e := range paths {
+			a.addPath(net.NewPfx(pfx.Addr(), pfx.Pfxlen()), e.path)
+		}
+	}
+}
+
+// Count returns the number of paths held
+func (a *AdjRIBOut) Count() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	n := 0
+	for _, paths := range a.routes {
+		n += len(paths)
+	}
+
+	return n
+}
+
+// Routes returns all routes held
+func (a *AdjRIBOut) Routes() []*route.Route {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	res := make([]*route.Route, 0, len(a.routes))
+	for pfx, paths := range a.routes {
+		r := route.NewRoute(net.NewPfx(pfx.Addr(), pfx.Pfxlen()))
+		for _, e := range paths {
+			r.AddPath(e.path)
+		}
+		res = append(res, r)
+	}
+
+	return res
+}
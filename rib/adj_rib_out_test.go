@@ -0,0 +1,443 @@
+package rib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taktv6/tbgp/net"
+	"github.com/taktv6/tbgp/packet"
+	"github.com/taktv6/tbgp/route"
+)
+
+func TestAdjRIBOutAddPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		neighbor *Neighbor
+		path     *route.Path
+		expected bool
+	}{
+		{
+			name: "eBGP path to iBGP neighbor",
+			neighbor: &Neighbor{
+				Address: 100,
+				IBGP:    true,
+			},
+			path: &route.Path{
+				Source: 200,
+				EBGP:   true,
+			},
+			expected: true,
+		},
+		{
+			name: "iBGP path to iBGP neighbor",
+			neighbor: &Neighbor{
+				Address: 100,
+				IBGP:    true,
+			},
+			path: &route.Path{
+				Source: 200,
+			},
+			expected: false,
+		},
+		{
+			name: "iBGP path to eBGP neighbor",
+			neighbor: &Neighbor{
+				Address: 100,
+			},
+			path: &route.Path{
+				Source: 200,
+			},
+			expected: true,
+		},
+		{
+			name: "Long-lived stale path to neighbor not supporting LLGR",
+			neighbor: &Neighbor{
+				Address: 100,
+			},
+			path: &route.Path{
+				Source:      200,
+				EBGP:        true,
+				Communities: []uint32{packet.LLGRStaleCommunity},
+			},
+			expected: false,
+		},
+		{
+			name: "Long-lived stale path to neighbor supporting LLGR",
+			neighbor: &Neighbor{
+				Address:                  100,
+				LongLivedGracefulRestart: true,
+			},
+			path: &route.Path{
+				Source:      200,
+				EBGP:        true,
+				Communities: []uint32{packet.LLGRStaleCommunity},
+			},
+			expected: true,
+		},
+		{
+			name: "Path back to its source",
+			neighbor: &Neighbor{
+				Address: 100,
+			},
+			path: &route.Path{
+				Source: 100,
+				EBGP:   true,
+			},
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		pfx := net.NewPfx(167772160, 8) // 10.0.0.0/8
+		a := NewAdjRIBOut(test.neighbor)
+		m := newMockClient()
+		a.Register(m)
+
+		a.AddPath(pfx, test.path)
+		if !test.expected {
+			assert.Empty(t, m.added, test.name)
+			continue
+		}
+
+		assert.True(t, test.path.SameIdentity(m.added[*pfx]), test.name)
+	}
+}
+
+func TestAdjRIBOutWithdrawUnexportable(t *testing.T) {
+	pfx := net.NewPfx(167772160, 8) // 10.0.0.0/8
+	a := NewAdjRIBOut(&Neighbor{
+		Address: 100,
+		IBGP:    true,
+	})
+	m := newMockClient()
+	a.Register(m)
+
+	p := &route.Path{Source: 200, EBGP: true}
+	a.AddPath(pfx, p)
+
+	// The new best path is not exportable. The old one has to be withdrawn.
+	a.AddPath(pfx, &route.Path{Source: 300})
+	assert.Equal(t, p, m.removed[*pfx])
+	assert.Equal(t, 0, a.Count())
+}
+
+func TestAdjRIBOutRefresh(t *testing.T) {
+	pfx := net.NewPfx(167772160, 8) // 10.0.0.0/8
+	a := NewAdjRIBOut(&Neighbor{
+		Address: 100,
+	})
+	p := &route.Path{Source: 200, EBGP: true}
+	a.AddPath(pfx, p)
+
+	m := newMockClient()
+	a.Register(m)
+	advertised := m.added[*pfx]
+	m.added = make(map[net.Prefix]*route.Path)
+
+	a.Refresh()
+	assert.Equal(t, advertised, m.added[*pfx])
+}
+
+func TestAdjRIBOutAddPathIdentifiers(t *testing.T) {
+	pfx := net.NewPfx(167772160, 8) // 10.0.0.0/8
+	a := NewAdjRIBOut(&Neighbor{
+		Address: 100,
+		AddPath: true,
+	})
+	r := &recordingClient{}
+	a.Register(r)
+
+	p1 := &route.Path{Source: 200, EBGP: true}
+	p2 := &route.Path{Source: 300, EBGP: true}
+	a.AddPath(pfx, p1)
+	a.AddPath(pfx, p2)
+	assert.Equal(t, 2, a.Count())
+	assert.Equal(t, uint32(1), r.added[0].PathIdentifier)
+	assert.Equal(t, uint32(2), r.added[1].PathIdentifier)
+
+	// The original paths must not be modified
+	assert.Equal(t, uint32(0), p1.PathIdentifier)
+
+	// A replaced path keeps its path identifier
+	a.AddPath(pfx, &route.Path{Source: 200, EBGP: true, Origin: packet.INCOMPLETE})
+	assert.Equal(t, uint32(1), r.added[2].PathIdentifier)
+	assert.Equal(t, uint8(packet.INCOMPLETE), r.added[2].Origin)
+
+	assert.True(t, a.RemovePath(pfx, &route.Path{Source: 200}))
+	assert.Equal(t, uint32(1), r.removed[0].PathIdentifier)
+	assert.Equal(t, 1, a.Count())
+
+	// Freed path identifiers are reused
+	a.AddPath(pfx, &route.Path{Source: 400, EBGP: true})
+	assert.Equal(t, uint32(1), r.added[3].PathIdentifier)
+}
+
+func TestAdjRIBOutRouteReflection(t *testing.T) {
+	tests := []struct {
+		name     string
+		neighbor *Neighbor
+		path     *route.Path
+		expected *route.Path
+	}{
+		{
+			name: "Client path to non-client",
+			neighbor: &Neighbor{
+				Address:   100,
+				IBGP:      true,
+				ClusterID: 1000,
+			},
+			path: &route.Path{
+				Source:          200,
+				RouterID:        20,
+				ReflectorClient: true,
+			},
+			expected: &route.Path{
+				Source:          200,
+				RouterID:        20,
+				ReflectorClient: true,
+				OriginatorID:    20,
+				ClusterList:     []uint32{1000},
+			},
+		},
+		{
+			name: "Non-client path to client",
+			neighbor: &Neighbor{
+				Address:              100,
+				IBGP:                 true,
+				RouteReflectorClient: true,
+				ClusterID:            1000,
+			},
+			path: &route.Path{
+				Source:       200,
+				RouterID:     20,
+				OriginatorID: 30,
+				ClusterList:  []uint32{2000},
+			},
+			expected: &route.Path{
+				Source:       200,
+				RouterID:     20,
+				OriginatorID: 30,
+				ClusterList:  []uint32{1000, 2000},
+			},
+		},
+		{
+			name: "Non-client path to non-client",
+			neighbor: &Neighbor{
+				Address:   100,
+				IBGP:      true,
+				ClusterID: 1000,
+			},
+			path: &route.Path{
+				Source:   200,
+				RouterID: 20,
+			},
+			expected: nil,
+		},
+		{
+			name: "eBGP path to client",
+			neighbor: &Neighbor{
+				Address:              100,
+				IBGP:                 true,
+				RouteReflectorClient: true,
+				ClusterID:            1000,
+			},
+			path: &route.Path{
+				Source:   200,
+				RouterID: 20,
+				EBGP:     true,
+			},
+			expected: &route.Path{
+				Source:   200,
+				RouterID: 20,
+				EBGP:     true,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		pfx := net.NewPfx(167772160, 8) // 10.0.0.0/8
+		a := NewAdjRIBOut(test.neighbor)
+		m := newMockClient()
+		a.Register(m)
+
+		a.AddPath(pfx, test.path)
+		assert.Equal(t, test.expected, m.added[*pfx], test.name)
+	}
+}
+
+func TestAdjRIBOutConfederation(t *testing.T) {
+	asPath := packet.ASPath{
+		{
+			Type:  packet.ASConfedSequence,
+			Count: 1,
+			ASNs:  []uint32{65001},
+		},
+		{
+			Type:  packet.ASSequence,
+			Count: 1,
+			ASNs:  []uint32{3320},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		neighbor *Neighbor
+		expected packet.ASPath
+	}{
+		{
+			name: "Confed-eBGP neighbor",
+			neighbor: &Neighbor{
+				Address:       100,
+				LocalASN:      65000,
+				PeerASN:       65002,
+				Confederation: true,
+			},
+			expected: packet.ASPath{
+				{
+					Type:  packet.ASConfedSequence,
+					Count: 2,
+					ASNs:  []uint32{65000, 65001},
+				},
+				{
+					Type:  packet.ASSequence,
+					Count: 1,
+					ASNs:  []uint32{3320},
+				},
+			},
+		},
+		{
+			name: "Neighbor outside the confederation",
+			neighbor: &Neighbor{
+				Address:  100,
+				LocalASN: 200,
+				PeerASN:  300,
+			},
+			expected: packet.ASPath{
+				{
+					Type:  packet.ASSequence,
+					Count: 2,
+					ASNs:  []uint32{200, 3320},
+				},
+			},
+		},
+		{
+			name: "iBGP neighbor",
+			neighbor: &Neighbor{
+				Address:  100,
+				LocalASN: 65000,
+				PeerASN:  65000,
+				IBGP:     true,
+			},
+			expected: asPath,
+		},
+	}
+
+	for _, test := range tests {
+		pfx := net.NewPfx(167772160, 8) // 10.0.0.0/8
+		a := NewAdjRIBOut(test.neighbor)
+		m := newMockClient()
+		a.Register(m)
+
+		a.AddPath(pfx, &route.Path{
+			Source:        200,
+			Confederation: true,
+			ASPath:        asPath,
+		})
+		assert.Equal(t, test.expected, m.added[*pfx].ASPath, test.name)
+	}
+}
+
+func TestAdjRIBOutEBGPAttributes(t *testing.T) {
+	pfx := net.NewPfx(167772160, 8) // 10.0.0.0/8
+	a := NewAdjRIBOut(&Neighbor{
+		Address:      100,
+		LocalAddress: 50,
+		LocalASN:     200,
+		PeerASN:      300,
+	})
+	m := newMockClient()
+	a.Register(m)
+
+	p := &route.Path{
+		Source:    400,
+		EBGP:      true,
+		NextHop:   400,
+		LocalPref: 150,
+		MED:       10,
+		ASPath: packet.ASPath{
+			{
+				Type:  packet.ASSequence,
+				Count: 1,
+				ASNs:  []uint32{400},
+			},
+		},
+	}
+	a.AddPath(pfx, p)
+
+	assert.Equal(t, &route.Path{
+		Source: 400,
+		EBGP:   true,
+		// Next hop self
+		NextHop: 50,
+		ASPath: packet.ASPath{
+			{
+				Type:  packet.ASSequence,
+				Count: 2,
+				ASNs:  []uint32{200, 400},
+			},
+		},
+	}, m.added[*pfx])
+
+	// The path held by the Loc-RIB must not be modified
+	assert.Equal(t, uint32(150), p.LocalPref)
+	assert.Equal(t, uint32(10), p.MED)
+
+	// The MED of locally originated paths is sent
+	a.AddPath(pfx, &route.Path{MED: 10})
+	assert.Equal(t, uint32(10), m.added[*pfx].MED)
+	assert.Equal(t, uint32(50), m.added[*pfx].NextHop)
+}
+
+func TestAdjRIBOutOnlyToCustomer(t *testing.T) {
+	pfx := net.NewPfx(167772160, 8) // 10.0.0.0/8
+	marked := &route.Path{
+		Source:         400,
+		EBGP:           true,
+		OnlyToCustomer: 400,
+	}
+	unmarked := &route.Path{
+		Source: 500,
+		EBGP:   true,
+	}
+
+	// Paths marked Only to Customer must not be sent to providers
+	provider := NewAdjRIBOut(&Neighbor{
+		Address:              100,
+		LocalASN:             200,
+		PeerASN:              300,
+		RejectOnlyToCustomer: true,
+	})
+	m := newMockClient()
+	provider.Register(m)
+
+	provider.AddPath(pfx, marked)
+	assert.Equal(t, 0, provider.Count())
+	provider.AddPath(pfx, unmarked)
+	assert.Equal(t, uint32(0), m.added[*pfx].OnlyToCustomer)
+
+	// Paths to customers are marked with our AS unless marked already
+	customer := NewAdjRIBOut(&Neighbor{
+		Address:            100,
+		LocalASN:           200,
+		PeerASN:            300,
+		MarkOnlyToCustomer: true,
+	})
+	m = newMockClient()
+	customer.Register(m)
+
+	customer.AddPath(pfx, unmarked)
+	assert.Equal(t, uint32(200), m.added[*pfx].OnlyToCustomer)
+	assert.Equal(t, uint32(0), unmarked.OnlyToCustomer)
+	customer.AddPath(pfx, marked)
+	assert.Equal(t, uint32(400), m.added[*pfx].OnlyToCustomer)
+}
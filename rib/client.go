@@ -0,0 +1,80 @@
+package rib
+
+import (
+	"github.com/taktv6/tbgp/net"
+	"github.com/taktv6/tbgp/route"
+)
+
+// RouteTableClient is the interface every consumer of routing table changes has to implement
+type RouteTableClient interface {
+	AddPath(pfx *net.Prefix, p *route.Path) error
+	RemovePath(pfx *net.Prefix, p *route.Path) bool
+}
+
+// ClientOptions define which paths of a prefix are sent to a client of the Loc-RIB
+type ClientOptions struct {
+	BestOnly bool // Only the best path is sent
+	EcmpOnly bool // Only the best path and the paths equally preferred are sent (see route.Path.ECMP)
+	MaxPaths uint // Maximum number of paths sent per prefix. 0 means no limit.
+
+	// ExcludeSuppressed prevents sending the paths of prefixes suppressed by a summary-only aggregate (see LocRIB.Suppress)
+	ExcludeSuppressed bool
+}
+
+// paths returns the paths of r to be sent to a client ordered by preference
+func (o ClientOptions) paths(r *route.Route) []*route.Path {
+	if r == nil {
+		return nil
+	}
+
+	if o.BestOnly {
+		best := r.BestPath()
+		if best == nil {
+			return nil
+		}
+		return []*route.Path{best}
+	}
+
+	paths := r.SortedPaths()
+	if o.EcmpOnly {
+		paths = r.ECMPPaths()
+	}
+
+	if o.MaxPaths > 0 && uint(len(paths)) > o.MaxPaths {
+		paths = paths[:o.MaxPaths]
+	}
+
+	return paths
+}
+
+// clientManager keeps track of the clients of a routing table.
+// It is not thread safe and has to be protected by the lock of the embedding table.
+type clientManager struct {
+	clients map[RouteTableClient]ClientOptions
+}
+
+func newClientManager() clientManager {
+	return clientManager{
+		clients: make(map[RouteTableClient]ClientOptions),
+	}
+}
+
+func (c *clientManager) add(client RouteTableClient, opts ClientOptions) {
+	c.clients[client] = opts
+}
+
+func (c *clientManager) remove(client RouteTableClient) {
+	delete(c.clients, client)
+}
+
+func (c *clientManager) addPath(pfx *net.Prefix, p *route.Path) {
+	for client := range c.clients {
+		client.AddPath(pfx, p)
+	}
+}
+
+func (c *clientManager) removePath(pfx *net.Prefix, p *route.Path) {
+	for client := range c.clients {
+		client.RemovePath(pfx, p)
+	}
+}
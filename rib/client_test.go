@@ -0,0 +1,86 @@
+package rib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taktv6/tbgp/net"
+	"github.com/taktv6/tbgp/route"
+)
+
+// mockClient records all paths it is sent
+type mockClient struct {
+	added   map[net.Prefix]*route.Path
+	removed map[net.Prefix]*route.Path
+}
+
+func newMockClient() *mockClient {
+	return &mockClient{
+		added:   make(map[net.Prefix]*route.Path),
+		removed: make(map[net.Prefix]*route.Path),
+	}
+}
+
+func (m *mockClient) AddPath(pfx *net.Prefix, p *route.Path) error {
+	m.added[*pfx] = p
+	return nil
+}
+
+func (m *mockClient) RemovePath(pfx *net.Prefix, p *route.Path) bool {
+	m.removed[*pfx] = p
+	return true
+}
+
+// recordingClient records all paths it is sent in order
+type recordingClient struct {
+	added   []*route.Path
+	removed []*route.Path
+}
+
+func (r *recordingClient) AddPath(pfx *net.Prefix, p *route.Path) error {
+	r.added = append(r.added, p)
+	return nil
+}
+
+func (r *recordingClient) RemovePath(pfx *net.Prefix, p *route.Path) bool {
+	r.removed = append(r.removed, p)
+	return true
+}
+
+func TestClientOptionsPaths(t *testing.T) {
+	a := &route.Path{Source: 1, LocalPref: 100}
+	b := &route.Path{Source: 2, LocalPref: 200}
+	c := &route.Path{Source: 3, LocalPref: 200}
+	r := route.NewRoute(net.NewPfx(167772160, 8), a, b, c) // 10.0.0.0/8
+
+	tests := []struct {
+		name     string
+		opts     ClientOptions
+		expected []*route.Path
+	}{
+		{
+			name:     "Best only",
+			opts:     ClientOptions{BestOnly: true},
+			expected: []*route.Path{b},
+		},
+		{
+			name:     "All paths",
+			opts:     ClientOptions{},
+			expected: []*route.Path{b, c, a},
+		},
+		{
+			name:     "ECMP paths",
+			opts:     ClientOptions{EcmpOnly: true},
+			expected: []*route.Path{b, c},
+		},
+		{
+			name:     "Best 2 paths",
+			opts:     ClientOptions{MaxPaths: 2},
+			expected: []*route.Path{b, c},
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, test.opts.paths(r), test.name)
+	}
+}
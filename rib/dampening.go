@@ -0,0 +1,221 @@
+package rib
+
+import (
+	"math"
+	"time"
+
+	"github.com/taktv6/tbgp/net"
+)
+
+// DampeningParameters configure route flap dampening (RFC2439). Penalties are unitless figures of merit.
+type DampeningParameters struct {
+	// HalfLife is the time after which a penalty has decayed to half of its value
+	HalfLife time.Duration
+
+	// ReuseThreshold is the penalty below which a suppressed path is used again
+	ReuseThreshold uint32
+
+	// SuppressThreshold is the penalty above which a path is suppressed
+	SuppressThreshold uint32
+
+	// MaxSuppressTime is the maximum time a path is suppressed after it stopped flapping
+	MaxSuppressTime time.Duration
+
+	// WithdrawPenalty is added to the penalty of a path whenever it is withdrawn
+	WithdrawPenalty uint32
+
+	// AttributeChangePenalty is added to the penalty of a path whenever it is replaced by one with other attributes
+	AttributeChangePenalty uint32
+}
+
+// DefaultDampeningParameters returns commonly used dampening parameters
+func DefaultDampeningParameters() DampeningParameters {
+	return DampeningParameters{
+		HalfLife:               15 * time.Minute,
+		ReuseThreshold:         750,
+		SuppressThreshold:      2000,
+		MaxSuppressTime:        60 * time.Minute,
+		WithdrawPenalty:        1000,
+		AttributeChangePenalty: 500,
+	}
+}
+
+// DampedPath describes the flap history of a path received from a neighbor
+type DampedPath struct {
+	Prefix         *net.Prefix
+	PathIdentifier uint32
+	Penalty        uint32
+	Suppressed     bool
+
+	// ReuseTime is the time the path will be used again if it stops flapping. It is zero if not suppressed.
+	ReuseTime time.Time
+}
+
+// dampening keeps the flap history of the paths received from a neighbor
+type dampening struct {
+	params  DampeningParameters
+	ceiling float64
+	now     func() time.Time
+	history map[pathKey]*flapHistory
+	gcTimer *time.Timer // Disposes expired histories periodically while there are any
+}
+
+type flapHistory struct {
+	penalty    float64
+	updated    time.Time
+	suppressed bool
+	since      time.Time
+	timer      *time.Timer
+}
+
+func newDampening(params DampeningParameters) *dampening {
+	// The penalty is capped so no path is suppressed longer than MaxSuppressTime after its last flap (RFC2439 4.2)
+	ceiling := float64(params.ReuseThreshold) * math.Pow(2, params.MaxSuppressTime.Seconds()/params.HalfLife.Seconds())
+
+	return &dampening{
+		params:  params,
+		ceiling: ceiling,
+		now:     time.Now,
+		history: make(map[pathKey]*flapHistory),
+	}
+}
+
+// decay updates the penalty of h to the current time
+func (d *dampening) decay(h *flapHistory, now time.Time) {
+	elapsed := now.Sub(h.updated)
+	if elapsed <= 0 {
+		return
+	}
+
+	h.penalty *= math.Pow(0.5, elapsed.Seconds()/d.params.HalfLife.Seconds())
+	h.updated = now
+}
+
+// penalize adds penalty to the path identified by k. It returns true if the path got suppressed.
+func (d *dampening) penalize(k pathKey, penalty uint32) bool {
+	now := d.now()
+	h, ok := d.history[k]
+	if !ok {
+		h = &flapHistory{
+			updated: now,
+		}
+		d.history[k] = h
+	}
+
+	d.decay(h, now)
+	h.penalty = math.Min(h.penalty+float64(penalty), d.ceiling)
+
+	if h.suppressed || h.penalty <= float64(d.params.SuppressThreshold) {
+		return false
+	}
+
+	h.suppressed = true
+	h.since = now
+	return true
+}
+
+// suppressed checks if the path identified by k is suppressed. d may be nil.
+func (d *dampening) suppressed(k pathKey) bool {
+	if d == nil {
+		return false
+	}
+
+	h, ok := d.history[k]
+	return ok && h.suppressed
+}
+
+// reuseIn returns the time until a suppressed path may be used again
+func (d *dampening) reuseIn(h *flapHistory, now time.Time) time.Duration {
+	maxSuppress := h.since.Add(d.params.MaxSuppressTime).Sub(now)
+	if h.penalty <= float64(d.params.ReuseThreshold) {
+		return 0
+	}
+
+	halfLives := math.Log2(h.penalty / float64(d.params.ReuseThreshold))
+	decay := time.Duration(halfLives * float64(d.params.HalfLife))
+	if decay > maxSuppress {
+		decay = maxSuppress
+	}
+	if decay < 0 {
+		return 0
+	}
+
+	return decay
+}
+
+// reusable checks if the suppressed path identified by k may be used again
+func (d *dampening) reusable(k pathKey) bool {
+	h, ok := d.history[k]
+	if !ok || !h.suppressed {
+		return false
+	}
+
+	now := d.now()
+	d.decay(h, now)
+	if d.reuseIn(h, now) > 0 {
+		return false
+	}
+
+	h.suppressed = false
+	return true
+}
+
+// expire disposes the history of k once the penalty has decayed below half the reuse threshold (RFC2439 4.8.6)
+func (d *dampening) expire(k pathKey) {
+	h, ok := d.history[k]
+	if !ok || h.suppressed {
+		return
+	}
+
+	d.decay(h, d.now())
+	if h.penalty < float64(d.params.ReuseThreshold)/2 {
+		delete(d.history, k)
+	}
+}
+
+// collect disposes the histories of all paths not suppressed that have expired
+func (d *dampening) collect() {
+	for k := range d.history {
+		d.expire(k)
+	}
+}
+
+// stop stops all timers and disposes all histories
+func (d *dampening) stop() {
+	for _, h := range d.history {
+		if h.timer != nil {
+			h.timer.Stop()
+		}
+	}
+	if d.gcTimer != nil {
+		d.gcTimer.Stop()
+		d.gcTimer = nil
+	}
+
+	d.history = make(map[pathKey]*flapHistory)
+}
+
+// paths returns the flap history of all paths. Histories expired are disposed.
+func (d *dampening) paths() []DampedPath {
+	now := d.now()
+	res := make([]DampedPath, 0, len(d.history))
+	for k, h := range d.history {
+		d.expire(k)
+		if _, ok := d.history[k]; !ok {
+			continue
+		}
+
+		p := DampedPath{
+			Prefix:         k.prefix(),
+			PathIdentifier: k.pathID,
+			Penalty:        uint32(h.penalty),
+			Suppressed:     h.suppressed,
+		}
+		if h.suppressed {
+			p.ReuseTime = now.Add(d.reuseIn(h, now))
+		}
+		res = append(res, p)
+	}
+
+	return res
+}
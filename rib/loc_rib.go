@@ -0,0 +1,296 @@
+package rib
+
+import (
+	"sync"
+
+	"github.com/taktv6/tbgp/lpm"
+	"github.com/taktv6/tbgp/net"
+	"github.com/taktv6/tbgp/route"
+)
+
+// LocRIB holds the paths of all neighbors and propagates the paths of every prefix selected
+// by the options of a client (e.g. the best path only) to its clients
+type LocRIB struct {
+	clientManager
+	mu     sync.RWMutex
+	routes map[net.Prefix]*route.Route
+
+	// suppressed holds the prefixes of summary-only aggregates. The value is the number of Suppress calls.
+	suppressed *lpm.LPM
+}
+
+// NewLocRIB creates a new empty Loc-RIB
+func NewLocRIB() *LocRIB {
+	return &LocRIB{
+		clientManager: newClientManager(),
+		routes:        make(map[net.Prefix]*route.Route),
+		suppressed:    lpm.New(),
+	}
+}
+
+// Register adds a client and sends it the best paths of all prefixes
+func (l *LocRIB) Register(client RouteTableClient) {
+	l.RegisterWithOptions(client, ClientOptions{BestOnly: true})
+}
+
+// RegisterWithOptions adds a client and sends it the paths of all prefixes selected by opts
+func (l *LocRIB) RegisterWithOptions(client RouteTableClient, opts ClientOptions) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.add(client, opts)
+	for _, r := range l.routes {
+		if opts.ExcludeSuppressed && l.isSuppressed(r.Prefix()) {
+			continue
+		}
+
+		for _, p := range opts.paths(r) {
+			client.AddPath(r.Prefix(), p)
+		}
+	}
+}
+
+// Unregister removes a client
+func (l *LocRIB) Unregister(client RouteTableClient) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.remove(client)
+}
+
+// AddPath adds a path for pfx replacing a path with the same identity (see route.Path.SameIdentity)
+func (l *LocRIB) AddPath(pfx *net.Prefix, p *route.Path) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	r, ok := l.routes[*pfx]
+	if !ok {
+		r = route.NewRoute(net.NewPfx(pfx.Addr(), pfx.Pfxlen()))
+		l.routes[*pfx] = r
+	}
+
+	old := copyRoute(r)
+	r.AddPath(p)
+	l.propagate(old, r)
+
+	return nil
+}
+
+// RemovePath removes the path with the identity of p for pfx
+func (l *LocRIB) RemovePath(pfx *net.Prefix, p *route.Path) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	r, ok := l.routes[*pfx]
+	if !ok {
+		return false
+	}
+
+	old := copyRoute(r)
+	if !r.RemovePath(p) {
+		return false
+	}
+
+	if len(r.Paths()) == 0 {
+		delete(l.routes, *pfx)
+	}
+	l.propagate(old, r)
+
+	return true
+}
+
+// propagate informs every client about the changes of the paths selected for it between old and new.
+// Clients are called with l.mu held, so they must not block (e.g. by writing to a neighbor).
+func (l *LocRIB) propagate(old *route.Route, new *route.Route) {
+	pfx := new.Prefix()
+	suppressed := l.isSuppressed(pfx)
+	for client, opts := range l.clients {
+		if opts.ExcludeSuppressed && suppressed {
+			continue
+		}
+
+		oldPaths := opts.paths(old)
+		newPaths := opts.paths(new)
+
+		// A client receiving the best path only has it replaced implicitly by a new best path
+		if opts.BestOnly {
+			if len(newPaths) == 0 {
+				if len(oldPaths) > 0 {
+					client.RemovePath(pfx, oldPaths[0])
+				}
+				continue
+			}
+
+			if len(oldPaths) == 0 || oldPaths[0] != newPaths[0] {
+				client.AddPath(pfx, newPaths[0])
+			}
+			continue
+		}
+
+		for _, p := range newPaths {
+			if !containsPath(oldPaths, p) {
+				client.AddPath(pfx, p)
+			}
+		}
+
+		for _, p := range oldPaths {
+			if !containsIdentity(newPaths, p) {
+				client.RemovePath(pfx, p)
+			}
+		}
+	}
+}
+
+// Suppress suppresses all prefixes more specific than pfx for clients excluding suppressed prefixes.
+// Calls are counted so a prefix can be suppressed by multiple aggregates.
+func (l *LocRIB) Suppress(pfx *net.Prefix) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	count := l.suppressCount(pfx)
+	if count == 0 {
+		for _, r := range l.moreSpecifics(pfx) {
+			if l.isSuppressed(r.Prefix()) {
+				continue
+			}
+
+			l.sendSuppressed(r, func(client RouteTableClient, p *route.Path) {
+				client.RemovePath(r.Prefix(), p)
+			})
+		}
+	}
+
+	l.suppressed.InsertValue(pfx, count+1)
+}
+
+// Unsuppress reverts a call of Suppress for pfx. Prefixes not suppressed anymore are sent to the clients again.
+func (l *LocRIB) Unsuppress(pfx *net.Prefix) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	count := l.suppressCount(pfx)
+	if count == 0 {
+		return
+	}
+
+	if count > 1 {
+		l.suppressed.InsertValue(pfx, count-1)
+		return
+	}
+
+	l.suppressed.Remove(pfx)
+	for _, r := range l.moreSpecifics(pfx) {
+		if l.isSuppressed(r.Prefix()) {
+			continue
+		}
+
+		l.sendSuppressed(r, func(client RouteTableClient, p *route.Path) {
+			client.AddPath(r.Prefix(), p)
+		})
+	}
+}
+
+// IsSuppressed checks if pfx is suppressed by a summary-only aggregate
+func (l *LocRIB) IsSuppressed(pfx *net.Prefix) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.isSuppressed(pfx)
+}
+
+func (l *LocRIB) isSuppressed(pfx *net.Prefix) bool {
+	covering, _ := l.suppressed.Covering(pfx)
+	return covering != nil
+}
+
+func (l *LocRIB) suppressCount(pfx *net.Prefix) int {
+	v, ok := l.suppressed.Value(pfx)
+	if !ok {
+		return 0
+	}
+
+	return v.(int)
+}
+
+// moreSpecifics returns the routes of all prefixes more specific than pfx
+func (l *LocRIB) moreSpecifics(pfx *net.Prefix) []*route.Route {
+	res := make([]*route.Route, 0)
+	for _, r := range l.routes {
+		if pfx.Contains(r.Prefix()) {
+			res = append(res, r)
+		}
+	}
+
+	return res
+}
+
+// sendSuppressed calls fn for every path of r selected for a client excluding suppressed prefixes
+func (l *LocRIB) sendSuppressed(r *route.Route, fn func(client RouteTableClient, p *route.Path)) {
+	for client, opts := range l.clients {
+		if !opts.ExcludeSuppressed {
+			continue
+		}
+
+		for _, p := range opts.paths(r) {
+			fn(client, p)
+		}
+	}
+}
+
+func copyRoute(r *route.Route) *route.Route {
+	return route.NewRoute(r.Prefix(), append([]*route.Path(nil), r.Paths()...)...)
+}
+
+func containsPath(paths []*route.Path, p *route.Path) bool {
+	for _, q := range paths {
+		if q == p {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsIdentity(paths []*route.Path, p *route.Path) bool {
+	for _, q := range paths {
+		if q.SameIdentity(p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Get returns the route for pfx or nil if there is none
+func (l *LocRIB) Get(pfx *net.Prefix) *route.Route {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	r, ok := l.routes[*pfx]
+	if !ok {
+		return nil
+	}
+
+	return copyRoute(r)
+}
+
+// Count returns the number of prefixes held
+func (l *LocRIB) Count() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return len(l.routes)
+}
+
+// Routes returns all routes held
+func (l *LocRIB) Routes() []*route.Route {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	res := make([]*route.Route, 0, len(l.routes))
+	for _, r := range l.routes {
+		res = append(res, copyRoute(r))
+	}
+
+	return res
+}
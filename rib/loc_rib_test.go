@@ -0,0 +1,188 @@
+package rib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taktv6/tbgp/net"
+	"github.com/taktv6/tbgp/route"
+)
+
+func TestLocRIBAddPath(t *testing.T) {
+	pfx := net.NewPfx(167772160, 8) // 10.0.0.0/8
+	a := &route.Path{Source: 1, LocalPref: 100}
+	b := &route.Path{Source: 2, LocalPref: 200}
+	c := &route.Path{Source: 3, LocalPref: 50}
+
+	l := NewLocRIB()
+	m := newMockClient()
+	l.Register(m)
+
+	l.AddPath(pfx, a)
+	assert.Equal(t, a, m.added[*pfx])
+
+	l.AddPath(pfx, b)
+	assert.Equal(t, b, m.added[*pfx])
+
+	// c is not better than b and must not be propagated
+	m.added = make(map[net.Prefix]*route.Path)
+	l.AddPath(pfx, c)
+	assert.Empty(t, m.added)
+
+	assert.Equal(t, 1, l.Count())
+	assert.Len(t, l.Get(pfx).Paths(), 3)
+}
+
+func TestLocRIBRemovePath(t *testing.T) {
+	pfx := net.NewPfx(167772160, 8) // 10.0.0.0/8
+	a := &route.Path{Source: 1, LocalPref: 100}
+	b := &route.Path{Source: 2, LocalPref: 200}
+
+	l := NewLocRIB()
+	l.AddPath(pfx, a)
+	l.AddPath(pfx, b)
+
+	m := newMockClient()
+	l.Register(m)
+	assert.Equal(t, b, m.added[*pfx])
+
+	// Removing the best path makes the next best path being propagated
+	assert.True(t, l.RemovePath(pfx, &route.Path{Source: 2}))
+	assert.Equal(t, a, m.added[*pfx])
+	assert.Empty(t, m.removed)
+
+	assert.False(t, l.RemovePath(pfx, &route.Path{Source: 3}))
+
+	// Removing the last path withdraws the prefix
+	assert.True(t, l.RemovePath(pfx, &route.Path{Source: 1}))
+	assert.Equal(t, a, m.removed[*pfx])
+	assert.Equal(t, 0, l.Count())
+	assert.Nil(t, l.Get(pfx))
+}
+
+func TestLocRIBUnregister(t *testing.T) {
+	pfx := net.NewPfx(167772160, 8) // 10.0.0.0/8
+
+	l := NewLocRIB()
+	m := newMockClient()
+	l.Register(m)
+	l.Unregister(m)
+
+	l.AddPath(pfx, &route.Path{Source: 1})
+	assert.Empty(t, m.added)
+}
+
+func TestLocRIBRegisterWithOptions(t *testing.T) {
+	pfx := net.NewPfx(167772160, 8) // 10.0.0.0/8
+	a := &route.Path{Source: 1, LocalPref: 100}
+	b := &route.Path{Source: 2, LocalPref: 200}
+
+	l := NewLocRIB()
+	r := &recordingClient{}
+	l.RegisterWithOptions(r, ClientOptions{})
+
+	l.AddPath(pfx, a)
+	l.AddPath(pfx, b)
+	assert.Equal(t, []*route.Path{a, b}, r.added)
+
+	// A client receiving all paths gets every path withdrawn separately
+	assert.True(t, l.RemovePath(pfx, &route.Path{Source: 2}))
+	assert.Equal(t, []*route.Path{b}, r.removed)
+
+	// A replaced path is sent again without withdrawing it first
+	a2 := &route.Path{Source: 1, LocalPref: 300}
+	l.AddPath(pfx, a2)
+	assert.Equal(t, []*route.Path{a, b, a2}, r.added)
+	assert.Equal(t, []*route.Path{b}, r.removed)
+}
+
+func TestLocRIBRegisterWithOptionsMaxPaths(t *testing.T) {
+	pfx := net.NewPfx(167772160, 8) // 10.0.0.0/8
+	a := &route.Path{Source: 1, LocalPref: 100}
+	b := &route.Path{Source: 2, LocalPref: 200}
+
+	l := NewLocRIB()
+	r := &recordingClient{}
+	l.RegisterWithOptions(r, ClientOptions{MaxPaths: 1})
+
+	l.AddPath(pfx, a)
+	assert.Equal(t, []*route.Path{a}, r.added)
+
+	// b displaces a from the paths selected for the client
+	l.AddPath(pfx, b)
+	assert.Equal(t, []*route.Path{a, b}, r.added)
+	assert.Equal(t, []*route.Path{a}, r.removed)
+}
+
+func TestLocRIBSuppress(t *testing.T) {
+	aggregate := net.NewPfx(167772160, 8) // 10.0.0.0/8
+	inner := net.NewPfx(167772160, 16)    // 10.0.0.0/16
+	other := net.NewPfx(3232235520, 16)   // 192.168.0.0/16
+	a := &route.Path{Source: 1, LocalPref: 100}
+	b := &route.Path{Source: 2, LocalPref: 100}
+
+	l := NewLocRIB()
+	l.AddPath(inner, a)
+	l.AddPath(other, b)
+
+	m := newMockClient()
+	l.RegisterWithOptions(m, ClientOptions{BestOnly: true, ExcludeSuppressed: true})
+	all := newMockClient()
+	l.Register(all)
+
+	// Suppressing withdraws more specifics only
+	l.Suppress(aggregate)
+	l.Suppress(aggregate)
+	assert.Equal(t, a, m.removed[*inner])
+	assert.NotContains(t, m.removed, *other)
+	assert.Empty(t, all.removed)
+	assert.True(t, l.IsSuppressed(inner))
+	assert.False(t, l.IsSuppressed(aggregate))
+
+	// Changes of suppressed prefixes are not propagated
+	m.added = make(map[net.Prefix]*route.Path)
+	l.AddPath(aggregate, b)
+	c := &route.Path{Source: 3, LocalPref: 200}
+	l.AddPath(inner, c)
+	assert.Equal(t, b, m.added[*aggregate])
+	assert.NotContains(t, m.added, *inner)
+	assert.Equal(t, c, all.added[*inner])
+
+	// A client registered while suppressed does not receive suppressed prefixes
+	late := newMockClient()
+	l.RegisterWithOptions(late, ClientOptions{BestOnly: true, ExcludeSuppressed: true})
+	assert.NotContains(t, late.added, *inner)
+	assert.Contains(t, late.added, *other)
+
+	// Prefixes are sent again once the last suppression is reverted
+	l.Unsuppress(aggregate)
+	assert.NotContains(t, m.added, *inner)
+	l.Unsuppress(aggregate)
+	assert.Equal(t, c, m.added[*inner])
+	assert.False(t, l.IsSuppressed(inner))
+
+	// Unsuppressing a prefix not suppressed is a no-op
+	l.Unsuppress(aggregate)
+}
+
+func TestLocRIBSuppressNested(t *testing.T) {
+	outer := net.NewPfx(167772160, 8)   // 10.0.0.0/8
+	middle := net.NewPfx(167772160, 12) // 10.0.0.0/12
+	inner := net.NewPfx(167772160, 16)  // 10.0.0.0/16
+	a := &route.Path{Source: 1}
+
+	l := NewLocRIB()
+	l.AddPath(inner, a)
+	r := &recordingClient{}
+	l.RegisterWithOptions(r, ClientOptions{BestOnly: true, ExcludeSuppressed: true})
+
+	// A prefix suppressed by two aggregates is withdrawn and sent again once
+	l.Suppress(outer)
+	l.Suppress(middle)
+	l.Unsuppress(outer)
+	assert.Equal(t, []*route.Path{a}, r.removed)
+	assert.Equal(t, []*route.Path{a}, r.added)
+
+	l.Unsuppress(middle)
+	assert.Equal(t, []*route.Path{a, a}, r.added)
+}
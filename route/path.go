@@ -0,0 +1,213 @@
+package route
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+
+	"github.com/taktv6/tbgp/packet"
+	"github.com/taktv6/tbgp/rpki"
+	"github.com/taktv6/tflow2/convert"
+)
+
+// Path represents a BGP path towards a prefix
+type Path struct {
+	Source          uint32 // Address of the peer the path was received from
+	PathIdentifier  uint32 // Path identifier the path was received with (RFC7911)
+	RouterID        uint32 // BGP identifier of the peer the path was received from
+	EBGP            bool
+	NextHop         uint32
+	LocalPref       uint32
+	ASPath          packet.ASPath
+	Origin          uint8
+	MED             uint32
+	AtomicAggregate bool
+	Aggregator      *packet.Aggretator
+	Communities     []uint32
+	OriginatorID    uint32   // BGP identifier of the originator of a reflected path (RFC4456)
+	ClusterList     []uint32 // Clusters a reflected path has passed (RFC4456)
+	ReflectorClient bool     // Path was received from a route reflector client
+	Confederation   bool     // Path was received from a confed-eBGP neighbor (RFC5065)
+	OnlyToCustomer  uint32   // AS of the Only to Customer attribute (RFC9234), 0 if not present
+	ValidationState rpki.ValidationState
+	ASPAState       rpki.ASPAState
+}
+
+// Copy creates a deep copy of p
+func (p *Path) Copy() *Path {
+	if p == nil {
+		return nil
+	}
+
+	c := *p
+	c.ASPath = p.ASPath.Copy()
+	if p.Aggregator != nil {
+		aggr := *p.Aggregator
+		c.Aggregator = &aggr
+	}
+	if p.Communities != nil {
+		c.Communities = make([]uint32, len(p.Communities))
+		copy(c.Communities, p.Communities)
+	}
+	if p.ClusterList != nil {
+		c.ClusterList = make([]uint32, len(p.ClusterList))
+		copy(c.ClusterList, p.ClusterList)
+	}
+
+	return &c
+}
+
+// HasCommunity checks if p carries community comm
+func (p *Path) HasCommunity(comm uint32) bool {
+	for _, c := range p.Communities {
+		if c == comm {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LongLivedStale checks if p is a stale route retained by long-lived graceful restart (RFC9494)
+func (p *Path) LongLivedStale() bool {
+	return p.HasCommunity(packet.LLGRStaleCommunity)
+}
+
+// MarkLongLivedStale returns a copy of p tagged with the LLGR_STALE community
+func (p *Path) MarkLongLivedStale() *Path {
+	if p.LongLivedStale() {
+		return p
+	}
+
+	c := p.Copy()
+	c.Communities = append(c.Communities, packet.LLGRStaleCommunity)
+	return c
+}
+
+// Equal checks if p and q carry the same information
+func (p *Path) Equal(q *Path) bool {
+	return reflect.DeepEqual(p, q)
+}
+
+// SameIdentity checks if p and q identify the same path, i.e. were received from
+// the same peer with the same path identifier. Their attributes may differ.
+func (p *Path) SameIdentity(q *Path) bool {
+	return p.Source == q.Source && p.PathIdentifier == q.PathIdentifier
+}
+
+// Better checks if p is preferred over q by the BGP decision process (RFC4271 9.1.2.2)
+func (p *Path) Better(q *Path) bool {
+	if q == nil {
+		return true
+	}
+
+	// Long-lived stale routes are least preferred (RFC9494 4.3)
+	if p.LongLivedStale() != q.LongLivedStale() {
+		return !p.LongLivedStale()
+	}
+
+	if p.LocalPref != q.LocalPref {
+		return p.LocalPref > q.LocalPref
+	}
+
+	// Valid routes are preferred over not found ones which are preferred over invalid ones
+	if p.ValidationState != q.ValidationState {
+		return validationPreference(p.ValidationState) > validationPreference(q.ValidationState)
+	}
+
+	if p.ASPath.Length() != q.ASPath.Length() {
+		return p.ASPath.Length() < q.ASPath.Length()
+	}
+
+	if p.Origin != q.Origin {
+		return p.Origin < q.Origin
+	}
+
+	// MEDs are only comparable for paths received from the same neighbor AS
+	if p.neighborAS() == q.neighborAS() && p.MED != q.MED {
+		return p.MED < q.MED
+	}
+
+	if p.EBGP != q.EBGP {
+		return p.EBGP
+	}
+
+	// The ORIGINATOR_ID replaces the BGP identifier of reflected paths (RFC4456 9)
+	if p.originatorID() != q.originatorID() {
+		return p.originatorID() < q.originatorID()
+	}
+
+	if len(p.ClusterList) != len(q.ClusterList) {
+		return len(p.ClusterList) < len(q.ClusterList)
+	}
+
+	if p.Source != q.Source {
+		return p.Source < q.Source
+	}
+
+	return p.PathIdentifier < q.PathIdentifier
+}
+
+// ECMP checks if p and q are equally preferred by the BGP decision process
+// up to the tie breaking steps and thus are suitable for multipath forwarding
+func (p *Path) ECMP(q *Path) bool {
+	return p.LongLivedStale() == q.LongLivedStale() &&
+		p.LocalPref == q.LocalPref &&
+		p.ValidationState == q.ValidationState &&
+		p.ASPath.Length() == q.ASPath.Length() &&
+		p.Origin == q.Origin &&
+		p.MED == q.MED &&
+		p.EBGP == q.EBGP
+}
+
+func validationPreference(s rpki.ValidationState) int {
+	switch s {
+	case rpki.Valid:
+		return 2
+	case rpki.NotFound:
+		return 1
+	}
+
+	return 0
+}
+
+// originatorID returns the BGP identifier of the speaker that originated p into the AS
+func (p *Path) originatorID() uint32 {
+	if p.OriginatorID != 0 {
+		return p.OriginatorID
+	}
+
+	return p.RouterID
+}
+
+// InClusterList checks if clusterID is in the CLUSTER_LIST of p
+func (p *Path) InClusterList(clusterID uint32) bool {
+	for _, id := range p.ClusterList {
+		if id == clusterID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// neighborAS returns the AS a path was received from. Confederation segments are skipped (RFC5065 5.3).
+func (p *Path) neighborAS() uint32 {
+	return p.ASPath.FirstAS()
+}
+
+// String returns a string representation of p
+func (p *Path) String() string {
+	return fmt.Sprintf("source: %s, next hop: %s, local pref: %d, AS path: %s, origin: %d, MED: %d, communities: %s",
+		net.IP(convert.Uint32Byte(p.Source)), net.IP(convert.Uint32Byte(p.NextHop)), p.LocalPref, p.ASPath, p.Origin, p.MED, communitiesString(p.Communities))
+}
+
+func communitiesString(comms []uint32) string {
+	res := make([]string, len(comms))
+	for i, c := range comms {
+		res[i] = fmt.Sprintf("%d:%d", c>>16, c&0xffff)
+	}
+
+	return strings.Join(res, " ")
+}
@@ -0,0 +1,255 @@
+package route
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taktv6/tbgp/packet"
+	"github.com/taktv6/tbgp/rpki"
+)
+
+func TestBetter(t *testing.T) {
+	tests := []struct {
+		name     string
+		p        *Path
+		q        *Path
+		expected bool
+	}{
+		{
+			name:     "Any path is better than none",
+			p:        &Path{},
+			q:        nil,
+			expected: true,
+		},
+		{
+			name: "Long-lived stale path with higher local pref",
+			p: &Path{
+				LocalPref:   200,
+				Communities: []uint32{packet.LLGRStaleCommunity},
+			},
+			q: &Path{
+				LocalPref: 100,
+			},
+			expected: false,
+		},
+		{
+			name: "Higher local pref",
+			p: &Path{
+				LocalPref: 200,
+			},
+			q: &Path{
+				LocalPref: 100,
+			},
+			expected: true,
+		},
+		{
+			name: "Valid path with longer AS path",
+			p: &Path{
+				ValidationState: rpki.Valid,
+				ASPath: packet.ASPath{
+					{
+						Type: packet.ASSequence,
+						ASNs: []uint32{100, 200},
+					},
+				},
+			},
+			q: &Path{
+				ValidationState: rpki.NotFound,
+			},
+			expected: true,
+		},
+		{
+			name: "Invalid path",
+			p: &Path{
+				ValidationState: rpki.Invalid,
+			},
+			q: &Path{
+				ValidationState: rpki.NotFound,
+				Source:          2,
+			},
+			expected: false,
+		},
+		{
+			name: "Longer AS path",
+			p: &Path{
+				ASPath: packet.ASPath{
+					{
+						Type: packet.ASSequence,
+						ASNs: []uint32{100, 200},
+					},
+				},
+			},
+			q: &Path{
+				ASPath: packet.ASPath{
+					{
+						Type: packet.ASSequence,
+						ASNs: []uint32{300},
+					},
+				},
+			},
+			expected: false,
+		},
+		{
+			name: "Lower origin",
+			p: &Path{
+				Origin: packet.IGP,
+			},
+			q: &Path{
+				Origin: packet.INCOMPLETE,
+			},
+			expected: true,
+		},
+		{
+			name: "Lower MED from same neighbor AS",
+			p: &Path{
+				ASPath: packet.ASPath{
+					{
+						Type: packet.ASSequence,
+						ASNs: []uint32{100, 200},
+					},
+				},
+				MED:      10,
+				RouterID: 2,
+			},
+			q: &Path{
+				ASPath: packet.ASPath{
+					{
+						Type: packet.ASSequence,
+						ASNs: []uint32{100, 300},
+					},
+				},
+				MED:      20,
+				RouterID: 1,
+			},
+			expected: true,
+		},
+		{
+			name: "Lower MED from different neighbor AS",
+			p: &Path{
+				ASPath: packet.ASPath{
+					{
+						Type: packet.ASSequence,
+						ASNs: []uint32{100},
+					},
+				},
+				MED:      10,
+				RouterID: 2,
+			},
+			q: &Path{
+				ASPath: packet.ASPath{
+					{
+						Type: packet.ASSequence,
+						ASNs: []uint32{200},
+					},
+				},
+				MED:      20,
+				RouterID: 1,
+			},
+			expected: false,
+		},
+		{
+			name: "eBGP over iBGP",
+			p: &Path{
+				EBGP:     true,
+				RouterID: 2,
+			},
+			q: &Path{
+				EBGP:     false,
+				RouterID: 1,
+			},
+			expected: true,
+		},
+		{
+			name: "Lower router ID",
+			p: &Path{
+				RouterID: 1,
+			},
+			q: &Path{
+				RouterID: 2,
+			},
+			expected: true,
+		},
+		{
+			name: "Lower originator ID replacing a higher router ID",
+			p: &Path{
+				RouterID:     3,
+				OriginatorID: 1,
+			},
+			q: &Path{
+				RouterID: 2,
+			},
+			expected: true,
+		},
+		{
+			name: "Shorter cluster list",
+			p: &Path{
+				OriginatorID: 1,
+				ClusterList:  []uint32{10},
+				Source:       2,
+			},
+			q: &Path{
+				OriginatorID: 1,
+				ClusterList:  []uint32{10, 20},
+				Source:       1,
+			},
+			expected: true,
+		},
+		{
+			name: "Higher peer address",
+			p: &Path{
+				Source: 2,
+			},
+			q: &Path{
+				Source: 1,
+			},
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, test.p.Better(test.q), test.name)
+	}
+}
+
+func TestPathCopy(t *testing.T) {
+	p := &Path{
+		Source: 100,
+		ASPath: packet.ASPath{
+			{
+				Type: packet.ASSequence,
+				ASNs: []uint32{100, 200},
+			},
+		},
+		Aggregator: &packet.Aggretator{
+			ASN: 100,
+		},
+		Communities: []uint32{100},
+		ClusterList: []uint32{100},
+	}
+
+	c := p.Copy()
+	assert.True(t, p.Equal(c))
+
+	c.ASPath[0].ASNs[0] = 300
+	c.Aggregator.ASN = 300
+	c.Communities[0] = 300
+	c.ClusterList[0] = 300
+	assert.False(t, p.Equal(c))
+	assert.Equal(t, uint32(100), p.ASPath[0].ASNs[0])
+	assert.Equal(t, uint16(100), p.Aggregator.ASN)
+	assert.Equal(t, uint32(100), p.Communities[0])
+	assert.Equal(t, uint32(100), p.ClusterList[0])
+}
+
+func TestMarkLongLivedStale(t *testing.T) {
+	p := &Path{
+		Communities: []uint32{100},
+	}
+
+	s := p.MarkLongLivedStale()
+	assert.True(t, s.LongLivedStale())
+	assert.False(t, p.LongLivedStale())
+	assert.Equal(t, []uint32{100, packet.LLGRStaleCommunity}, s.Communities)
+
+	assert.True(t, s == s.MarkLongLivedStale())
+}
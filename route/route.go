@@ -0,0 +1,102 @@
+package route
+
+import (
+	"sort"
+
+	"github.com/taktv6/tbgp/net"
+)
+
+// Route is a prefix together with all paths known towards it
+type Route struct {
+	pfx   *net.Prefix
+	paths []*Path
+}
+
+// NewRoute creates a new route for pfx
+func NewRoute(pfx *net.Prefix, paths ...*Path) *Route {
+	return &Route{
+		pfx:   pfx,
+		paths: paths,
+	}
+}
+
+// Prefix returns the prefix of the route
+func (r *Route) Prefix() *net.Prefix {
+	return r.pfx
+}
+
+// Paths returns all paths of the route
+func (r *Route) Paths() []*Path {
+	return r.paths
+}
+
+// BestPath returns the most preferred path of the route
+func (r *Route) BestPath() *Path {
+	var best *Path
+	for _, p := range r.paths {
+		if p.Better(best) {
+			best = p
+		}
+	}
+
+	return best
+}
+
+// SortedPaths returns all paths of the route ordered by preference
+func (r *Route) SortedPaths() []*Path {
+	paths := append([]*Path(nil), r.paths...)
+	sort.Slice(paths, func(i, j int) bool {
+		return paths[i].Better(paths[j])
+	})
+
+	return paths
+}
+
+// ECMPPaths returns the best path along with all paths equally preferred (see Path.ECMP)
+func (r *Route) ECMPPaths() []*Path {
+	best := r.BestPath()
+	if best == nil {
+		return nil
+	}
+
+	paths := []*Path{best}
+	for _, p := range r.SortedPaths() {
+		if p != best && p.ECMP(best) {
+			paths = append(paths, p)
+		}
+	}
+
+	return paths
+}
+
+// AddPath adds p to the route. An existing path with the same identity (see Path.SameIdentity) is replaced.
+func (r *Route) AddPath(p *Path) {
+	i := r.pathIndex(p)
+	if i < 0 {
+		r.paths = append(r.paths, p)
+		return
+	}
+
+	r.paths[i] = p
+}
+
+// RemovePath removes the path with the same identity as p
+func (r *Route) RemovePath(p *Path) bool {
+	i := r.pathIndex(p)
+	if i < 0 {
+		return false
+	}
+
+	r.paths = append(r.paths[:i], r.paths[i+1:]...)
+	return true
+}
+
+func (r *Route) pathIndex(p *Path) int {
+	for i := range r.paths {
+		if r.paths[i].SameIdentity(p) {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,65 @@
+package route
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taktv6/tbgp/net"
+)
+
+func TestRouteAddPath(t *testing.T) {
+	r := NewRoute(net.NewPfx(167772160, 8)) // 10.0.0.0/8
+
+	r.AddPath(&Path{Source: 1, LocalPref: 100})
+	r.AddPath(&Path{Source: 2, LocalPref: 200})
+	r.AddPath(&Path{Source: 1, LocalPref: 300})
+
+	assert.Equal(t, []*Path{
+		{Source: 1, LocalPref: 300},
+		{Source: 2, LocalPref: 200},
+	}, r.Paths())
+	assert.Equal(t, &Path{Source: 1, LocalPref: 300}, r.BestPath())
+}
+
+func TestRouteRemovePath(t *testing.T) {
+	r := NewRoute(net.NewPfx(167772160, 8), &Path{Source: 1}, &Path{Source: 2}) // 10.0.0.0/8
+
+	assert.True(t, r.RemovePath(&Path{Source: 1}))
+	assert.False(t, r.RemovePath(&Path{Source: 3}))
+	assert.Equal(t, []*Path{{Source: 2}}, r.Paths())
+
+	assert.True(t, r.RemovePath(&Path{Source: 2}))
+	assert.Nil(t, r.BestPath())
+}
+
+func TestRoutePathIdentifiers(t *testing.T) {
+	r := NewRoute(net.NewPfx(167772160, 8)) // 10.0.0.0/8
+
+	r.AddPath(&Path{Source: 1, PathIdentifier: 1, LocalPref: 100})
+	r.AddPath(&Path{Source: 1, PathIdentifier: 2, LocalPref: 200})
+	assert.Len(t, r.Paths(), 2)
+
+	assert.True(t, r.RemovePath(&Path{Source: 1, PathIdentifier: 2}))
+	assert.Equal(t, []*Path{{Source: 1, PathIdentifier: 1, LocalPref: 100}}, r.Paths())
+}
+
+func TestRouteSortedPaths(t *testing.T) {
+	a := &Path{Source: 1, LocalPref: 100}
+	b := &Path{Source: 2, LocalPref: 300}
+	c := &Path{Source: 3, LocalPref: 200}
+	r := NewRoute(net.NewPfx(167772160, 8), a, b, c) // 10.0.0.0/8
+
+	assert.Equal(t, []*Path{b, c, a}, r.SortedPaths())
+	assert.Equal(t, []*Path{a, b, c}, r.Paths())
+}
+
+func TestRouteECMPPaths(t *testing.T) {
+	a := &Path{Source: 1, LocalPref: 200, RouterID: 3}
+	b := &Path{Source: 2, LocalPref: 200, RouterID: 1}
+	c := &Path{Source: 3, LocalPref: 200, RouterID: 2, MED: 10}
+	d := &Path{Source: 4, LocalPref: 200, RouterID: 2}
+	r := NewRoute(net.NewPfx(167772160, 8), a, b, c, d) // 10.0.0.0/8
+
+	assert.Equal(t, []*Path{b, d, a}, r.ECMPPaths())
+	assert.Nil(t, NewRoute(net.NewPfx(167772160, 8)).ECMPPaths())
+}
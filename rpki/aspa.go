@@ -0,0 +1,303 @@
+package rpki
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/taktv6/tbgp/packet"
+)
+
+// ASPAState is the AS_PATH verification state of a route based on ASPA records
+// (draft-ietf-sidrops-aspa-verification)
+type ASPAState uint8
+
+const (
+	// ASPAUnknown means the AS_PATH could neither be proven valid nor invalid due to missing ASPA records
+	ASPAUnknown ASPAState = iota
+
+	// ASPAValid means the ASPA records attest every hop of the AS_PATH to be valley free
+	ASPAValid
+
+	// ASPAInvalid means the ASPA records prove the AS_PATH to contain a route leak or the AS_PATH is malformed
+	ASPAInvalid
+)
+
+// String returns a string representation of s
+func (s ASPAState) String() string {
+	switch s {
+	case ASPAValid:
+		return "valid"
+	case ASPAInvalid:
+		return "invalid"
+	}
+
+	return "unknown"
+}
+
+// ASPA is a validated ASPA payload: The set of ASes authorized to act as provider for a customer AS
+type ASPA struct {
+	CustomerASN uint32
+	Providers   []uint32
+}
+
+// hop is the result of checking a single hop of an AS_PATH against the ASPA records
+type hop uint8
+
+const (
+	noAttestation hop = iota
+	providerPlus
+	notProviderPlus
+)
+
+// ASPATable holds ASPA records and verifies AS_PATHs against them
+type ASPATable struct {
+	mu        sync.RWMutex
+	providers map[uint32]map[uint32]struct{}
+}
+
+// NewASPATable creates a new empty ASPA table
+func NewASPATable() *ASPATable {
+	return &ASPATable{
+		providers: make(map[uint32]map[uint32]struct{}),
+	}
+}
+
+func (t *ASPATable) set(a ASPA) bool {
+	providers := make(map[uint32]struct{}, len(a.Providers))
+	for _, asn := range a.Providers {
+		providers[asn] = struct{}{}
+	}
+
+	if old, ok := t.providers[a.CustomerASN]; ok && sameProviders(old, providers) {
+		return false
+	}
+
+	t.providers[a.CustomerASN] = providers
+	return true
+}
+
+func sameProviders(a map[uint32]struct{}, b map[uint32]struct{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for asn := range a {
+		if _, ok := b[asn]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Update removes the records of all customer ASes withdrawn and adds or replaces the records announced
+// in a single step. It returns true if the table has changed.
+func (t *ASPATable) Update(announced []ASPA, withdrawn []uint32) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	changed := false
+	for _, asn := range withdrawn {
+		if _, ok := t.providers[asn]; ok {
+			delete(t.providers, asn)
+			changed = true
+		}
+	}
+
+	for _, a := range announced {
+		if t.set(a) {
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// Replace replaces the content of the table by aspas. It returns true if the table has changed.
+func (t *ASPATable) Replace(aspas []ASPA) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	keep := make(map[uint32]struct{}, len(aspas))
+	for _, a := range aspas {
+		keep[a.CustomerASN] = struct{}{}
+	}
+
+	changed := false
+	for asn := range t.providers {
+		if _, ok := keep[asn]; !ok {
+			delete(t.providers, asn)
+			changed = true
+		}
+	}
+
+	for _, a := range aspas {
+		if t.set(a) {
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// Flush removes all records. It returns true if the table has changed.
+func (t *ASPATable) Flush() bool {
+	return t.Replace(nil)
+}
+
+// Count returns the number of records in the table
+func (t *ASPATable) Count() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return len(t.providers)
+}
+
+// ASPAs returns all records in the table ordered by customer ASN
+func (t *ASPATable) ASPAs() []ASPA {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	res := make([]ASPA, 0, len(t.providers))
+	for customer, providers := range t.providers {
+		a := ASPA{
+			CustomerASN: customer,
+			Providers:   make([]uint32, 0, len(providers)),
+		}
+		for asn := range providers {
+			a.Providers = append(a.Providers, asn)
+		}
+		sort.Slice(a.Providers, func(i, j int) bool { return a.Providers[i] < a.Providers[j] })
+		res = append(res, a)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].CustomerASN < res[j].CustomerASN })
+
+	return res
+}
+
+// Verify determines the ASPA state of a route with AS_PATH p received via eBGP from neighborAS.
+// Downstream verification is used for routes received from a provider, upstream verification otherwise.
+// A neighborAS of 0 skips checking the neighbor to be the leftmost AS which is required for routes
+// received from a transparent route server. Confederation segments are ignored.
+func (t *ASPATable) Verify(p packet.ASPath, neighborAS uint32, downstream bool) ASPAState {
+	path, ok := collapse(p)
+	if !ok {
+		return ASPAInvalid
+	}
+
+	if neighborAS != 0 && (len(path) == 0 || path[len(path)-1] != neighborAS) {
+		return ASPAInvalid
+	}
+
+	if len(path) == 0 {
+		return ASPAUnknown
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if downstream {
+		return t.verifyDownstream(path)
+	}
+
+	return t.verifyUpstream(path)
+}
+
+// collapse returns the ASNs of p in order from the origin to the neighbor with prepends removed.
+// ok is false if p contains an AS_SET.
+func collapse(p packet.ASPath) (path []uint32, ok bool) {
+	for i := len(p) - 1; i >= 0; i-- {
+		switch p[i].Type {
+		case packet.ASConfedSequence, packet.ASConfedSet:
+			continue
+		case packet.ASSet:
+			return nil, false
+		}
+
+		for j := len(p[i].ASNs) - 1; j >= 0; j-- {
+			asn := p[i].ASNs[j]
+			if len(path) > 0 && path[len(path)-1] == asn {
+				continue
+			}
+			path = append(path, asn)
+		}
+	}
+
+	return path, true
+}
+
+// hop checks if provider is attested to be a provider of customer
+func (t *ASPATable) hop(customer uint32, provider uint32) hop {
+	providers, ok := t.providers[customer]
+	if !ok {
+		return noAttestation
+	}
+
+	if _, ok := providers[provider]; ok {
+		return providerPlus
+	}
+
+	return notProviderPlus
+}
+
+// verifyUpstream verifies a path received from a customer, lateral peer or route server (path[0] being the origin)
+func (t *ASPATable) verifyUpstream(path []uint32) ASPAState {
+	res := ASPAValid
+	for i := 0; i < len(path)-1; i++ {
+		switch t.hop(path[i], path[i+1]) {
+		case notProviderPlus:
+			return ASPAInvalid
+		case noAttestation:
+			res = ASPAUnknown
+		}
+	}
+
+	return res
+}
+
+// verifyDownstream verifies a path received from a provider (path[0] being the origin). The path has to consist
+// of an up-ramp of customer to provider hops followed by a down-ramp of provider to customer hops.
+func (t *ASPATable) verifyDownstream(path []uint32) ASPAState {
+	n := len(path)
+	if n <= 2 {
+		return ASPAValid
+	}
+
+	// The up-ramp must not be proven to end before the down-ramp is proven to begin
+	uMin := n
+	for u := 1; u < n; u++ {
+		if t.hop(path[u-1], path[u]) == notProviderPlus {
+			uMin = u
+			break
+		}
+	}
+
+	vMax := -1
+	for v := n - 2; v >= 0; v-- {
+		if t.hop(path[v+1], path[v]) == notProviderPlus {
+			vMax = v
+			break
+		}
+	}
+
+	if uMin <= vMax {
+		return ASPAInvalid
+	}
+
+	// The path is proven valid if the attested up- and down-ramps cover all of it
+	k := 0
+	for k < n-1 && t.hop(path[k], path[k+1]) == providerPlus {
+		k++
+	}
+
+	l := n - 1
+	for l > 0 && t.hop(path[l], path[l-1]) == providerPlus {
+		l--
+	}
+
+	if l-k <= 1 {
+		return ASPAValid
+	}
+
+	return ASPAUnknown
+}
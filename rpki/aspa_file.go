@@ -0,0 +1,50 @@
+package rpki
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// aspaFile is the JSON format of ASPA records as exported by rpki-client:
+// {"aspas": [{"customer_asid": 64496, "providers": [64497, 64498]}]}
+type aspaFile struct {
+	ASPAs []struct {
+		CustomerASID *uint32  `json:"customer_asid"`
+		Providers    []uint32 `json:"providers"`
+	} `json:"aspas"`
+}
+
+// LoadASPAFile reads ASPA records from the JSON file at path
+func LoadASPAFile(path string) ([]ASPA, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open ASPA file: %v", err)
+	}
+	defer f.Close()
+
+	return ReadASPAs(f)
+}
+
+// ReadASPAs reads ASPA records in JSON format from r
+func ReadASPAs(r io.Reader) ([]ASPA, error) {
+	var f aspaFile
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, fmt.Errorf("Unable to decode ASPA records: %v", err)
+	}
+
+	res := make([]ASPA, 0, len(f.ASPAs))
+	for i, a := range f.ASPAs {
+		if a.CustomerASID == nil {
+			return nil, fmt.Errorf("ASPA record %d lacks a customer ASN", i)
+		}
+
+		res = append(res, ASPA{
+			CustomerASN: *a.CustomerASID,
+			Providers:   a.Providers,
+		})
+	}
+
+	return res, nil
+}
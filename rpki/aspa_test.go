@@ -0,0 +1,204 @@
+package rpki
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taktv6/tbgp/packet"
+)
+
+func seq(asns ...uint32) packet.ASPathSegment {
+	return packet.ASPathSegment{
+		Type:  packet.ASSequence,
+		Count: uint8(len(asns)),
+		ASNs:  asns,
+	}
+}
+
+func TestASPAVerify(t *testing.T) {
+	// 10 and 20 are customers of 100, 100 is a customer of 1000, 30 is attested to have no provider
+	table := NewASPATable()
+	table.Replace([]ASPA{
+		{CustomerASN: 10, Providers: []uint32{100}},
+		{CustomerASN: 20, Providers: []uint32{100}},
+		{CustomerASN: 100, Providers: []uint32{1000}},
+		{CustomerASN: 30, Providers: []uint32{0}},
+	})
+
+	tests := []struct {
+		name       string
+		path       packet.ASPath
+		neighborAS uint32
+		downstream bool
+		expected   ASPAState
+	}{
+		{
+			name:       "Upstream from origin",
+			path:       packet.ASPath{seq(10)},
+			neighborAS: 10,
+			expected:   ASPAValid,
+		},
+		{
+			name:       "Upstream customer to provider",
+			path:       packet.ASPath{seq(100, 10)},
+			neighborAS: 100,
+			expected:   ASPAValid,
+		},
+		{
+			name:       "Upstream with prepends",
+			path:       packet.ASPath{seq(100, 100, 10, 10, 10)},
+			neighborAS: 100,
+			expected:   ASPAValid,
+		},
+		{
+			name:       "Upstream leak",
+			path:       packet.ASPath{seq(20, 100, 10)},
+			neighborAS: 20,
+			expected:   ASPAInvalid,
+		},
+		{
+			name:       "Upstream without attestation",
+			path:       packet.ASPath{seq(40, 10)},
+			neighborAS: 40,
+			expected:   ASPAInvalid,
+		},
+		{
+			name:       "Upstream from unattested AS",
+			path:       packet.ASPath{seq(100, 50)},
+			neighborAS: 100,
+			expected:   ASPAUnknown,
+		},
+		{
+			name:       "Neighbor AS not leftmost",
+			path:       packet.ASPath{seq(100, 10)},
+			neighborAS: 200,
+			expected:   ASPAInvalid,
+		},
+		{
+			name:     "Route server does not prepend",
+			path:     packet.ASPath{seq(100, 10)},
+			expected: ASPAValid,
+		},
+		{
+			name: "AS_SET",
+			path: packet.ASPath{
+				seq(100),
+				{
+					Type:  packet.ASSet,
+					Count: 2,
+					ASNs:  []uint32{10, 20},
+				},
+			},
+			neighborAS: 100,
+			expected:   ASPAInvalid,
+		},
+		{
+			name: "Confederation segments ignored",
+			path: packet.ASPath{
+				{
+					Type:  packet.ASConfedSequence,
+					Count: 1,
+					ASNs:  []uint32{65001},
+				},
+				seq(100, 10),
+			},
+			neighborAS: 100,
+			expected:   ASPAValid,
+		},
+		{
+			name:       "Downstream up and down ramp",
+			path:       packet.ASPath{seq(20, 100, 10)},
+			neighborAS: 20,
+			downstream: true,
+			expected:   ASPAValid,
+		},
+		{
+			name:       "Downstream via provider of provider",
+			path:       packet.ASPath{seq(100, 1000, 100, 10)},
+			neighborAS: 100,
+			downstream: true,
+			expected:   ASPAValid,
+		},
+		{
+			name:       "Downstream valley",
+			path:       packet.ASPath{seq(100, 30, 20, 10)},
+			neighborAS: 100,
+			downstream: true,
+			expected:   ASPAInvalid,
+		},
+		{
+			name:       "Downstream gap between ramps",
+			path:       packet.ASPath{seq(100, 70, 60, 50)},
+			neighborAS: 100,
+			downstream: true,
+			expected:   ASPAUnknown,
+		},
+		{
+			name:       "Downstream two hops",
+			path:       packet.ASPath{seq(40, 50)},
+			neighborAS: 40,
+			downstream: true,
+			expected:   ASPAValid,
+		},
+	}
+
+	for _, test := range tests {
+		res := table.Verify(test.path, test.neighborAS, test.downstream)
+		assert.Equal(t, test.expected, res, test.name)
+	}
+}
+
+func TestASPATableUpdate(t *testing.T) {
+	table := NewASPATable()
+
+	assert.True(t, table.Update([]ASPA{{CustomerASN: 10, Providers: []uint32{200, 100}}}, nil))
+	assert.False(t, table.Update([]ASPA{{CustomerASN: 10, Providers: []uint32{100, 200}}}, nil))
+	assert.True(t, table.Update([]ASPA{{CustomerASN: 20, Providers: []uint32{100}}}, []uint32{10, 30}))
+	assert.Equal(t, []ASPA{{CustomerASN: 20, Providers: []uint32{100}}}, table.ASPAs())
+
+	assert.True(t, table.Replace([]ASPA{{CustomerASN: 10, Providers: []uint32{100}}}))
+	assert.Equal(t, []ASPA{{CustomerASN: 10, Providers: []uint32{100}}}, table.ASPAs())
+
+	assert.True(t, table.Flush())
+	assert.False(t, table.Flush())
+	assert.Equal(t, 0, table.Count())
+}
+
+func TestReadASPAs(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantFail bool
+		expected []ASPA
+	}{
+		{
+			name:  "rpki-client export",
+			input: `{"metadata": {}, "roas": [], "aspas": [{"customer_asid": 10, "expires": 1700000000, "providers": [100, 200]}]}`,
+			expected: []ASPA{
+				{CustomerASN: 10, Providers: []uint32{100, 200}},
+			},
+		},
+		{
+			name:     "Missing customer",
+			input:    `{"aspas": [{"providers": [100]}]}`,
+			wantFail: true,
+		},
+		{
+			name:     "Invalid JSON",
+			input:    `{"aspas": [`,
+			wantFail: true,
+		},
+	}
+
+	for _, test := range tests {
+		res, err := ReadASPAs(strings.NewReader(test.input))
+		if test.wantFail {
+			assert.Error(t, err, test.name)
+			continue
+		}
+
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.expected, res, test.name)
+	}
+}
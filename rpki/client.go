@@ -0,0 +1,435 @@
+package rpki
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	tnet "github.com/taktv6/tbgp/net"
+)
+
+// Default timing parameters (RFC8210 6). Version 1 caches may announce their own.
+const (
+	DefaultRefreshInterval = 3600 * time.Second
+	DefaultRetryInterval   = 600 * time.Second
+	DefaultExpireInterval  = 7200 * time.Second
+
+	dialTimeout = 10 * time.Second
+)
+
+var (
+	errStopped          = errors.New("Client stopped")
+	errVersionDowngrade = errors.New("Cache does not support the protocol version")
+)
+
+// Client is an RPKI-to-Router client (RFC8210) keeping a VRP table in sync with an RPKI cache
+// via plain TCP. onChange is called with the prefixes of all VRPs changed by an update.
+// ASPA records are only received from caches supporting protocol version 2.
+type Client struct {
+	addr         string
+	table        *VRPTable
+	onChange     func(changed []*tnet.Prefix)
+	aspas        *ASPATable
+	onASPAChange func()
+
+	mu              sync.RWMutex
+	version         uint8
+	sessionID       uint16
+	serial          uint32
+	synced          bool
+	resetting       bool
+	lastUpdate      time.Time
+	refreshInterval time.Duration
+	retryInterval   time.Duration
+	expireInterval  time.Duration
+
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// update collects the VRPs of a cache response until End of Data is received
+type update struct {
+	reset         bool
+	announced     []VRP
+	withdrawn     []VRP
+	aspaAnnounced []ASPA
+	aspaWithdrawn []uint32
+}
+
+// NewClient creates a new RTR client for the cache at addr (host:port) maintaining table
+func NewClient(addr string, table *VRPTable, onChange func(changed []*tnet.Prefix)) *Client {
+	return &Client{
+		addr:            addr,
+		table:           table,
+		onChange:        onChange,
+		version:         Version2,
+		refreshInterval: DefaultRefreshInterval,
+		retryInterval:   DefaultRetryInterval,
+		expireInterval:  DefaultExpireInterval,
+		stopCh:          make(chan struct{}),
+		doneCh:          make(chan struct{}),
+	}
+}
+
+// SetASPATable makes the client keep table in sync with the ASPA records of the cache. onChange is
+// called whenever an update changed the ASPA records. It must be called before the client is started.
+func (c *Client) SetASPATable(table *ASPATable, onChange func()) {
+	c.aspas = table
+	c.onASPAChange = onChange
+}
+
+// Start connects to the cache and keeps the VRP table in sync until Stop is called
+func (c *Client) Start() {
+	go c.run()
+}
+
+// Stop closes the session with the cache. The VRP table is left as is.
+func (c *Client) Stop() {
+	close(c.stopCh)
+	<-c.doneCh
+}
+
+// Synced checks if the VRP table holds the complete data of the cache
+func (c *Client) Synced() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.synced
+}
+
+// Serial returns the session ID and serial number of the data held
+func (c *Client) Serial() (uint16, uint32) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.sessionID, c.serial
+}
+
+// Version returns the protocol version used with the cache
+func (c *Client) Version() uint8 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.version
+}
+
+func (c *Client) run() {
+	defer close(c.doneCh)
+
+	for {
+		err := c.session()
+		if err == errStopped {
+			return
+		}
+
+		if err != errVersionDowngrade {
+			log.WithFields(log.Fields{
+				"cache": c.addr,
+			}).Warningf("RTR session failed: %v", err)
+		}
+
+		c.expire()
+		if err == errVersionDowngrade {
+			continue
+		}
+
+		c.mu.RLock()
+		retry := c.retryInterval
+		c.mu.RUnlock()
+
+		select {
+		case <-c.stopCh:
+			return
+		case <-time.After(retry):
+		}
+	}
+}
+
+// session runs a single session with the cache until it fails or the client is stopped
+func (c *Client) session() error {
+	con, err := net.DialTimeout("tcp", c.addr, dialTimeout)
+	if err != nil {
+		return fmt.Errorf("Unable to connect: %v", err)
+	}
+	defer con.Close()
+
+	pduCh := make(chan *pdu)
+	errCh := make(chan error, 1)
+	quit := make(chan struct{})
+	defer close(quit)
+
+	go func() {
+		for {
+			p, err := readPDU(con)
+			if err != nil {
+				errCh <- err
+				return
+			}
+
+			select {
+			case pduCh <- p:
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	if err := c.query(con); err != nil {
+		return err
+	}
+
+	c.mu.RLock()
+	refreshTimer := time.NewTimer(c.refreshInterval)
+	c.mu.RUnlock()
+	defer refreshTimer.Stop()
+
+	var pending *update
+	for {
+		select {
+		case <-c.stopCh:
+			return errStopped
+		case err := <-errCh:
+			return fmt.Errorf("Unable to read PDU: %v", err)
+		case <-refreshTimer.C:
+			if pending == nil {
+				if err := c.query(con); err != nil {
+					return err
+				}
+			}
+
+			c.mu.RLock()
+			refreshTimer.Reset(c.refreshInterval)
+			c.mu.RUnlock()
+		case p := <-pduCh:
+			done, err := c.handle(con, p, &pending)
+			if err != nil {
+				return err
+			}
+
+			if done {
+				c.mu.RLock()
+				refreshTimer.Reset(c.refreshInterval)
+				c.mu.RUnlock()
+			}
+		}
+	}
+}
+
+// query asks the cache for the changes since the data held or for all of its data if there is none
+func (c *Client) query(con net.Conn) error {
+	c.mu.Lock()
+	var msg []byte
+	if c.synced {
+		msg = serializeSerialQuery(c.version, c.sessionID, c.serial)
+	} else {
+		msg = serializeResetQuery(c.version)
+	}
+	c.resetting = !c.synced
+	c.mu.Unlock()
+
+	if _, err := con.Write(msg); err != nil {
+		return fmt.Errorf("Unable to send query: %v", err)
+	}
+
+	return nil
+}
+
+// handle processes a PDU received from the cache. It returns true once an update has been completed.
+func (c *Client) handle(con net.Conn, p *pdu, pending **update) (bool, error) {
+	c.mu.RLock()
+	version := c.version
+	c.mu.RUnlock()
+
+	// A cache supporting a lower version only answers with PDUs of that version (RFC8210 7)
+	if p.header.Version != version {
+		if p.header.Version < version {
+			c.downgrade(p.header.Version)
+			return false, errVersionDowngrade
+		}
+
+		c.sendError(con, UnexpectedProtocolVersion, p)
+		return false, fmt.Errorf("Unexpected protocol version: %d", p.header.Version)
+	}
+
+	v, err := p.decode()
+	if err != nil {
+		c.sendError(con, CorruptData, p)
+		return false, fmt.Errorf("Unable to decode PDU: %v", err)
+	}
+
+	switch v := v.(type) {
+	case *serialNotify:
+		if *pending == nil {
+			return false, c.query(con)
+		}
+	case *cacheResponse:
+		return false, c.processCacheResponse(con, v, pending)
+	case *prefixPDU:
+		if *pending == nil {
+			c.sendError(con, CorruptData, p)
+			return false, fmt.Errorf("Prefix PDU outside of a cache response")
+		}
+
+		// IPv6 routes are not supported
+		if v.ipv6 {
+			return false, nil
+		}
+
+		if v.announce {
+			(*pending).announced = append((*pending).announced, v.vrp)
+		} else {
+			(*pending).withdrawn = append((*pending).withdrawn, v.vrp)
+		}
+	case *aspaRecord:
+		if *pending == nil {
+			c.sendError(con, CorruptData, p)
+			return false, fmt.Errorf("ASPA PDU outside of a cache response")
+		}
+
+		if v.announce {
+			(*pending).aspaAnnounced = append((*pending).aspaAnnounced, v.aspa)
+		} else {
+			(*pending).aspaWithdrawn = append((*pending).aspaWithdrawn, v.aspa.CustomerASN)
+		}
+	case *endOfData:
+		if *pending == nil {
+			c.sendError(con, CorruptData, p)
+			return false, fmt.Errorf("End of Data PDU outside of a cache response")
+		}
+
+		c.apply(*pending, v)
+		*pending = nil
+		return true, nil
+	case *cacheReset:
+		c.mu.Lock()
+		c.synced = false
+		c.mu.Unlock()
+		*pending = nil
+		return false, c.query(con)
+	case *routerKey:
+	case *errorReport:
+		if v.code == UnsupportedProtocolVersion && version > Version0 {
+			c.downgrade(version - 1)
+			return false, errVersionDowngrade
+		}
+
+		return false, fmt.Errorf("Cache reported error %d: %s", v.code, v.text)
+	}
+
+	return false, nil
+}
+
+// processCacheResponse starts collecting an update. If the cache answers a serial query with a different
+// session ID it has been restarted and the data held is no longer valid (RFC8210 5.1).
+func (c *Client) processCacheResponse(con net.Conn, r *cacheResponse, pending **update) error {
+	c.mu.Lock()
+	resetting := c.resetting
+	if !resetting && r.sessionID != c.sessionID {
+		c.synced = false
+		c.mu.Unlock()
+		*pending = nil
+		return c.query(con)
+	}
+	c.sessionID = r.sessionID
+	c.mu.Unlock()
+
+	*pending = &update{
+		reset: resetting,
+	}
+
+	return nil
+}
+
+// apply updates the VRP table with a completed update
+func (c *Client) apply(u *update, e *endOfData) {
+	var changed []*tnet.Prefix
+	if u.reset {
+		changed = c.table.Replace(u.announced)
+	} else {
+		changed = c.table.Update(u.announced, u.withdrawn)
+	}
+
+	aspaChanged := false
+	if c.aspas != nil {
+		if u.reset {
+			aspaChanged = c.aspas.Replace(u.aspaAnnounced)
+		} else {
+			aspaChanged = c.aspas.Update(u.aspaAnnounced, u.aspaWithdrawn)
+		}
+	}
+
+	c.mu.Lock()
+	c.serial = e.serial
+	c.synced = true
+	c.resetting = false
+	c.lastUpdate = time.Now()
+	if c.version >= Version1 {
+		c.refreshInterval = intervalOrDefault(e.refreshInterval, DefaultRefreshInterval)
+		c.retryInterval = intervalOrDefault(e.retryInterval, DefaultRetryInterval)
+		c.expireInterval = intervalOrDefault(e.expireInterval, DefaultExpireInterval)
+	}
+	c.mu.Unlock()
+
+	log.WithFields(log.Fields{
+		"cache":  c.addr,
+		"serial": e.serial,
+		"vrps":   c.table.Count(),
+	}).Info("RPKI cache synced")
+
+	if len(changed) > 0 && c.onChange != nil {
+		c.onChange(changed)
+	}
+
+	if aspaChanged && c.onASPAChange != nil {
+		c.onASPAChange()
+	}
+}
+
+// expire flushes the VRP table if no update has been received from the cache within the expire interval
+func (c *Client) expire() {
+	c.mu.Lock()
+	if c.lastUpdate.IsZero() || time.Since(c.lastUpdate) < c.expireInterval {
+		c.mu.Unlock()
+		return
+	}
+	c.synced = false
+	c.lastUpdate = time.Time{}
+	c.mu.Unlock()
+
+	changed := c.table.Flush()
+	if len(changed) > 0 && c.onChange != nil {
+		c.onChange(changed)
+	}
+
+	if c.aspas != nil && c.aspas.Flush() && c.onASPAChange != nil {
+		c.onASPAChange()
+	}
+}
+
+func (c *Client) downgrade(version uint8) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.version = version
+	c.synced = false
+}
+
+func (c *Client) sendError(con net.Conn, code uint16, p *pdu) {
+	c.mu.RLock()
+	version := c.version
+	c.mu.RUnlock()
+
+	erroneous := append(serializeHeaderBytes(p.header), p.body...)
+	con.Write(serializeErrorReport(version, code, erroneous, ""))
+}
+
+func intervalOrDefault(seconds uint32, def time.Duration) time.Duration {
+	if seconds == 0 {
+		return def
+	}
+
+	return time.Duration(seconds) * time.Second
+}
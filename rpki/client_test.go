@@ -0,0 +1,260 @@
+package rpki
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	tnet "github.com/taktv6/tbgp/net"
+)
+
+// cacheStub is a minimal RPKI cache serving a fixed set of VRPs and incremental updates
+type cacheStub struct {
+	l         net.Listener
+	version   uint8
+	v0Only    bool
+	sessionID uint16
+
+	mu      sync.Mutex
+	con     net.Conn
+	serial  uint32
+	vrps    []VRP
+	aspas   []ASPA
+	updates map[uint32]*update // changes leading to a serial
+}
+
+func newCacheStub(t *testing.T, vrps []VRP) *cacheStub {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen: %v", err)
+	}
+
+	c := &cacheStub{
+		l:         l,
+		version:   Version1,
+		sessionID: 42,
+		serial:    1,
+		vrps:      vrps,
+		updates:   make(map[uint32]*update),
+	}
+	go c.serve()
+
+	return c
+}
+
+func (c *cacheStub) addr() string {
+	return c.l.Addr().String()
+}
+
+func (c *cacheStub) close() {
+	c.l.Close()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.con != nil {
+		c.con.Close()
+	}
+}
+
+func (c *cacheStub) serve() {
+	for {
+		con, err := c.l.Accept()
+		if err != nil {
+			return
+		}
+
+		c.mu.Lock()
+		c.con = con
+		c.mu.Unlock()
+
+		for {
+			p, err := readPDU(con)
+			if err != nil {
+				break
+			}
+			c.handle(con, p)
+		}
+	}
+}
+
+func (c *cacheStub) handle(con net.Conn, p *pdu) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.v0Only && p.header.Version != Version0 {
+		con.Write(serializeErrorReport(Version0, UnsupportedProtocolVersion, nil, ""))
+		return
+	}
+	c.version = p.header.Version
+
+	buf := bytes.NewBuffer(nil)
+	serializeHeader(buf, c.version, cacheResponsePDU, c.sessionID, 0)
+
+	switch p.header.Type {
+	case resetQueryPDU:
+		for _, v := range c.vrps {
+			c.serializePrefix(buf, v, true)
+		}
+		if c.version >= Version2 {
+			for _, a := range c.aspas {
+				serializeHeader(buf, c.version, aspaPDU, uint16(announceFlag)<<8, 4+4*len(a.Providers))
+				binary.Write(buf, binary.BigEndian, a.CustomerASN)
+				binary.Write(buf, binary.BigEndian, a.Providers)
+			}
+		}
+	case serialQueryPDU:
+		serial := binary.BigEndian.Uint32(p.body)
+		for s := serial + 1; s <= c.serial; s++ {
+			for _, v := range c.updates[s].withdrawn {
+				c.serializePrefix(buf, v, false)
+			}
+			for _, v := range c.updates[s].announced {
+				c.serializePrefix(buf, v, true)
+			}
+		}
+	}
+
+	if c.version == Version0 {
+		serializeHeader(buf, c.version, endOfDataPDU, c.sessionID, 4)
+		binary.Write(buf, binary.BigEndian, c.serial)
+	} else {
+		serializeHeader(buf, c.version, endOfDataPDU, c.sessionID, 16)
+		binary.Write(buf, binary.BigEndian, []uint32{c.serial, 3600, 1, 7200})
+	}
+
+	con.Write(buf.Bytes())
+}
+
+func (c *cacheStub) serializePrefix(buf *bytes.Buffer, v VRP, announce bool) {
+	flags := uint8(0)
+	if announce {
+		flags = announceFlag
+	}
+
+	serializeHeader(buf, c.version, ipv4PrefixPDU, 0, ipv4PrefixLen-headerLen)
+	buf.Write([]byte{flags, v.Prefix.Pfxlen(), v.MaxLength, 0})
+	binary.Write(buf, binary.BigEndian, []uint32{v.Prefix.Addr(), v.ASN})
+}
+
+// notify adds an update and sends a Serial Notify to the router
+func (c *cacheStub) notify(announced []VRP, withdrawn []VRP) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.serial++
+	c.updates[c.serial] = &update{
+		announced: announced,
+		withdrawn: withdrawn,
+	}
+
+	buf := bytes.NewBuffer(nil)
+	serializeHeader(buf, c.version, serialNotifyPDU, c.sessionID, 4)
+	binary.Write(buf, binary.BigEndian, c.serial)
+	c.con.Write(buf.Bytes())
+}
+
+func TestClient(t *testing.T) {
+	a := VRP{
+		Prefix:    *tnet.NewPfx(167772160, 8), // 10.0.0.0/8
+		MaxLength: 8,
+		ASN:       100,
+	}
+	b := VRP{
+		Prefix:    *tnet.NewPfx(184549376, 8), // 11.0.0.0/8
+		MaxLength: 8,
+		ASN:       200,
+	}
+
+	cache := newCacheStub(t, []VRP{a})
+	defer cache.close()
+
+	changes := make(chan []*tnet.Prefix, 10)
+	table := NewVRPTable()
+	c := NewClient(cache.addr(), table, func(changed []*tnet.Prefix) {
+		changes <- changed
+	})
+	c.Start()
+	defer c.Stop()
+
+	assert.Equal(t, []*tnet.Prefix{tnet.NewPfx(167772160, 8)}, waitForChange(t, changes))
+	assert.True(t, c.Synced())
+	assert.Equal(t, []VRP{a}, table.VRPs())
+
+	cache.notify([]VRP{b}, []VRP{a})
+	assert.Len(t, waitForChange(t, changes), 2)
+	assert.Equal(t, []VRP{b}, table.VRPs())
+
+	sessionID, serial := c.Serial()
+	assert.Equal(t, uint16(42), sessionID)
+	assert.Equal(t, uint32(2), serial)
+}
+
+func TestClientVersionDowngrade(t *testing.T) {
+	a := VRP{
+		Prefix:    *tnet.NewPfx(167772160, 8), // 10.0.0.0/8
+		MaxLength: 8,
+		ASN:       100,
+	}
+
+	cache := newCacheStub(t, []VRP{a})
+	cache.mu.Lock()
+	cache.v0Only = true
+	cache.mu.Unlock()
+	defer cache.close()
+
+	changes := make(chan []*tnet.Prefix, 10)
+	c := NewClient(cache.addr(), NewVRPTable(), func(changed []*tnet.Prefix) {
+		changes <- changed
+	})
+	c.Start()
+	defer c.Stop()
+
+	waitForChange(t, changes)
+	assert.Equal(t, uint8(Version0), c.Version())
+}
+
+func TestClientASPA(t *testing.T) {
+	a := ASPA{
+		CustomerASN: 10,
+		Providers:   []uint32{100},
+	}
+
+	cache := newCacheStub(t, nil)
+	cache.mu.Lock()
+	cache.aspas = []ASPA{a}
+	cache.mu.Unlock()
+	defer cache.close()
+
+	changes := make(chan struct{}, 10)
+	aspas := NewASPATable()
+	c := NewClient(cache.addr(), NewVRPTable(), nil)
+	c.SetASPATable(aspas, func() {
+		changes <- struct{}{}
+	})
+	c.Start()
+	defer c.Stop()
+
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timeout waiting for ASPA table change")
+	}
+
+	assert.Equal(t, uint8(Version2), c.Version())
+	assert.Equal(t, []ASPA{a}, aspas.ASPAs())
+}
+
+func waitForChange(t *testing.T, changes chan []*tnet.Prefix) []*tnet.Prefix {
+	select {
+	case changed := <-changes:
+		return changed
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timeout waiting for VRP table change")
+	}
+
+	return nil
+}
@@ -0,0 +1,331 @@
+package rpki
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/taktv6/tbgp/net"
+)
+
+// RTR protocol versions (RFC6810, RFC8210, draft-ietf-sidrops-8210bis)
+const (
+	Version0 = 0
+	Version1 = 1
+	Version2 = 2
+)
+
+// PDU types (RFC8210 5)
+const (
+	serialNotifyPDU  = 0
+	serialQueryPDU   = 1
+	resetQueryPDU    = 2
+	cacheResponsePDU = 3
+	ipv4PrefixPDU    = 4
+	ipv6PrefixPDU    = 6
+	endOfDataPDU     = 7
+	cacheResetPDU    = 8
+	routerKeyPDU     = 9
+	errorReportPDU   = 10
+	aspaPDU          = 11
+)
+
+// Error codes of Error Report PDUs (RFC8210 12)
+const (
+	CorruptData                 = 0
+	InternalError               = 1
+	NoDataAvailable             = 2
+	InvalidRequest              = 3
+	UnsupportedProtocolVersion  = 4
+	UnsupportedPDUType          = 5
+	WithdrawalOfUnknownRecord   = 6
+	DuplicateAnnouncementRecord = 7
+	UnexpectedProtocolVersion   = 8
+)
+
+const (
+	headerLen     = 8
+	maxPDULen     = 65536
+	announceFlag  = 0x01
+	ipv4PrefixLen = 20
+)
+
+// pduHeader is the header every PDU starts with. The session ID field
+// carries the error code in Error Report PDUs and is zero in others.
+type pduHeader struct {
+	Version   uint8
+	Type      uint8
+	SessionID uint16
+	Length    uint32
+}
+
+// pdu is a decoded PDU. body holds everything after the header.
+type pdu struct {
+	header pduHeader
+	body   []byte
+}
+
+type serialNotify struct {
+	sessionID uint16
+	serial    uint32
+}
+
+type cacheResponse struct {
+	sessionID uint16
+}
+
+type prefixPDU struct {
+	announce bool
+	ipv6     bool
+	vrp      VRP
+}
+
+type endOfData struct {
+	sessionID uint16
+	serial    uint32
+
+	// Timing parameters are only sent by version 1 caches (RFC8210 6)
+	refreshInterval uint32
+	retryInterval   uint32
+	expireInterval  uint32
+}
+
+// aspaRecord announces or withdraws the ASPA record of a customer AS. Withdrawals carry no providers.
+type aspaRecord struct {
+	announce bool
+	aspa     ASPA
+}
+
+type cacheReset struct{}
+
+type routerKey struct{}
+
+type errorReport struct {
+	code uint16
+	text string
+}
+
+// readPDU reads a single PDU from r
+func readPDU(r io.Reader) (*pdu, error) {
+	hdr := make([]byte, headerLen)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, err
+	}
+
+	p := &pdu{}
+	if err := binary.Read(bytes.NewReader(hdr), binary.BigEndian, &p.header); err != nil {
+		return nil, err
+	}
+
+	if p.header.Length < headerLen || p.header.Length > maxPDULen {
+		return nil, fmt.Errorf("Invalid PDU length: %d", p.header.Length)
+	}
+
+	p.body = make([]byte, p.header.Length-headerLen)
+	if _, err := io.ReadFull(r, p.body); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// decode decodes the body of p according to its type
+func (p *pdu) decode() (interface{}, error) {
+	buf := bytes.NewBuffer(p.body)
+
+	switch p.header.Type {
+	case serialNotifyPDU:
+		n := &serialNotify{
+			sessionID: p.header.SessionID,
+		}
+		return n, decodeFields(buf, &n.serial)
+	case cacheResponsePDU:
+		return &cacheResponse{
+			sessionID: p.header.SessionID,
+		}, nil
+	case ipv4PrefixPDU:
+		return decodePrefix(buf, false)
+	case ipv6PrefixPDU:
+		return decodePrefix(buf, true)
+	case endOfDataPDU:
+		return p.decodeEndOfData(buf)
+	case cacheResetPDU:
+		return &cacheReset{}, nil
+	case routerKeyPDU:
+		// Router keys are only needed for BGPsec which we do not support
+		return &routerKey{}, nil
+	case errorReportPDU:
+		return p.decodeErrorReport(buf)
+	case aspaPDU:
+		if p.header.Version < Version2 {
+			break
+		}
+		return p.decodeASPA(buf)
+	}
+
+	return nil, fmt.Errorf("Unsupported PDU type: %d", p.header.Type)
+}
+
+func decodePrefix(buf *bytes.Buffer, ipv6 bool) (*prefixPDU, error) {
+	var flags, pfxlen, maxLen, zero uint8
+	if err := decodeFields(buf, &flags, &pfxlen, &maxLen, &zero); err != nil {
+		return nil, err
+	}
+
+	addrLen := 4
+	if ipv6 {
+		addrLen = 16
+	}
+	addr := make([]byte, addrLen)
+
+	var asn uint32
+	if err := decodeFields(buf, addr, &asn); err != nil {
+		return nil, err
+	}
+
+	p := &prefixPDU{
+		announce: flags&announceFlag != 0,
+		ipv6:     ipv6,
+	}
+	if ipv6 {
+		return p, nil
+	}
+
+	if pfxlen > 32 || maxLen > 32 || maxLen < pfxlen {
+		return nil, fmt.Errorf("Invalid prefix length %d or max length %d", pfxlen, maxLen)
+	}
+
+	// Host bits are cleared as the trie relies on normalized prefixes
+	mask := uint32(0)
+	if pfxlen > 0 {
+		mask = ^uint32(0) << (32 - pfxlen)
+	}
+
+	p.vrp = VRP{
+		Prefix:    *net.NewPfx(binary.BigEndian.Uint32(addr)&mask, pfxlen),
+		MaxLength: maxLen,
+		ASN:       asn,
+	}
+
+	return p, nil
+}
+
+func (p *pdu) decodeEndOfData(buf *bytes.Buffer) (*endOfData, error) {
+	e := &endOfData{
+		sessionID: p.header.SessionID,
+	}
+
+	if p.header.Version == Version0 {
+		return e, decodeFields(buf, &e.serial)
+	}
+
+	return e, decodeFields(buf, &e.serial, &e.refreshInterval, &e.retryInterval, &e.expireInterval)
+}
+
+// decodeASPA decodes an ASPA PDU. The flags are carried in the first byte of the session ID field.
+func (p *pdu) decodeASPA(buf *bytes.Buffer) (*aspaRecord, error) {
+	r := &aspaRecord{
+		announce: uint8(p.header.SessionID>>8)&announceFlag != 0,
+	}
+
+	if err := decodeFields(buf, &r.aspa.CustomerASN); err != nil {
+		return nil, err
+	}
+
+	if buf.Len()%4 != 0 {
+		return nil, fmt.Errorf("Invalid length of provider list: %d", buf.Len())
+	}
+
+	if !r.announce {
+		if buf.Len() != 0 {
+			return nil, fmt.Errorf("ASPA withdrawal must not contain providers")
+		}
+		return r, nil
+	}
+
+	r.aspa.Providers = make([]uint32, buf.Len()/4)
+	if err := decodeFields(buf, r.aspa.Providers); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (p *pdu) decodeErrorReport(buf *bytes.Buffer) (*errorReport, error) {
+	e := &errorReport{
+		code: p.header.SessionID,
+	}
+
+	var pduLen uint32
+	if err := decodeFields(buf, &pduLen); err != nil {
+		return nil, err
+	}
+	if int(pduLen) > buf.Len() {
+		return nil, fmt.Errorf("Invalid encapsulated PDU length: %d", pduLen)
+	}
+	buf.Next(int(pduLen))
+
+	var textLen uint32
+	if err := decodeFields(buf, &textLen); err != nil {
+		return nil, err
+	}
+	if int(textLen) > buf.Len() {
+		return nil, fmt.Errorf("Invalid error text length: %d", textLen)
+	}
+	e.text = string(buf.Next(int(textLen)))
+
+	return e, nil
+}
+
+func decodeFields(buf *bytes.Buffer, fields ...interface{}) error {
+	for _, f := range fields {
+		if err := binary.Read(buf, binary.BigEndian, f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// serializeHeader writes a PDU header for a PDU of type t with a body of bodyLen bytes
+func serializeHeader(buf *bytes.Buffer, version uint8, t uint8, sessionID uint16, bodyLen int) {
+	binary.Write(buf, binary.BigEndian, pduHeader{
+		Version:   version,
+		Type:      t,
+		SessionID: sessionID,
+		Length:    uint32(headerLen + bodyLen),
+	})
+}
+
+func serializeHeaderBytes(hdr pduHeader) []byte {
+	buf := bytes.NewBuffer(nil)
+	binary.Write(buf, binary.BigEndian, hdr)
+	return buf.Bytes()
+}
+
+// serializeResetQuery creates a Reset Query PDU asking the cache for all of its data
+func serializeResetQuery(version uint8) []byte {
+	buf := bytes.NewBuffer(nil)
+	serializeHeader(buf, version, resetQueryPDU, 0, 0)
+	return buf.Bytes()
+}
+
+// serializeSerialQuery creates a Serial Query PDU asking the cache for all changes since serial
+func serializeSerialQuery(version uint8, sessionID uint16, serial uint32) []byte {
+	buf := bytes.NewBuffer(nil)
+	serializeHeader(buf, version, serialQueryPDU, sessionID, 4)
+	binary.Write(buf, binary.BigEndian, serial)
+	return buf.Bytes()
+}
+
+// serializeErrorReport creates an Error Report PDU encapsulating the erroneous PDU
+func serializeErrorReport(version uint8, code uint16, erroneous []byte, text string) []byte {
+	buf := bytes.NewBuffer(nil)
+	serializeHeader(buf, version, errorReportPDU, code, 8+len(erroneous)+len(text))
+	binary.Write(buf, binary.BigEndian, uint32(len(erroneous)))
+	buf.Write(erroneous)
+	binary.Write(buf, binary.BigEndian, uint32(len(text)))
+	buf.WriteString(text)
+	return buf.Bytes()
+}
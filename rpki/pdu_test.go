@@ -0,0 +1,209 @@
+package rpki
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taktv6/tbgp/net"
+)
+
+func TestDecodePDU(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []byte
+		wantFail bool
+		expected interface{}
+	}{
+		{
+			name: "Serial Notify",
+			input: []byte{
+				1, 0, 0, 10, // Version, Type, Session ID
+				0, 0, 0, 12, // Length
+				0, 0, 0, 5, // Serial
+			},
+			expected: &serialNotify{
+				sessionID: 10,
+				serial:    5,
+			},
+		},
+		{
+			name: "IPv4 Prefix",
+			input: []byte{
+				1, 4, 0, 0, // Version, Type, Zero
+				0, 0, 0, 20, // Length
+				1, 8, 16, 0, // Flags, Prefix Length, Max Length, Zero
+				10, 0, 0, 0, // 10.0.0.0
+				0, 0, 0, 100, // ASN
+			},
+			expected: &prefixPDU{
+				announce: true,
+				vrp: VRP{
+					Prefix:    *net.NewPfx(167772160, 8),
+					MaxLength: 16,
+					ASN:       100,
+				},
+			},
+		},
+		{
+			name: "IPv4 Prefix with max length below prefix length",
+			input: []byte{
+				1, 4, 0, 0, // Version, Type, Zero
+				0, 0, 0, 20, // Length
+				1, 16, 8, 0, // Flags, Prefix Length, Max Length, Zero
+				10, 0, 0, 0, // 10.0.0.0
+				0, 0, 0, 100, // ASN
+			},
+			wantFail: true,
+		},
+		{
+			name: "End of Data version 1",
+			input: []byte{
+				1, 7, 0, 10, // Version, Type, Session ID
+				0, 0, 0, 24, // Length
+				0, 0, 0, 5, // Serial
+				0, 0, 0, 60, // Refresh Interval
+				0, 0, 0, 30, // Retry Interval
+				0, 0, 0, 120, // Expire Interval
+			},
+			expected: &endOfData{
+				sessionID:       10,
+				serial:          5,
+				refreshInterval: 60,
+				retryInterval:   30,
+				expireInterval:  120,
+			},
+		},
+		{
+			name: "End of Data version 0",
+			input: []byte{
+				0, 7, 0, 10, // Version, Type, Session ID
+				0, 0, 0, 12, // Length
+				0, 0, 0, 5, // Serial
+			},
+			expected: &endOfData{
+				sessionID: 10,
+				serial:    5,
+			},
+		},
+		{
+			name: "Error Report",
+			input: []byte{
+				1, 10, 0, 2, // Version, Type, Error Code
+				0, 0, 0, 18, // Length
+				0, 0, 0, 0, // Encapsulated PDU Length
+				0, 0, 0, 2, // Error Text Length
+				'n', 'o',
+			},
+			expected: &errorReport{
+				code: NoDataAvailable,
+				text: "no",
+			},
+		},
+		{
+			name: "ASPA announcement",
+			input: []byte{
+				2, 11, 1, 0, // Version, Type, Flags, Zero
+				0, 0, 0, 20, // Length
+				0, 0, 0, 10, // Customer ASN
+				0, 0, 0, 100, // Provider ASN
+				0, 0, 0, 200, // Provider ASN
+			},
+			expected: &aspaRecord{
+				announce: true,
+				aspa: ASPA{
+					CustomerASN: 10,
+					Providers:   []uint32{100, 200},
+				},
+			},
+		},
+		{
+			name: "ASPA withdrawal",
+			input: []byte{
+				2, 11, 0, 0, // Version, Type, Flags, Zero
+				0, 0, 0, 12, // Length
+				0, 0, 0, 10, // Customer ASN
+			},
+			expected: &aspaRecord{
+				aspa: ASPA{
+					CustomerASN: 10,
+				},
+			},
+		},
+		{
+			name: "ASPA with truncated provider",
+			input: []byte{
+				2, 11, 1, 0, // Version, Type, Flags, Zero
+				0, 0, 0, 14, // Length
+				0, 0, 0, 10, // Customer ASN
+				0, 100,
+			},
+			wantFail: true,
+		},
+		{
+			name: "ASPA in version 1",
+			input: []byte{
+				1, 11, 1, 0, // Version, Type, Flags, Zero
+				0, 0, 0, 16, // Length
+				0, 0, 0, 10, // Customer ASN
+				0, 0, 0, 100, // Provider ASN
+			},
+			wantFail: true,
+		},
+		{
+			name: "Unsupported PDU type",
+			input: []byte{
+				1, 99, 0, 0, // Version, Type, Zero
+				0, 0, 0, 8, // Length
+			},
+			wantFail: true,
+		},
+		{
+			name: "Invalid length",
+			input: []byte{
+				1, 2, 0, 0, // Version, Type, Zero
+				0, 0, 0, 4, // Length
+			},
+			wantFail: true,
+		},
+	}
+
+	for _, test := range tests {
+		var res interface{}
+		p, err := readPDU(bytes.NewBuffer(test.input))
+		if err == nil {
+			res, err = p.decode()
+		}
+
+		if test.wantFail && err == nil {
+			t.Errorf("Expected error did not happen for test %q", test.name)
+			continue
+		}
+
+		if !test.wantFail && err != nil {
+			t.Errorf("Unexpected failure for test %q: %v", test.name, err)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		assert.Equal(t, test.expected, res, test.name)
+	}
+}
+
+func TestSerializeSerialQuery(t *testing.T) {
+	assert.Equal(t, []byte{
+		1, 1, 0, 10, // Version, Type, Session ID
+		0, 0, 0, 12, // Length
+		0, 0, 0, 5, // Serial
+	}, serializeSerialQuery(Version1, 10, 5))
+}
+
+func TestSerializeResetQuery(t *testing.T) {
+	assert.Equal(t, []byte{
+		0, 2, 0, 0, // Version, Type, Zero
+		0, 0, 0, 8, // Length
+	}, serializeResetQuery(Version0))
+}
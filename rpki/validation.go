@@ -0,0 +1,224 @@
+package rpki
+
+import (
+	"sync"
+
+	"github.com/taktv6/tbgp/lpm"
+	"github.com/taktv6/tbgp/net"
+)
+
+// ValidationState is the route origin validation state of a route (RFC6811)
+type ValidationState uint8
+
+const (
+	// NotFound means no VRP covers the prefix of the route
+	NotFound ValidationState = iota
+
+	// Valid means a VRP covering the prefix matches the origin AS and prefix length of the route
+	Valid
+
+	// Invalid means VRPs cover the prefix of the route but none of them matches
+	Invalid
+)
+
+// String returns a string representation of s
+func (s ValidationState) String() string {
+	switch s {
+	case Valid:
+		return "valid"
+	case Invalid:
+		return "invalid"
+	}
+
+	return "not found"
+}
+
+// VRP is a validated ROA payload: An AS authorized to originate a prefix up to a maximum length
+type VRP struct {
+	Prefix    net.Prefix
+	MaxLength uint8
+	ASN       uint32
+}
+
+// VRPTable holds VRPs and validates routes against them. VRP prefixes are kept in a trie
+// to find the VRPs covering a route efficiently.
+type VRPTable struct {
+	mu   sync.RWMutex
+	lpm  *lpm.LPM
+	vrps map[net.Prefix]map[VRP]struct{}
+}
+
+// NewVRPTable creates a new empty VRP table
+func NewVRPTable() *VRPTable {
+	return &VRPTable{
+		lpm:  lpm.New(),
+		vrps: make(map[net.Prefix]map[VRP]struct{}),
+	}
+}
+
+// Add adds v to the table. It returns false if v was present already.
+func (t *VRPTable) Add(v VRP) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.add(v)
+}
+
+func (t *VRPTable) add(v VRP) bool {
+	vrps, ok := t.vrps[v.Prefix]
+	if !ok {
+		vrps = make(map[VRP]struct{})
+		t.vrps[v.Prefix] = vrps
+		t.lpm.Insert(net.NewPfx(v.Prefix.Addr(), v.Prefix.Pfxlen()))
+	}
+
+	if _, ok := vrps[v]; ok {
+		return false
+	}
+
+	vrps[v] = struct{}{}
+	return true
+}
+
+// Remove removes v from the table. It returns false if v was not present.
+func (t *VRPTable) Remove(v VRP) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.remove(v)
+}
+
+func (t *VRPTable) remove(v VRP) bool {
+	vrps, ok := t.vrps[v.Prefix]
+	if !ok {
+		return false
+	}
+
+	if _, ok := vrps[v]; !ok {
+		return false
+	}
+
+	delete(vrps, v)
+	if len(vrps) == 0 {
+		delete(t.vrps, v.Prefix)
+		t.lpm.Remove(net.NewPfx(v.Prefix.Addr(), v.Prefix.Pfxlen()))
+	}
+
+	return true
+}
+
+// Update adds announced and removes withdrawn VRPs in a single step so routes are never validated
+// against a partially updated table. It returns the prefixes of all VRPs actually changed.
+func (t *VRPTable) Update(announced []VRP, withdrawn []VRP) []*net.Prefix {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	changed := make(map[net.Prefix]struct{})
+	for _, v := range withdrawn {
+		if t.remove(v) {
+			changed[v.Prefix] = struct{}{}
+		}
+	}
+
+	for _, v := range announced {
+		if t.add(v) {
+			changed[v.Prefix] = struct{}{}
+		}
+	}
+
+	return prefixes(changed)
+}
+
+// Replace replaces the content of the table by vrps. It returns the prefixes of all VRPs changed.
+func (t *VRPTable) Replace(vrps []VRP) []*net.Prefix {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	keep := make(map[VRP]struct{}, len(vrps))
+	for _, v := range vrps {
+		keep[v] = struct{}{}
+	}
+
+	changed := make(map[net.Prefix]struct{})
+	for _, set := range t.vrps {
+		for v := range set {
+			if _, ok := keep[v]; !ok {
+				t.remove(v)
+				changed[v.Prefix] = struct{}{}
+			}
+		}
+	}
+
+	for _, v := range vrps {
+		if t.add(v) {
+			changed[v.Prefix] = struct{}{}
+		}
+	}
+
+	return prefixes(changed)
+}
+
+// Flush removes all VRPs. It returns the prefixes of all VRPs removed.
+func (t *VRPTable) Flush() []*net.Prefix {
+	return t.Replace(nil)
+}
+
+// Count returns the number of VRPs in the table
+func (t *VRPTable) Count() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	n := 0
+	for _, vrps := range t.vrps {
+		n += len(vrps)
+	}
+
+	return n
+}
+
+// VRPs returns all VRPs in the table
+func (t *VRPTable) VRPs() []VRP {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	res := make([]VRP, 0, len(t.vrps))
+	for _, vrps := range t.vrps {
+		for v := range vrps {
+			res = append(res, v)
+		}
+	}
+
+	return res
+}
+
+// Validate determines the validation state of a route for pfx originated by originAS (RFC6811 2).
+// An originAS of 0 represents the origin NONE (e.g. for an AS_PATH ending with an AS_SET)
+// which is never matched by any VRP.
+func (t *VRPTable) Validate(pfx *net.Prefix, originAS uint32) ValidationState {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	covering := t.lpm.LPM(pfx)
+	if len(covering) == 0 {
+		return NotFound
+	}
+
+	for _, c := range covering {
+		for v := range t.vrps[*c] {
+			if originAS != 0 && v.ASN == originAS && pfx.Pfxlen() <= v.MaxLength {
+				return Valid
+			}
+		}
+	}
+
+	return Invalid
+}
+
+func prefixes(set map[net.Prefix]struct{}) []*net.Prefix {
+	res := make([]*net.Prefix, 0, len(set))
+	for pfx := range set {
+		res = append(res, net.NewPfx(pfx.Addr(), pfx.Pfxlen()))
+	}
+
+	return res
+}
@@ -0,0 +1,109 @@
+package rpki
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taktv6/tbgp/net"
+)
+
+func TestValidate(t *testing.T) {
+	table := NewVRPTable()
+	table.Add(VRP{
+		Prefix:    *net.NewPfx(167772160, 8), // 10.0.0.0/8
+		MaxLength: 16,
+		ASN:       100,
+	})
+	table.Add(VRP{
+		Prefix:    *net.NewPfx(167772160, 16), // 10.0.0.0/16
+		MaxLength: 24,
+		ASN:       200,
+	})
+
+	tests := []struct {
+		name     string
+		pfx      *net.Prefix
+		originAS uint32
+		expected ValidationState
+	}{
+		{
+			name:     "Exact match",
+			pfx:      net.NewPfx(167772160, 8), // 10.0.0.0/8
+			originAS: 100,
+			expected: Valid,
+		},
+		{
+			name:     "More specific within max length",
+			pfx:      net.NewPfx(167837696, 16), // 10.1.0.0/16
+			originAS: 100,
+			expected: Valid,
+		},
+		{
+			name:     "More specific exceeding max length",
+			pfx:      net.NewPfx(167837696, 24), // 10.1.0.0/24
+			originAS: 100,
+			expected: Invalid,
+		},
+		{
+			name:     "Matched by more specific VRP",
+			pfx:      net.NewPfx(167772160, 24), // 10.0.0.0/24
+			originAS: 200,
+			expected: Valid,
+		},
+		{
+			name:     "Wrong origin",
+			pfx:      net.NewPfx(167772160, 8), // 10.0.0.0/8
+			originAS: 300,
+			expected: Invalid,
+		},
+		{
+			name:     "Origin NONE",
+			pfx:      net.NewPfx(167772160, 8), // 10.0.0.0/8
+			originAS: 0,
+			expected: Invalid,
+		},
+		{
+			name:     "Not covered",
+			pfx:      net.NewPfx(184549376, 8), // 11.0.0.0/8
+			originAS: 100,
+			expected: NotFound,
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, table.Validate(test.pfx, test.originAS), test.name)
+	}
+}
+
+func TestVRPTableUpdate(t *testing.T) {
+	a := VRP{
+		Prefix:    *net.NewPfx(167772160, 8), // 10.0.0.0/8
+		MaxLength: 8,
+		ASN:       100,
+	}
+	b := VRP{
+		Prefix:    *net.NewPfx(184549376, 8), // 11.0.0.0/8
+		MaxLength: 8,
+		ASN:       100,
+	}
+
+	table := NewVRPTable()
+	changed := table.Update([]VRP{a, b}, nil)
+	assert.Len(t, changed, 2)
+	assert.Equal(t, 2, table.Count())
+
+	// Announcing a VRP again changes nothing
+	changed = table.Update([]VRP{a}, nil)
+	assert.Empty(t, changed)
+
+	changed = table.Update(nil, []VRP{a})
+	assert.Equal(t, []*net.Prefix{net.NewPfx(167772160, 8)}, changed)
+	assert.Equal(t, NotFound, table.Validate(net.NewPfx(167772160, 8), 100))
+
+	changed = table.Replace([]VRP{a})
+	assert.Len(t, changed, 2)
+	assert.Equal(t, []VRP{a}, table.VRPs())
+
+	table.Flush()
+	assert.Equal(t, 0, table.Count())
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"github.com/taktv6/tbgp/config"
+	"github.com/taktv6/tbgp/packet"
+	"github.com/taktv6/tbgp/rib"
+)
+
+// addPathEnabled checks if ADD-PATH is enabled in any direction for the session
+func (fsm *FSM) addPathEnabled() bool {
+	return fsm.addPathReceive || fsm.addPathSend != config.AddPathSendDisabled
+}
+
+// addPathCapability creates the ADD-PATH capability (RFC7911) announcing the directions enabled for IPv4 unicast
+func (fsm *FSM) addPathCapability() packet.Capability {
+	var sendReceive uint8
+	if fsm.addPathReceive {
+		sendReceive |= packet.AddPathReceive
+	}
+	if fsm.addPathSend != config.AddPathSendDisabled {
+		sendReceive |= packet.AddPathSend
+	}
+
+	return packet.Capability{
+		Code: packet.AddPathCapability,
+		Value: packet.AddPathCapabilityValue{
+			AFIs: []packet.AddPathAFI{
+				{
+					AFI:         packet.IPv4AFI,
+					SAFI:        packet.UnicastSAFI,
+					SendReceive: sendReceive,
+				},
+			},
+		},
+	}
+}
+
+// processAddPathCapability determines the directions ADD-PATH has been negotiated in for IPv4 unicast.
+// Paths are received with path identifiers if we want to receive and the neighbor is willing to send
+// and vice versa (RFC7911 4).
+func (fsm *FSM) processAddPathCapability(caps packet.Capabilities) {
+	fsm.addPathRX = false
+	fsm.addPathTX = false
+
+	c, ok := caps.Get(packet.AddPathCapability)
+	if !ok {
+		return
+	}
+
+	sendReceive := c.Value.(packet.AddPathCapabilityValue).SendReceive(packet.IPv4AFI, packet.UnicastSAFI)
+	fsm.addPathRX = fsm.addPathReceive && sendReceive&packet.AddPathSend != 0
+	fsm.addPathTX = fsm.addPathSend != config.AddPathSendDisabled && sendReceive&packet.AddPathReceive != 0
+}
+
+// locRIBClientOptions returns the options selecting the paths of the Loc-RIB advertised to the neighbor
+// Contributors of summary-only aggregates are never advertised.
+func (fsm *FSM) locRIBClientOptions() rib.ClientOptions {
+	opts := rib.ClientOptions{BestOnly: true}
+	if fsm.addPathTX {
+		switch fsm.addPathSend {
+		case config.AddPathSendBestN:
+			opts = rib.ClientOptions{MaxPaths: fsm.addPathSendMax}
+		case config.AddPathSendECMP:
+			opts = rib.ClientOptions{EcmpOnly: true}
+		default:
+			opts = rib.ClientOptions{}
+		}
+	}
+
+	opts.ExcludeSuppressed = true
+	return opts
+}
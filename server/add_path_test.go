@@ -0,0 +1,116 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taktv6/tbgp/config"
+	"github.com/taktv6/tbgp/packet"
+	"github.com/taktv6/tbgp/rib"
+)
+
+func addPathCapabilities(sendReceive uint8) packet.Capabilities {
+	return packet.Capabilities{
+		{
+			Code: packet.AddPathCapability,
+			Value: packet.AddPathCapabilityValue{
+				AFIs: []packet.AddPathAFI{
+					{
+						AFI:         packet.IPv4AFI,
+						SAFI:        packet.UnicastSAFI,
+						SendReceive: sendReceive,
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestProcessAddPathCapability(t *testing.T) {
+	tests := []struct {
+		name       string
+		peer       config.Peer
+		caps       packet.Capabilities
+		expectedRX bool
+		expectedTX bool
+	}{
+		{
+			name: "Capability not announced by neighbor",
+			peer: config.Peer{AddPathReceive: true, AddPathSend: config.AddPathSendAll},
+			caps: packet.Capabilities{},
+		},
+		{
+			name:       "Both directions",
+			peer:       config.Peer{AddPathReceive: true, AddPathSend: config.AddPathSendAll},
+			caps:       addPathCapabilities(packet.AddPathSend | packet.AddPathReceive),
+			expectedRX: true,
+			expectedTX: true,
+		},
+		{
+			name:       "Neighbor sending only",
+			peer:       config.Peer{AddPathReceive: true, AddPathSend: config.AddPathSendAll},
+			caps:       addPathCapabilities(packet.AddPathSend),
+			expectedRX: true,
+		},
+		{
+			name:       "Neighbor receiving only",
+			peer:       config.Peer{AddPathReceive: true, AddPathSend: config.AddPathSendAll},
+			caps:       addPathCapabilities(packet.AddPathReceive),
+			expectedTX: true,
+		},
+		{
+			name: "Disabled locally",
+			peer: config.Peer{},
+			caps: addPathCapabilities(packet.AddPathSend | packet.AddPathReceive),
+		},
+	}
+
+	for _, test := range tests {
+		fsm := newTestFSM(test.peer)
+		fsm.addPathRX = true
+		fsm.addPathTX = true
+
+		fsm.processAddPathCapability(test.caps)
+		assert.Equal(t, test.expectedRX, fsm.addPathRX, test.name)
+		assert.Equal(t, test.expectedTX, fsm.addPathTX, test.name)
+	}
+}
+
+func TestLocRIBClientOptions(t *testing.T) {
+	tests := []struct {
+		name      string
+		peer      config.Peer
+		addPathTX bool
+		expected  rib.ClientOptions
+	}{
+		{
+			name:     "ADD-PATH not negotiated",
+			peer:     config.Peer{AddPathSend: config.AddPathSendAll},
+			expected: rib.ClientOptions{BestOnly: true, ExcludeSuppressed: true},
+		},
+		{
+			name:      "All paths",
+			peer:      config.Peer{AddPathSend: config.AddPathSendAll},
+			addPathTX: true,
+			expected:  rib.ClientOptions{ExcludeSuppressed: true},
+		},
+		{
+			name:      "Best N paths",
+			peer:      config.Peer{AddPathSend: config.AddPathSendBestN, AddPathSendMax: 2},
+			addPathTX: true,
+			expected:  rib.ClientOptions{MaxPaths: 2, ExcludeSuppressed: true},
+		},
+		{
+			name:      "ECMP paths",
+			peer:      config.Peer{AddPathSend: config.AddPathSendECMP},
+			addPathTX: true,
+			expected:  rib.ClientOptions{EcmpOnly: true, ExcludeSuppressed: true},
+		},
+	}
+
+	for _, test := range tests {
+		fsm := newTestFSM(test.peer)
+		fsm.addPathTX = test.addPathTX
+		assert.Equal(t, test.expected, fsm.locRIBClientOptions(), test.name)
+	}
+}
@@ -0,0 +1,267 @@
+package server
+
+import (
+	"sort"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/taktv6/tbgp/config"
+	"github.com/taktv6/tbgp/lpm"
+	"github.com/taktv6/tbgp/net"
+	"github.com/taktv6/tbgp/packet"
+	"github.com/taktv6/tbgp/rib"
+	"github.com/taktv6/tbgp/route"
+	"github.com/taktv6/tflow2/convert"
+)
+
+// aggregator originates the configured aggregate routes (RFC4271 9.2.2.2). An aggregate is active while
+// at least one more specific prefix is in the Loc-RIB. Its attributes are derived from the best paths
+// of all more specific prefixes and recomputed whenever one of them changes.
+type aggregator struct {
+	locRIB     *rib.LocRIB
+	routerID   uint32
+	localAS    uint32
+	aggregates *lpm.LPM // Configured aggregates. Not modified after creation.
+
+	mu           sync.Mutex
+	contributors *lpm.LPM // Best paths of all prefixes in the Loc-RIB
+	dirty        map[net.Prefix]*aggregate
+	updateCh     chan struct{}
+}
+
+// aggregate is a configured aggregate route
+type aggregate struct {
+	config config.Aggregate
+	path   *route.Path // Path originated, nil if inactive. Only accessed by the update worker.
+}
+
+// startAggregates starts originating the configured aggregate routes
+func (b *BGPServer) startAggregates(c *config.Global) {
+	if len(c.Aggregates) == 0 {
+		return
+	}
+
+	localAS := c.LocalAS
+	if b.confed.enabled() {
+		localAS = b.confed.id
+	}
+
+	a := &aggregator{
+		locRIB:       b.locRIB,
+		routerID:     c.RouterID,
+		localAS:      localAS,
+		aggregates:   lpm.New(),
+		contributors: lpm.New(),
+		dirty:        make(map[net.Prefix]*aggregate),
+		updateCh:     make(chan struct{}, 1),
+	}
+
+	for _, cfg := range c.Aggregates {
+		pfx := net.NewPfx(cfg.Prefix.Addr(), cfg.Prefix.Pfxlen())
+		a.aggregates.InsertValue(pfx, &aggregate{
+			config: cfg,
+		})
+	}
+
+	b.aggregator = a
+	go a.updateWorker()
+	b.locRIB.Register(a)
+}
+
+// AddPath records the best path of a prefix of the Loc-RIB
+func (a *aggregator) AddPath(pfx *net.Prefix, p *route.Path) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.contributors.InsertValue(pfx, p)
+	a.markDirty(pfx)
+
+	return nil
+}
+
+// RemovePath removes the best path of a prefix of the Loc-RIB
+func (a *aggregator) RemovePath(pfx *net.Prefix, p *route.Path) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.contributors.Value(pfx); !ok {
+		return false
+	}
+
+	a.contributors.Remove(pfx)
+	a.markDirty(pfx)
+
+	return true
+}
+
+// markDirty schedules recomputing all aggregates pfx is a more specific of.
+// It is called by the Loc-RIB while holding its lock, so the Loc-RIB is updated asynchronously.
+func (a *aggregator) markDirty(pfx *net.Prefix) {
+	marked := false
+	a.aggregates.WalkLessSpecifics(pfx, func(aggrPfx *net.Prefix, value interface{}) bool {
+		if !aggrPfx.Equal(pfx) {
+			a.dirty[*aggrPfx] = value.(*aggregate)
+			marked = true
+		}
+		return true
+	})
+
+	if !marked {
+		return
+	}
+
+	select {
+	case a.updateCh <- struct{}{}:
+	default:
+	}
+}
+
+func (a *aggregator) updateWorker() {
+	for range a.updateCh {
+		a.update()
+	}
+}
+
+// update recomputes the paths of all dirty aggregates and updates the Loc-RIB accordingly
+func (a *aggregator) update() {
+	a.mu.Lock()
+	dirty := a.dirty
+	a.dirty = make(map[net.Prefix]*aggregate)
+
+	paths := make(map[net.Prefix]*route.Path, len(dirty))
+	for pfx, aggr := range dirty {
+		paths[pfx] = a.aggregatePath(aggr.config, a.contributingPaths(&pfx))
+	}
+	a.mu.Unlock()
+
+	for pfx, aggr := range dirty {
+		aggrPfx := net.NewPfx(pfx.Addr(), pfx.Pfxlen())
+		a.apply(aggrPfx, aggr, paths[pfx])
+	}
+}
+
+// contributingPaths returns the best paths of all prefixes more specific than pfx
+func (a *aggregator) contributingPaths(pfx *net.Prefix) []*route.Path {
+	res := make([]*route.Path, 0)
+	a.contributors.WalkMoreSpecifics(pfx, func(c *net.Prefix, value interface{}) bool {
+		if !c.Equal(pfx) {
+			res = append(res, value.(*route.Path))
+		}
+		return true
+	})
+
+	return res
+}
+
+// apply replaces the path originated for an aggregate by p. A nil path deactivates the aggregate.
+func (a *aggregator) apply(pfx *net.Prefix, aggr *aggregate, p *route.Path) {
+	old := aggr.path
+	switch {
+	case old == nil && p == nil:
+		return
+	case old != nil && p != nil && p.Equal(old):
+		return
+	}
+
+	aggr.path = p
+	if p == nil {
+		log.WithFields(log.Fields{
+			"prefix": pfx.String(),
+		}).Info("Aggregate deactivated")
+		a.locRIB.RemovePath(pfx, old)
+		if aggr.config.SummaryOnly {
+			a.locRIB.Unsuppress(pfx)
+		}
+		return
+	}
+
+	if old == nil {
+		log.WithFields(log.Fields{
+			"prefix": pfx.String(),
+		}).Info("Aggregate activated")
+		if aggr.config.SummaryOnly {
+			a.locRIB.Suppress(pfx)
+		}
+	}
+	a.locRIB.AddPath(pfx, p)
+}
+
+// aggregatePath creates the path of an aggregate from the paths of the contributing prefixes.
+// It returns nil if there are none or if they differ in MED as they must not be aggregated then (RFC4271 9.2.2.2).
+func (a *aggregator) aggregatePath(cfg config.Aggregate, contributors []*route.Path) *route.Path {
+	if len(contributors) == 0 {
+		return nil
+	}
+
+	for _, c := range contributors[1:] {
+		if c.MED != contributors[0].MED {
+			log.WithFields(log.Fields{
+				"prefix": cfg.Prefix.String(),
+			}).Warning("Unable to aggregate routes with different MEDs")
+			return nil
+		}
+	}
+
+	p := &route.Path{
+		RouterID:   a.routerID,
+		LocalPref:  defaultLocalPref,
+		ASPath:     packet.ASPath{},
+		Aggregator: a.aggregatorAttribute(),
+	}
+
+	asPaths := make([]packet.ASPath, 0, len(contributors))
+	communities := make(map[uint32]struct{})
+	for _, c := range contributors {
+		// INCOMPLETE takes precedence over EGP which takes precedence over IGP (RFC4271 9.2.2.2)
+		if c.Origin > p.Origin {
+			p.Origin = c.Origin
+		}
+
+		if c.AtomicAggregate {
+			p.AtomicAggregate = true
+		}
+
+		asPaths = append(asPaths, c.ASPath)
+		for _, comm := range c.Communities {
+			communities[comm] = struct{}{}
+		}
+	}
+
+	if len(communities) > 0 {
+		p.Communities = make([]uint32, 0, len(communities))
+		for comm := range communities {
+			p.Communities = append(p.Communities, comm)
+		}
+		sort.Slice(p.Communities, func(i, j int) bool {
+			return p.Communities[i] < p.Communities[j]
+		})
+	}
+
+	if cfg.ASSet {
+		p.ASPath = packet.AggregateASPaths(asPaths)
+		return p
+	}
+
+	// The AS path information of the contributors is lost
+	for _, asPath := range asPaths {
+		if asPath.Length() > 0 {
+			p.AtomicAggregate = true
+			break
+		}
+	}
+
+	return p
+}
+
+// aggregatorAttribute returns the AGGREGATOR attribute identifying us as the speaker that formed the aggregates
+func (a *aggregator) aggregatorAttribute() *packet.Aggretator {
+	aggr := &packet.Aggretator{
+		ASN: uint16(a.localAS),
+	}
+	if a.localAS > uint16max {
+		aggr.ASN = asTrans
+	}
+	copy(aggr.Addr[:], convert.Uint32Byte(a.routerID))
+
+	return aggr
+}
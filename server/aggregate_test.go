@@ -0,0 +1,137 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taktv6/tbgp/config"
+	tnet "github.com/taktv6/tbgp/net"
+	"github.com/taktv6/tbgp/packet"
+	"github.com/taktv6/tbgp/route"
+)
+
+func TestAggregatePath(t *testing.T) {
+	a := &aggregator{
+		routerID: 0x0a000001,
+		localAS:  65000,
+	}
+	aggregator := &packet.Aggretator{
+		ASN:  65000,
+		Addr: [4]byte{10, 0, 0, 1},
+	}
+
+	tests := []struct {
+		name         string
+		asSet        bool
+		contributors []*route.Path
+		expected     *route.Path
+	}{
+		{
+			name:         "No contributors",
+			contributors: []*route.Path{},
+			expected:     nil,
+		},
+		{
+			name: "Communities merged without AS_SET",
+			contributors: []*route.Path{
+				{
+					Origin:      packet.IGP,
+					ASPath:      asPath(65001),
+					Communities: []uint32{3, 1},
+				},
+				{
+					Origin:      packet.INCOMPLETE,
+					ASPath:      asPath(65002),
+					Communities: []uint32{2, 1},
+				},
+			},
+			expected: &route.Path{
+				RouterID:        0x0a000001,
+				LocalPref:       defaultLocalPref,
+				Origin:          packet.INCOMPLETE,
+				ASPath:          packet.ASPath{},
+				AtomicAggregate: true,
+				Aggregator:      aggregator,
+				Communities:     []uint32{1, 2, 3},
+			},
+		},
+		{
+			name:  "Communities merged with AS_SET",
+			asSet: true,
+			contributors: []*route.Path{
+				{
+					Origin:      packet.EGP,
+					ASPath:      asPath(65001),
+					Communities: []uint32{1},
+				},
+				{
+					ASPath:      asPath(65001),
+					Communities: []uint32{2},
+				},
+			},
+			expected: &route.Path{
+				RouterID:    0x0a000001,
+				LocalPref:   defaultLocalPref,
+				Origin:      packet.EGP,
+				ASPath:      packet.AggregateASPaths([]packet.ASPath{asPath(65001), asPath(65001)}),
+				Aggregator:  aggregator,
+				Communities: []uint32{1, 2},
+			},
+		},
+		{
+			name: "Local routes without ATOMIC_AGGREGATE",
+			contributors: []*route.Path{
+				{
+					ASPath: packet.ASPath{},
+				},
+			},
+			expected: &route.Path{
+				RouterID:   0x0a000001,
+				LocalPref:  defaultLocalPref,
+				ASPath:     packet.ASPath{},
+				Aggregator: aggregator,
+			},
+		},
+		{
+			name: "Same MEDs",
+			contributors: []*route.Path{
+				{
+					ASPath: packet.ASPath{},
+					MED:    10,
+				},
+				{
+					ASPath: packet.ASPath{},
+					MED:    10,
+				},
+			},
+			expected: &route.Path{
+				RouterID:   0x0a000001,
+				LocalPref:  defaultLocalPref,
+				ASPath:     packet.ASPath{},
+				Aggregator: aggregator,
+			},
+		},
+		{
+			name: "Different MEDs",
+			contributors: []*route.Path{
+				{
+					ASPath: packet.ASPath{},
+					MED:    10,
+				},
+				{
+					ASPath: packet.ASPath{},
+					MED:    20,
+				},
+			},
+			expected: nil,
+		},
+	}
+
+	for _, test := range tests {
+		cfg := config.Aggregate{
+			Prefix: tnet.NewPfx(0x0a000000, 8),
+			ASSet:  test.asSet,
+		}
+		assert.Equal(t, test.expected, a.aggregatePath(cfg, test.contributors), test.name)
+	}
+}
@@ -0,0 +1,451 @@
+package server
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/taktv6/tbgp/bmp"
+	"github.com/taktv6/tbgp/config"
+	tnet "github.com/taktv6/tbgp/net"
+	"github.com/taktv6/tbgp/packet"
+	"github.com/taktv6/tbgp/rib"
+	"github.com/taktv6/tbgp/route"
+	"github.com/taktv6/tflow2/convert"
+)
+
+// FSM events reported in Peer Down notifications of sessions closed without NOTIFICATION (RFC4271 8.1)
+const (
+	unknownEvent       = 0
+	tcpConnectionFails = 18
+)
+
+// bmpExporter reports the sessions and routes of all peers to the configured BMP stations (RFC7854)
+type bmpExporter struct {
+	routerID uint32
+	localAS  uint32
+	locRIB   *rib.LocRIB
+	stopCh   chan struct{}
+
+	mu       sync.Mutex
+	stations []*bmpStation
+	peers    map[string]*bmpPeer
+}
+
+// bmpStation is a station and the monitors registered with the routing tables on its behalf
+type bmpStation struct {
+	config.BMPStation
+	client   *bmp.Client
+	active   bool
+	monitors map[string][]*bmpMonitor
+	locRIB   *bmpMonitor
+}
+
+// bmpPeer describes an established session
+type bmpPeer struct {
+	up       *bmp.PeerUpMsg
+	adjRibIn *rib.AdjRIBIn
+	addPath  bool
+	internal bool
+}
+
+// startBMP starts the sessions with all BMP stations configured
+func (b *BGPServer) startBMP(c *config.Global) {
+	if len(c.BMPStations) == 0 {
+		return
+	}
+
+	localAS := c.LocalAS
+	if b.confed.enabled() {
+		localAS = b.confed.id
+	}
+
+	b.bmp = &bmpExporter{
+		routerID: c.RouterID,
+		localAS:  localAS,
+		locRIB:   b.locRIB,
+		stopCh:   make(chan struct{}),
+		peers:    make(map[string]*bmpPeer),
+	}
+
+	for _, sc := range c.BMPStations {
+		b.bmp.addStation(sc)
+	}
+}
+
+// StopBMP closes the sessions with all BMP stations
+func (b *BGPServer) StopBMP() {
+	if b.bmp == nil {
+		return
+	}
+
+	close(b.bmp.stopCh)
+	for _, s := range b.bmp.stations {
+		s.client.Stop()
+	}
+}
+
+func (e *bmpExporter) addStation(c config.BMPStation) {
+	s := &bmpStation{
+		BMPStation: c,
+		monitors:   make(map[string][]*bmpMonitor),
+	}
+	s.client = bmp.NewClient(c.Address, e.information(), func() {
+		e.connected(s)
+	}, func() {
+		e.disconnected(s)
+	})
+	e.stations = append(e.stations, s)
+
+	s.client.Start()
+	if c.StatisticsInterval > 0 {
+		go e.reportStatistics(s, time.Duration(c.StatisticsInterval)*time.Second)
+	}
+}
+
+// information returns the information TLVs of Initiation messages. sysDescr and sysName are mandatory (RFC7854 4.3).
+func (e *bmpExporter) information() []bmp.InformationTLV {
+	name, err := os.Hostname()
+	if err != nil {
+		name = net.IP(convert.Uint32Byte(e.routerID)).String()
+	}
+
+	return []bmp.InformationTLV{
+		bmp.NewStringTLV(bmp.SysDescrTLV, "tbgp"),
+		bmp.NewStringTLV(bmp.SysNameTLV, name),
+	}
+}
+
+// prePolicy checks if any station monitors the routes as received from peers. e may be nil.
+func (e *bmpExporter) prePolicy() bool {
+	if e == nil {
+		return false
+	}
+
+	for _, s := range e.stations {
+		if s.PrePolicy {
+			return true
+		}
+	}
+
+	return false
+}
+
+// connected sends the current state to a station a session has been established with
+func (e *bmpExporter) connected(s *bmpStation) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	s.active = true
+	if s.LocRIB {
+		e.monitorLocRIB(s)
+	}
+
+	for addr, p := range e.peers {
+		e.monitor(s, addr, p)
+	}
+}
+
+// disconnected stops passing on changes to a station the session has ended with
+func (e *bmpExporter) disconnected(s *bmpStation) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	s.active = false
+	if s.locRIB != nil {
+		e.locRIB.Unregister(s.locRIB)
+		s.locRIB = nil
+	}
+
+	for addr, p := range e.peers {
+		e.unmonitor(s, addr, p)
+	}
+}
+
+// monitorLocRIB sends the Peer Up notification of the Loc-RIB instance followed by the best routes (RFC9069)
+func (e *bmpExporter) monitorLocRIB(s *bmpStation) {
+	// The OPEN messages of the Loc-RIB instance are fabricated from the local parameters (RFC9069 5.3)
+	open := packet.SerializeOpenMsg(&packet.BGPOpen{
+		Version:       BGPVersion,
+		AS:            uint16(e.localAS),
+		BGPIdentifier: e.routerID,
+	})
+
+	peer := bmp.PeerHeader{
+		PeerType:  bmp.LocRIBInstancePeer,
+		AS:        e.localAS,
+		BGPID:     e.routerID,
+		Timestamp: time.Now(),
+	}
+
+	s.client.Send(bmp.SerializePeerUpMsg(&bmp.PeerUpMsg{
+		Peer:         peer,
+		SentOpen:     open,
+		ReceivedOpen: open,
+		Information: []bmp.InformationTLV{
+			bmp.NewStringTLV(bmp.VRFTableNameTLV, "global"),
+		},
+	}))
+
+	s.locRIB = &bmpMonitor{
+		client:   s.client,
+		peer:     peer,
+		internal: true,
+	}
+	e.locRIB.Register(s.locRIB)
+	s.locRIB.endOfRIB()
+}
+
+// monitor sends the Peer Up notification of an established session followed by its routes and
+// passes on all changes from then on
+func (e *bmpExporter) monitor(s *bmpStation, addr string, p *bmpPeer) {
+	s.client.Send(bmp.SerializePeerUpMsg(p.up))
+
+	if s.PrePolicy {
+		m := p.monitor(s.client, bmp.LegacyASPathFlag)
+		if err := p.adjRibIn.RegisterPrePolicy(m); err != nil {
+			log.WithFields(log.Fields{
+				"station": s.Address,
+				"peer":    addr,
+			}).Errorf("Unable to monitor pre-policy routes: %v", err)
+		} else {
+			s.monitors[addr] = append(s.monitors[addr], m)
+			m.endOfRIB()
+		}
+	}
+
+	if s.PostPolicy {
+		m := p.monitor(s.client, bmp.PostPolicyFlag|bmp.LegacyASPathFlag)
+		p.adjRibIn.Register(m)
+		s.monitors[addr] = append(s.monitors[addr], m)
+		m.endOfRIB()
+	}
+}
+
+func (e *bmpExporter) unmonitor(s *bmpStation, addr string, p *bmpPeer) {
+	for _, m := range s.monitors[addr] {
+		p.adjRibIn.UnregisterPrePolicy(m)
+		p.adjRibIn.Unregister(m)
+	}
+	delete(s.monitors, addr)
+}
+
+// peerUp reports a session having been established. e may be nil.
+func (e *bmpExporter) peerUp(fsm *FSM) {
+	if e == nil {
+		return
+	}
+
+	p := &bmpPeer{
+		up:       fsm.peerUpMsg(fsm.con.LocalAddr().(*net.TCPAddr), fsm.con.RemoteAddr().(*net.TCPAddr)),
+		adjRibIn: fsm.adjRibIn,
+		addPath:  fsm.addPathRX,
+		internal: !fsm.external(),
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	addr := fsm.remote.String()
+	e.peers[addr] = p
+	for _, s := range e.stations {
+		if s.active {
+			e.monitor(s, addr, p)
+		}
+	}
+}
+
+// peerUpMsg creates the Peer Up notification of the established session between local and remote
+func (fsm *FSM) peerUpMsg(local *net.TCPAddr, remote *net.TCPAddr) *bmp.PeerUpMsg {
+	return &bmp.PeerUpMsg{
+		Peer: bmp.PeerHeader{
+			PeerType:  bmp.GlobalInstancePeer,
+			Address:   fsm.remote,
+			AS:        uint32(fsm.remoteASN),
+			BGPID:     fsm.neighborID,
+			Timestamp: time.Now(),
+		},
+		LocalAddress: local.IP,
+		LocalPort:    uint16(local.Port),
+		RemotePort:   uint16(remote.Port),
+		SentOpen:     bgpMessage(fsm.sentOpen),
+		ReceivedOpen: bgpMessage(fsm.receivedOpen),
+	}
+}
+
+// peerDown reports a session having gone down giving the reason recorded by the FSM. e may be nil.
+func (e *bmpExporter) peerDown(fsm *FSM) {
+	if e == nil {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	addr := fsm.remote.String()
+	p, ok := e.peers[addr]
+	if !ok {
+		return
+	}
+	delete(e.peers, addr)
+
+	peer := p.up.Peer
+	peer.Timestamp = time.Now()
+	msg := bmp.SerializePeerDownMsg(&bmp.PeerDownMsg{
+		Peer:   peer,
+		Reason: fsm.downReason,
+		Data:   fsm.downData,
+	})
+
+	for _, s := range e.stations {
+		if s.active {
+			e.unmonitor(s, addr, p)
+			s.client.Send(msg)
+		}
+	}
+}
+
+// reportStatistics sends the number of routes of all peers to a station every interval
+func (e *bmpExporter) reportStatistics(s *bmpStation, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-e.stopCh:
+			return
+		case <-t.C:
+		}
+
+		e.mu.Lock()
+		if s.active {
+			for _, p := range e.peers {
+				s.client.Send(bmp.SerializeStatisticsReportMsg(p.statistics()))
+			}
+		}
+		e.mu.Unlock()
+	}
+}
+
+// statistics returns the number of routes received (Adj-RIB-In) and accepted by the import filter
+// (eligible for the Loc-RIB)
+func (p *bmpPeer) statistics() *bmp.StatisticsReportMsg {
+	received := uint64(p.adjRibIn.ReceivedCount())
+	accepted := uint64(p.adjRibIn.Count())
+
+	peer := p.up.Peer
+	peer.Timestamp = time.Now()
+	return &bmp.StatisticsReportMsg{
+		Peer: peer,
+		Stats: []bmp.Stat{
+			{Type: bmp.AdjRIBInRoutesStat, Value: received},
+			{Type: bmp.LocRIBRoutesStat, Value: accepted},
+			{Type: bmp.AdjRIBInRoutesPerAFIStat, Value: received, AFI: packet.IPv4AFI, SAFI: packet.UnicastSAFI},
+			{Type: bmp.LocRIBRoutesPerAFIStat, Value: accepted, AFI: packet.IPv4AFI, SAFI: packet.UnicastSAFI},
+		},
+	}
+}
+
+func (p *bmpPeer) monitor(client *bmp.Client, flags uint8) *bmpMonitor {
+	peer := p.up.Peer
+	peer.Flags = flags
+
+	return &bmpMonitor{
+		client:   client,
+		peer:     peer,
+		addPath:  p.addPath,
+		internal: p.internal,
+	}
+}
+
+// bmpMonitor is a client of a routing table sending all changes as Route Monitoring messages to a station.
+// AS_PATHs are sent with 2 byte ASNs as used with all peers, which is flagged for all but the Loc-RIB instance.
+type bmpMonitor struct {
+	client   *bmp.Client
+	peer     bmp.PeerHeader
+	addPath  bool
+	internal bool
+}
+
+// AddPath sends p for pfx to the station
+func (m *bmpMonitor) AddPath(pfx *tnet.Prefix, p *route.Path) error {
+	return m.send(&packet.BGPUpdate{
+		PathAttributes: pathAttributes(p, m.internal),
+		NLRI:           pfxToNLRI(pfx, p.PathIdentifier),
+	})
+}
+
+// RemovePath sends the withdrawal of p for pfx to the station
+func (m *bmpMonitor) RemovePath(pfx *tnet.Prefix, p *route.Path) bool {
+	return m.send(&packet.BGPUpdate{
+		WithdrawnRoutes: pfxToNLRI(pfx, p.PathIdentifier),
+	}) == nil
+}
+
+// endOfRIB marks the end of the routes sent initially (RFC7854 5)
+func (m *bmpMonitor) endOfRIB() {
+	m.sendUpdate(packet.SerializeEndOfRIBMsg(packet.IPv4AFI, packet.UnicastSAFI))
+}
+
+func (m *bmpMonitor) send(update *packet.BGPUpdate) error {
+	msg, err := packet.SerializeUpdateMsgWithOptions(update, &packet.EncodeOptions{
+		AddPath: m.addPath,
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"station": m.client.Addr(),
+		}).Errorf("Unable to serialize UPDATE message: %v", err)
+		return err
+	}
+
+	m.sendUpdate(msg)
+	return nil
+}
+
+func (m *bmpMonitor) sendUpdate(update []byte) {
+	peer := m.peer
+	peer.Timestamp = time.Now()
+	m.client.Send(bmp.SerializeRouteMonitoringMsg(&bmp.RouteMonitoringMsg{
+		Peer:   peer,
+		Update: update,
+	}))
+}
+
+// notify sends a NOTIFICATION message on the established session and records it as the reason the session went down
+func (fsm *FSM) notify(errorCode uint8, errorSubCode uint8) {
+	fsm.sendNotification(fsm.con, errorCode, errorSubCode)
+	fsm.sessionDown(bmp.LocalNotification, packet.SerializeNotificationMsg(&packet.BGPNotification{
+		ErrorCode:    errorCode,
+		ErrorSubcode: errorSubCode,
+	}))
+}
+
+// sessionDown records the reason the established session went down for Peer Down notifications
+func (fsm *FSM) sessionDown(reason uint8, data []byte) {
+	fsm.downReason = reason
+	fsm.downData = data
+}
+
+// bgpMessage returns msg cut to the length given in its header. Messages with an invalid header are returned as is.
+func bgpMessage(msg []byte) []byte {
+	if len(msg) < packet.HeaderLen {
+		return msg
+	}
+
+	l := int(binary.BigEndian.Uint16(msg[16:18]))
+	if l < packet.HeaderLen || l > len(msg) {
+		return msg
+	}
+
+	return msg[:l]
+}
+
+// fsmEvent returns the data of Peer Down notifications of sessions closed due to an FSM event without NOTIFICATION
+func fsmEvent(event uint16) []byte {
+	data := make([]byte, 2)
+	binary.BigEndian.PutUint16(data, event)
+	return data
+}
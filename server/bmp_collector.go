@@ -0,0 +1,363 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/taktv6/tbgp/bmp"
+	"github.com/taktv6/tbgp/config"
+	"github.com/taktv6/tbgp/packet"
+	"github.com/taktv6/tbgp/rib"
+	"github.com/taktv6/tbgp/route"
+)
+
+// bmpCollector keeps the routes monitored routers report via BMP (RFC7854) in per-peer RIBs
+type bmpCollector struct {
+	station *bmp.Station
+
+	mu      sync.RWMutex
+	routers map[string]*monitoredRouter
+}
+
+// monitoredRouter is a router having established a BMP session with us
+type monitoredRouter struct {
+	information []bmp.InformationTLV
+	peers       map[string]*monitoredPeer
+	locRIB      *monitoredPeer
+}
+
+// monitoredPeer is a peer of a monitored router. The routes of Loc-RIB instance peers (RFC9069) are kept in postPolicy.
+type monitoredPeer struct {
+	addPath      bool
+	localAS      uint32
+	legacyASPath bool
+	prePolicy    *rib.AdjRIBIn
+	postPolicy   *rib.AdjRIBIn
+}
+
+// startBMPStation accepts BMP sessions of monitored routers if configured
+func (b *BGPServer) startBMPStation(c *config.Global) error {
+	if c.BMPListen == "" {
+		return nil
+	}
+
+	collector := &bmpCollector{
+		routers: make(map[string]*monitoredRouter),
+	}
+
+	s, err := bmp.NewStation(c.BMPListen, collector)
+	if err != nil {
+		return err
+	}
+	collector.station = s
+	b.collector = collector
+
+	return nil
+}
+
+// StopBMPStation closes the sessions of all monitored routers
+func (b *BGPServer) StopBMPStation() {
+	if b.collector == nil {
+		return
+	}
+
+	b.collector.station.Close()
+}
+
+// HandleMessage processes a message received from the monitored router with address router
+func (c *bmpCollector) HandleMessage(router net.IP, msg interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if m, ok := msg.(*bmp.InitiationMsg); ok {
+		c.routers[router.String()] = &monitoredRouter{
+			information: m.Information,
+			peers:       make(map[string]*monitoredPeer),
+		}
+		return
+	}
+
+	r, ok := c.routers[router.String()]
+	if !ok {
+		return
+	}
+
+	switch m := msg.(type) {
+	case *bmp.PeerUpMsg:
+		r.peerUp(router, m)
+	case *bmp.PeerDownMsg:
+		if m.Peer.PeerType == bmp.LocRIBInstancePeer {
+			r.locRIB = nil
+			return
+		}
+		delete(r.peers, m.Peer.Address.String())
+	case *bmp.RouteMonitoringMsg:
+		r.routeMonitoring(router, m)
+	}
+}
+
+// SessionClosed drops all routes of the monitored router with address router
+func (c *bmpCollector) SessionClosed(router net.IP) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.routers, router.String())
+}
+
+// peerUp creates the RIBs of a peer whose session came up
+func (r *monitoredRouter) peerUp(router net.IP, m *bmp.PeerUpMsg) {
+	// Only IPv4 sessions of the global routing table are supported
+	if m.Peer.PeerType == bmp.RDInstancePeer || (m.Peer.PeerType != bmp.LocRIBInstancePeer && m.Peer.Address.To4() == nil) {
+		return
+	}
+
+	sent, err := decodeOpen(m.SentOpen)
+	if err != nil {
+		logMonitoredPeer(router, m.Peer).Warningf("Unable to decode sent OPEN message: %v", err)
+		return
+	}
+
+	received, err := decodeOpen(m.ReceivedOpen)
+	if err != nil {
+		logMonitoredPeer(router, m.Peer).Warningf("Unable to decode received OPEN message: %v", err)
+		return
+	}
+
+	p := &monitoredPeer{
+		addPath:    addPathNegotiated(sent, received),
+		localAS:    uint32(sent.AS),
+		postPolicy: rib.NewAdjRIBIn(nil, false),
+	}
+
+	if m.Peer.PeerType == bmp.LocRIBInstancePeer {
+		// Loc-RIB instance peers do not use the legacy AS_PATH flag. The AS_PATH format follows the capabilities announced.
+		p.legacyASPath = !sent.Capabilities().Has(packet.FourOctetASCapability)
+		r.locRIB = p
+		return
+	}
+
+	p.prePolicy = rib.NewAdjRIBIn(nil, false)
+	r.peers[m.Peer.Address.String()] = p
+}
+
+// routeMonitoring updates the RIBs of a peer with the UPDATE message carried by m
+func (r *monitoredRouter) routeMonitoring(router net.IP, m *bmp.RouteMonitoringMsg) {
+	p := r.locRIB
+	if m.Peer.PeerType != bmp.LocRIBInstancePeer {
+		p = r.peers[m.Peer.Address.String()]
+	}
+	if p == nil {
+		return
+	}
+
+	// Adj-RIB-Out monitoring (RFC8671) is not supported
+	if m.Peer.PeerType != bmp.LocRIBInstancePeer && m.Peer.Flags&bmp.AdjRIBOutFlag != 0 {
+		return
+	}
+
+	legacyASPath := p.legacyASPath
+	if m.Peer.PeerType != bmp.LocRIBInstancePeer {
+		legacyASPath = m.Peer.Flags&bmp.LegacyASPathFlag != 0
+	}
+
+	msg, err := packet.DecodeWithOptions(bytes.NewBuffer(m.Update), &packet.DecodeOptions{
+		AddPath: p.addPath,
+		ASN4:    !legacyASPath,
+	})
+	if err != nil {
+		logMonitoredPeer(router, m.Peer).Warningf("Unable to decode UPDATE message: %v", err)
+		return
+	}
+
+	u, ok := msg.Body.(*packet.BGPUpdate)
+	if !ok {
+		return
+	}
+
+	// We only speak 2 byte ASNs so others are replaced by AS_TRANS
+	replaceASN4(u)
+
+	a := p.prePolicy
+	if m.Peer.PeerType == bmp.LocRIBInstancePeer || m.Peer.Flags&bmp.PostPolicyFlag != 0 {
+		a = p.postPolicy
+	}
+
+	source := ipToUint32(m.Peer.Address)
+	for n := u.WithdrawnRoutes; n != nil; n = n.Next {
+		a.RemovePath(nlriToPfx(n), &route.Path{
+			Source:         source,
+			PathIdentifier: n.PathIdentifier,
+		})
+	}
+
+	if u.NLRI == nil {
+		return
+	}
+
+	path := &route.Path{
+		Source:    source,
+		RouterID:  m.Peer.BGPID,
+		EBGP:      m.Peer.AS != p.localAS,
+		LocalPref: defaultLocalPref,
+	}
+	decodePathAttributes(path, u.PathAttributes, true)
+
+	for n := u.NLRI; n != nil; n = n.Next {
+		q := path.Copy()
+		q.PathIdentifier = n.PathIdentifier
+		a.AddPath(nlriToPfx(n), q)
+	}
+}
+
+// decodeOpen decodes the OPEN message msg
+func decodeOpen(msg []byte) (*packet.BGPOpen, error) {
+	m, err := packet.Decode(bytes.NewBuffer(msg))
+	if err != nil {
+		return nil, err
+	}
+
+	open, ok := m.Body.(*packet.BGPOpen)
+	if !ok {
+		return nil, fmt.Errorf("Not an OPEN message")
+	}
+
+	return open, nil
+}
+
+// addPathNegotiated checks if the router receives multiple paths per IPv4 unicast prefix from the peer (RFC7911)
+func addPathNegotiated(sent *packet.BGPOpen, received *packet.BGPOpen) bool {
+	return addPathMode(sent)&packet.AddPathReceive != 0 && addPathMode(received)&packet.AddPathSend != 0
+}
+
+func addPathMode(open *packet.BGPOpen) uint8 {
+	c, ok := open.Capabilities().Get(packet.AddPathCapability)
+	if !ok {
+		return 0
+	}
+
+	return c.Value.(packet.AddPathCapabilityValue).SendReceive(packet.IPv4AFI, packet.UnicastSAFI)
+}
+
+func logMonitoredPeer(router net.IP, peer bmp.PeerHeader) *log.Entry {
+	return log.WithFields(log.Fields{
+		"router": router.String(),
+		"peer":   peer.Address.String(),
+	})
+}
+
+// MonitoredRouters returns the addresses of all routers having established a BMP session with us
+func (b *BGPServer) MonitoredRouters() []net.IP {
+	if b.collector == nil {
+		return nil
+	}
+
+	b.collector.mu.RLock()
+	defer b.collector.mu.RUnlock()
+
+	res := make([]net.IP, 0, len(b.collector.routers))
+	for addr := range b.collector.routers {
+		res = append(res, net.ParseIP(addr))
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return bytes.Compare(res[i], res[j]) < 0
+	})
+
+	return res
+}
+
+// MonitoredRouter returns the RIBs of the monitored router with address addr.
+// They are queried the same way as the RIBs of our own peers.
+func (b *BGPServer) MonitoredRouter(addr net.IP) (RIBs, error) {
+	if b.collector == nil {
+		return nil, fmt.Errorf("BMP station mode not enabled")
+	}
+
+	b.collector.mu.RLock()
+	defer b.collector.mu.RUnlock()
+
+	if _, ok := b.collector.routers[addr.String()]; !ok {
+		return nil, fmt.Errorf("Unknown monitored router: %s", addr.String())
+	}
+
+	return &monitoredRIBs{
+		collector: b.collector,
+		router:    addr,
+	}, nil
+}
+
+// monitoredRIBs gives access to the RIBs of a monitored router. Queries fail once the router closed its BMP session.
+type monitoredRIBs struct {
+	collector *bmpCollector
+	router    net.IP
+}
+
+// Peers returns the addresses of the established peers of the monitored router
+func (m *monitoredRIBs) Peers() []net.IP {
+	r, unlock, err := m.get()
+	if err != nil {
+		return nil
+	}
+	defer unlock()
+
+	res := make([]net.IP, 0, len(r.peers))
+	for addr := range r.peers {
+		res = append(res, net.ParseIP(addr))
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return bytes.Compare(res[i], res[j]) < 0
+	})
+
+	return res
+}
+
+// AdjRIBIn returns the routes the peer with address addr sent to the monitored router. If prePolicy
+// is set the routes as received are returned, otherwise the routes accepted by the import filter.
+func (m *monitoredRIBs) AdjRIBIn(addr net.IP, prePolicy bool) ([]*route.Route, error) {
+	r, unlock, err := m.get()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	p, ok := r.peers[addr.String()]
+	if !ok {
+		return nil, fmt.Errorf("Unknown peer: %s", addr.String())
+	}
+
+	if prePolicy {
+		return p.prePolicy.Routes(), nil
+	}
+
+	return p.postPolicy.Routes(), nil
+}
+
+// LocRIB returns the routes of the Loc-RIB of the monitored router (RFC9069)
+func (m *monitoredRIBs) LocRIB() ([]*route.Route, error) {
+	r, unlock, err := m.get()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if r.locRIB == nil {
+		return nil, fmt.Errorf("Loc-RIB of %s not monitored", m.router.String())
+	}
+
+	return r.locRIB.postPolicy.Routes(), nil
+}
+
+// get returns the monitored router locked for reading
+func (m *monitoredRIBs) get() (*monitoredRouter, func(), error) {
+	m.collector.mu.RLock()
+	r, ok := m.collector.routers[m.router.String()]
+	if !ok {
+		m.collector.mu.RUnlock()
+		return nil, nil, fmt.Errorf("Unknown monitored router: %s", m.router.String())
+	}
+
+	return r, m.collector.mu.RUnlock, nil
+}
@@ -0,0 +1,260 @@
+package server
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taktv6/tbgp/bmp"
+	"github.com/taktv6/tbgp/packet"
+	"github.com/taktv6/tbgp/route"
+)
+
+var (
+	monitoredRouterAddr = net.ParseIP("192.0.2.1")
+	monitoredPeerAddr   = net.ParseIP("10.0.0.1")
+)
+
+// serializedOpen serializes an OPEN message of AS asn. asn4 adds the 4 byte ASN capability.
+func serializedOpen(asn uint16, asn4 bool, caps packet.Capabilities) []byte {
+	if asn4 {
+		caps = append(caps, packet.Capability{
+			Code:  packet.FourOctetASCapability,
+			Value: []byte{0, 0, 0, byte(asn)},
+		})
+	}
+
+	return packet.SerializeOpenMsg(&packet.BGPOpen{
+		Version:       BGPVersion,
+		AS:            asn,
+		HoldTime:      90,
+		BGPIdentifier: 0x0a000000 + uint32(asn),
+		OptParams: []packet.OptParam{
+			{
+				Type:  packet.CapabilitiesParam,
+				Value: caps,
+			},
+		},
+	})
+}
+
+// serializedUpdate serializes an UPDATE message advertising 10.0.0.0/8 with AS_PATH path
+func serializedUpdate(t *testing.T, path packet.ASPath, opt *packet.EncodeOptions) []byte {
+	msg, err := packet.SerializeUpdateMsgWithOptions(&packet.BGPUpdate{
+		PathAttributes: &packet.PathAttribute{
+			TypeCode:   packet.ASPathAttr,
+			Transitive: true,
+			Value:      path,
+		},
+		NLRI: &packet.NLRI{
+			PathIdentifier: 1,
+			IP:             [4]byte{10, 0, 0, 0},
+			Pfxlen:         8,
+		},
+	}, opt)
+	if err != nil {
+		t.Fatalf("Unable to serialize UPDATE message: %v", err)
+	}
+
+	return msg
+}
+
+// newTestCollector returns a server collecting the routes of a monitored router having sent an Initiation message
+func newTestCollector() *BGPServer {
+	b := NewBgpServer()
+	b.collector = &bmpCollector{
+		routers: make(map[string]*monitoredRouter),
+	}
+	b.collector.HandleMessage(monitoredRouterAddr, &bmp.InitiationMsg{})
+
+	return b
+}
+
+func TestBMPCollectorRouteMonitoring(t *testing.T) {
+	tests := []struct {
+		name       string
+		flags      uint8
+		asn4       bool
+		addPath    bool
+		path       packet.ASPath
+		prePolicy  []uint32
+		postPolicy []uint32
+	}{
+		{
+			name:      "Legacy AS_PATH",
+			flags:     bmp.LegacyASPathFlag,
+			path:      asPath(65001, 65002),
+			prePolicy: []uint32{65001, 65002},
+		},
+		{
+			name:      "4 byte AS_PATH",
+			asn4:      true,
+			path:      asPath(65001, 4200000000),
+			prePolicy: []uint32{65001, asTrans},
+		},
+		{
+			name:       "Post-policy",
+			flags:      bmp.PostPolicyFlag,
+			asn4:       true,
+			path:       asPath(65001),
+			postPolicy: []uint32{65001},
+		},
+		{
+			name:      "ADD-PATH",
+			flags:     bmp.LegacyASPathFlag,
+			addPath:   true,
+			path:      asPath(65001),
+			prePolicy: []uint32{65001},
+		},
+		{
+			name:  "Adj-RIB-Out",
+			flags: bmp.AdjRIBOutFlag | bmp.LegacyASPathFlag,
+			path:  asPath(65001),
+		},
+	}
+
+	for _, test := range tests {
+		b := newTestCollector()
+
+		var sentCaps, receivedCaps packet.Capabilities
+		if test.addPath {
+			sentCaps = addPathCapabilities(packet.AddPathReceive)
+			receivedCaps = addPathCapabilities(packet.AddPathSend)
+		}
+
+		peer := bmp.PeerHeader{
+			Address: monitoredPeerAddr,
+			AS:      65001,
+			BGPID:   1,
+		}
+		b.collector.HandleMessage(monitoredRouterAddr, &bmp.PeerUpMsg{
+			Peer:         peer,
+			SentOpen:     serializedOpen(65000, test.asn4, sentCaps),
+			ReceivedOpen: serializedOpen(65001, test.asn4, receivedCaps),
+		})
+
+		peer.Flags = test.flags
+		b.collector.HandleMessage(monitoredRouterAddr, &bmp.RouteMonitoringMsg{
+			Peer: peer,
+			Update: serializedUpdate(t, test.path, &packet.EncodeOptions{
+				AddPath: test.addPath,
+				ASN4:    test.asn4,
+			}),
+		})
+
+		ribs, err := b.MonitoredRouter(monitoredRouterAddr)
+		if !assert.NoError(t, err, test.name) {
+			continue
+		}
+		assert.Equal(t, []net.IP{monitoredPeerAddr}, ribs.Peers(), test.name)
+
+		for _, prePolicy := range []bool{true, false} {
+			expected := test.postPolicy
+			if prePolicy {
+				expected = test.prePolicy
+			}
+
+			routes, err := ribs.AdjRIBIn(monitoredPeerAddr, prePolicy)
+			if !assert.NoError(t, err, test.name) {
+				continue
+			}
+			assertMonitoredPath(t, routes, expected, test.name)
+		}
+
+		b.collector.HandleMessage(monitoredRouterAddr, &bmp.PeerDownMsg{
+			Peer: peer,
+		})
+		_, err = ribs.AdjRIBIn(monitoredPeerAddr, true)
+		assert.Error(t, err, test.name)
+	}
+}
+
+func TestBMPCollectorLocRIB(t *testing.T) {
+	tests := []struct {
+		name     string
+		asn4     bool
+		path     packet.ASPath
+		expected []uint32
+	}{
+		{
+			name:     "Legacy AS_PATH",
+			path:     asPath(65001),
+			expected: []uint32{65001},
+		},
+		{
+			name:     "4 byte AS_PATH",
+			asn4:     true,
+			path:     asPath(4200000000),
+			expected: []uint32{asTrans},
+		},
+	}
+
+	for _, test := range tests {
+		b := newTestCollector()
+
+		ribs, err := b.MonitoredRouter(monitoredRouterAddr)
+		if !assert.NoError(t, err, test.name) {
+			continue
+		}
+		_, err = ribs.LocRIB()
+		assert.Error(t, err, test.name)
+
+		peer := bmp.PeerHeader{
+			PeerType: bmp.LocRIBInstancePeer,
+			BGPID:    1,
+		}
+		open := serializedOpen(65000, test.asn4, nil)
+		b.collector.HandleMessage(monitoredRouterAddr, &bmp.PeerUpMsg{
+			Peer:         peer,
+			SentOpen:     open,
+			ReceivedOpen: open,
+		})
+		b.collector.HandleMessage(monitoredRouterAddr, &bmp.RouteMonitoringMsg{
+			Peer:   peer,
+			Update: serializedUpdate(t, test.path, &packet.EncodeOptions{ASN4: test.asn4}),
+		})
+
+		routes, err := ribs.LocRIB()
+		if !assert.NoError(t, err, test.name) {
+			continue
+		}
+		assertMonitoredPath(t, routes, test.expected, test.name)
+		assert.Empty(t, ribs.Peers(), test.name)
+	}
+}
+
+func TestBMPCollectorSessionClosed(t *testing.T) {
+	b := NewBgpServer()
+	_, err := b.MonitoredRouter(monitoredRouterAddr)
+	assert.Error(t, err, "Station mode disabled")
+
+	b = newTestCollector()
+	assert.Equal(t, []net.IP{monitoredRouterAddr}, b.MonitoredRouters())
+
+	ribs, err := b.MonitoredRouter(monitoredRouterAddr)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	b.collector.SessionClosed(monitoredRouterAddr)
+	assert.Empty(t, b.MonitoredRouters())
+	assert.Empty(t, ribs.Peers())
+	_, err = ribs.LocRIB()
+	assert.Error(t, err)
+	_, err = b.MonitoredRouter(monitoredRouterAddr)
+	assert.Error(t, err)
+}
+
+// assertMonitoredPath checks that routes hold 10.0.0.0/8 with a single path with AS_PATH asns. No routes are expected if asns is nil.
+func assertMonitoredPath(t *testing.T, routes []*route.Route, asns []uint32, name string) {
+	if asns == nil {
+		assert.Empty(t, routes, name)
+		return
+	}
+
+	if !assert.Len(t, routes, 1, name) || !assert.Len(t, routes[0].Paths(), 1, name) {
+		return
+	}
+	assert.Equal(t, "10.0.0.0/8", routes[0].Prefix().String(), name)
+	assert.Equal(t, asns, routes[0].Paths()[0].ASPath[0].ASNs, name)
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taktv6/tbgp/bmp"
+	"github.com/taktv6/tbgp/packet"
+)
+
+// padded returns msg at the start of a buffer of the maximum message length
+func padded(msg []byte) []byte {
+	buf := make([]byte, packet.MaxLen)
+	copy(buf, msg)
+	return buf
+}
+
+func TestBGPMessage(t *testing.T) {
+	keepalive := packet.SerializeKeepaliveMsg()
+
+	tests := []struct {
+		name     string
+		input    []byte
+		expected []byte
+	}{
+		{
+			name:     "Exact message",
+			input:    keepalive,
+			expected: keepalive,
+		},
+		{
+			name:     "Padded message",
+			input:    padded(keepalive),
+			expected: keepalive,
+		},
+		{
+			name:     "Truncated header",
+			input:    keepalive[:10],
+			expected: keepalive[:10],
+		},
+		{
+			name:     "Nil",
+			input:    nil,
+			expected: nil,
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, bgpMessage(test.input), test.name)
+	}
+}
+
+func TestBMPPeerUpDown(t *testing.T) {
+	sentOpen := packet.SerializeOpenMsg(&packet.BGPOpen{
+		Version:       BGPVersion,
+		AS:            65000,
+		HoldTime:      90,
+		BGPIdentifier: 1,
+	})
+	receivedOpen := packet.SerializeOpenMsg(&packet.BGPOpen{
+		Version:       BGPVersion,
+		AS:            65001,
+		HoldTime:      90,
+		BGPIdentifier: 2,
+	})
+	notification := packet.SerializeNotificationMsg(&packet.BGPNotification{
+		ErrorCode:    packet.Cease,
+		ErrorSubcode: packet.AdminShut,
+	})
+
+	fsm := &FSM{
+		remote:       net.IP{10, 0, 0, 1},
+		remoteASN:    65001,
+		neighborID:   2,
+		sentOpen:     sentOpen,
+		receivedOpen: bgpMessage(padded(receivedOpen)),
+	}
+
+	up := fsm.peerUpMsg(&net.TCPAddr{IP: net.IP{10, 0, 0, 2}, Port: 179}, &net.TCPAddr{IP: fsm.remote, Port: 50000})
+	msg, err := bmp.ReadMessage(bytes.NewBuffer(bmp.SerializePeerUpMsg(up)))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, sentOpen, msg.(*bmp.PeerUpMsg).SentOpen)
+	assert.Equal(t, receivedOpen, msg.(*bmp.PeerUpMsg).ReceivedOpen)
+	assert.Empty(t, msg.(*bmp.PeerUpMsg).Information)
+
+	fsm.sessionDown(bmp.RemoteNotification, bgpMessage(padded(notification)))
+	msg, err = bmp.ReadMessage(bytes.NewBuffer(bmp.SerializePeerDownMsg(&bmp.PeerDownMsg{
+		Peer:   up.Peer,
+		Reason: fsm.downReason,
+		Data:   fsm.downData,
+	})))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, uint8(bmp.RemoteNotification), msg.(*bmp.PeerDownMsg).Reason)
+	assert.Equal(t, notification, msg.(*bmp.PeerDownMsg).Data)
+}
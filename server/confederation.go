@@ -0,0 +1,65 @@
+package server
+
+import (
+	"github.com/taktv6/tbgp/config"
+	"github.com/taktv6/tbgp/route"
+)
+
+// confederation holds the BGP confederation (RFC5065) we are a member of
+type confederation struct {
+	id      uint32
+	members map[uint32]struct{}
+}
+
+func newConfederation(c *config.Global) *confederation {
+	confed := &confederation{
+		id:      c.ConfederationID,
+		members: make(map[uint32]struct{}),
+	}
+
+	for _, asn := range c.ConfederationMembers {
+		confed.members[asn] = struct{}{}
+	}
+
+	return confed
+}
+
+// enabled checks if we are a member of a confederation
+func (c *confederation) enabled() bool {
+	return c.id != 0
+}
+
+// member checks if asn is a member AS of the confederation
+func (c *confederation) member(asn uint32) bool {
+	if !c.enabled() {
+		return false
+	}
+
+	_, ok := c.members[asn]
+	return ok
+}
+
+// external checks if the neighbor is outside of our AS and confederation
+func (fsm *FSM) external() bool {
+	return fsm.localASN != fsm.remoteASN && !fsm.confedEBGP
+}
+
+// announcedASN returns the ASN we present ourselves with to the neighbor.
+// Neighbors outside the confederation only know its confederation ID (RFC5065 4.1).
+func (fsm *FSM) announcedASN() uint16 {
+	if fsm.external() && fsm.server.confed.enabled() {
+		return uint16(fsm.server.confed.id)
+	}
+
+	return fsm.localASN
+}
+
+// invalidConfedPath checks if p is to be discarded due to its confederation segments. Neighbors outside the
+// confederation must not send them at all. Paths with our member AS in them have looped (RFC5065 5).
+func (fsm *FSM) invalidConfedPath(p *route.Path) bool {
+	if fsm.external() {
+		return p.ASPath.HasConfedSegments()
+	}
+
+	return p.ASPath.ConfedContains(uint32(fsm.localASN))
+}
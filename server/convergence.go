@@ -0,0 +1,168 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/taktv6/tbgp/packet"
+)
+
+// initialSyncTimeout is the time after which a peer not sending End-of-RIB is considered synced anyway
+var initialSyncTimeout = time.Minute * 5
+
+// convergence tracks the initial synchronization of all configured peers.
+// A peer is synced once it has sent End-of-RIB after the session has been established.
+type convergence struct {
+	mu     sync.Mutex
+	synced map[string]bool
+	ready  chan struct{}
+
+	// forced is set if the readiness deadline expired before all peers were synced
+	forced bool
+}
+
+func newConvergence() *convergence {
+	return &convergence{
+		synced: make(map[string]bool),
+		ready:  make(chan struct{}),
+	}
+}
+
+// addPeer makes readiness wait for the peer with address addr
+func (c *convergence) addPeer(addr string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.synced[addr] = false
+}
+
+// setSynced sets the initial sync state of the peer with address addr
+func (c *convergence) setSynced(addr string, synced bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.synced[addr]; !ok {
+		return
+	}
+	c.synced[addr] = synced
+
+	if !synced || c.isReady() {
+		return
+	}
+
+	if c.unsynced() > 0 {
+		return
+	}
+
+	close(c.ready)
+	log.Info("Initial convergence: All peers are synced")
+}
+
+// startDeadline makes the server ready after d regardless of the peers not synced by then
+func (c *convergence) startDeadline(d time.Duration) {
+	time.AfterFunc(d, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		if c.isReady() {
+			return
+		}
+
+		c.forced = true
+		close(c.ready)
+		log.WithFields(log.Fields{
+			"unsynced": c.unsynced(),
+		}).Warning("Initial convergence: Readiness deadline expired. Considering all peers synced")
+	})
+}
+
+// unsynced returns the number of peers not synced. c.mu has to be held.
+func (c *convergence) unsynced() int {
+	n := 0
+	for _, s := range c.synced {
+		if !s {
+			n++
+		}
+	}
+
+	return n
+}
+
+// isSynced checks if the peer with address addr has finished sending its routes
+func (c *convergence) isSynced(addr string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.synced[addr]
+}
+
+// isForced checks if the readiness deadline expired before all peers were synced
+func (c *convergence) isForced() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.forced
+}
+
+func (c *convergence) isReady() bool {
+	select {
+	case <-c.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// startInitialSync is called when a session is established
+func (fsm *FSM) startInitialSync() {
+	fsm.mu.Lock()
+	defer fsm.mu.Unlock()
+
+	fsm.resetInitialSync()
+	fsm.startTimer(&fsm.initialSyncTimer, initialSyncTimeout, func() {
+		log.WithFields(log.Fields{
+			"peer": fsm.remote.String(),
+		}).Warning("Initial sync: No End-of-RIB received. Considering peer synced")
+		fsm.server.convergence.setSynced(fsm.remote.String(), true)
+	})
+}
+
+// stopInitialSync is called when a session goes down
+func (fsm *FSM) stopInitialSync() {
+	fsm.mu.Lock()
+	defer fsm.mu.Unlock()
+
+	fsm.resetInitialSync()
+}
+
+// resetInitialSync stops the initial sync timer and marks the peer not synced. fsm.mu has to be held.
+func (fsm *FSM) resetInitialSync() {
+	fsm.cancelTimer(&fsm.initialSyncTimer)
+	fsm.server.convergence.setSynced(fsm.remote.String(), false)
+}
+
+// processEndOfRIB handles an End-of-RIB marker received from the neighbor
+func (fsm *FSM) processEndOfRIB(afi uint16, safi uint8) {
+	// IPv4 unicast is the only address family we negotiate
+	if afi != packet.IPv4AFI || safi != packet.UnicastSAFI {
+		return
+	}
+
+	fsm.mu.Lock()
+	fsm.cancelTimer(&fsm.initialSyncTimer)
+	fsm.server.convergence.setSynced(fsm.remote.String(), true)
+	fsm.mu.Unlock()
+
+	log.WithFields(log.Fields{
+		"peer": fsm.remote.String(),
+	}).Info("Initial sync: Received End-of-RIB")
+
+	fsm.endOfRIBReceived()
+}
+
+// sendEndOfRIB signals the neighbor that the initial routing update is complete.
+// It is queued so it follows the routes sent by the Adj-RIB-Out.
+func (fsm *FSM) sendEndOfRIB() {
+	fsm.updateSender.enqueue(packet.SerializeEndOfRIBMsg(packet.IPv4AFI, packet.UnicastSAFI))
+}
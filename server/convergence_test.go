@@ -0,0 +1,185 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taktv6/tbgp/config"
+	tnet "github.com/taktv6/tbgp/net"
+	"github.com/taktv6/tbgp/packet"
+	"github.com/taktv6/tbgp/rib"
+	"github.com/taktv6/tbgp/route"
+)
+
+func TestConvergence(t *testing.T) {
+	tests := []struct {
+		name     string
+		peers    []string
+		synced   []string
+		expected bool
+	}{
+		{
+			name:     "All peers synced",
+			peers:    []string{"10.0.0.1", "10.0.0.2"},
+			synced:   []string{"10.0.0.1", "10.0.0.2"},
+			expected: true,
+		},
+		{
+			name:     "Peer not synced",
+			peers:    []string{"10.0.0.1", "10.0.0.2"},
+			synced:   []string{"10.0.0.1"},
+			expected: false,
+		},
+		{
+			name:     "Unknown peer ignored",
+			peers:    []string{"10.0.0.1"},
+			synced:   []string{"10.0.0.3"},
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		c := newConvergence()
+		for _, addr := range test.peers {
+			c.addPeer(addr)
+		}
+		for _, addr := range test.synced {
+			c.setSynced(addr, true)
+		}
+
+		assert.Equal(t, test.expected, c.isReady(), test.name)
+	}
+}
+
+func TestConvergenceDeadline(t *testing.T) {
+	c := newConvergence()
+	c.addPeer("10.0.0.1")
+	c.startDeadline(10 * time.Millisecond)
+
+	assert.False(t, c.isReady())
+	assert.Eventually(t, c.isReady, time.Second, time.Millisecond)
+	assert.False(t, c.isSynced("10.0.0.1"))
+	assert.True(t, c.isForced())
+
+	c = newConvergence()
+	c.addPeer("10.0.0.1")
+	c.startDeadline(10 * time.Millisecond)
+	c.setSynced("10.0.0.1", true)
+
+	assert.True(t, c.isReady())
+	time.Sleep(20 * time.Millisecond)
+	assert.False(t, c.isForced())
+}
+
+func TestInitialSync(t *testing.T) {
+	defer func(d time.Duration) {
+		initialSyncTimeout = d
+	}(initialSyncTimeout)
+	initialSyncTimeout = 10 * time.Millisecond
+
+	tests := []struct {
+		name     string
+		stop     bool
+		eor      bool
+		expected bool
+	}{
+		{
+			name:     "Synced without End-of-RIB after timeout",
+			expected: true,
+		},
+		{
+			name:     "Synced by End-of-RIB",
+			eor:      true,
+			expected: true,
+		},
+		{
+			name:     "Session down before timeout",
+			stop:     true,
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		fsm := newTestFSM(config.Peer{})
+		addr := fsm.remote.String()
+		fsm.server.convergence.addPeer(addr)
+
+		fsm.startInitialSync()
+		if test.eor {
+			fsm.processEndOfRIB(packet.IPv4AFI, packet.UnicastSAFI)
+			assert.True(t, fsm.server.convergence.isSynced(addr), test.name)
+		}
+		if test.stop {
+			fsm.stopInitialSync()
+		}
+		time.Sleep(5 * initialSyncTimeout)
+
+		assert.Equal(t, test.expected, fsm.server.convergence.isSynced(addr), test.name)
+		fsm.mu.Lock()
+		assert.Nil(t, fsm.initialSyncTimer, test.name)
+		fsm.mu.Unlock()
+	}
+}
+
+func TestSendEndOfRIB(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefixes []*tnet.Prefix
+	}{
+		{
+			name:     "Empty Loc-RIB",
+			prefixes: []*tnet.Prefix{},
+		},
+		{
+			name: "End-of-RIB after all routes",
+			prefixes: []*tnet.Prefix{
+				tnet.NewPfx(0x0a000000, 8),  // 10.0.0.0/8
+				tnet.NewPfx(0xac100000, 12), // 172.16.0.0/12
+			},
+		},
+	}
+
+	for _, test := range tests {
+		local, remote := tcpPair(t)
+		fsm := newTestFSM(config.Peer{})
+		fsm.con = local
+		fsm.updateSender = newUpdateSender(fsm)
+		fsm.adjRibOut = rib.NewAdjRIBOut(&rib.Neighbor{
+			Address:      fsm.remoteAddr(),
+			LocalAddress: fsm.localAddr(),
+			LocalASN:     65000,
+			PeerASN:      65001,
+		})
+		fsm.adjRibOut.Register(fsm.updateSender)
+
+		for _, pfx := range test.prefixes {
+			fsm.locRIB.AddPath(pfx, &route.Path{
+				ASPath: asPath(65100),
+			})
+		}
+		fsm.advertiseRoutes()
+
+		remote.SetReadDeadline(time.Now().Add(time.Second))
+		for i := 0; i <= len(test.prefixes); i++ {
+			msg, err := recvMsg(remote)
+			if err != nil {
+				t.Fatalf("%s: Unable to receive message: %v", test.name, err)
+			}
+
+			m, err := packet.Decode(bytes.NewBuffer(msg))
+			if err != nil {
+				t.Fatalf("%s: Unable to decode message: %v", test.name, err)
+			}
+
+			update := m.Body.(*packet.BGPUpdate)
+			assert.Equal(t, i == len(test.prefixes), update.IsEndOfRIB(), test.name)
+		}
+
+		fsm.locRIB.Unregister(fsm.adjRibOut)
+		fsm.updateSender.stop()
+		local.Close()
+		remote.Close()
+	}
+}
@@ -0,0 +1,34 @@
+package server
+
+import (
+	"time"
+
+	"github.com/taktv6/tbgp/config"
+	"github.com/taktv6/tbgp/rib"
+)
+
+// dampeningParameters converts the dampening configuration of a peer, falling back to the defaults
+// for values not set
+func dampeningParameters(c *config.Dampening) rib.DampeningParameters {
+	p := rib.DefaultDampeningParameters()
+	if c.HalfLife != 0 {
+		p.HalfLife = time.Duration(c.HalfLife) * time.Second
+	}
+	if c.ReuseThreshold != 0 {
+		p.ReuseThreshold = c.ReuseThreshold
+	}
+	if c.SuppressThreshold != 0 {
+		p.SuppressThreshold = c.SuppressThreshold
+	}
+	if c.MaxSuppressTime != 0 {
+		p.MaxSuppressTime = time.Duration(c.MaxSuppressTime) * time.Second
+	}
+	if c.WithdrawPenalty != 0 {
+		p.WithdrawPenalty = c.WithdrawPenalty
+	}
+	if c.AttributeChangePenalty != 0 {
+		p.AttributeChangePenalty = c.AttributeChangePenalty
+	}
+
+	return p
+}
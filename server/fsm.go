@@ -5,14 +5,14 @@ import (
 	"fmt"
 	"math"
 	"net"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/taktv6/tbgp/bmp"
 	"github.com/taktv6/tbgp/config"
-	"github.com/taktv6/tbgp/lpm"
-	tnet "github.com/taktv6/tbgp/net"
 	"github.com/taktv6/tbgp/packet"
-	"github.com/taktv6/tflow2/convert"
+	"github.com/taktv6/tbgp/rib"
 	tomb "gopkg.in/tomb.v2"
 )
 
@@ -29,6 +29,9 @@ const (
 	ConnectRetryTimerExpires = 9
 	HoldTimerExpires         = 10
 	KeepaliveTimerExpires    = 11
+
+	// Events not defined by RFC4271
+	RouteRefreshRequest = 100
 )
 
 const (
@@ -83,8 +86,57 @@ type FSM struct {
 	msgRecvFailCh chan msgRecvErr
 	stopMsgRecvCh chan struct{}
 
-	adjRibIn  *lpm.LPM
-	adjRibOut *lpm.LPM
+	// mu protects the state accessed by the callbacks of timers started by startTimer
+	mu sync.Mutex
+
+	routeRefresh           bool
+	enhancedRouteRefresh   bool
+	endOfRouteRefreshTimer *time.Timer
+
+	gracefulRestart     bool
+	peerGracefulRestart *packet.GracefulRestartCapabilityValue
+	restartTimer        *time.Timer
+
+	// sessionLost is set if the session went down in a way the neighbor's routes are retained as stale for
+	// when graceful restart has been negotiated: TCP failure or hold timer expiry. Without the N-bit (RFC8538),
+	// which we do not support, routes are flushed on any NOTIFICATION received.
+	sessionLost bool
+
+	longLivedGracefulRestart     bool
+	longLivedStaleTime           uint32
+	peerLongLivedGracefulRestart *packet.LongLivedGracefulRestartAFI
+	longLivedStaleTimer          *time.Timer
+
+	initialSyncTimer *time.Timer
+
+	addPathReceive bool
+	addPathSend    config.AddPathSendMode
+	addPathSendMax uint
+	addPathRX      bool
+	addPathTX      bool
+
+	routeReflectorClient bool
+	clusterID            uint32
+
+	confedEBGP     bool
+	allowASIn      uint8
+	enforceFirstAS bool
+
+	role       config.Role
+	strictRole bool
+
+	maxPrefix *maxPrefix
+
+	sentOpen     []byte
+	receivedOpen []byte
+	downReason   uint8
+	downData     []byte
+
+	server       *BGPServer
+	locRIB       *rib.LocRIB
+	adjRibIn     *rib.AdjRIBIn
+	adjRibOut    *rib.AdjRIBOut
+	updateSender *updateSender
 }
 
 type msgRecvMsg struct {
@@ -97,7 +149,7 @@ type msgRecvErr struct {
 	con *net.TCPConn
 }
 
-func NewFSM(c config.Peer) *FSM {
+func NewFSM(c config.Peer, server *BGPServer) *FSM {
 	fsm := &FSM{
 		state:             Idle,
 		passive:           true,
@@ -114,14 +166,51 @@ func NewFSM(c config.Peer) *FSM {
 		keepaliveTime:  time.Duration(c.KeepAlive),
 		keepaliveTimer: time.NewTimer(0),
 
-		routerID: c.RouterID,
-		remote:   c.PeerAddress,
-		local:    c.LocalAddress,
-		localASN: uint16(c.LocalAS),
-		eventCh:  make(chan int),
-		conCh:    make(chan *net.TCPConn),
-		conErrCh: make(chan error), initiateCon: make(chan struct{}),
+		routerID:  c.RouterID,
+		remote:    c.PeerAddress,
+		local:     c.LocalAddress,
+		localASN:  uint16(c.LocalAS),
+		remoteASN: uint16(c.PeerAS),
+		eventCh:   make(chan int),
+		conCh:     make(chan *net.TCPConn),
+		conErrCh:  make(chan error), initiateCon: make(chan struct{}),
+
+		gracefulRestart:          c.GracefulRestart,
+		longLivedGracefulRestart: c.LongLivedGracefulRestart,
+		longLivedStaleTime:       c.LongLivedStaleTime,
+
+		addPathReceive: c.AddPathReceive,
+		addPathSend:    c.AddPathSend,
+		addPathSendMax: c.AddPathSendMax,
+
+		routeReflectorClient: c.RouteReflectorClient,
+		clusterID:            clusterID(c),
+
+		confedEBGP: c.LocalAS != c.PeerAS && server.confed.member(c.PeerAS),
+		allowASIn:  c.AllowASIn,
+
+		enforceFirstAS: !c.SkipFirstASCheck && c.Role != config.RoleRSClient,
+
+		role:       c.Role,
+		strictRole: c.StrictRole,
+
+		maxPrefix: newMaxPrefix(c),
+
+		server:   server,
+		locRIB:   server.locRIB,
+		adjRibIn: rib.NewAdjRIBIn(c.ImportFilter, server.keepPrePolicy(c)),
+	}
+	if c.Dampening != nil {
+		fsm.adjRibIn.EnableDampening(dampeningParameters(c.Dampening))
+	}
+	if server.vrps != nil {
+		fsm.adjRibIn.SetOriginValidator(server.vrps, server.originASN(c))
 	}
+	if server.aspas != nil && c.Role != config.RoleNone {
+		fsm.adjRibIn.SetPathVerifier(server.aspas, aspaNeighborAS(c), c.Role == config.RoleCustomer)
+	}
+	fsm.adjRibIn.Register(fsm.locRIB)
+
 	return fsm
 }
 
@@ -201,7 +290,6 @@ func (fsm *FSM) main() error {
 }
 
 func (fsm *FSM) idle() int {
-	fsm.adjRibIn = nil
 	fsm.adjRibOut = nil
 	for {
 		select {
@@ -362,7 +450,7 @@ func (fsm *FSM) openSent() int {
 		select {
 		case e := <-fsm.eventCh:
 			if e == ManualStop {
-				sendNotification(fsm.con, packet.Cease, 0)
+				fsm.sendNotification(fsm.con, packet.Cease, 0)
 				stopTimer(fsm.connectRetryTimer)
 				fsm.disconnect()
 				fsm.connectRetryCounter = 0
@@ -370,7 +458,7 @@ func (fsm *FSM) openSent() int {
 			}
 			continue
 		case <-fsm.holdTimer.C:
-			sendNotification(fsm.con, packet.HoldTimeExpired, 0)
+			fsm.sendNotification(fsm.con, packet.HoldTimeExpired, 0)
 			stopTimer(fsm.connectRetryTimer)
 			fsm.disconnect()
 			fsm.connectRetryCounter++
@@ -394,12 +482,13 @@ func (fsm *FSM) openSent() int {
 			go fsm.msgReceiver(c)
 			continue
 		case recvMsg := <-fsm.msgRecvCh:
+			fsm.logMRT(recvMsg.con, recvMsg.msg, false)
 			msg, err := packet.Decode(bytes.NewBuffer(recvMsg.msg))
 			if err != nil {
 				switch bgperr := err.(type) {
 				case packet.BGPError:
-					sendNotification(fsm.con, bgperr.ErrorCode, bgperr.ErrorSubCode)
-					sendNotification(fsm.con2, bgperr.ErrorCode, bgperr.ErrorSubCode)
+					fsm.sendNotification(fsm.con, bgperr.ErrorCode, bgperr.ErrorSubCode)
+					fsm.sendNotification(fsm.con2, bgperr.ErrorCode, bgperr.ErrorSubCode)
 				}
 				stopTimer(fsm.connectRetryTimer)
 				fsm.disconnect()
@@ -427,7 +516,16 @@ func (fsm *FSM) openSent() int {
 				return fsm.changeState(Idle, "Received NOTIFICATION")
 			case packet.OpenMsg:
 				openMsg := msg.Body.(*packet.BGPOpen)
+				if fsm.roleMismatch(openMsg.Capabilities()) {
+					fsm.sendNotification(fsm.con, packet.OpenMessageError, packet.RoleMismatch)
+					stopTimer(fsm.connectRetryTimer)
+					fsm.disconnect()
+					fsm.connectRetryCounter++
+					return fsm.changeState(Idle, "BGP role mismatch")
+				}
 				fsm.neighborID = openMsg.BGPIdentifier
+				fsm.receivedOpen = bgpMessage(recvMsg.msg)
+				fsm.processCapabilities(openMsg.Capabilities())
 				fsm.resolveCollision()
 				stopTimer(fsm.connectRetryTimer)
 				err := fsm.sendKeepalive()
@@ -442,7 +540,7 @@ func (fsm *FSM) openSent() int {
 				}
 				return fsm.changeState(OpenConfirm, "Received OPEN message")
 			default:
-				sendNotification(fsm.con, packet.FiniteStateMachineError, 0)
+				fsm.sendNotification(fsm.con, packet.FiniteStateMachineError, 0)
 				stopTimer(fsm.connectRetryTimer)
 				fsm.con.Close()
 				fsm.connectRetryCounter++
@@ -488,12 +586,12 @@ func (fsm *FSM) resolveCollision() {
 	if fsm.routerID > fsm.neighborID {
 		// Terminate passive connection
 		if fsm.isPassive(fsm.con) {
-			dumpCon(fsm.con)
+			fsm.terminateCon(fsm.con)
 			fsm.con = fsm.con2
 			return
 		}
 		if fsm.isPassive(fsm.con2) {
-			dumpCon(fsm.con2)
+			fsm.terminateCon(fsm.con2)
 			return
 		}
 		return
@@ -501,20 +599,21 @@ func (fsm *FSM) resolveCollision() {
 
 	// Terminate active connection
 	if !fsm.isPassive(fsm.con) {
-		dumpCon(fsm.con)
+		fsm.terminateCon(fsm.con)
 		fsm.con = fsm.con2
 		return
 	}
 	if !fsm.isPassive(fsm.con2) {
-		dumpCon(fsm.con2)
+		fsm.terminateCon(fsm.con2)
 		fsm.con2.Close()
 		fsm.con2 = nil
 		return
 	}
 }
 
-func dumpCon(c *net.TCPConn) {
-	sendNotification(c, packet.Cease, packet.ConnectionCollisionResolution)
+// terminateCon closes c, which lost the connection collision resolution (RFC4271 6.8)
+func (fsm *FSM) terminateCon(c *net.TCPConn) {
+	fsm.sendNotification(c, packet.Cease, packet.ConnectionCollisionResolution)
 	c.Close()
 }
 
@@ -530,7 +629,7 @@ func (fsm *FSM) openConfirm() int {
 		select {
 		case e := <-fsm.eventCh:
 			if e == ManualStop { // Event 2
-				sendNotification(fsm.con, packet.Cease, 0)
+				fsm.sendNotification(fsm.con, packet.Cease, 0)
 				stopTimer(fsm.connectRetryTimer)
 				fsm.disconnect()
 				fsm.connectRetryCounter = 0
@@ -538,7 +637,7 @@ func (fsm *FSM) openConfirm() int {
 			}
 			continue
 		case <-fsm.holdTimer.C:
-			sendNotification(fsm.con, packet.HoldTimeExpired, 0)
+			fsm.sendNotification(fsm.con, packet.HoldTimeExpired, 0)
 			stopTimer(fsm.connectRetryTimer)
 			fsm.disconnect()
 			fsm.connectRetryCounter++
@@ -572,13 +671,14 @@ func (fsm *FSM) openConfirm() int {
 			go fsm.msgReceiver(c)
 			continue
 		case recvMsg := <-fsm.msgRecvCh:
+			fsm.logMRT(recvMsg.con, recvMsg.msg, false)
 			msg, err := packet.Decode(bytes.NewBuffer(recvMsg.msg))
 			if err != nil {
 				fmt.Printf("Failed to decode message: %v\n", recvMsg.msg)
 				switch bgperr := err.(type) {
 				case packet.BGPError:
-					sendNotification(fsm.con, bgperr.ErrorCode, bgperr.ErrorSubCode)
-					sendNotification(fsm.con2, bgperr.ErrorCode, bgperr.ErrorSubCode)
+					fsm.sendNotification(fsm.con, bgperr.ErrorCode, bgperr.ErrorSubCode)
+					fsm.sendNotification(fsm.con2, bgperr.ErrorCode, bgperr.ErrorSubCode)
 				}
 				stopTimer(fsm.connectRetryTimer)
 				fsm.disconnect()
@@ -611,7 +711,7 @@ func (fsm *FSM) openConfirm() int {
 				fsm.neighborID = openMsg.BGPIdentifier
 				fsm.resolveCollision()
 			default:
-				sendNotification(fsm.con, packet.FiniteStateMachineError, 0)
+				fsm.sendNotification(fsm.con, packet.FiniteStateMachineError, 0)
 				stopTimer(fsm.connectRetryTimer)
 				fsm.con.Close()
 				fsm.connectRetryCounter++
@@ -643,38 +743,37 @@ func (fsm *FSM) openConfirmTCPFail(err error) int {
 }
 
 func (fsm *FSM) established() int {
-	fsm.adjRibIn = lpm.New()
-	go func() {
-		for {
-			time.Sleep(time.Second * 10)
-			fmt.Printf("Dumping AdjRibIn\n")
-			pfxs := fsm.adjRibIn.Dump()
-			for _, pfx := range pfxs {
-				fmt.Printf("LPM: %s\n", pfx.String())
-			}
-		}
-	}()
+	deferralCh := fsm.startRouting()
+	defer fsm.stopRouting()
 
 	for {
 		select {
+		case <-deferralCh:
+			deferralCh = nil
+			fsm.advertiseRoutes()
+			continue
 		case e := <-fsm.eventCh:
 			if e == ManualStop { // Event 2
-				sendNotification(fsm.con, packet.Cease, 0)
+				fsm.notify(packet.Cease, 0)
 				stopTimer(fsm.connectRetryTimer)
 				fsm.con.Close()
 				fsm.connectRetryCounter = 0
 				return fsm.changeState(Idle, "Manual stop event")
 			}
 			if e == AutomaticStop { // Event 8
-				sendNotification(fsm.con, packet.Cease, 0)
+				fsm.notify(packet.Cease, 0)
 				stopTimer(fsm.connectRetryTimer)
 				fsm.con.Close()
 				fsm.connectRetryCounter++
 				return fsm.changeState(Idle, "Automatic stop event")
 			}
+			if e == RouteRefreshRequest {
+				fsm.requestRouteRefresh()
+			}
 			continue
 		case <-fsm.holdTimer.C:
-			sendNotification(fsm.con, packet.HoldTimeExpired, 0)
+			fsm.sessionLost = true
+			fsm.notify(packet.HoldTimeExpired, 0)
 			stopTimer(fsm.connectRetryTimer)
 			fsm.con.Close()
 			fsm.connectRetryCounter++
@@ -682,6 +781,8 @@ func (fsm *FSM) established() int {
 		case <-fsm.keepaliveTimer.C:
 			err := fsm.sendKeepalive()
 			if err != nil {
+				fsm.sessionLost = true
+				fsm.sessionDown(bmp.LocalNoNotification, fsmEvent(tcpConnectionFails))
 				stopTimer(fsm.connectRetryTimer)
 				fsm.con.Close()
 				fsm.connectRetryCounter++
@@ -693,11 +794,14 @@ func (fsm *FSM) established() int {
 			c.Close()
 			continue
 		case recvMsg := <-fsm.msgRecvCh:
-			msg, err := packet.Decode(bytes.NewBuffer(recvMsg.msg))
+			fsm.logMRT(recvMsg.con, recvMsg.msg, false)
+			msg, err := packet.DecodeWithOptions(bytes.NewBuffer(recvMsg.msg), &packet.DecodeOptions{
+				AddPath: fsm.addPathRX,
+			})
 			if err != nil {
 				switch bgperr := err.(type) {
 				case packet.BGPError:
-					sendNotification(fsm.con, bgperr.ErrorCode, bgperr.ErrorSubCode)
+					fsm.notify(bgperr.ErrorCode, bgperr.ErrorSubCode)
 				}
 				stopTimer(fsm.connectRetryTimer)
 				fsm.con.Close()
@@ -706,6 +810,7 @@ func (fsm *FSM) established() int {
 			}
 			switch msg.Header.Type {
 			case packet.NotificationMsg:
+				fsm.sessionDown(bmp.RemoteNotification, bgpMessage(recvMsg.msg))
 				stopTimer(fsm.connectRetryTimer)
 				fsm.con.Close()
 				fsm.connectRetryCounter++
@@ -716,21 +821,23 @@ func (fsm *FSM) established() int {
 				}
 
 				u := msg.Body.(*packet.BGPUpdate)
-
-				for r := u.WithdrawnRoutes; r != nil; r = r.Next {
-					x := r.IP.([4]byte)
-					pfx := tnet.NewPfx(convert.Uint32b(x[:]), r.Pfxlen)
-					fmt.Printf("LPM: Removing prefix %s\n", pfx.String())
-					fsm.adjRibIn.Remove(pfx)
+				if fsm.maxPrefixExceeded(u) {
+					fsm.notify(packet.Cease, packet.MaxPrefReached)
+					stopTimer(fsm.connectRetryTimer)
+					fsm.con.Close()
+					fsm.connectRetryCounter++
+					fsm.scheduleMaxPrefixRestart()
+					return fsm.changeState(Idle, "Maximum number of prefixes reached")
 				}
 
-				for r := u.NLRI; r != nil; r = r.Next {
-					x := r.IP.([4]byte)
-					pfx := tnet.NewPfx(convert.Uint32b(x[:]), r.Pfxlen)
-					fmt.Printf("LPM: Adding prefix %s\n", pfx.String())
-					fsm.adjRibIn.Insert(pfx)
+				fsm.processUpdate(u)
+				continue
+			case packet.RouteRefreshMsg:
+				if fsm.holdTime != 0 {
+					fsm.holdTimer.Reset(time.Second * fsm.holdTime)
 				}
 
+				fsm.processRouteRefresh(msg.Body.(*packet.BGPRouteRefresh))
 				continue
 			case packet.KeepaliveMsg:
 				if fsm.holdTime != 0 {
@@ -739,18 +846,18 @@ func (fsm *FSM) established() int {
 				continue
 			case packet.OpenMsg:
 				if fsm.con2 != nil {
-					sendNotification(fsm.con2, packet.Cease, packet.ConnectionCollisionResolution)
+					fsm.sendNotification(fsm.con2, packet.Cease, packet.ConnectionCollisionResolution)
 					fsm.con2.Close()
 					fsm.con2 = nil
 					continue
 				}
-				sendNotification(fsm.con, packet.FiniteStateMachineError, 0)
+				fsm.notify(packet.FiniteStateMachineError, 0)
 				stopTimer(fsm.connectRetryTimer)
 				fsm.con.Close()
 				fsm.connectRetryCounter++
 				return fsm.changeState(Idle, "FSM Error")
 			default:
-				sendNotification(fsm.con, packet.FiniteStateMachineError, 0)
+				fsm.notify(packet.FiniteStateMachineError, 0)
 				stopTimer(fsm.connectRetryTimer)
 				fsm.con.Close()
 				fsm.connectRetryCounter++
@@ -769,11 +876,113 @@ func (fsm *FSM) established() int {
 				fsm.con2 = nil
 				continue
 			}
+			fsm.sessionLost = true
+			fsm.sessionDown(bmp.RemoteNoNotification, nil)
 			return fsm.openConfirmTCPFail(err.err)
 		}
 	}
 }
 
+// startRouting sets up the Adj-RIB-Out of an established session. If route advertisement is
+// deferred due to a graceful restart the returned channel is closed once routes may be advertised.
+func (fsm *FSM) startRouting() <-chan struct{} {
+	fsm.sessionLost = false
+	fsm.sessionDown(bmp.LocalNoNotification, fsmEvent(unknownEvent))
+	fsm.maxPrefix.reset()
+	fsm.gracefulRestartEstablished()
+	fsm.startInitialSync()
+
+	fsm.updateSender = newUpdateSender(fsm)
+	fsm.adjRibOut = rib.NewAdjRIBOut(&rib.Neighbor{
+		Address:      fsm.remoteAddr(),
+		LocalAddress: fsm.localAddr(),
+		LocalASN:     uint32(fsm.announcedASN()),
+		PeerASN:      uint32(fsm.remoteASN),
+		IBGP:         fsm.localASN == fsm.remoteASN,
+
+		LongLivedGracefulRestart: fsm.peerLongLivedGracefulRestart != nil,
+		AddPath:                  fsm.addPathTX,
+		RouteReflectorClient:     fsm.routeReflectorClient,
+		ClusterID:                fsm.clusterID,
+		Confederation:            fsm.confedEBGP,
+		RejectOnlyToCustomer:     fsm.rejectOnlyToCustomer(),
+		MarkOnlyToCustomer:       fsm.markOnlyToCustomer(),
+	})
+	fsm.adjRibOut.Register(fsm.updateSender)
+	fsm.server.bmp.peerUp(fsm)
+
+	if fsm.server.gr.isRestarting() {
+		return fsm.server.gr.deferralDone()
+	}
+
+	fsm.advertiseRoutes()
+	return nil
+}
+
+// advertiseRoutes connects the Adj-RIB-Out to the Loc-RIB which sends all current routes to the neighbor
+func (fsm *FSM) advertiseRoutes() {
+	fsm.locRIB.RegisterWithOptions(fsm.adjRibOut, fsm.locRIBClientOptions())
+	fsm.sendEndOfRIB()
+}
+
+// stopRouting disconnects the Adj-RIB-Out of a session from the Loc-RIB and withdraws all routes learned from
+// the neighbor. If the session was lost and the neighbor supports graceful restart its routes are retained as stale.
+func (fsm *FSM) stopRouting() {
+	fsm.server.bmp.peerDown(fsm)
+	fsm.locRIB.Unregister(fsm.adjRibOut)
+	fsm.updateSender.stop()
+	fsm.stopInitialSync()
+	fsm.stopRouteRefresh()
+
+	if fsm.sessionLost && fsm.retainStaleRoutes() {
+		return
+	}
+
+	fsm.adjRibIn.Flush()
+}
+
+func (fsm *FSM) remoteAddr() uint32 {
+	return ipToUint32(fsm.remote)
+}
+
+// localAddr returns the local address of the established session
+func (fsm *FSM) localAddr() uint32 {
+	if fsm.con == nil {
+		return ipToUint32(fsm.local)
+	}
+
+	return ipToUint32(fsm.con.LocalAddr().(*net.TCPAddr).IP)
+}
+
+// startTimer sets *t to a timer calling fn after d. fn is not called if the timer has been stopped by
+// cancelTimer or replaced before. fsm.mu has to be held when calling it and is held when calling fn.
+func (fsm *FSM) startTimer(t **time.Timer, d time.Duration, fn func()) {
+	var timer *time.Timer
+	timer = time.AfterFunc(d, func() {
+		fsm.mu.Lock()
+		defer fsm.mu.Unlock()
+
+		if *t != timer {
+			return
+		}
+		*t = nil
+		fn()
+	})
+	*t = timer
+}
+
+// cancelTimer stops the timer *t started by startTimer. It returns false if there was none.
+// fsm.mu has to be held.
+func (fsm *FSM) cancelTimer(t **time.Timer) bool {
+	if *t == nil {
+		return false
+	}
+
+	(*t).Stop()
+	*t = nil
+	return true
+}
+
 func stopTimer(t *time.Timer) {
 	if !t.Stop() {
 		select {
@@ -802,7 +1011,7 @@ func (fsm *FSM) resetDelayOpenTimer() {
 func (fsm *FSM) sendKeepalive() error {
 	msg := packet.SerializeKeepaliveMsg()
 
-	_, err := fsm.con.Write(msg)
+	err := fsm.write(fsm.con, msg)
 	if err != nil {
 		return fmt.Errorf("Unable to send KEEPALIVE message: %v", err)
 	}
@@ -813,28 +1022,37 @@ func (fsm *FSM) sendKeepalive() error {
 func (fsm *FSM) sendOpen(c *net.TCPConn) error {
 	msg := packet.SerializeOpenMsg(&packet.BGPOpen{
 		Version:       BGPVersion,
-		AS:            fsm.localASN,
+		AS:            fsm.announcedASN(),
 		HoldTime:      uint16(fsm.holdTimeConfigured),
 		BGPIdentifier: fsm.routerID,
-		OptParmLen:    0,
+		OptParams: []packet.OptParam{
+			{
+				Type:  packet.CapabilitiesParam,
+				Value: fsm.capabilities(),
+			},
+		},
 	})
 
-	_, err := c.Write(msg)
+	err := fsm.write(c, msg)
 	if err != nil {
 		return fmt.Errorf("Unable to send OPEN message: %v", err)
 	}
+	fsm.sentOpen = msg
 
 	return nil
 }
 
-func sendNotification(c *net.TCPConn, errorCode uint8, errorSubCode uint8) error {
+func (fsm *FSM) sendNotification(c *net.TCPConn, errorCode uint8, errorSubCode uint8) error {
 	if c == nil {
 		return fmt.Errorf("connection is nil")
 	}
 
-	msg := packet.SerializeNotificationMsg(&packet.BGPNotification{})
+	msg := packet.SerializeNotificationMsg(&packet.BGPNotification{
+		ErrorCode:    errorCode,
+		ErrorSubcode: errorSubCode,
+	})
 
-	_, err := c.Write(msg)
+	err := fsm.write(c, msg)
 	if err != nil {
 		return fmt.Errorf("Unable to send NOTIFICATION message: %v", err)
 	}
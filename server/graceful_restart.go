@@ -0,0 +1,288 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/taktv6/tbgp/config"
+	"github.com/taktv6/tbgp/packet"
+)
+
+// stalePathTime is the maximum time stale routes of a restarted neighbor are retained
+// after the session has been re-established without receiving End-of-RIB
+const stalePathTime = time.Second * 360
+
+// gracefulRestart keeps the speaker wide graceful restart state (RFC4724)
+type gracefulRestart struct {
+	restartTime         uint16
+	forwardingPreserved bool
+
+	mu         sync.Mutex
+	restarting bool
+	pending    map[string]struct{}
+	done       chan struct{}
+}
+
+func newGracefulRestart(c *config.Global) *gracefulRestart {
+	gr := &gracefulRestart{
+		restartTime:         c.GracefulRestartTime,
+		forwardingPreserved: c.ForwardingStatePreserved,
+		restarting:          c.Restarting,
+		pending:             make(map[string]struct{}),
+		done:                make(chan struct{}),
+	}
+
+	if !gr.restarting {
+		close(gr.done)
+		return gr
+	}
+
+	time.AfterFunc(time.Second*time.Duration(c.SelectionDeferralTime), func() {
+		gr.finish("Selection deferral timer expired")
+	})
+
+	return gr
+}
+
+// isRestarting checks if route advertisement is still deferred
+func (gr *gracefulRestart) isRestarting() bool {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	return gr.restarting
+}
+
+// deferralDone returns a channel that is closed once route advertisement is no longer deferred
+func (gr *gracefulRestart) deferralDone() <-chan struct{} {
+	return gr.done
+}
+
+// addPeer makes route advertisement wait for End-of-RIB from the peer with address addr
+func (gr *gracefulRestart) addPeer(addr string) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	if gr.restarting {
+		gr.pending[addr] = struct{}{}
+	}
+}
+
+// peerDone is called once the peer with address addr has sent End-of-RIB
+// or turned out not to support graceful restart
+func (gr *gracefulRestart) peerDone(addr string) {
+	gr.mu.Lock()
+	delete(gr.pending, addr)
+	n := len(gr.pending)
+	gr.mu.Unlock()
+
+	if n == 0 {
+		gr.finish("Received End-of-RIB from all peers")
+	}
+}
+
+func (gr *gracefulRestart) finish(reason string) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	if !gr.restarting {
+		return
+	}
+
+	gr.restarting = false
+	close(gr.done)
+
+	log.WithFields(log.Fields{
+		"reason": reason,
+	}).Info("Graceful restart finished. Advertising routes")
+}
+
+// gracefulRestartCapability returns the graceful restart capability we announce in our OPEN message.
+// If only long-lived graceful restart is enabled a restart time of 0 is announced (RFC9494 4.1).
+func (fsm *FSM) gracefulRestartCapability() packet.Capability {
+	gr := fsm.server.gr
+
+	restartTime := uint16(0)
+	if fsm.gracefulRestart {
+		restartTime = gr.restartTime
+	}
+
+	return packet.Capability{
+		Code: packet.GracefulRestartCapability,
+		Value: packet.GracefulRestartCapabilityValue{
+			Restarting:  gr.isRestarting(),
+			RestartTime: restartTime,
+			AFIs: []packet.GracefulRestartAFI{
+				{
+					AFI:                 packet.IPv4AFI,
+					SAFI:                packet.UnicastSAFI,
+					ForwardingPreserved: gr.forwardingPreserved,
+				},
+			},
+		},
+	}
+}
+
+// longLivedGracefulRestartCapability returns the long-lived graceful restart capability we announce in our OPEN message
+func (fsm *FSM) longLivedGracefulRestartCapability() packet.Capability {
+	return packet.Capability{
+		Code: packet.LongLivedGracefulRestartCapability,
+		Value: packet.LongLivedGracefulRestartCapabilityValue{
+			AFIs: []packet.LongLivedGracefulRestartAFI{
+				{
+					AFI:                 packet.IPv4AFI,
+					SAFI:                packet.UnicastSAFI,
+					ForwardingPreserved: fsm.server.gr.forwardingPreserved,
+					StaleTime:           fsm.longLivedStaleTime,
+				},
+			},
+		},
+	}
+}
+
+// processGracefulRestartCapability determines if (long-lived) graceful restart has been negotiated for IPv4 unicast
+func (fsm *FSM) processGracefulRestartCapability(caps packet.Capabilities) {
+	fsm.peerGracefulRestart = nil
+	fsm.peerLongLivedGracefulRestart = nil
+
+	c, ok := caps.Get(packet.GracefulRestartCapability)
+	if !ok {
+		return
+	}
+
+	v := c.Value.(packet.GracefulRestartCapabilityValue)
+	if fsm.gracefulRestart && v.Supports(packet.IPv4AFI, packet.UnicastSAFI) {
+		fsm.peerGracefulRestart = &v
+	}
+
+	// LLGR is only valid along with the graceful restart capability (RFC9494 4.1)
+	c, ok = caps.Get(packet.LongLivedGracefulRestartCapability)
+	if !fsm.longLivedGracefulRestart || !ok {
+		return
+	}
+
+	a, ok := c.Value.(packet.LongLivedGracefulRestartCapabilityValue).Get(packet.IPv4AFI, packet.UnicastSAFI)
+	if ok {
+		fsm.peerLongLivedGracefulRestart = &a
+	}
+}
+
+// retainStaleRoutes keeps the routes of a neighbor whose session went down as stale
+// until it has restarted (helper mode). Once the restart time expired routes are kept
+// as long-lived stale if LLGR has been negotiated. It returns false if the routes must be flushed.
+func (fsm *FSM) retainStaleRoutes() bool {
+	restartTime := time.Duration(0)
+	if fsm.peerGracefulRestart != nil {
+		restartTime = time.Second * time.Duration(fsm.peerGracefulRestart.RestartTime)
+	}
+
+	staleTime := time.Duration(0)
+	if fsm.peerLongLivedGracefulRestart != nil {
+		staleTime = time.Second * time.Duration(fsm.peerLongLivedGracefulRestart.StaleTime)
+	}
+
+	if restartTime == 0 && staleTime == 0 {
+		return false
+	}
+
+	fsm.mu.Lock()
+	defer fsm.mu.Unlock()
+
+	fsm.stopRestartTimers()
+	fsm.adjRibIn.MarkStale()
+
+	log.WithFields(log.Fields{
+		"peer":         fsm.remote.String(),
+		"restart_time": restartTime,
+		"stale_time":   staleTime,
+	}).Info("Graceful restart: Retaining stale routes")
+
+	if staleTime == 0 {
+		fsm.startTimer(&fsm.restartTimer, restartTime, func() {
+			fsm.removeStaleRoutes("Restart timer expired")
+		})
+		return true
+	}
+
+	fsm.startTimer(&fsm.restartTimer, restartTime, fsm.markLongLivedStale)
+	fsm.startTimer(&fsm.longLivedStaleTimer, restartTime+staleTime, func() {
+		fsm.removeStaleRoutes("Long-lived stale timer expired")
+	})
+
+	return true
+}
+
+// gracefulRestartEstablished is called when a session is established. If the neighbor restarted
+// its stale routes are either kept until End-of-RIB or flushed if its forwarding state was not preserved.
+func (fsm *FSM) gracefulRestartEstablished() {
+	if fsm.peerGracefulRestart == nil {
+		fsm.server.gr.peerDone(fsm.remote.String())
+	}
+
+	fsm.mu.Lock()
+	defer fsm.mu.Unlock()
+
+	if !fsm.stopRestartTimers() {
+		return
+	}
+
+	if !fsm.peerForwardingPreserved() {
+		fsm.removeStaleRoutes("Forwarding state not preserved")
+		return
+	}
+
+	fsm.startTimer(&fsm.restartTimer, stalePathTime, func() {
+		fsm.removeStaleRoutes("Stale path timer expired")
+	})
+}
+
+// peerForwardingPreserved checks if the neighbor preserved its forwarding state for IPv4 unicast across the restart
+func (fsm *FSM) peerForwardingPreserved() bool {
+	if fsm.peerGracefulRestart != nil && fsm.peerGracefulRestart.Preserved(packet.IPv4AFI, packet.UnicastSAFI) {
+		return true
+	}
+
+	return fsm.peerLongLivedGracefulRestart != nil && fsm.peerLongLivedGracefulRestart.ForwardingPreserved
+}
+
+// endOfRIBReceived is called when the neighbor has sent End-of-RIB
+func (fsm *FSM) endOfRIBReceived() {
+	fsm.server.gr.peerDone(fsm.remote.String())
+
+	fsm.mu.Lock()
+	defer fsm.mu.Unlock()
+
+	if !fsm.stopRestartTimers() {
+		return
+	}
+
+	fsm.removeStaleRoutes("Received End-of-RIB")
+}
+
+// stopRestartTimers stops all timers guarding stale routes. It returns false if there were none.
+// The timers are cleared once expired, so there are none if the stale routes have been purged. fsm.mu has to be held.
+func (fsm *FSM) stopRestartTimers() bool {
+	restart := fsm.cancelTimer(&fsm.restartTimer)
+	longLived := fsm.cancelTimer(&fsm.longLivedStaleTimer)
+
+	return restart || longLived
+}
+
+func (fsm *FSM) markLongLivedStale() {
+	n := fsm.adjRibIn.MarkLongLivedStale()
+
+	log.WithFields(log.Fields{
+		"peer":    fsm.remote.String(),
+		"removed": n,
+	}).Info("Long-lived graceful restart: Retaining stale routes as long-lived stale")
+}
+
+func (fsm *FSM) removeStaleRoutes(reason string) {
+	n := fsm.adjRibIn.RemoveStale()
+
+	log.WithFields(log.Fields{
+		"peer":   fsm.remote.String(),
+		"stale":  n,
+		"reason": reason,
+	}).Info("Graceful restart: Purged stale routes")
+}
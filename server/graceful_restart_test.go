@@ -0,0 +1,159 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taktv6/tbgp/config"
+	"github.com/taktv6/tbgp/packet"
+)
+
+// newTestGracefulRestartFSM returns the FSM of a peer holding a route that negotiated graceful restart
+// with restartTime and LLGR with staleTime if not 0
+func newTestGracefulRestartFSM(restartTime uint16, staleTime uint32, preserved bool) *FSM {
+	fsm := newTestFSM(config.Peer{})
+	fsm.peerGracefulRestart = &packet.GracefulRestartCapabilityValue{
+		RestartTime: restartTime,
+		AFIs: []packet.GracefulRestartAFI{
+			{
+				AFI:                 packet.IPv4AFI,
+				SAFI:                packet.UnicastSAFI,
+				ForwardingPreserved: preserved,
+			},
+		},
+	}
+	if staleTime != 0 {
+		fsm.peerLongLivedGracefulRestart = &packet.LongLivedGracefulRestartAFI{
+			AFI:                 packet.IPv4AFI,
+			SAFI:                packet.UnicastSAFI,
+			ForwardingPreserved: preserved,
+			StaleTime:           staleTime,
+		}
+	}
+	fsm.processUpdate(testUpdate)
+
+	return fsm
+}
+
+func TestRetainStaleRoutes(t *testing.T) {
+	tests := []struct {
+		name              string
+		restartTime       uint16
+		staleTime         uint32
+		expected          bool
+		expectedLongLived bool
+	}{
+		{
+			name:     "Graceful restart not negotiated",
+			expected: false,
+		},
+		{
+			name:        "Graceful restart",
+			restartTime: 120,
+			expected:    true,
+		},
+		{
+			name:              "Long-lived graceful restart",
+			restartTime:       120,
+			staleTime:         3600,
+			expected:          true,
+			expectedLongLived: true,
+		},
+	}
+
+	for _, test := range tests {
+		fsm := newTestGracefulRestartFSM(test.restartTime, test.staleTime, true)
+		assert.Equal(t, test.expected, fsm.retainStaleRoutes(), test.name)
+
+		fsm.mu.Lock()
+		assert.Equal(t, test.expected, fsm.restartTimer != nil, test.name)
+		assert.Equal(t, test.expectedLongLived, fsm.longLivedStaleTimer != nil, test.name)
+		fsm.stopRestartTimers()
+		fsm.mu.Unlock()
+	}
+}
+
+func TestGracefulRestartEstablished(t *testing.T) {
+	tests := []struct {
+		name          string
+		preserved     bool
+		eor           bool
+		expected      int
+		expectedTimer bool
+	}{
+		{
+			name:          "Stale routes retained until End-of-RIB",
+			preserved:     true,
+			expected:      1,
+			expectedTimer: true,
+		},
+		{
+			name:      "Stale routes purged on End-of-RIB",
+			preserved: true,
+			eor:       true,
+			expected:  0,
+		},
+		{
+			name:      "Forwarding state not preserved",
+			preserved: false,
+			expected:  0,
+		},
+	}
+
+	for _, test := range tests {
+		fsm := newTestGracefulRestartFSM(120, 0, test.preserved)
+		fsm.retainStaleRoutes()
+		fsm.gracefulRestartEstablished()
+		if test.eor {
+			fsm.endOfRIBReceived()
+		}
+
+		assert.Len(t, fsm.adjRibIn.Routes(), test.expected, test.name)
+		fsm.mu.Lock()
+		assert.Equal(t, test.expectedTimer, fsm.restartTimer != nil, test.name)
+		fsm.stopRestartTimers()
+		fsm.mu.Unlock()
+	}
+}
+
+func TestRestartTimerExpired(t *testing.T) {
+	tests := []struct {
+		name              string
+		staleTime         uint32
+		expected          int
+		expectedLongLived bool
+	}{
+		{
+			name:     "Stale routes purged",
+			expected: 0,
+		},
+		{
+			name:              "Stale routes retained as long-lived stale",
+			staleTime:         3600,
+			expected:          1,
+			expectedLongLived: true,
+		},
+	}
+
+	for _, test := range tests {
+		fsm := newTestGracefulRestartFSM(1, test.staleTime, true)
+		fsm.retainStaleRoutes()
+
+		assert.Eventually(t, func() bool {
+			fsm.mu.Lock()
+			defer fsm.mu.Unlock()
+			return fsm.restartTimer == nil
+		}, 3*time.Second, 10*time.Millisecond, test.name)
+
+		routes := fsm.adjRibIn.Routes()
+		assert.Len(t, routes, test.expected, test.name)
+		if test.expectedLongLived && len(routes) > 0 {
+			assert.True(t, routes[0].Paths()[0].HasCommunity(packet.LLGRStaleCommunity), test.name)
+		}
+
+		fsm.mu.Lock()
+		assert.Equal(t, test.expectedLongLived, fsm.stopRestartTimers(), test.name)
+		fsm.mu.Unlock()
+	}
+}
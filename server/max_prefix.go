@@ -0,0 +1,123 @@
+package server
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/taktv6/tbgp/config"
+	tnet "github.com/taktv6/tbgp/net"
+	"github.com/taktv6/tbgp/packet"
+)
+
+// maxPrefix tracks the number of prefixes received from a neighbor against the configured limit
+type maxPrefix struct {
+	config.MaxPrefix
+
+	// received holds the path identifiers received per prefix. With ADD-PATH (RFC7911) a prefix may have several.
+	received map[tnet.Prefix]map[uint32]struct{}
+	warned   bool
+	exceeded bool
+}
+
+// newMaxPrefix returns the limit configured for IPv4 unicast, the only address family we negotiate.
+// It returns nil if there is none.
+func newMaxPrefix(c config.Peer) *maxPrefix {
+	for _, m := range c.MaxPrefixes {
+		if m.AFI == packet.IPv4AFI && m.SAFI == packet.UnicastSAFI && m.Limit > 0 {
+			return &maxPrefix{
+				MaxPrefix: m,
+				received:  make(map[tnet.Prefix]map[uint32]struct{}),
+			}
+		}
+	}
+
+	return nil
+}
+
+// reset clears the prefixes received and the warnings logged during a previous session
+func (m *maxPrefix) reset() {
+	if m == nil {
+		return
+	}
+
+	m.received = make(map[tnet.Prefix]map[uint32]struct{})
+	m.warned = false
+	m.exceeded = false
+}
+
+// update accounts for the withdraws and advertisements of u
+func (m *maxPrefix) update(u *packet.BGPUpdate) {
+	for n := u.WithdrawnRoutes; n != nil; n = n.Next {
+		pfx := *nlriToPfx(n)
+		delete(m.received[pfx], n.PathIdentifier)
+		if len(m.received[pfx]) == 0 {
+			delete(m.received, pfx)
+		}
+	}
+
+	for n := u.NLRI; n != nil; n = n.Next {
+		pfx := *nlriToPfx(n)
+		if m.received[pfx] == nil {
+			m.received[pfx] = make(map[uint32]struct{})
+		}
+		m.received[pfx][n.PathIdentifier] = struct{}{}
+	}
+}
+
+// maxPrefixExceeded accounts for the prefixes of u and checks the number of prefixes received from the
+// neighbor against the configured limit. It has to be called before u is processed. Warnings are logged
+// once when the threshold or the limit is crossed. It returns true if u must not be processed and the
+// session has to be torn down with a Cease/Maximum Number of Prefixes Reached NOTIFICATION (RFC4486).
+func (fsm *FSM) maxPrefixExceeded(u *packet.BGPUpdate) bool {
+	m := fsm.maxPrefix
+	if m == nil {
+		return false
+	}
+
+	m.update(u)
+	n := uint(len(m.received))
+	if n > m.Limit {
+		if !m.exceeded {
+			m.exceeded = true
+			log.WithFields(log.Fields{
+				"peer":     fsm.remote.String(),
+				"prefixes": n,
+				"limit":    m.Limit,
+			}).Warning("Maximum number of prefixes exceeded")
+		}
+
+		return !m.WarningOnly
+	}
+	m.exceeded = false
+
+	if m.WarningThreshold == 0 || n*100 < m.Limit*uint(m.WarningThreshold) {
+		m.warned = false
+		return false
+	}
+
+	if !m.warned {
+		m.warned = true
+		log.WithFields(log.Fields{
+			"peer":      fsm.remote.String(),
+			"prefixes":  n,
+			"limit":     m.Limit,
+			"threshold": m.WarningThreshold,
+		}).Warning("Number of prefixes reached warning threshold")
+	}
+
+	return false
+}
+
+// scheduleMaxPrefixRestart restarts a session torn down for exceeding the prefix limit after the configured interval
+func (fsm *FSM) scheduleMaxPrefixRestart() {
+	if fsm.maxPrefix.RestartInterval == 0 {
+		return
+	}
+
+	time.AfterFunc(time.Duration(fsm.maxPrefix.RestartInterval)*time.Second, func() {
+		select {
+		case fsm.eventCh <- AutomaticStart:
+		case <-fsm.t.Dying():
+		}
+	})
+}
@@ -0,0 +1,232 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taktv6/tbgp/config"
+	"github.com/taktv6/tbgp/packet"
+)
+
+// nlris chains NLRIs for the /8 prefixes of the first octets given. Path identifiers are numbered from 1.
+func nlris(octets ...byte) *packet.NLRI {
+	var res *packet.NLRI
+	for i := len(octets) - 1; i >= 0; i-- {
+		res = &packet.NLRI{
+			PathIdentifier: uint32(i + 1),
+			IP:             [4]byte{octets[i], 0, 0, 0},
+			Pfxlen:         8,
+			Next:           res,
+		}
+	}
+
+	return res
+}
+
+func maxPrefixPeer(limit uint, threshold uint8, warningOnly bool) config.Peer {
+	return config.Peer{
+		MaxPrefixes: []config.MaxPrefix{
+			{
+				AFI:              packet.IPv4AFI,
+				SAFI:             packet.UnicastSAFI,
+				Limit:            limit,
+				WarningThreshold: threshold,
+				WarningOnly:      warningOnly,
+				RestartInterval:  1,
+			},
+		},
+	}
+}
+
+func TestMaxPrefixExceeded(t *testing.T) {
+	tests := []struct {
+		name     string
+		peer     config.Peer
+		updates  []*packet.BGPUpdate
+		expected []bool
+		warned   bool
+		exceeded bool
+	}{
+		{
+			name: "No limit",
+			peer: config.Peer{},
+			updates: []*packet.BGPUpdate{
+				{NLRI: nlris(10, 11)},
+			},
+			expected: []bool{false},
+		},
+		{
+			name: "Below limit",
+			peer: maxPrefixPeer(2, 0, false),
+			updates: []*packet.BGPUpdate{
+				{NLRI: nlris(10, 11)},
+			},
+			expected: []bool{false},
+		},
+		{
+			name: "Limit exceeded",
+			peer: maxPrefixPeer(2, 0, false),
+			updates: []*packet.BGPUpdate{
+				{NLRI: nlris(10, 11)},
+				{NLRI: nlris(12)},
+			},
+			expected: []bool{false, true},
+			exceeded: true,
+		},
+		{
+			name: "Warning only",
+			peer: maxPrefixPeer(2, 0, true),
+			updates: []*packet.BGPUpdate{
+				{NLRI: nlris(10, 11, 12)},
+			},
+			expected: []bool{false},
+			exceeded: true,
+		},
+		{
+			name: "Readvertisements counted once",
+			peer: maxPrefixPeer(1, 0, false),
+			updates: []*packet.BGPUpdate{
+				{NLRI: nlris(10)},
+				{NLRI: nlris(10)},
+			},
+			expected: []bool{false, false},
+		},
+		{
+			name: "Paths of a prefix counted once",
+			peer: maxPrefixPeer(1, 0, false),
+			updates: []*packet.BGPUpdate{
+				{NLRI: nlris(10, 10, 10)},
+			},
+			expected: []bool{false},
+		},
+		{
+			name: "Withdrawn prefixes not counted",
+			peer: maxPrefixPeer(1, 0, false),
+			updates: []*packet.BGPUpdate{
+				{NLRI: nlris(10)},
+				{WithdrawnRoutes: nlris(10), NLRI: nlris(11)},
+			},
+			expected: []bool{false, false},
+		},
+		{
+			name: "Prefix kept until all paths are withdrawn",
+			peer: maxPrefixPeer(1, 0, false),
+			updates: []*packet.BGPUpdate{
+				{NLRI: nlris(10, 10)},
+				{WithdrawnRoutes: nlris(10), NLRI: nlris(11)},
+			},
+			expected: []bool{false, true},
+			exceeded: true,
+		},
+		{
+			name: "Warning threshold reached",
+			peer: maxPrefixPeer(4, 50, false),
+			updates: []*packet.BGPUpdate{
+				{NLRI: nlris(10, 11)},
+			},
+			expected: []bool{false},
+			warned:   true,
+		},
+		{
+			name: "Below warning threshold",
+			peer: maxPrefixPeer(4, 75, false),
+			updates: []*packet.BGPUpdate{
+				{NLRI: nlris(10, 11)},
+			},
+			expected: []bool{false},
+		},
+		{
+			name: "Warning threshold left",
+			peer: maxPrefixPeer(4, 50, false),
+			updates: []*packet.BGPUpdate{
+				{NLRI: nlris(10, 11)},
+				{WithdrawnRoutes: nlris(10)},
+			},
+			expected: []bool{false, false},
+		},
+	}
+
+	for _, test := range tests {
+		fsm := newTestFSM(test.peer)
+		for i, u := range test.updates {
+			assert.Equal(t, test.expected[i], fsm.maxPrefixExceeded(u), "%s: update %d", test.name, i)
+		}
+
+		if fsm.maxPrefix == nil {
+			continue
+		}
+		assert.Equal(t, test.warned, fsm.maxPrefix.warned, test.name)
+		assert.Equal(t, test.exceeded, fsm.maxPrefix.exceeded, test.name)
+	}
+}
+
+func TestMaxPrefixTeardown(t *testing.T) {
+	local, remote := tcpPair(t)
+	defer local.Close()
+	defer remote.Close()
+
+	fsm := newTestFSM(maxPrefixPeer(1, 0, false))
+	fsm.con = local
+	fsm.holdTimer = time.NewTimer(time.Hour)
+	fsm.keepaliveTimer = time.NewTimer(time.Hour)
+
+	state := make(chan int)
+	go func() {
+		state <- fsm.established()
+	}()
+
+	for i, octets := range [][]byte{{10}, {11}} {
+		msg, err := packet.SerializeUpdateMsg(&packet.BGPUpdate{
+			PathAttributes: testUpdate.PathAttributes,
+			NLRI:           nlris(octets...),
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		fsm.msgRecvCh <- msgRecvMsg{msg: msg, con: local}
+		if i == 0 {
+			assert.Eventually(t, func() bool {
+				return fsm.locRIB.Count() == 1
+			}, time.Second, time.Millisecond, "Routes below the limit")
+		}
+	}
+
+	select {
+	case s := <-state:
+		assert.Equal(t, Idle, s)
+	case <-time.After(time.Second):
+		t.Fatalf("Session not torn down")
+	}
+	assert.Empty(t, fsm.adjRibIn.Routes())
+	assert.Nil(t, fsm.locRIB.Get(nlriToPfx(nlris(11))), "Routes exceeding the limit")
+
+	// The End-of-RIB marker sent after the initial routes precedes the NOTIFICATION
+	remote.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		msg, err := recvMsg(remote)
+		if err != nil {
+			t.Fatalf("NOTIFICATION not received: %v", err)
+		}
+
+		m, err := packet.Decode(bytes.NewBuffer(msg))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		if n, ok := m.Body.(*packet.BGPNotification); ok {
+			assert.Equal(t, uint8(packet.Cease), n.ErrorCode)
+			assert.Equal(t, uint8(packet.MaxPrefReached), n.ErrorSubcode)
+			break
+		}
+	}
+
+	select {
+	case e := <-fsm.eventCh:
+		assert.Equal(t, AutomaticStart, e)
+	case <-time.After(2 * time.Second):
+		t.Errorf("Session not restarted")
+	}
+}
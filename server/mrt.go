@@ -0,0 +1,285 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/taktv6/tbgp/config"
+	"github.com/taktv6/tbgp/mrt"
+	"github.com/taktv6/tbgp/packet"
+	"github.com/taktv6/tbgp/route"
+	"github.com/taktv6/tflow2/convert"
+)
+
+// mrtExporter writes routing information to MRT files (RFC6396)
+type mrtExporter struct {
+	config   config.MRT
+	routerID uint32
+	localAS  uint32
+	updates  *mrt.Writer
+	stopCh   chan struct{}
+}
+
+// mrtSession records the messages of a single BGP session as BGP4MP records
+type mrtSession struct {
+	updates *mrt.Writer
+	peerAS  uint32
+	localAS uint32
+	peer    net.IP
+	local   net.IP
+}
+
+// startMRT starts writing MRT files if configured
+func (b *BGPServer) startMRT(c *config.Global) {
+	if c.MRT == nil {
+		return
+	}
+
+	localAS := c.LocalAS
+	if b.confed.enabled() {
+		localAS = b.confed.id
+	}
+
+	b.mrt = &mrtExporter{
+		config:   *c.MRT,
+		routerID: c.RouterID,
+		localAS:  localAS,
+		stopCh:   make(chan struct{}),
+	}
+
+	if c.MRT.UpdatesFile != "" {
+		b.mrt.updates = mrt.NewWriter(c.MRT.UpdatesFile, time.Duration(c.MRT.RotationInterval)*time.Second,
+			c.MRT.MaxFileSize, c.MRT.Gzip)
+	}
+
+	if c.MRT.LocRIBDumpFile != "" || c.MRT.AdjRIBInDumpFile != "" {
+		go b.dumpRIBs(time.Duration(c.MRT.DumpInterval) * time.Second)
+	}
+}
+
+// StopMRT stops taking snapshots and closes the updates file
+func (b *BGPServer) StopMRT() {
+	if b.mrt == nil {
+		return
+	}
+
+	close(b.mrt.stopCh)
+	if b.mrt.updates != nil {
+		if err := b.mrt.updates.Close(); err != nil {
+			log.Errorf("Unable to close MRT updates file: %v", err)
+		}
+	}
+}
+
+// dumpRIBs writes TABLE_DUMP_V2 snapshots every interval
+func (b *BGPServer) dumpRIBs(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-b.mrt.stopCh:
+			return
+		case <-t.C:
+		}
+
+		if b.mrt.config.LocRIBDumpFile != "" {
+			if err := b.mrt.tableDump(b.mrt.config.LocRIBDumpFile, "loc-rib", b.locRIB.Routes(), b.peerAS); err != nil {
+				log.Errorf("Unable to write Loc-RIB snapshot: %v", err)
+			}
+		}
+
+		if b.mrt.config.AdjRIBInDumpFile != "" {
+			if err := b.mrt.tableDump(b.mrt.config.AdjRIBInDumpFile, "adj-rib-in", b.receivedRoutes(), b.peerAS); err != nil {
+				log.Errorf("Unable to write Adj-RIB-In snapshot: %v", err)
+			}
+		}
+	}
+}
+
+// receivedRoutes returns the routes received from all peers, pre-policy if retained
+func (b *BGPServer) receivedRoutes() []*route.Route {
+	routes := make(map[string]*route.Route)
+	for _, peer := range b.peerList() {
+		received, err := peer.fsm.adjRibIn.PrePolicyRoutes()
+		if err != nil {
+			received = peer.fsm.adjRibIn.Routes()
+		}
+
+		for _, r := range received {
+			existing, ok := routes[r.Prefix().String()]
+			if !ok {
+				routes[r.Prefix().String()] = route.NewRoute(r.Prefix(), r.Paths()...)
+				continue
+			}
+
+			for _, p := range r.Paths() {
+				existing.AddPath(p)
+			}
+		}
+	}
+
+	res := make([]*route.Route, 0, len(routes))
+	for _, r := range routes {
+		res = append(res, r)
+	}
+
+	return res
+}
+
+// peerAS returns the AS of the peer with address addr
+func (b *BGPServer) peerAS(addr uint32) uint32 {
+	peer, ok := b.peer(net.IP(convert.Uint32Byte(addr)).String())
+	if !ok {
+		return 0
+	}
+
+	return peer.asn
+}
+
+// tableDump writes a snapshot of routes to a new file. peerAS looks up the AS of the peers paths were received from.
+func (e *mrtExporter) tableDump(layout string, viewName string, routes []*route.Route, peerAS func(addr uint32) uint32) error {
+	now := time.Now()
+
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Prefix().Compare(routes[j].Prefix()) < 0
+	})
+
+	// Paths originated locally are attributed to the first peer, which is us
+	peers := &packet.MRTPeerIndexTable{
+		CollectorID: e.routerID,
+		ViewName:    viewName,
+		Peers: []packet.MRTPeer{
+			{
+				BGPID:   e.routerID,
+				Address: net.IPv4zero.To4(),
+				AS:      e.localAS,
+			},
+		},
+	}
+	index := map[uint32]uint16{
+		0: 0,
+	}
+
+	ribs := make([]*packet.MRTRIB, 0, len(routes))
+	for i, r := range routes {
+		rib := &packet.MRTRIB{
+			Sequence: uint32(i),
+			Pfxlen:   r.Prefix().Pfxlen(),
+		}
+		copy(rib.Prefix[:], convert.Uint32Byte(r.Prefix().Addr()))
+
+		for _, p := range r.Paths() {
+			idx, ok := index[p.Source]
+			if !ok {
+				idx = uint16(len(peers.Peers))
+				index[p.Source] = idx
+				peers.Peers = append(peers.Peers, packet.MRTPeer{
+					BGPID:   p.RouterID,
+					Address: net.IP(convert.Uint32Byte(p.Source)),
+					AS:      peerAS(p.Source),
+				})
+			}
+
+			if p.PathIdentifier != 0 {
+				rib.AddPath = true
+			}
+
+			rib.Entries = append(rib.Entries, packet.MRTRIBEntry{
+				PeerIndex:      idx,
+				Originated:     now,
+				PathIdentifier: p.PathIdentifier,
+				PathAttributes: pathAttributes(p, true),
+			})
+		}
+
+		ribs = append(ribs, rib)
+	}
+
+	w := mrt.NewWriter(layout, 0, 0, e.config.Gzip)
+	if err := e.writeRecord(w, now, peers); err != nil {
+		w.Close()
+		return err
+	}
+
+	for _, rib := range ribs {
+		if err := e.writeRecord(w, now, rib); err != nil {
+			w.Close()
+			return err
+		}
+	}
+
+	return w.Close()
+}
+
+func (e *mrtExporter) writeRecord(w *mrt.Writer, ts time.Time, body interface{}) error {
+	rec, err := packet.SerializeMRTRecord(ts, body)
+	if err != nil {
+		return fmt.Errorf("Unable to serialize MRT record: %v", err)
+	}
+
+	return w.Write(rec)
+}
+
+// session returns the recorder of the messages of the session on c. e may be nil.
+func (e *mrtExporter) session(fsm *FSM, c *net.TCPConn) *mrtSession {
+	if e == nil || e.updates == nil || c == nil {
+		return nil
+	}
+
+	return &mrtSession{
+		updates: e.updates,
+		peerAS:  uint32(fsm.remoteASN),
+		localAS: uint32(fsm.announcedASN()),
+		peer:    c.RemoteAddr().(*net.TCPAddr).IP,
+		local:   c.LocalAddr().(*net.TCPAddr).IP,
+	}
+}
+
+// log records msg as received from the neighbor or sent by us (local). s may be nil.
+func (s *mrtSession) log(msg []byte, local bool, addPath bool) {
+	if s == nil {
+		return
+	}
+
+	rec, err := packet.SerializeMRTRecord(time.Now(), &packet.MRTBGP4MPMessage{
+		PeerAS:       s.peerAS,
+		LocalAS:      s.localAS,
+		PeerAddress:  s.peer,
+		LocalAddress: s.local,
+		Local:        local,
+		AddPath:      addPath,
+		Message:      msg,
+	})
+	if err == nil {
+		err = s.updates.Write(rec)
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"peer": s.peer.String(),
+		}).Errorf("Unable to log message: %v", err)
+	}
+}
+
+// logMRT records a message received from or sent (local) to the neighbor on c
+func (fsm *FSM) logMRT(c *net.TCPConn, msg []byte, local bool) {
+	addPath := fsm.addPathRX
+	if local {
+		addPath = fsm.addPathTX
+	}
+
+	fsm.server.mrt.session(fsm, c).log(msg, local, addPath)
+}
+
+// write sends msg to the neighbor on c and records it
+func (fsm *FSM) write(c *net.TCPConn, msg []byte) error {
+	if _, err := c.Write(msg); err != nil {
+		return err
+	}
+
+	fsm.logMRT(c, msg, true)
+	return nil
+}
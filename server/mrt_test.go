@@ -0,0 +1,92 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taktv6/tbgp/mrt"
+	"github.com/taktv6/tbgp/packet"
+)
+
+func TestLogReceivedMessages(t *testing.T) {
+	update, err := packet.SerializeUpdateMsg(&packet.BGPUpdate{
+		WithdrawnRoutes: &packet.NLRI{
+			IP:     [4]byte{10, 0, 0, 0},
+			Pfxlen: 8,
+		},
+	})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name string
+		msg  []byte
+	}{
+		{
+			name: "KEEPALIVE",
+			msg:  packet.SerializeKeepaliveMsg(),
+		},
+		{
+			name: "UPDATE",
+			msg:  update,
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "updates.mrt")
+	s := &mrtSession{
+		updates: mrt.NewWriter(path, 0, 0, false),
+		peerAS:  65001,
+		localAS: 65000,
+		peer:    net.IP{10, 0, 0, 1},
+		local:   net.IP{10, 0, 0, 2},
+	}
+
+	stream := bytes.NewBuffer(nil)
+	for _, test := range tests {
+		stream.Write(test.msg)
+	}
+
+	for _, test := range tests {
+		msg, err := recvMsg(stream)
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.msg, msg, test.name)
+		s.log(msg, false, false)
+	}
+	assert.NoError(t, s.updates.Close())
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+
+	// BGP4MP_MESSAGE_AS4 records carry 20 bytes of IPv4 session information before the message
+	for _, test := range tests {
+		if !assert.True(t, len(data) >= 12, test.name) {
+			return
+		}
+		l := binary.BigEndian.Uint32(data[8:12])
+		assert.Equal(t, uint32(20+len(test.msg)), l, test.name)
+
+		rec, err := packet.ReadMRTRecord(bytes.NewReader(data))
+		if !assert.NoError(t, err, test.name) {
+			return
+		}
+		assert.Equal(t, test.msg, rec.Body.(*packet.MRTBGP4MPMessage).Message, test.name)
+		data = data[12+l:]
+	}
+	assert.Empty(t, data)
+}
+
+func TestRecvMsgInvalidLength(t *testing.T) {
+	msg := packet.SerializeKeepaliveMsg()
+	binary.BigEndian.PutUint16(msg[16:18], packet.MaxLen+1)
+
+	_, err := recvMsg(bytes.NewBuffer(msg))
+	assert.Error(t, err)
+
+	binary.BigEndian.PutUint16(msg[16:18], packet.MinLen-1)
+	_, err = recvMsg(bytes.NewBuffer(msg))
+	assert.Error(t, err)
+}
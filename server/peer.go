@@ -11,13 +11,21 @@ type Peer struct {
 	asn      uint32
 	fsm      *FSM
 	routerID uint32
+	replay   *config.Replay
 }
 
-func NewPeer(c config.Peer) (*Peer, error) {
+func NewPeer(c config.Peer, server *BGPServer) (*Peer, error) {
 	p := &Peer{
 		addr: c.PeerAddress,
 		asn:  c.PeerAS,
-		fsm:  NewFSM(c),
+		fsm:  NewFSM(c, server),
+	}
+	if c.Replay != nil {
+		replay := *c.Replay
+		if replay.Source == nil {
+			replay.Source = c.PeerAddress
+		}
+		p.replay = &replay
 	}
 	return p, nil
 }
@@ -31,6 +39,11 @@ func (p *Peer) GetASN() uint32 {
 }
 
 func (p *Peer) Start() {
+	if p.replay != nil {
+		go p.fsm.replay(p.replay)
+		return
+	}
+
 	p.fsm.start()
 	p.fsm.activate()
 }
@@ -0,0 +1,209 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/taktv6/tbgp/config"
+	"github.com/taktv6/tbgp/packet"
+)
+
+// mrtReplay feeds the routes a peer recorded in an MRT file into the import pipeline of a synthetic peer
+type mrtReplay struct {
+	fsm    *FSM
+	config *config.Replay
+
+	// peerIndex is the index of the recorded peer in the PEER_INDEX_TABLE. It is -1 if not listed.
+	peerIndex int
+
+	// openASN4 records if the OPEN messages of the recorded session announced 4 byte ASNs (RFC6793),
+	// keyed by whether they were sent by the recording router. AS_PATHs carry 4 byte ASNs if no OPEN was recorded.
+	openASN4 map[bool]bool
+	last     time.Time
+	updates  int
+}
+
+// replay feeds the routes recorded in an MRT file into the Adj-RIB-In. The peer counts as synced afterwards.
+func (fsm *FSM) replay(c *config.Replay) {
+	r := &mrtReplay{
+		fsm:       fsm,
+		config:    c,
+		peerIndex: -1,
+		openASN4:  make(map[bool]bool),
+	}
+
+	// The routes received from the recorded peer are attributed to the synthetic peer
+	fsm.neighborID = ipToUint32(c.Source)
+
+	l := log.WithFields(log.Fields{
+		"peer": fsm.remote.String(),
+		"file": c.File,
+	})
+	l.Info("Replaying MRT file")
+
+	if err := r.run(); err != nil {
+		l.Errorf("Unable to replay MRT file: %v", err)
+	} else {
+		l.Infof("Replayed %d updates", r.updates)
+	}
+
+	fsm.processEndOfRIB(packet.IPv4AFI, packet.UnicastSAFI)
+}
+
+func (r *mrtReplay) run() error {
+	f, err := os.Open(r.config.File)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	var in io.Reader = br
+	magic, err := br.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		in = gz
+	}
+
+	for {
+		rec, err := packet.ReadMRTRecord(in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch b := rec.Body.(type) {
+		case *packet.MRTPeerIndexTable:
+			r.peerIndexTable(b)
+		case *packet.MRTRIB:
+			if err := r.rib(b); err != nil {
+				return err
+			}
+		case *packet.MRTBGP4MPMessage:
+			if err := r.message(rec.Timestamp, b); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// peerIndexTable looks up the recorded peer in the peers of the following RIB records
+func (r *mrtReplay) peerIndexTable(t *packet.MRTPeerIndexTable) {
+	r.peerIndex = -1
+	for i, p := range t.Peers {
+		if p.Address.Equal(r.config.Source) {
+			r.peerIndex = i
+			r.fsm.neighborID = p.BGPID
+			return
+		}
+	}
+}
+
+// rib feeds the paths of the recorded peer towards a prefix of a TABLE_DUMP_V2 snapshot into the Adj-RIB-In
+func (r *mrtReplay) rib(rib *packet.MRTRIB) error {
+	for _, e := range rib.Entries {
+		if int(e.PeerIndex) != r.peerIndex {
+			continue
+		}
+
+		r.fsm.addPathRX = rib.AddPath
+		err := r.update(&packet.BGPUpdate{
+			PathAttributes: e.PathAttributes,
+			NLRI: &packet.NLRI{
+				PathIdentifier: e.PathIdentifier,
+				IP:             rib.Prefix,
+				Pfxlen:         rib.Pfxlen,
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// message feeds an UPDATE message the recorded peer sent into the Adj-RIB-In
+func (r *mrtReplay) message(ts time.Time, m *packet.MRTBGP4MPMessage) error {
+	if !m.PeerAddress.Equal(r.config.Source) || len(m.Message) < packet.HeaderLen {
+		return nil
+	}
+
+	switch m.Message[packet.HeaderLen-1] {
+	case packet.OpenMsg:
+		msg, err := packet.Decode(bytes.NewBuffer(m.Message))
+		if err != nil {
+			return fmt.Errorf("Unable to decode OPEN message: %v", err)
+		}
+
+		open := msg.Body.(*packet.BGPOpen)
+		r.openASN4[m.Local] = open.Capabilities().Has(packet.FourOctetASCapability)
+		if !m.Local {
+			r.fsm.neighborID = open.BGPIdentifier
+		}
+	case packet.UpdateMsg:
+		if m.Local {
+			return nil
+		}
+
+		r.wait(ts)
+
+		msg, err := packet.DecodeWithOptions(bytes.NewBuffer(m.Message), &packet.DecodeOptions{
+			AddPath: m.AddPath,
+			ASN4:    r.asn4(),
+		})
+		if err != nil {
+			return fmt.Errorf("Unable to decode UPDATE message: %v", err)
+		}
+
+		r.fsm.addPathRX = m.AddPath
+		return r.update(msg.Body.(*packet.BGPUpdate))
+	}
+
+	return nil
+}
+
+// wait delays replaying a message recorded at ts by the time passed since the previous one if recorded timing is enabled
+func (r *mrtReplay) wait(ts time.Time) {
+	if r.config.RecordedTiming && !r.last.IsZero() && ts.After(r.last) {
+		time.Sleep(ts.Sub(r.last))
+	}
+	r.last = ts
+}
+
+func (r *mrtReplay) asn4() bool {
+	for _, asn4 := range r.openASN4 {
+		if !asn4 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// update passes u on to the import pipeline. We only speak 2 byte ASNs so others are replaced by AS_TRANS.
+// Exceeding the prefix limit aborts the replay and withdraws its routes like tearing down a session would.
+// There is no session to restart so the restart interval does not apply.
+func (r *mrtReplay) update(u *packet.BGPUpdate) error {
+	replaceASN4(u)
+	if r.fsm.maxPrefixExceeded(u) {
+		r.fsm.adjRibIn.Flush()
+		return fmt.Errorf("Maximum number of prefixes reached")
+	}
+
+	r.fsm.processUpdate(u)
+	r.updates++
+	return nil
+}
@@ -0,0 +1,327 @@
+package server
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taktv6/tbgp/config"
+	"github.com/taktv6/tbgp/mrt"
+	"github.com/taktv6/tbgp/packet"
+)
+
+var (
+	recordedPeerAddr = net.IP{10, 0, 0, 1}
+	otherPeerAddr    = net.IP{10, 0, 0, 9}
+	recordingAddr    = net.IP{10, 0, 0, 2}
+	recordingTime    = time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+)
+
+type mrtTestRecord struct {
+	ts   time.Time
+	body interface{}
+}
+
+// writeMRTFile writes records to a new MRT file in dir and returns its path
+func writeMRTFile(t *testing.T, dir string, compress bool, records []mrtTestRecord) string {
+	w := mrt.NewWriter(filepath.Join(dir, "replay"), 0, 0, compress)
+	for _, rec := range records {
+		b, err := packet.SerializeMRTRecord(rec.ts, rec.body)
+		if err != nil {
+			t.Fatalf("Unable to serialize MRT record: %v", err)
+		}
+
+		if err := w.Write(b); err != nil {
+			t.Fatalf("Unable to write MRT record: %v", err)
+		}
+	}
+
+	path := w.Path()
+	if err := w.Close(); err != nil {
+		t.Fatalf("Unable to close MRT file: %v", err)
+	}
+
+	return path
+}
+
+// ribRecord is a TABLE_DUMP_V2 RIB record of the /8 prefix given by octet with a path for every AS_PATH in paths.
+// The paths are attributed to the peers of the PEER_INDEX_TABLE in order.
+func ribRecord(octet byte, paths ...packet.ASPath) mrtTestRecord {
+	rib := &packet.MRTRIB{
+		Prefix: [4]byte{octet, 0, 0, 0},
+		Pfxlen: 8,
+	}
+	for i, p := range paths {
+		rib.Entries = append(rib.Entries, packet.MRTRIBEntry{
+			PeerIndex:  uint16(i),
+			Originated: recordingTime,
+			PathAttributes: &packet.PathAttribute{
+				TypeCode:   packet.ASPathAttr,
+				Transitive: true,
+				Value:      p,
+			},
+		})
+	}
+
+	return mrtTestRecord{
+		ts:   recordingTime,
+		body: rib,
+	}
+}
+
+// bgp4mpRecord is a BGP4MP record of msg exchanged with the peer with address peer at ts
+func bgp4mpRecord(ts time.Time, peer net.IP, local bool, msg []byte) mrtTestRecord {
+	return mrtTestRecord{
+		ts: ts,
+		body: &packet.MRTBGP4MPMessage{
+			PeerAS:       65001,
+			LocalAS:      65000,
+			PeerAddress:  peer,
+			LocalAddress: recordingAddr,
+			Local:        local,
+			Message:      msg,
+		},
+	}
+}
+
+// replayFile replays the MRT file at path for the synthetic peer c
+func replayFile(c config.Peer, path string, source net.IP, recordedTiming bool) *FSM {
+	fsm := newTestFSM(c)
+	fsm.server.convergence.addPeer(fsm.remote.String())
+	fsm.replay(&config.Replay{
+		File:           path,
+		Source:         source,
+		RecordedTiming: recordedTiming,
+	})
+
+	return fsm
+}
+
+func TestReplayTableDump(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	records := []mrtTestRecord{
+		{
+			ts: recordingTime,
+			body: &packet.MRTPeerIndexTable{
+				Peers: []packet.MRTPeer{
+					{
+						BGPID:   0x01010101,
+						Address: otherPeerAddr,
+						AS:      65009,
+					},
+					{
+						BGPID:   0x02020202,
+						Address: recordedPeerAddr,
+						AS:      4200000000,
+					},
+				},
+			},
+		},
+		ribRecord(10, asPath(65009), asPath(65001, 4200000000)),
+		ribRecord(11, asPath(65009)),
+	}
+
+	tests := []struct {
+		name     string
+		compress bool
+		source   net.IP
+		expected []uint32
+	}{
+		{
+			name:     "Recorded peer",
+			source:   recordedPeerAddr,
+			expected: []uint32{65001, asTrans},
+		},
+		{
+			name:     "Compressed",
+			compress: true,
+			source:   recordedPeerAddr,
+			expected: []uint32{65001, asTrans},
+		},
+		{
+			name:   "Peer not recorded",
+			source: net.IP{10, 0, 0, 5},
+		},
+	}
+
+	for _, test := range tests {
+		fsm := replayFile(config.Peer{}, writeMRTFile(t, dir, test.compress, records), test.source, false)
+		assert.True(t, fsm.server.convergence.isSynced(fsm.remote.String()), test.name)
+
+		routes := fsm.adjRibIn.Routes()
+		assertMonitoredPath(t, routes, test.expected, test.name)
+		if test.expected == nil {
+			assert.Equal(t, 0, fsm.locRIB.Count(), test.name)
+			continue
+		}
+
+		assert.Equal(t, uint32(0x02020202), routes[0].Paths()[0].RouterID, test.name)
+		assert.Equal(t, 1, fsm.locRIB.Count(), test.name)
+	}
+}
+
+func TestReplayBGP4MP(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name         string
+		opens        bool
+		receivedASN4 bool
+		path         packet.ASPath
+		expected     []uint32
+		routerID     uint32
+	}{
+		{
+			name:         "4 byte ASNs negotiated",
+			opens:        true,
+			receivedASN4: true,
+			path:         asPath(65001, 4200000000),
+			expected:     []uint32{65001, asTrans},
+			routerID:     0x0a000000 + 65001,
+		},
+		{
+			name:     "2 byte ASNs negotiated",
+			opens:    true,
+			path:     asPath(65001, 65002),
+			expected: []uint32{65001, 65002},
+			routerID: 0x0a000000 + 65001,
+		},
+		{
+			name:     "No OPEN recorded",
+			path:     asPath(65001, 4200000000),
+			expected: []uint32{65001, asTrans},
+			routerID: ipToUint32(recordedPeerAddr),
+		},
+	}
+
+	for _, test := range tests {
+		asn4 := !test.opens || test.receivedASN4
+
+		var records []mrtTestRecord
+		if test.opens {
+			records = append(records,
+				bgp4mpRecord(recordingTime, recordedPeerAddr, true, serializedOpen(65000, true, nil)),
+				bgp4mpRecord(recordingTime, recordedPeerAddr, false, serializedOpen(65001, test.receivedASN4, nil)),
+			)
+		}
+		records = append(records,
+			bgp4mpRecord(recordingTime, recordedPeerAddr, false, serializedUpdate(t, test.path, &packet.EncodeOptions{ASN4: asn4})),
+			// Neither UPDATEs sent by the recording router nor those of other peers are replayed
+			bgp4mpRecord(recordingTime, recordedPeerAddr, true, serializedUpdate(t, asPath(65000), &packet.EncodeOptions{ASN4: asn4})),
+			bgp4mpRecord(recordingTime, otherPeerAddr, false, serializedUpdate(t, asPath(65009), &packet.EncodeOptions{ASN4: true})),
+		)
+
+		fsm := replayFile(config.Peer{}, writeMRTFile(t, dir, false, records), recordedPeerAddr, false)
+		assert.True(t, fsm.server.convergence.isSynced(fsm.remote.String()), test.name)
+
+		routes := fsm.adjRibIn.Routes()
+		assertMonitoredPath(t, routes, test.expected, test.name)
+		if len(routes) == 1 {
+			assert.Equal(t, test.routerID, routes[0].Paths()[0].RouterID, test.name)
+		}
+		assert.Equal(t, 1, fsm.locRIB.Count(), test.name)
+	}
+}
+
+func TestReplayRecordedTiming(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	update := serializedUpdate(t, asPath(65001), &packet.EncodeOptions{ASN4: true})
+	path := writeMRTFile(t, dir, false, []mrtTestRecord{
+		bgp4mpRecord(recordingTime, recordedPeerAddr, false, update),
+		bgp4mpRecord(recordingTime.Add(time.Second), recordedPeerAddr, false, update),
+	})
+
+	tests := []struct {
+		name           string
+		recordedTiming bool
+		expected       bool
+	}{
+		{
+			name:     "Full speed",
+			expected: false,
+		},
+		{
+			name:           "Recorded timing",
+			recordedTiming: true,
+			expected:       true,
+		},
+	}
+
+	for _, test := range tests {
+		start := time.Now()
+		replayFile(config.Peer{}, path, recordedPeerAddr, test.recordedTiming)
+		assert.Equal(t, test.expected, time.Since(start) >= time.Second, test.name)
+	}
+}
+
+func TestReplayMaxPrefix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	records := []mrtTestRecord{
+		{
+			ts: recordingTime,
+			body: &packet.MRTPeerIndexTable{
+				Peers: []packet.MRTPeer{
+					{
+						BGPID:   0x02020202,
+						Address: recordedPeerAddr,
+						AS:      65001,
+					},
+				},
+			},
+		},
+		ribRecord(10, asPath(65001)),
+		ribRecord(11, asPath(65001)),
+	}
+
+	tests := []struct {
+		name     string
+		peer     config.Peer
+		expected int
+	}{
+		{
+			name:     "Below limit",
+			peer:     maxPrefixPeer(2, 0, false),
+			expected: 2,
+		},
+		{
+			name:     "Limit exceeded",
+			peer:     maxPrefixPeer(1, 0, false),
+			expected: 0,
+		},
+		{
+			name:     "Warning only",
+			peer:     maxPrefixPeer(1, 0, true),
+			expected: 2,
+		},
+	}
+
+	for _, test := range tests {
+		fsm := replayFile(test.peer, writeMRTFile(t, dir, false, records), recordedPeerAddr, false)
+		assert.True(t, fsm.server.convergence.isSynced(fsm.remote.String()), test.name)
+		assert.Len(t, fsm.adjRibIn.Routes(), test.expected, test.name)
+		assert.Equal(t, test.expected, fsm.locRIB.Count(), test.name)
+	}
+}
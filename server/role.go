@@ -0,0 +1,135 @@
+package server
+
+import (
+	"github.com/taktv6/tbgp/config"
+	"github.com/taktv6/tbgp/packet"
+	"github.com/taktv6/tbgp/route"
+)
+
+// roleEnabled checks if a BGP role is configured for the session. Roles only apply to eBGP sessions (RFC9234 4).
+func (fsm *FSM) roleEnabled() bool {
+	return fsm.role != config.RoleNone && fsm.external()
+}
+
+// roleValue returns the value representing r in the BGP Role capability
+func roleValue(r config.Role) uint8 {
+	switch r {
+	case config.RoleProvider:
+		return packet.RoleProvider
+	case config.RoleRS:
+		return packet.RoleRS
+	case config.RoleRSClient:
+		return packet.RoleRSClient
+	case config.RoleCustomer:
+		return packet.RoleCustomer
+	}
+
+	return packet.RolePeer
+}
+
+// roleCapability creates the BGP Role capability (RFC9234 4.1) announcing our role
+func (fsm *FSM) roleCapability() packet.Capability {
+	return packet.Capability{
+		Code: packet.RoleCapability,
+		Value: packet.RoleCapabilityValue{
+			Role: roleValue(fsm.role),
+		},
+	}
+}
+
+// roleMismatch checks if the roles announced by the neighbor do not correspond to ours (RFC9234 4.2).
+// Neighbors not announcing a role are only refused in strict mode.
+func (fsm *FSM) roleMismatch(caps packet.Capabilities) bool {
+	if !fsm.roleEnabled() {
+		return false
+	}
+
+	var roles []uint8
+	for _, c := range caps {
+		if c.Code != packet.RoleCapability {
+			continue
+		}
+		roles = append(roles, c.Value.(packet.RoleCapabilityValue).Role)
+	}
+
+	if len(roles) == 0 {
+		return fsm.strictRole
+	}
+
+	expected := map[config.Role]uint8{
+		config.RoleProvider: packet.RoleCustomer,
+		config.RoleRS:       packet.RoleRSClient,
+		config.RoleRSClient: packet.RoleRS,
+		config.RoleCustomer: packet.RoleProvider,
+		config.RolePeer:     packet.RolePeer,
+	}[fsm.role]
+
+	// Multiple capabilities announcing different roles are a mismatch as well
+	for _, r := range roles {
+		if r != expected {
+			return true
+		}
+	}
+
+	return false
+}
+
+// routeLeak checks if p has been leaked to us: Paths carrying the Only to Customer attribute must not be
+// received from customers and route server clients and only from the peer that marked them (RFC9234 5).
+func (fsm *FSM) routeLeak(p *route.Path) bool {
+	if !fsm.roleEnabled() || p.OnlyToCustomer == 0 {
+		return false
+	}
+
+	switch fsm.role {
+	case config.RoleProvider, config.RoleRS:
+		return true
+	case config.RolePeer:
+		return p.OnlyToCustomer != uint32(fsm.remoteASN)
+	}
+
+	return false
+}
+
+// addOnlyToCustomer marks paths received from providers, peers and route servers to be sent to customers
+// only unless marked already (RFC9234 5)
+func (fsm *FSM) addOnlyToCustomer(p *route.Path) {
+	if !fsm.roleEnabled() || p.OnlyToCustomer != 0 {
+		return
+	}
+
+	switch fsm.role {
+	case config.RoleCustomer, config.RolePeer, config.RoleRSClient:
+		p.OnlyToCustomer = uint32(fsm.remoteASN)
+	}
+}
+
+// rejectOnlyToCustomer checks if paths marked Only to Customer must not be sent to the neighbor
+// as it is a provider, peer or route server (RFC9234 5)
+func (fsm *FSM) rejectOnlyToCustomer() bool {
+	if !fsm.roleEnabled() {
+		return false
+	}
+
+	switch fsm.role {
+	case config.RoleCustomer, config.RolePeer, config.RoleRSClient:
+		return true
+	}
+
+	return false
+}
+
+// markOnlyToCustomer checks if paths sent to the neighbor are to be marked Only to Customer
+// as it is a customer, peer or route server client (RFC9234 5)
+func (fsm *FSM) markOnlyToCustomer() bool {
+	if !fsm.roleEnabled() {
+		return false
+	}
+
+	switch fsm.role {
+	case config.RoleProvider, config.RolePeer, config.RoleRS:
+		return true
+	}
+
+	return false
+}
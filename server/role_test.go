@@ -0,0 +1,150 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taktv6/tbgp/config"
+	"github.com/taktv6/tbgp/packet"
+	"github.com/taktv6/tbgp/route"
+)
+
+func roleCapabilities(roles ...uint8) packet.Capabilities {
+	caps := packet.Capabilities{}
+	for _, r := range roles {
+		caps = append(caps, packet.Capability{
+			Code:  packet.RoleCapability,
+			Value: packet.RoleCapabilityValue{Role: r},
+		})
+	}
+
+	return caps
+}
+
+func TestRoleMismatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		peer     config.Peer
+		caps     packet.Capabilities
+		expected bool
+	}{
+		{
+			name:     "No role configured",
+			peer:     config.Peer{},
+			caps:     roleCapabilities(packet.RoleProvider),
+			expected: false,
+		},
+		{
+			name:     "Provider and customer",
+			peer:     config.Peer{Role: config.RoleProvider},
+			caps:     roleCapabilities(packet.RoleCustomer),
+			expected: false,
+		},
+		{
+			name:     "Customer and provider",
+			peer:     config.Peer{Role: config.RoleCustomer},
+			caps:     roleCapabilities(packet.RoleProvider),
+			expected: false,
+		},
+		{
+			name:     "Route server and client",
+			peer:     config.Peer{Role: config.RoleRS},
+			caps:     roleCapabilities(packet.RoleRSClient),
+			expected: false,
+		},
+		{
+			name:     "Peers",
+			peer:     config.Peer{Role: config.RolePeer},
+			caps:     roleCapabilities(packet.RolePeer),
+			expected: false,
+		},
+		{
+			name:     "Both providers",
+			peer:     config.Peer{Role: config.RoleProvider},
+			caps:     roleCapabilities(packet.RoleProvider),
+			expected: true,
+		},
+		{
+			name:     "Conflicting roles announced",
+			peer:     config.Peer{Role: config.RoleCustomer},
+			caps:     roleCapabilities(packet.RoleProvider, packet.RolePeer),
+			expected: true,
+		},
+		{
+			name:     "No role announced",
+			peer:     config.Peer{Role: config.RoleCustomer},
+			caps:     roleCapabilities(),
+			expected: false,
+		},
+		{
+			name:     "No role announced in strict mode",
+			peer:     config.Peer{Role: config.RoleCustomer, StrictRole: true},
+			caps:     roleCapabilities(),
+			expected: true,
+		},
+		{
+			name:     "iBGP",
+			peer:     config.Peer{Role: config.RoleProvider, PeerAS: 65000},
+			caps:     roleCapabilities(packet.RoleProvider),
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		fsm := newTestFSM(test.peer)
+		assert.Equal(t, test.expected, fsm.roleMismatch(test.caps), test.name)
+	}
+}
+
+func TestOnlyToCustomer(t *testing.T) {
+	tests := []struct {
+		name           string
+		role           config.Role
+		onlyToCustomer uint32
+		expectedLeak   bool
+		expectedMark   uint32
+	}{
+		{
+			name:         "Unmarked path from provider",
+			role:         config.RoleCustomer,
+			expectedLeak: false,
+			expectedMark: 65001,
+		},
+		{
+			name:         "Unmarked path from customer",
+			role:         config.RoleProvider,
+			expectedLeak: false,
+			expectedMark: 0,
+		},
+		{
+			name:           "Marked path from customer",
+			role:           config.RoleProvider,
+			onlyToCustomer: 65100,
+			expectedLeak:   true,
+			expectedMark:   65100,
+		},
+		{
+			name:           "Path marked by peer",
+			role:           config.RolePeer,
+			onlyToCustomer: 65001,
+			expectedLeak:   false,
+			expectedMark:   65001,
+		},
+		{
+			name:           "Path marked by other AS from peer",
+			role:           config.RolePeer,
+			onlyToCustomer: 65100,
+			expectedLeak:   true,
+			expectedMark:   65100,
+		},
+	}
+
+	for _, test := range tests {
+		fsm := newTestFSM(config.Peer{Role: test.role})
+		p := &route.Path{OnlyToCustomer: test.onlyToCustomer}
+
+		assert.Equal(t, test.expectedLeak, fsm.routeLeak(p), test.name)
+		fsm.addOnlyToCustomer(p)
+		assert.Equal(t, test.expectedMark, p.OnlyToCustomer, test.name)
+	}
+}
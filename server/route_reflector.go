@@ -0,0 +1,25 @@
+package server
+
+import (
+	"github.com/taktv6/tbgp/config"
+	"github.com/taktv6/tbgp/route"
+)
+
+// clusterID returns the cluster ID configured for peer c. It defaults to the router ID.
+func clusterID(c config.Peer) uint32 {
+	if c.RouteReflectorClusterID != 0 {
+		return c.RouteReflectorClusterID
+	}
+
+	return c.RouterID
+}
+
+// reflectionLoop checks if p has been reflected back to us. Such paths are to be
+// discarded: They either originate from us or have passed our cluster already (RFC4456 8).
+func (fsm *FSM) reflectionLoop(p *route.Path) bool {
+	if p.EBGP {
+		return false
+	}
+
+	return (p.OriginatorID != 0 && p.OriginatorID == fsm.routerID) || p.InClusterList(fsm.clusterID)
+}
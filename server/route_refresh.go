@@ -0,0 +1,167 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/taktv6/tbgp/packet"
+)
+
+// endOfRouteRefreshTime is the maximum time stale routes are retained after BoRR without receiving EoRR (RFC7313 4)
+var endOfRouteRefreshTime = time.Second * 360
+
+// capabilities returns the capabilities we announce in our OPEN message
+func (fsm *FSM) capabilities() packet.Capabilities {
+	caps := packet.Capabilities{
+		{
+			Code: packet.MultiProtocolCapability,
+			Value: packet.MultiProtocolCapabilityValue{
+				AFI:  packet.IPv4AFI,
+				SAFI: packet.UnicastSAFI,
+			},
+		},
+		{
+			Code: packet.RouteRefreshCapability,
+		},
+		{
+			Code: packet.EnhancedRouteRefreshCapability,
+		},
+	}
+
+	if fsm.gracefulRestart || fsm.longLivedGracefulRestart {
+		caps = append(caps, fsm.gracefulRestartCapability())
+	}
+
+	if fsm.longLivedGracefulRestart {
+		caps = append(caps, fsm.longLivedGracefulRestartCapability())
+	}
+
+	if fsm.addPathEnabled() {
+		caps = append(caps, fsm.addPathCapability())
+	}
+
+	if fsm.roleEnabled() {
+		caps = append(caps, fsm.roleCapability())
+	}
+
+	return caps
+}
+
+// processCapabilities determines the capabilities negotiated with the neighbor
+func (fsm *FSM) processCapabilities(caps packet.Capabilities) {
+	fsm.routeRefresh = caps.Has(packet.RouteRefreshCapability)
+
+	// Enhanced route refresh is only usable along with route refresh (RFC7313)
+	fsm.enhancedRouteRefresh = fsm.routeRefresh && caps.Has(packet.EnhancedRouteRefreshCapability)
+
+	fsm.processGracefulRestartCapability(caps)
+	fsm.processAddPathCapability(caps)
+}
+
+// processRouteRefresh handles a ROUTE-REFRESH message received from the neighbor
+func (fsm *FSM) processRouteRefresh(msg *packet.BGPRouteRefresh) {
+	// IPv4 unicast is the only address family we negotiate. Anything else is to be ignored (RFC2918)
+	if msg.AFI != packet.IPv4AFI || msg.SAFI != packet.UnicastSAFI {
+		return
+	}
+
+	switch msg.SubType {
+	case packet.NormalRouteRefresh:
+		fsm.resendAdjRIBOut()
+	case packet.BeginningOfRouteRefresh:
+		if fsm.enhancedRouteRefresh {
+			fsm.beginRouteRefresh()
+		}
+	case packet.EndOfRouteRefresh:
+		if fsm.enhancedRouteRefresh {
+			fsm.endRouteRefresh("Route refresh finished")
+		}
+	}
+}
+
+// beginRouteRefresh marks all routes of the neighbor stale. They are purged once EoRR has been received or
+// the EoRR timer expired.
+func (fsm *FSM) beginRouteRefresh() {
+	fsm.mu.Lock()
+	defer fsm.mu.Unlock()
+
+	fsm.adjRibIn.MarkStale()
+	fsm.startTimer(&fsm.endOfRouteRefreshTimer, endOfRouteRefreshTime, func() {
+		fsm.purgeRefreshedRoutes("EoRR timer expired")
+	})
+}
+
+// endRouteRefresh purges the routes not refreshed by the neighbor
+func (fsm *FSM) endRouteRefresh(reason string) {
+	fsm.mu.Lock()
+	defer fsm.mu.Unlock()
+
+	fsm.cancelTimer(&fsm.endOfRouteRefreshTimer)
+	fsm.purgeRefreshedRoutes(reason)
+}
+
+// stopRouteRefresh stops waiting for EoRR when the session goes down
+func (fsm *FSM) stopRouteRefresh() {
+	fsm.mu.Lock()
+	defer fsm.mu.Unlock()
+
+	fsm.cancelTimer(&fsm.endOfRouteRefreshTimer)
+}
+
+func (fsm *FSM) purgeRefreshedRoutes(reason string) {
+	n := fsm.adjRibIn.RemoveStale()
+	log.WithFields(log.Fields{
+		"peer":   fsm.remote.String(),
+		"stale":  n,
+		"reason": reason,
+	}).Info("Route refresh: Purged stale routes")
+}
+
+// resendAdjRIBOut advertises all routes of the Adj-RIB-Out again. If enhanced route refresh
+// has been negotiated the routes are enclosed by BoRR and EoRR messages (RFC7313)
+func (fsm *FSM) resendAdjRIBOut() {
+	if fsm.enhancedRouteRefresh {
+		fsm.updateSender.enqueue(routeRefreshMsg(packet.BeginningOfRouteRefresh))
+	}
+
+	fsm.adjRibOut.Refresh()
+
+	if fsm.enhancedRouteRefresh {
+		fsm.updateSender.enqueue(routeRefreshMsg(packet.EndOfRouteRefresh))
+	}
+}
+
+// requestRouteRefresh asks the neighbor to advertise all of its routes again
+func (fsm *FSM) requestRouteRefresh() {
+	if !fsm.routeRefresh {
+		log.WithFields(log.Fields{
+			"peer": fsm.remote.String(),
+		}).Warning("Unable to request route refresh: Capability not supported by peer")
+		return
+	}
+
+	err := fsm.sendRouteRefresh(packet.NormalRouteRefresh)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"peer": fsm.remote.String(),
+		}).Errorf("Unable to request route refresh: %v", err)
+	}
+}
+
+func routeRefreshMsg(subType uint8) []byte {
+	return packet.SerializeRouteRefreshMsg(&packet.BGPRouteRefresh{
+		AFI:     packet.IPv4AFI,
+		SubType: subType,
+		SAFI:    packet.UnicastSAFI,
+	})
+}
+
+func (fsm *FSM) sendRouteRefresh(subType uint8) error {
+	err := fsm.write(fsm.con, routeRefreshMsg(subType))
+	if err != nil {
+		return fmt.Errorf("Unable to send ROUTE-REFRESH message: %v", err)
+	}
+
+	return nil
+}
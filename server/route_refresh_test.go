@@ -0,0 +1,166 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taktv6/tbgp/config"
+	"github.com/taktv6/tbgp/packet"
+)
+
+var testUpdate = &packet.BGPUpdate{
+	PathAttributes: &packet.PathAttribute{
+		TypeCode: packet.ASPathAttr,
+		Value:    asPath(65001),
+	},
+	NLRI: &packet.NLRI{
+		IP:     [4]byte{10, 0, 0, 0},
+		Pfxlen: 8,
+	},
+}
+
+func routeRefresh(subType uint8) *packet.BGPRouteRefresh {
+	return &packet.BGPRouteRefresh{
+		AFI:     packet.IPv4AFI,
+		SAFI:    packet.UnicastSAFI,
+		SubType: subType,
+	}
+}
+
+func TestProcessRouteRefresh(t *testing.T) {
+	tests := []struct {
+		name          string
+		enhanced      bool
+		before        []*packet.BGPRouteRefresh
+		refresh       bool
+		after         []*packet.BGPRouteRefresh
+		expected      int
+		expectedTimer bool
+	}{
+		{
+			name:     "Stale route purged on EoRR",
+			enhanced: true,
+			before: []*packet.BGPRouteRefresh{
+				routeRefresh(packet.BeginningOfRouteRefresh),
+			},
+			after: []*packet.BGPRouteRefresh{
+				routeRefresh(packet.EndOfRouteRefresh),
+			},
+			expected: 0,
+		},
+		{
+			name:     "Refreshed route retained on EoRR",
+			enhanced: true,
+			before: []*packet.BGPRouteRefresh{
+				routeRefresh(packet.BeginningOfRouteRefresh),
+			},
+			refresh: true,
+			after: []*packet.BGPRouteRefresh{
+				routeRefresh(packet.EndOfRouteRefresh),
+			},
+			expected: 1,
+		},
+		{
+			name:     "Stale route retained until EoRR",
+			enhanced: true,
+			before: []*packet.BGPRouteRefresh{
+				routeRefresh(packet.BeginningOfRouteRefresh),
+			},
+			expected:      1,
+			expectedTimer: true,
+		},
+		{
+			name:     "Enhanced route refresh not negotiated",
+			enhanced: false,
+			before: []*packet.BGPRouteRefresh{
+				routeRefresh(packet.BeginningOfRouteRefresh),
+			},
+			after: []*packet.BGPRouteRefresh{
+				routeRefresh(packet.EndOfRouteRefresh),
+			},
+			expected: 1,
+		},
+		{
+			name:     "Other address family ignored",
+			enhanced: true,
+			before: []*packet.BGPRouteRefresh{
+				{
+					AFI:     2,
+					SAFI:    packet.UnicastSAFI,
+					SubType: packet.BeginningOfRouteRefresh,
+				},
+			},
+			after: []*packet.BGPRouteRefresh{
+				{
+					AFI:     2,
+					SAFI:    packet.UnicastSAFI,
+					SubType: packet.EndOfRouteRefresh,
+				},
+			},
+			expected: 1,
+		},
+	}
+
+	for _, test := range tests {
+		fsm := newTestFSM(config.Peer{})
+		fsm.routeRefresh = true
+		fsm.enhancedRouteRefresh = test.enhanced
+		fsm.processUpdate(testUpdate)
+
+		for _, msg := range test.before {
+			fsm.processRouteRefresh(msg)
+		}
+		if test.refresh {
+			fsm.processUpdate(testUpdate)
+		}
+		for _, msg := range test.after {
+			fsm.processRouteRefresh(msg)
+		}
+
+		assert.Len(t, fsm.adjRibIn.Routes(), test.expected, test.name)
+		assert.Equal(t, test.expectedTimer, fsm.endOfRouteRefreshTimer != nil, test.name)
+		fsm.stopRouteRefresh()
+	}
+}
+
+func TestEndOfRouteRefreshTimer(t *testing.T) {
+	defer func(d time.Duration) {
+		endOfRouteRefreshTime = d
+	}(endOfRouteRefreshTime)
+	endOfRouteRefreshTime = 10 * time.Millisecond
+
+	tests := []struct {
+		name     string
+		stop     bool
+		expected int
+	}{
+		{
+			name:     "Stale route purged without EoRR",
+			expected: 0,
+		},
+		{
+			name:     "Session down before EoRR",
+			stop:     true,
+			expected: 1,
+		},
+	}
+
+	for _, test := range tests {
+		fsm := newTestFSM(config.Peer{})
+		fsm.routeRefresh = true
+		fsm.enhancedRouteRefresh = true
+		fsm.processUpdate(testUpdate)
+
+		fsm.processRouteRefresh(routeRefresh(packet.BeginningOfRouteRefresh))
+		if test.stop {
+			fsm.stopRouteRefresh()
+		}
+		time.Sleep(5 * endOfRouteRefreshTime)
+
+		fsm.mu.Lock()
+		assert.Nil(t, fsm.endOfRouteRefreshTimer, test.name)
+		fsm.mu.Unlock()
+		assert.Len(t, fsm.adjRibIn.Routes(), test.expected, test.name)
+	}
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/taktv6/tbgp/config"
+	tnet "github.com/taktv6/tbgp/net"
+	"github.com/taktv6/tbgp/rpki"
+)
+
+// startRPKI loads the ASPA records and starts the RTR session with the RPKI cache if configured
+func (b *BGPServer) startRPKI(c *config.Global) error {
+	if c.ASPAFile != "" {
+		b.aspas = rpki.NewASPATable()
+		b.aspaFile = c.ASPAFile
+		if err := b.ReloadASPAFile(); err != nil {
+			return err
+		}
+	}
+
+	if c.RPKICache == "" {
+		return nil
+	}
+
+	b.vrps = rpki.NewVRPTable()
+	b.rtr = rpki.NewClient(c.RPKICache, b.vrps, b.revalidate)
+	if b.aspas == nil {
+		b.aspas = rpki.NewASPATable()
+		b.rtr.SetASPATable(b.aspas, b.reverify)
+	}
+	b.rtr.Start()
+
+	return nil
+}
+
+// revalidate re-evaluates the routes of all peers affected by a change of the VRPs for prefixes changed
+func (b *BGPServer) revalidate(changed []*tnet.Prefix) {
+	for _, peer := range b.peerList() {
+		peer.fsm.adjRibIn.Revalidate(changed)
+	}
+}
+
+// reverify re-evaluates the routes of all peers after the ASPA records have changed
+func (b *BGPServer) reverify() {
+	for _, peer := range b.peerList() {
+		peer.fsm.adjRibIn.RevalidateAll()
+	}
+}
+
+// aspaNeighborAS returns the AS expected to be the leftmost AS of paths received from peer c.
+// Route servers do not add their AS to the AS_PATH so paths received from them are not checked.
+func aspaNeighborAS(c config.Peer) uint32 {
+	if c.Role == config.RoleRSClient {
+		return 0
+	}
+
+	return c.PeerAS
+}
+
+// originASN returns the AS routes with an empty AS_PATH received from peer c are originated by
+func (b *BGPServer) originASN(c config.Peer) uint32 {
+	if b.confed.enabled() {
+		return b.confed.id
+	}
+
+	return c.LocalAS
+}
+
+// RPKISynced checks if the VRPs of the RPKI cache have been received completely
+func (b *BGPServer) RPKISynced() (bool, error) {
+	if b.rtr == nil {
+		return false, fmt.Errorf("Route origin validation is not enabled")
+	}
+
+	return b.rtr.Synced(), nil
+}
+
+// VRPs returns all VRPs currently used for route origin validation
+func (b *BGPServer) VRPs() ([]rpki.VRP, error) {
+	if b.vrps == nil {
+		return nil, fmt.Errorf("Route origin validation is not enabled")
+	}
+
+	return b.vrps.VRPs(), nil
+}
+
+// ReloadASPAFile reads the ASPA records from the configured file again and re-evaluates all routes
+func (b *BGPServer) ReloadASPAFile() error {
+	if b.aspaFile == "" {
+		return fmt.Errorf("No ASPA file configured")
+	}
+
+	aspas, err := rpki.LoadASPAFile(b.aspaFile)
+	if err != nil {
+		return err
+	}
+
+	if b.aspas.Replace(aspas) {
+		b.reverify()
+	}
+
+	return nil
+}
+
+// ASPAs returns all ASPA records currently used for AS_PATH verification
+func (b *BGPServer) ASPAs() ([]rpki.ASPA, error) {
+	if b.aspas == nil {
+		return nil, fmt.Errorf("ASPA verification is not enabled")
+	}
+
+	return b.aspas.ASPAs(), nil
+}
@@ -1,14 +1,22 @@
 package server
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"net"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/taktv6/tbgp/config"
+	"github.com/taktv6/tbgp/filter"
 	"github.com/taktv6/tbgp/packet"
+	"github.com/taktv6/tbgp/rib"
+	"github.com/taktv6/tbgp/route"
+	"github.com/taktv6/tbgp/rpki"
 )
 
 const (
@@ -17,15 +25,32 @@ const (
 )
 
 type BGPServer struct {
-	listeners []*TCPListener
-	acceptCh  chan *net.TCPConn
-	peers     map[string]*Peer
-	routerID  uint32
+	listeners   []*TCPListener
+	acceptCh    chan *net.TCPConn
+	peersMu     sync.RWMutex
+	peers       map[string]*Peer
+	routerID    uint32
+	locRIB      *rib.LocRIB
+	gr          *gracefulRestart
+	convergence *convergence
+	confed      *confederation
+	vrps        *rpki.VRPTable
+	rtr         *rpki.Client
+	aspas       *rpki.ASPATable
+	aspaFile    string
+	bmp         *bmpExporter
+	collector   *bmpCollector
+	mrt         *mrtExporter
+	aggregator  *aggregator
 }
 
 func NewBgpServer() *BGPServer {
 	return &BGPServer{
-		peers: make(map[string]*Peer),
+		peers:       make(map[string]*Peer),
+		locRIB:      rib.NewLocRIB(),
+		gr:          newGracefulRestart(&config.Global{}),
+		convergence: newConvergence(),
+		confed:      newConfederation(&config.Global{}),
 	}
 }
 
@@ -40,6 +65,18 @@ func (b *BGPServer) Start(c *config.Global) error {
 
 	fmt.Printf("ROUTER ID: %d\n", c.RouterID)
 	b.routerID = c.RouterID
+	b.gr = newGracefulRestart(c)
+	b.confed = newConfederation(c)
+	if err := b.startRPKI(c); err != nil {
+		return fmt.Errorf("Failed to start RPKI: %v", err)
+	}
+	b.startBMP(c)
+	b.startMRT(c)
+	b.startAggregates(c)
+	b.convergence.startDeadline(time.Duration(c.ReadinessTimeout) * time.Second)
+	if err := b.startBMPStation(c); err != nil {
+		return fmt.Errorf("Failed to start BMP station: %v", err)
+	}
 
 	if c.Listen {
 		acceptCh := make(chan *net.TCPConn, 4096)
@@ -65,7 +102,8 @@ func (b *BGPServer) incomingConnectionWorker() {
 		fmt.Printf("Connection from: %v\n", c.RemoteAddr())
 
 		peerAddr := strings.Split(c.RemoteAddr().String(), ":")[0]
-		if _, ok := b.peers[peerAddr]; !ok {
+		peer, ok := b.peer(peerAddr)
+		if !ok {
 			c.Close()
 			log.WithFields(log.Fields{
 				"source": c.RemoteAddr(),
@@ -73,35 +111,184 @@ func (b *BGPServer) incomingConnectionWorker() {
 			continue
 		}
 
+		if peer.replay != nil {
+			c.Close()
+			log.WithFields(log.Fields{
+				"source": c.RemoteAddr(),
+			}).Warning("TCP connection from synthetic peer")
+			continue
+		}
+
 		log.WithFields(log.Fields{
 			"source": c.RemoteAddr(),
 		}).Info("Incoming TCP connection")
 
 		fmt.Printf("DEBUG: Sending incoming TCP connection to fsm for peer %s\n", peerAddr)
-		b.peers[peerAddr].fsm.conCh <- c
+		peer.fsm.conCh <- c
 		fmt.Printf("DEBUG: Sending done\n")
 	}
 }
 
 func (b *BGPServer) AddPeer(c config.Peer) error {
-	if c.LocalAS > uint16max || c.PeerAS > uint16max {
+	if c.LocalAS > uint16max || c.PeerAS > uint16max || b.confed.id > uint16max {
 		return fmt.Errorf("32bit ASNs are not supported yet")
 	}
 
-	peer, err := NewPeer(c)
+	peer, err := NewPeer(c, b)
 	if err != nil {
 		return err
 	}
 
+	if c.GracefulRestart {
+		b.gr.addPeer(peer.GetAddr().String())
+	}
+	b.convergence.addPeer(peer.GetAddr().String())
+
 	peer.routerID = c.RouterID
-	peerAddr := peer.GetAddr().String()
-	b.peers[peerAddr] = peer
-	b.peers[peerAddr].Start()
+	b.peersMu.Lock()
+	b.peers[peer.GetAddr().String()] = peer
+	b.peersMu.Unlock()
+	peer.Start()
 
 	return nil
 }
 
-func recvMsg(c *net.TCPConn) (msg []byte, err error) {
+// peer returns the peer with address addr
+func (b *BGPServer) peer(addr string) (*Peer, bool) {
+	b.peersMu.RLock()
+	defer b.peersMu.RUnlock()
+
+	peer, ok := b.peers[addr]
+	return peer, ok
+}
+
+// peerList returns a snapshot of all configured peers. Peers are added while other goroutines iterate them.
+func (b *BGPServer) peerList() []*Peer {
+	b.peersMu.RLock()
+	defer b.peersMu.RUnlock()
+
+	res := make([]*Peer, 0, len(b.peers))
+	for _, peer := range b.peers {
+		res = append(res, peer)
+	}
+
+	return res
+}
+
+// keepPrePolicy checks if the routes received from peer c have to be retained as received. This is the case
+// for soft reconfiguration and if the import filter has to be re-applied or changes are detected locally.
+func (b *BGPServer) keepPrePolicy(c config.Peer) bool {
+	return c.SoftReconfigInbound || b.vrps != nil || b.aspas != nil || c.Dampening != nil || b.bmp.prePolicy()
+}
+
+// SoftResetIn re-applies the import filter of the peer with address addr. If the routes
+// received from the peer are retained the filter is re-applied locally. Otherwise
+// the peer is asked to advertise all of its routes again.
+func (b *BGPServer) SoftResetIn(addr net.IP) error {
+	peer, ok := b.peer(addr.String())
+	if !ok {
+		return fmt.Errorf("Unknown peer: %s", addr.String())
+	}
+
+	if peer.fsm.adjRibIn.RetainsPrePolicy() {
+		return peer.fsm.adjRibIn.ReapplyImportFilter()
+	}
+
+	peer.fsm.eventCh <- RouteRefreshRequest
+	return nil
+}
+
+// SetImportFilter replaces the import filter of the peer with address addr and applies it
+func (b *BGPServer) SetImportFilter(addr net.IP, f *filter.Filter) error {
+	peer, ok := b.peer(addr.String())
+	if !ok {
+		return fmt.Errorf("Unknown peer: %s", addr.String())
+	}
+
+	peer.fsm.adjRibIn.SetImportFilter(f)
+	return b.SoftResetIn(addr)
+}
+
+// RIBs gives access to the routes received from a set of peers. It is implemented by the server
+// for its own peers and by the routers monitored via BMP for theirs (see MonitoredRouter).
+type RIBs interface {
+	Peers() []net.IP
+	AdjRIBIn(addr net.IP, prePolicy bool) ([]*route.Route, error)
+	LocRIB() ([]*route.Route, error)
+}
+
+// Peers returns the addresses of all configured peers
+func (b *BGPServer) Peers() []net.IP {
+	peers := b.peerList()
+	res := make([]net.IP, 0, len(peers))
+	for _, peer := range peers {
+		res = append(res, peer.GetAddr())
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return bytes.Compare(res[i], res[j]) < 0
+	})
+
+	return res
+}
+
+// LocRIB returns the best routes of all prefixes
+func (b *BGPServer) LocRIB() ([]*route.Route, error) {
+	return b.locRIB.Routes(), nil
+}
+
+// AdjRIBIn returns the routes received from the peer with address addr. If prePolicy
+// is set the routes as received are returned, otherwise the routes accepted by the import filter.
+func (b *BGPServer) AdjRIBIn(addr net.IP, prePolicy bool) ([]*route.Route, error) {
+	peer, ok := b.peer(addr.String())
+	if !ok {
+		return nil, fmt.Errorf("Unknown peer: %s", addr.String())
+	}
+
+	if prePolicy {
+		return peer.fsm.adjRibIn.PrePolicyRoutes()
+	}
+
+	return peer.fsm.adjRibIn.Routes(), nil
+}
+
+// DampedPaths returns the flap history of the routes received from the peer with address addr
+func (b *BGPServer) DampedPaths(addr net.IP) ([]rib.DampedPath, error) {
+	peer, ok := b.peer(addr.String())
+	if !ok {
+		return nil, fmt.Errorf("Unknown peer: %s", addr.String())
+	}
+
+	return peer.fsm.adjRibIn.DampedPaths()
+}
+
+// PeerSynced checks if the peer with address addr has finished sending its routes (End-of-RIB received)
+func (b *BGPServer) PeerSynced(addr net.IP) (bool, error) {
+	if _, ok := b.peer(addr.String()); !ok {
+		return false, fmt.Errorf("Unknown peer: %s", addr.String())
+	}
+
+	return b.convergence.isSynced(addr.String()), nil
+}
+
+// Ready returns a channel that is closed once all configured peers have finished sending their routes
+// or the readiness deadline after the start of the server has expired (see ReadinessForced)
+func (b *BGPServer) Ready() <-chan struct{} {
+	return b.convergence.ready
+}
+
+// IsReady checks if all configured peers have finished sending their routes or the readiness deadline has expired
+func (b *BGPServer) IsReady() bool {
+	return b.convergence.isReady()
+}
+
+// ReadinessForced checks if the server became ready because the readiness deadline expired
+// before all configured peers had finished sending their routes
+func (b *BGPServer) ReadinessForced() bool {
+	return b.convergence.isForced()
+}
+
+// recvMsg reads a single BGP message from c. The message returned is as long as given by its header.
+func recvMsg(c io.Reader) (msg []byte, err error) {
 	buffer := make([]byte, packet.MaxLen)
 	_, err = io.ReadFull(c, buffer[0:packet.MinLen])
 	if err != nil {
@@ -109,11 +296,14 @@ func recvMsg(c *net.TCPConn) (msg []byte, err error) {
 	}
 
 	l := int(buffer[16])*256 + int(buffer[17])
-	toRead := l
-	_, err = io.ReadFull(c, buffer[packet.MinLen:toRead])
+	if l < packet.MinLen || l > packet.MaxLen {
+		return nil, fmt.Errorf("Invalid message length: %d", l)
+	}
+
+	_, err = io.ReadFull(c, buffer[packet.MinLen:l])
 	if err != nil {
 		return nil, fmt.Errorf("Read failed: %v", err)
 	}
 
-	return buffer, nil
+	return buffer[:l], nil
 }
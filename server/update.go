@@ -0,0 +1,270 @@
+package server
+
+import (
+	tnet "github.com/taktv6/tbgp/net"
+	"github.com/taktv6/tbgp/packet"
+	"github.com/taktv6/tbgp/route"
+	"github.com/taktv6/tflow2/convert"
+)
+
+const (
+	defaultLocalPref = 100
+
+	// asTrans replaces 4 byte ASNs a 2 byte speaker is unable to represent (RFC6793 4.2.2)
+	asTrans = 23456
+)
+
+// processUpdate feeds the withdraws and advertisements of an UPDATE message into the Adj-RIB-In
+func (fsm *FSM) processUpdate(u *packet.BGPUpdate) {
+	if afi, safi, ok := u.EndOfRIB(); ok {
+		fsm.processEndOfRIB(afi, safi)
+		return
+	}
+
+	for r := u.WithdrawnRoutes; r != nil; r = r.Next {
+		fsm.adjRibIn.RemovePath(nlriToPfx(r), &route.Path{
+			Source:         fsm.remoteAddr(),
+			PathIdentifier: r.PathIdentifier,
+		})
+	}
+
+	if u.NLRI == nil {
+		return
+	}
+
+	p := fsm.newPath(u.PathAttributes)
+	if fsm.asPathLoop(p) || fsm.invalidFirstAS(p) || fsm.reflectionLoop(p) || fsm.invalidConfedPath(p) ||
+		fsm.routeLeak(p) {
+		fsm.withdrawNLRIs(u.NLRI)
+		return
+	}
+
+	for r := u.NLRI; r != nil; r = r.Next {
+		if !fsm.addPathRX {
+			fsm.adjRibIn.AddPath(nlriToPfx(r), p)
+			continue
+		}
+
+		// Every NLRI carries its own path identifier (RFC7911)
+		q := p.Copy()
+		q.PathIdentifier = r.PathIdentifier
+		fsm.adjRibIn.AddPath(nlriToPfx(r), q)
+	}
+}
+
+// newPath creates a path from the path attributes of an UPDATE message
+func (fsm *FSM) newPath(attrs *packet.PathAttribute) *route.Path {
+	p := &route.Path{
+		Source:          fsm.remoteAddr(),
+		RouterID:        fsm.neighborID,
+		EBGP:            fsm.external(),
+		LocalPref:       defaultLocalPref,
+		ReflectorClient: fsm.routeReflectorClient,
+		Confederation:   fsm.confedEBGP,
+	}
+
+	// LOCAL_PREF received from eBGP neighbors is to be ignored (RFC4271 5.1.5)
+	decodePathAttributes(p, attrs, !fsm.external())
+	fsm.addOnlyToCustomer(p)
+
+	return p
+}
+
+// decodePathAttributes sets the attributes of p to those of attrs. LOCAL_PREF is only taken if localPref is set.
+func decodePathAttributes(p *route.Path, attrs *packet.PathAttribute, localPref bool) {
+	for pa := attrs; pa != nil; pa = pa.Next {
+		switch pa.TypeCode {
+		case packet.OriginAttr:
+			p.Origin = pa.Value.(uint8)
+		case packet.ASPathAttr:
+			p.ASPath = pa.Value.(packet.ASPath)
+		case packet.NextHopAttr:
+			addr := pa.Value.([4]byte)
+			p.NextHop = convert.Uint32b(addr[:])
+		case packet.MEDAttr:
+			p.MED = pa.Value.(uint32)
+		case packet.LocalPrefAttr:
+			if localPref {
+				p.LocalPref = pa.Value.(uint32)
+			}
+		case packet.AtomicAggrAttr:
+			p.AtomicAggregate = true
+		case packet.AggregatorAttr:
+			aggr := pa.Value.(packet.Aggretator)
+			p.Aggregator = &aggr
+		case packet.CommunitiesAttr:
+			p.Communities = pa.Value.([]uint32)
+		case packet.OriginatorIDAttr:
+			p.OriginatorID = pa.Value.(uint32)
+		case packet.ClusterListAttr:
+			p.ClusterList = pa.Value.([]uint32)
+		case packet.OnlyToCustomerAttr:
+			p.OnlyToCustomer = pa.Value.(uint32)
+		}
+	}
+}
+
+// pathAttributes creates the path attributes to advertise p with to a neighbor.
+// internal is set for neighbors within our AS or confederation.
+func pathAttributes(p *route.Path, internal bool) *packet.PathAttribute {
+	var nextHop [4]byte
+	copy(nextHop[:], convert.Uint32Byte(p.NextHop))
+
+	asPath := p.ASPath
+	if asPath == nil {
+		asPath = packet.ASPath{}
+	}
+
+	attrs := []*packet.PathAttribute{
+		{
+			TypeCode:   packet.OriginAttr,
+			Transitive: true,
+			Value:      p.Origin,
+		},
+		{
+			TypeCode:   packet.ASPathAttr,
+			Transitive: true,
+			Value:      asPath,
+		},
+		{
+			TypeCode:   packet.NextHopAttr,
+			Transitive: true,
+			Value:      nextHop,
+		},
+	}
+
+	// MED is only sent to other ASes if set by us (RFC4271 5.1.4)
+	if internal || p.MED != 0 {
+		attrs = append(attrs, &packet.PathAttribute{
+			TypeCode: packet.MEDAttr,
+			Optional: true,
+			Value:    p.MED,
+		})
+	}
+
+	// LOCAL_PREF must not be sent to eBGP neighbors (RFC4271 5.1.5)
+	if internal {
+		attrs = append(attrs, &packet.PathAttribute{
+			TypeCode:   packet.LocalPrefAttr,
+			Transitive: true,
+			Value:      p.LocalPref,
+		})
+	}
+
+	if p.AtomicAggregate {
+		attrs = append(attrs, &packet.PathAttribute{
+			TypeCode:   packet.AtomicAggrAttr,
+			Transitive: true,
+		})
+	}
+
+	if p.Aggregator != nil {
+		attrs = append(attrs, &packet.PathAttribute{
+			TypeCode:   packet.AggregatorAttr,
+			Optional:   true,
+			Transitive: true,
+			Value:      *p.Aggregator,
+		})
+	}
+
+	if len(p.Communities) > 0 {
+		attrs = append(attrs, &packet.PathAttribute{
+			TypeCode:   packet.CommunitiesAttr,
+			Optional:   true,
+			Transitive: true,
+			Value:      p.Communities,
+		})
+	}
+
+	// ORIGINATOR_ID and CLUSTER_LIST must not leave the AS (RFC4456 8)
+	if internal && p.OriginatorID != 0 {
+		attrs = append(attrs, &packet.PathAttribute{
+			TypeCode: packet.OriginatorIDAttr,
+			Optional: true,
+			Value:    p.OriginatorID,
+		})
+	}
+
+	if internal && len(p.ClusterList) > 0 {
+		attrs = append(attrs, &packet.PathAttribute{
+			TypeCode: packet.ClusterListAttr,
+			Optional: true,
+			Value:    p.ClusterList,
+		})
+	}
+
+	if p.OnlyToCustomer != 0 {
+		attrs = append(attrs, &packet.PathAttribute{
+			TypeCode:   packet.OnlyToCustomerAttr,
+			Optional:   true,
+			Transitive: true,
+			Value:      p.OnlyToCustomer,
+		})
+	}
+
+	for i := 0; i < len(attrs)-1; i++ {
+		attrs[i].Next = attrs[i+1]
+	}
+
+	return attrs[0]
+}
+
+// asPathLoop checks if our AS is in the AS_PATH of p more often than allowed for the neighbor
+func (fsm *FSM) asPathLoop(p *route.Path) bool {
+	n := p.ASPath.Occurrences(uint32(fsm.localASN))
+	if fsm.server.confed.enabled() {
+		n += p.ASPath.Occurrences(fsm.server.confed.id)
+	}
+
+	return n > int(fsm.allowASIn)
+}
+
+// invalidFirstAS checks if the leftmost AS of the AS_PATH of a path received from an eBGP neighbor
+// is not the neighbors AS. Such paths are treated as withdrawn (RFC4271 6.3, RFC7606 7.2).
+// Paths are not checked if disabled for the neighbor, e.g. a route server.
+func (fsm *FSM) invalidFirstAS(p *route.Path) bool {
+	return fsm.enforceFirstAS && fsm.external() && p.ASPath.FirstAS() != uint32(fsm.remoteASN)
+}
+
+// withdrawNLRIs removes the paths for all prefixes of nlri previously received from the neighbor
+func (fsm *FSM) withdrawNLRIs(nlri *packet.NLRI) {
+	for r := nlri; r != nil; r = r.Next {
+		fsm.adjRibIn.RemovePath(nlriToPfx(r), &route.Path{
+			Source:         fsm.remoteAddr(),
+			PathIdentifier: r.PathIdentifier,
+		})
+	}
+}
+
+// replaceASN4 replaces the ASNs in the AS_PATH of u we are unable to represent by AS_TRANS
+func replaceASN4(u *packet.BGPUpdate) {
+	for pa := u.PathAttributes; pa != nil; pa = pa.Next {
+		if pa.TypeCode != packet.ASPathAttr {
+			continue
+		}
+
+		for _, segment := range pa.Value.(packet.ASPath) {
+			for i, asn := range segment.ASNs {
+				if asn > uint16max {
+					segment.ASNs[i] = asTrans
+				}
+			}
+		}
+	}
+}
+
+func nlriToPfx(n *packet.NLRI) *tnet.Prefix {
+	addr := n.IP.([4]byte)
+	return tnet.NewPfx(convert.Uint32b(addr[:]), n.Pfxlen)
+}
+
+func pfxToNLRI(pfx *tnet.Prefix, pathID uint32) *packet.NLRI {
+	var addr [4]byte
+	copy(addr[:], convert.Uint32Byte(pfx.Addr()))
+
+	return &packet.NLRI{
+		PathIdentifier: pathID,
+		IP:             addr,
+		Pfxlen:         pfx.Pfxlen(),
+	}
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	tnet "github.com/taktv6/tbgp/net"
+	"github.com/taktv6/tbgp/packet"
+	"github.com/taktv6/tbgp/route"
+)
+
+// updateSender sends the routes of an Adj-RIB-Out to a neighbor. Messages are queued and written by a
+// separate goroutine, so the RIBs calling it while holding their locks are not blocked by the neighbor.
+type updateSender struct {
+	con      *net.TCPConn
+	remote   net.IP
+	addPath  bool
+	internal bool
+	mrt      *mrtSession
+
+	mu      sync.Mutex
+	queue   [][]byte
+	failed  bool          // Set if a message could not be written. Messages queued afterwards are dropped.
+	pending chan struct{} // Signals the worker that messages have been queued
+	stopCh  chan struct{}
+}
+
+func newUpdateSender(fsm *FSM) *updateSender {
+	u := &updateSender{
+		con:      fsm.con,
+		remote:   fsm.remote,
+		addPath:  fsm.addPathTX,
+		internal: !fsm.external(),
+		mrt:      fsm.server.mrt.session(fsm, fsm.con),
+		pending:  make(chan struct{}, 1),
+		stopCh:   make(chan struct{}),
+	}
+
+	go u.sendWorker()
+	return u
+}
+
+// AddPath advertises p for pfx to the neighbor
+func (u *updateSender) AddPath(pfx *tnet.Prefix, p *route.Path) error {
+	err := u.send(&packet.BGPUpdate{
+		PathAttributes: pathAttributes(p, u.internal),
+		NLRI:           pfxToNLRI(pfx, p.PathIdentifier),
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"peer":   u.remote.String(),
+			"prefix": pfx.String(),
+		}).Errorf("Unable to advertise prefix: %v", err)
+	}
+
+	return err
+}
+
+// RemovePath withdraws the path advertised for pfx from the neighbor
+func (u *updateSender) RemovePath(pfx *tnet.Prefix, p *route.Path) bool {
+	err := u.send(&packet.BGPUpdate{
+		WithdrawnRoutes: pfxToNLRI(pfx, p.PathIdentifier),
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"peer":   u.remote.String(),
+			"prefix": pfx.String(),
+		}).Errorf("Unable to withdraw prefix: %v", err)
+		return false
+	}
+
+	return true
+}
+
+func (u *updateSender) send(update *packet.BGPUpdate) error {
+	msg, err := packet.SerializeUpdateMsgWithOptions(update, &packet.EncodeOptions{
+		AddPath: u.addPath,
+	})
+	if err != nil {
+		return fmt.Errorf("Unable to serialize UPDATE message: %v", err)
+	}
+
+	u.enqueue(msg)
+	return nil
+}
+
+// enqueue queues msg to be sent after all messages queued before. Messages like End-of-RIB that have
+// to follow the updates sent so far are to be sent using enqueue as well.
+func (u *updateSender) enqueue(msg []byte) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.failed {
+		return
+	}
+	u.queue = append(u.queue, msg)
+
+	select {
+	case u.pending <- struct{}{}:
+	default:
+	}
+}
+
+// stop terminates the worker. Messages not sent yet are dropped.
+func (u *updateSender) stop() {
+	close(u.stopCh)
+}
+
+func (u *updateSender) sendWorker() {
+	for {
+		select {
+		case <-u.stopCh:
+			return
+		case <-u.pending:
+		}
+
+		u.mu.Lock()
+		msgs := u.queue
+		u.queue = nil
+		u.mu.Unlock()
+
+		for _, msg := range msgs {
+			select {
+			case <-u.stopCh:
+				return
+			default:
+			}
+
+			if err := u.write(msg); err != nil {
+				log.WithFields(log.Fields{
+					"peer": u.remote.String(),
+				}).Errorf("Unable to send message: %v", err)
+				u.fail()
+				return
+			}
+		}
+	}
+}
+
+func (u *updateSender) write(msg []byte) error {
+	if _, err := u.con.Write(msg); err != nil {
+		return err
+	}
+	u.mrt.log(msg, true, u.addPath)
+
+	return nil
+}
+
+// fail drops all queued messages and the ones queued later
+func (u *updateSender) fail() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.failed = true
+	u.queue = nil
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taktv6/tbgp/config"
+	tnet "github.com/taktv6/tbgp/net"
+	"github.com/taktv6/tbgp/packet"
+	"github.com/taktv6/tbgp/route"
+)
+
+// tcpPair returns both ends of a TCP connection on the loopback interface
+func tcpPair(t *testing.T) (*net.TCPConn, *net.TCPConn) {
+	l, err := net.ListenTCP("tcp4", &net.TCPAddr{IP: net.IP{127, 0, 0, 1}})
+	if err != nil {
+		t.Fatalf("Unable to listen: %v", err)
+	}
+	defer l.Close()
+
+	c, err := net.DialTCP("tcp4", nil, l.Addr().(*net.TCPAddr))
+	if err != nil {
+		t.Fatalf("Unable to connect: %v", err)
+	}
+
+	s, err := l.AcceptTCP()
+	if err != nil {
+		t.Fatalf("Unable to accept: %v", err)
+	}
+
+	return c, s
+}
+
+func TestUpdateSender(t *testing.T) {
+	local, remote := tcpPair(t)
+	defer local.Close()
+	defer remote.Close()
+
+	fsm := newTestFSM(config.Peer{})
+	fsm.con = local
+	u := newUpdateSender(fsm)
+	defer u.stop()
+
+	pfx := tnet.NewPfx(0x0a000000, 8)
+	p := &route.Path{
+		ASPath: asPath(65000),
+	}
+	assert.Nil(t, u.AddPath(pfx, p))
+	assert.True(t, u.RemovePath(pfx, p))
+	u.enqueue(packet.SerializeEndOfRIBMsg(packet.IPv4AFI, packet.UnicastSAFI))
+
+	tests := []struct {
+		name  string
+		check func(update *packet.BGPUpdate) bool
+	}{
+		{
+			name: "Advertisement",
+			check: func(update *packet.BGPUpdate) bool {
+				return update.NLRI != nil && update.NLRI.Pfxlen == 8
+			},
+		},
+		{
+			name: "Withdrawal",
+			check: func(update *packet.BGPUpdate) bool {
+				return update.WithdrawnRoutes != nil && update.WithdrawnRoutes.Pfxlen == 8
+			},
+		},
+		{
+			name: "End-of-RIB",
+			check: func(update *packet.BGPUpdate) bool {
+				return update.IsEndOfRIB()
+			},
+		},
+	}
+
+	remote.SetReadDeadline(time.Now().Add(time.Second))
+	for _, test := range tests {
+		msg, err := recvMsg(remote)
+		if err != nil {
+			t.Fatalf("%s: Unable to receive message: %v", test.name, err)
+		}
+
+		m, err := packet.Decode(bytes.NewBuffer(msg))
+		if err != nil {
+			t.Fatalf("%s: Unable to decode message: %v", test.name, err)
+		}
+
+		update, ok := m.Body.(*packet.BGPUpdate)
+		if !ok {
+			t.Errorf("%s: Unexpected message type %d", test.name, m.Header.Type)
+			continue
+		}
+		assert.True(t, test.check(update), test.name)
+	}
+}
+
+func TestUpdateSenderFailed(t *testing.T) {
+	local, remote := tcpPair(t)
+	remote.Close()
+	local.Close()
+
+	fsm := newTestFSM(config.Peer{})
+	fsm.con = local
+	u := newUpdateSender(fsm)
+	defer u.stop()
+
+	u.enqueue(packet.SerializeEndOfRIBMsg(packet.IPv4AFI, packet.UnicastSAFI))
+	assert.Eventually(t, func() bool {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		return u.failed
+	}, time.Second, time.Millisecond)
+
+	u.enqueue(packet.SerializeEndOfRIBMsg(packet.IPv4AFI, packet.UnicastSAFI))
+	u.mu.Lock()
+	assert.Empty(t, u.queue)
+	u.mu.Unlock()
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taktv6/tbgp/config"
+	"github.com/taktv6/tbgp/packet"
+	"github.com/taktv6/tbgp/route"
+)
+
+// newTestFSM creates the FSM of a peer of a new server without starting it
+func newTestFSM(c config.Peer) *FSM {
+	if c.PeerAddress == nil {
+		c.PeerAddress = net.IP{10, 0, 0, 1}
+	}
+	if c.LocalAddress == nil {
+		c.LocalAddress = net.IP{10, 0, 0, 2}
+	}
+	if c.LocalAS == 0 {
+		c.LocalAS = 65000
+	}
+	if c.PeerAS == 0 {
+		c.PeerAS = 65001
+	}
+
+	return NewFSM(c, NewBgpServer())
+}
+
+func asPath(asns ...uint32) packet.ASPath {
+	return packet.ASPath{
+		{
+			Type:  packet.ASSequence,
+			Count: uint8(len(asns)),
+			ASNs:  asns,
+		},
+	}
+}
+
+func TestInvalidFirstAS(t *testing.T) {
+	tests := []struct {
+		name     string
+		peer     config.Peer
+		asPath   packet.ASPath
+		expected bool
+	}{
+		{
+			name:     "Neighbor AS first",
+			peer:     config.Peer{},
+			asPath:   asPath(65001, 65100),
+			expected: false,
+		},
+		{
+			name:     "Other AS first",
+			peer:     config.Peer{},
+			asPath:   asPath(65100),
+			expected: true,
+		},
+		{
+			name:     "Empty AS path",
+			peer:     config.Peer{},
+			asPath:   packet.ASPath{},
+			expected: true,
+		},
+		{
+			name: "Route server",
+			peer: config.Peer{
+				Role: config.RoleRSClient,
+			},
+			asPath:   asPath(65100),
+			expected: false,
+		},
+		{
+			name: "Check disabled",
+			peer: config.Peer{
+				SkipFirstASCheck: true,
+			},
+			asPath:   asPath(65100),
+			expected: false,
+		},
+		{
+			name: "iBGP",
+			peer: config.Peer{
+				PeerAS: 65000,
+			},
+			asPath:   asPath(65100),
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		fsm := newTestFSM(test.peer)
+		assert.Equal(t, test.expected, fsm.invalidFirstAS(&route.Path{ASPath: test.asPath}), test.name)
+	}
+}
+
+func TestProcessUpdateFirstAS(t *testing.T) {
+	update := &packet.BGPUpdate{
+		PathAttributes: &packet.PathAttribute{
+			TypeCode: packet.ASPathAttr,
+			Value:    asPath(65100),
+		},
+		NLRI: &packet.NLRI{
+			IP:     [4]byte{10, 0, 0, 0},
+			Pfxlen: 8,
+		},
+	}
+
+	tests := []struct {
+		name     string
+		role     config.Role
+		expected int
+	}{
+		{
+			name:     "Path of a regular peer dropped",
+			role:     config.RoleNone,
+			expected: 0,
+		},
+		{
+			name:     "Path of a route server accepted",
+			role:     config.RoleRSClient,
+			expected: 1,
+		},
+	}
+
+	for _, test := range tests {
+		fsm := newTestFSM(config.Peer{
+			Role: test.role,
+		})
+		fsm.processUpdate(update)
+		assert.Len(t, fsm.adjRibIn.Routes(), test.expected, test.name)
+	}
+}
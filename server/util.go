@@ -5,6 +5,8 @@ import (
 	"os"
 	"strings"
 	"syscall"
+
+	"github.com/taktv6/tflow2/convert"
 )
 
 func extractFileAndFamilyFromTCPListener(l *net.TCPListener) (*os.File, int, error) {
@@ -31,3 +33,12 @@ func extractFileAndFamilyFromTCPListener(l *net.TCPListener) (*os.File, int, err
 
 	return fi, family, nil
 }
+
+func ipToUint32(ip net.IP) uint32 {
+	addr := ip.To4()
+	if addr == nil {
+		return 0
+	}
+
+	return convert.Uint32b(addr)
+}